@@ -1,90 +1,220 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
-	"github.com/gin-gonic/gin"
-	"gorm.io/driver/postgres"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
 	"gorm.io/gorm"
 
+	"github.com/stavagg/petGoApi/internal/admin"
+	"github.com/stavagg/petGoApi/internal/caldav"
 	"github.com/stavagg/petGoApi/internal/config"
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/graph"
+	"github.com/stavagg/petGoApi/internal/grpcserver"
 	"github.com/stavagg/petGoApi/internal/handler"
-	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/openapi"
+	"github.com/stavagg/petGoApi/internal/outbox"
+	"github.com/stavagg/petGoApi/internal/pgnotify"
 	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/router"
 	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stavagg/petGoApi/internal/worker"
 )
 
+// shutdownTimeout is how long in-flight requests get to finish after a
+// shutdown signal.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	cfg := config.Load()
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
-		cfg.DBHost, cfg.DBUser, cfg.DBPass, cfg.DBName, cfg.DBPort)
-
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
-	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		root := &cobra.Command{Use: "petgoapi", SilenceUsage: true, SilenceErrors: true}
+		root.AddCommand(admin.NewCommand(cfg))
+		root.SetArgs(os.Args[1:])
+		if err := root.Execute(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
 	}
 
-	if err := db.AutoMigrate(&model.Todo{}); err != nil {
-		log.Fatal("Failed to migrate database:", err)
+	if err := openapi.Check(); err != nil {
+		log.Fatal("Invalid OpenAPI document:", err)
 	}
 
-	todoRepo := repository.NewTodoRepository(db)
-	todoService := service.NewTodoService(todoRepo)
-	todoHandler := handler.NewTodoHandler(todoService)
+	var db *gorm.DB
+	var todoRepo repository.TodoRepositoryInterface
+	var eventRepo repository.TodoEventRepositoryInterface
+	var webhookRepo repository.WebhookRepositoryInterface
+	var outboxRepo repository.OutboxRepositoryInterface
+	var users service.UserServiceInterface
+	if cfg.StorageDriver == config.StorageMemory {
+		if cfg.APIKeysRequired {
+			log.Fatal("API_KEYS_REQUIRED needs a database: with STORAGE_DRIVER=memory no keys can be created")
+		}
+		memoryRepo := repository.NewMemoryTodoRepository()
+		todoRepo = memoryRepo
+		outboxRepo = memoryRepo.Outbox()
+		eventRepo = memoryRepo.Events()
+		webhookRepo = repository.NewMemoryWebhookRepository()
+	} else {
+		var err error
+		db, err = repository.Open(cfg)
+		if err != nil {
+			log.Fatal("Failed to connect to database:", err)
+		}
 
-	r := gin.Default()
+		if err := repository.Migrate(db); err != nil {
+			log.Fatal("Failed to migrate database:", err)
+		}
 
-	r.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+		todoRepo = repository.NewTodoRepository(db)
+		eventRepo = repository.NewTodoEventRepository(db)
+		webhookRepo = repository.NewWebhookRepository(db)
+		outboxRepo = repository.NewOutboxRepository(db)
+		if cfg.APIKeysRequired {
+			users = service.NewUserService(repository.NewUserRepository(db))
 		}
-		c.Next()
+	}
+
+	bus := eventbus.NewBus(cfg.EventReplaySize)
+	var publisher eventbus.Publisher = bus
+	if cfg.StorageDriver == config.StoragePostgres && cfg.PGNotify {
+		notifier := pgnotify.NewNotifier(db, cfg.PostgresDSN(), bus, outboxRepo)
+		go notifier.Run(context.Background())
+		publisher = notifier
+	}
+
+	webhookClient := service.NewWebhookClient(cfg.WebhookTimeout, cfg.WebhookAllowPrivate)
+	webhookService := service.NewWebhookService(webhookRepo, webhookClient, service.WebhookOptions{
+		MaxAttempts:          cfg.WebhookMaxAttempts,
+		DisableAfter:         cfg.WebhookDisableAfter,
+		RetryBase:            cfg.WebhookRetryBase,
+		RetryMax:             time.Hour,
+		AllowPrivateNetworks: cfg.WebhookAllowPrivate,
 	})
+	webhookDispatcher := worker.NewWebhookDispatcher(webhookService, cfg.WebhookPollInterval)
+	go webhookDispatcher.Run(context.Background())
 
-	r.GET("/", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"message": "🚀 PetGoApi is running!",
-			"version": "1.0.0",
-			"status":  "healthy",
-			"endpoints": []string{
-				"GET /api/v1/todos - получить все задачи",
-				"POST /api/v1/todos - создать задачу",
-				"GET /api/v1/todos/:id - получить задачу по ID",
-				"PUT /api/v1/todos/:id - обновить задачу",
-				"DELETE /api/v1/todos/:id - удалить задачу",
-				"POST /api/v1/todos/:id/toggle - переключить статус",
-				"GET /api/v1/todos/stats - статистика",
-			},
-		})
+	sinks := []outbox.Sink{outbox.NewBusSink(publisher), outbox.NewWebhookSink(webhookService, webhookDispatcher)}
+	if cfg.OutboxLogEvents {
+		sinks = append(sinks, outbox.LogSink{})
+	}
+	relay := outbox.NewRelay(outboxRepo, outbox.Options{
+		Interval:    cfg.OutboxPollInterval,
+		Lease:       time.Minute,
+		BatchSize:   100,
+		RetryBase:   time.Second,
+		RetryMax:    time.Minute,
+		MaxAttempts: cfg.OutboxMaxAttempts,
+		Retention:   cfg.OutboxRetention,
+	}, sinks...)
+	go relay.Run(context.Background())
+
+	todoService := service.NewTodoService(todoRepo, eventRepo, relay)
+	undoService := service.NewUndoService(todoRepo, relay, cfg.UndoWindow)
+	syncService := service.NewSyncService(todoService, todoRepo, cfg.SyncOverlap)
+	todoHandler := handler.NewTodoHandler(todoService, undoService)
+	eventHandler := handler.NewEventHandler(bus, cfg.SSEHeartbeat)
+	wsHandler := handler.NewWebSocketHandler(todoService, bus, cfg.WebSocketAllowedOrigins)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	outboxHandler := handler.NewOutboxHandler(relay)
+	syncHandler := handler.NewSyncHandler(syncService)
+	graphHandler := graph.NewHandler(todoService, bus, graph.Options{
+		ComplexityLimit: cfg.GraphQLComplexityLimit,
+		AllowedOrigins:  cfg.GraphQLAllowedOrigins,
+		Introspection:   cfg.GraphQLIntrospection,
 	})
 
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{"status": "ok", "database": "connected"})
+	trashPurger := worker.NewTrashPurger(todoService, cfg.TrashRetention, cfg.TrashPurgeInterval)
+	go trashPurger.Run(context.Background())
+
+	routerOpts := router.Options{Storage: cfg.StorageDriver, ValidateResponses: cfg.ValidateResponses, Users: users}
+	if db != nil {
+		sqlDB, err := db.DB()
+		if err != nil {
+			log.Fatal("Failed to get database connection:", err)
+		}
+		routerOpts.DB = sqlDB
+	}
+	r := router.New(routerOpts, router.Handlers{
+		Todos:     todoHandler,
+		Events:    eventHandler,
+		WebSocket: wsHandler,
+		Webhooks:  webhookHandler,
+		Outbox:    outboxHandler,
+		Sync:      syncHandler,
+		GraphQL:   graphHandler,
+		CalDAV:    caldav.NewHandler(todoService, syncService),
 	})
 
-	api := r.Group("/api/v1")
-	{
-		todos := api.Group("/todos")
-		{
-			todos.POST("", todoHandler.CreateTodo)
-			todos.GET("", todoHandler.GetAllTodos)
-			todos.GET("/stats", todoHandler.GetStats)
-			todos.GET("/:id", todoHandler.GetTodoByID)
-			todos.PUT("/:id", todoHandler.UpdateTodo)
-			todos.DELETE("/:id", todoHandler.DeleteTodo)
-			todos.POST("/:id/toggle", todoHandler.ToggleTodo)
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort != "" {
+		lis, err := net.Listen("tcp", cfg.GRPCPort)
+		if err != nil {
+			log.Fatal("Failed to listen for gRPC:", err)
 		}
+		grpcServer = grpcserver.New(todoService, bus, users)
+		go func() {
+			log.Printf("🔌 gRPC server starting on port %s", cfg.GRPCPort)
+			if err := grpcServer.Serve(lis); err != nil {
+				log.Fatal("gRPC server failed:", err)
+			}
+		}()
 	}
 
-	log.Printf("🚀 Server starting on port %s", cfg.Port)
-	log.Printf("🔗 API documentation: http://localhost%s/", cfg.Port)
-	log.Printf("📝 API endpoints: http://localhost%s/api/v1/todos", cfg.Port)
-	log.Fatal(r.Run(cfg.Port))
+	server := &http.Server{Addr: cfg.Port, Handler: r}
+	go func() {
+		log.Printf("🚀 Server starting on port %s (storage: %s)", cfg.Port, cfg.StorageDriver)
+		log.Printf("🔗 API documentation: http://localhost%s/docs", cfg.Port)
+		log.Printf("📝 API endpoints: http://localhost%s/api/v1/todos", cfg.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Server failed:", err)
+		}
+	}()
+
+	stop, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	<-stop.Done()
+	log.Println("Shutting down")
+
+	ctx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	var wg sync.WaitGroup
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// GracefulStop waits for streams like WatchTodos, which only
+			// end when their clients leave, so it is cut short after the
+			// timeout.
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcServer.Stop()
+			}
+		}()
+	}
+	if err := server.Shutdown(ctx); err != nil {
+		log.Println("Server shutdown:", err)
+	}
+	wg.Wait()
 }
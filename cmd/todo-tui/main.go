@@ -0,0 +1,77 @@
+// Command todo-tui is a terminal UI for the PetGoApi todos. It reads the
+// same config file as the todo command and follows the event stream to
+// show changes made elsewhere as they happen.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/stavagg/petGoApi/internal/cliconfig"
+	"github.com/stavagg/petGoApi/pkg/client"
+)
+
+func main() {
+	configPath := flag.String("config", "", "config file (default $TODO_CONFIG or <user config dir>/todo/config.yaml)")
+	server := flag.String("server", "", "API base URL (default from config, "+cliconfig.DefaultServer+")")
+	token := flag.String("token", "", "bearer token sent to the API")
+	actor := flag.String("actor", "", "author recorded in the todo history")
+	noLive := flag.Bool("no-live", false, "do not follow the event stream")
+	flag.Parse()
+
+	if err := run(*configPath, *server, *token, *actor, !*noLive); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(configPath, server, token, actor string, live bool) error {
+	if configPath == "" {
+		path, err := cliconfig.Path()
+		if err != nil {
+			return err
+		}
+		configPath = path
+	}
+	cfg, err := cliconfig.Read(configPath)
+	if err != nil {
+		return err
+	}
+	cfg.ApplyEnv()
+	if server != "" {
+		cfg.Server = server
+	}
+	if token != "" {
+		cfg.Token = token
+	}
+	if actor != "" {
+		cfg.Actor = actor
+	}
+
+	opts := []client.Option{client.WithUserAgent("todo-tui")}
+	if cfg.Token != "" {
+		opts = append(opts, client.WithToken(cfg.Token))
+	}
+	if cfg.Actor != "" {
+		opts = append(opts, client.WithActor(cfg.Actor))
+	}
+	c, err := client.New(cfg.Server, opts...)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var events chan tea.Msg
+	if live {
+		events = make(chan tea.Msg, 64)
+		go watch(ctx, c, events)
+	}
+	_, err = tea.NewProgram(newModel(ctx, c, events), tea.WithAltScreen()).Run()
+	return err
+}
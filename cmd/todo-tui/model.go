@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/stavagg/petGoApi/pkg/client"
+)
+
+type filter int
+
+const (
+	filterAll filter = iota
+	filterPending
+	filterCompleted
+)
+
+func (f filter) String() string {
+	return [...]string{"all", "pending", "completed"}[f]
+}
+
+func (f filter) match(todo client.Todo) bool {
+	switch f {
+	case filterPending:
+		return !todo.Completed
+	case filterCompleted:
+		return todo.Completed
+	}
+	return true
+}
+
+type mode int
+
+const (
+	modeList mode = iota
+	modeEdit
+	modeAdd
+)
+
+// Messages produced by commands.
+type (
+	loadedMsg struct {
+		todos []client.Todo
+		err   error
+	}
+	savedMsg struct {
+		todo *client.Todo
+		err  error
+	}
+)
+
+var (
+	titleStyle    = lipgloss.NewStyle().Bold(true)
+	selectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Bold(true)
+	doneStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("8")).Strikethrough(true)
+	dimStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+	errorStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	liveStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+)
+
+// model keeps every todo and shows those matching the filter, so change
+// events can be applied without reloading.
+type model struct {
+	ctx    context.Context
+	client *client.Client
+	// events delivers the watcher messages; nil without live refresh.
+	events <-chan tea.Msg
+
+	todos  []client.Todo
+	filter filter
+	cursor int
+	offset int
+	height int
+
+	mode  mode
+	input textinput.Model
+
+	loading bool
+	live    liveMsg
+	status  string
+	err     error
+}
+
+func newModel(ctx context.Context, c *client.Client, events <-chan tea.Msg) model {
+	input := textinput.New()
+	input.CharLimit = 255
+	input.Prompt = "> "
+	return model{ctx: ctx, client: c, events: events, input: input, height: 20, loading: true}
+}
+
+func (m model) Init() tea.Cmd {
+	if m.events == nil {
+		return m.load
+	}
+	return tea.Batch(m.load, m.waitForEvent)
+}
+
+func (m model) load() tea.Msg {
+	todos, err := m.client.ListTodos(m.ctx, client.ListOptions{})
+	return loadedMsg{todos: todos, err: err}
+}
+
+func (m model) waitForEvent() tea.Msg {
+	return <-m.events
+}
+
+func (m model) save(call func() (*client.Todo, error)) tea.Cmd {
+	return func() tea.Msg {
+		todo, err := call()
+		return savedMsg{todo: todo, err: err}
+	}
+}
+
+// visible returns the todos matching the filter.
+func (m model) visible() []client.Todo {
+	var todos []client.Todo
+	for _, todo := range m.todos {
+		if m.filter.match(todo) {
+			todos = append(todos, todo)
+		}
+	}
+	return todos
+}
+
+func (m model) selected() (client.Todo, bool) {
+	todos := m.visible()
+	if m.cursor < 0 || m.cursor >= len(todos) {
+		return client.Todo{}, false
+	}
+	return todos[m.cursor], true
+}
+
+// upsert replaces the todo with the same ID or adds it, keeping the list
+// ordered by ID.
+func (m *model) upsert(todo client.Todo) {
+	for i := range m.todos {
+		if m.todos[i].ID == todo.ID {
+			// Events can arrive after the response of the same change, or
+			// out of order with other clients; keep the newest version.
+			if todo.Version >= m.todos[i].Version {
+				m.todos[i] = todo
+			}
+			return
+		}
+	}
+	m.todos = append(m.todos, todo)
+	sort.Slice(m.todos, func(i, j int) bool { return m.todos[i].ID < m.todos[j].ID })
+}
+
+func (m *model) remove(id uint) {
+	for i := range m.todos {
+		if m.todos[i].ID == id {
+			m.todos = append(m.todos[:i], m.todos[i+1:]...)
+			return
+		}
+	}
+}
+
+// clamp keeps the cursor on a visible todo and in the scrolled window.
+func (m *model) clamp() {
+	n := len(m.visible())
+	if m.cursor >= n {
+		m.cursor = n - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	rows := m.listHeight()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+}
+
+func (m model) listHeight() int {
+	// Header, blank line, status and help.
+	return max(m.height-4, 1)
+}
+
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.height = msg.Height
+		m.input.Width = msg.Width - 4
+		m.clamp()
+		return m, nil
+
+	case loadedMsg:
+		m.loading = false
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.err = nil
+		m.todos = msg.todos
+		sort.Slice(m.todos, func(i, j int) bool { return m.todos[i].ID < m.todos[j].ID })
+		m.clamp()
+		return m, nil
+
+	case savedMsg:
+		if msg.err != nil {
+			m.err = msg.err
+			return m, nil
+		}
+		m.err = nil
+		m.upsert(*msg.todo)
+		m.clamp()
+		return m, nil
+
+	case eventMsg:
+		switch msg.Type {
+		case client.EventReset:
+			m.status = "missed changes, reloading"
+			return m, tea.Batch(m.load, m.waitForEvent)
+		case client.EventTodoDeleted:
+			m.remove(msg.TodoID)
+		default:
+			if msg.Todo != nil {
+				m.upsert(*msg.Todo)
+			}
+		}
+		m.clamp()
+		return m, m.waitForEvent
+
+	case liveMsg:
+		m.live = msg
+		return m, m.waitForEvent
+
+	case tea.KeyMsg:
+		if m.mode != modeList {
+			return m.updateInput(msg)
+		}
+		return m.updateList(msg)
+	}
+	return m, nil
+}
+
+func (m model) updateList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	m.status = ""
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "up", "k":
+		m.cursor--
+	case "down", "j":
+		m.cursor++
+	case "pgup":
+		m.cursor -= m.listHeight()
+	case "pgdown":
+		m.cursor += m.listHeight()
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(m.visible()) - 1
+	case "f":
+		m.filter = (m.filter + 1) % 3
+		m.cursor, m.offset = 0, 0
+	case "r":
+		m.loading = true
+		return m, m.load
+	case " ", "enter", "t":
+		todo, ok := m.selected()
+		if !ok {
+			return m, nil
+		}
+		return m, m.save(func() (*client.Todo, error) {
+			toggled, _, err := m.client.ToggleTodo(m.ctx, todo.ID)
+			return toggled, err
+		})
+	case "e":
+		todo, ok := m.selected()
+		if !ok {
+			return m, nil
+		}
+		m.mode = modeEdit
+		m.input.SetValue(todo.Title)
+		m.input.CursorEnd()
+		return m, m.input.Focus()
+	case "n", "a":
+		m.mode = modeAdd
+		m.input.SetValue("")
+		return m, m.input.Focus()
+	}
+	m.clamp()
+	return m, nil
+}
+
+func (m model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.mode = modeList
+		m.input.Blur()
+		return m, nil
+	case "enter":
+		title := strings.TrimSpace(m.input.Value())
+		if title == "" {
+			m.err = errors.New("title cannot be empty")
+			return m, nil
+		}
+		mode := m.mode
+		m.mode = modeList
+		m.input.Blur()
+		m.err = nil
+
+		if mode == modeAdd {
+			return m, m.save(func() (*client.Todo, error) {
+				return m.client.CreateTodo(m.ctx, client.CreateTodoRequest{Title: title})
+			})
+		}
+		todo, ok := m.selected()
+		if !ok || todo.Title == title {
+			return m, nil
+		}
+		return m, m.save(func() (*client.Todo, error) {
+			return m.client.UpdateTodo(m.ctx, todo.ID, client.UpdateTodoRequest{Title: title})
+		})
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m model) View() string {
+	var b strings.Builder
+
+	todos := m.visible()
+	b.WriteString(titleStyle.Render("Todos"))
+	fmt.Fprintf(&b, " %s", dimStyle.Render(fmt.Sprintf("%d shown, filter: %s", len(todos), m.filter)))
+	switch {
+	case m.events == nil:
+	case m.live.err != nil:
+		b.WriteString("  " + errorStyle.Render("○ offline: "+m.live.err.Error()))
+	case m.live.connected:
+		b.WriteString("  " + liveStyle.Render("● live"))
+	}
+	b.WriteString("\n\n")
+
+	rows := m.listHeight()
+	switch {
+	case m.loading && len(m.todos) == 0:
+		b.WriteString(dimStyle.Render("Loading…") + "\n")
+		rows--
+	case len(todos) == 0:
+		b.WriteString(dimStyle.Render("No todos") + "\n")
+		rows--
+	}
+	for i := m.offset; i < len(todos) && i < m.offset+rows; i++ {
+		todo := todos[i]
+		if i == m.cursor && m.mode == modeEdit {
+			b.WriteString(m.input.View() + "\n")
+			continue
+		}
+
+		check := "[ ]"
+		if todo.Completed {
+			check = "[x]"
+		}
+		line := fmt.Sprintf("%s #%d %s", check, todo.ID, todo.Title)
+		if todo.Project != "" {
+			line += dimStyle.Render(" (" + todo.Project + ")")
+		}
+		switch {
+		case i == m.cursor:
+			b.WriteString(selectedStyle.Render("› " + line))
+		case todo.Completed:
+			b.WriteString("  " + doneStyle.Render(line))
+		default:
+			b.WriteString("  " + line)
+		}
+		b.WriteString("\n")
+	}
+	if m.mode == modeAdd {
+		b.WriteString(m.input.View() + "\n")
+	}
+
+	b.WriteString("\n")
+	switch {
+	case m.err != nil:
+		b.WriteString(errorStyle.Render(m.err.Error()))
+	case m.status != "":
+		b.WriteString(dimStyle.Render(m.status))
+	case m.mode != modeList:
+		b.WriteString(dimStyle.Render("enter save • esc cancel"))
+	default:
+		b.WriteString(dimStyle.Render("↑/↓ move • space toggle • e edit • n new • f filter • r reload • q quit"))
+	}
+	return b.String()
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stavagg/petGoApi/internal/router/routertest"
+	"github.com/stavagg/petGoApi/pkg/client"
+)
+
+// newTestModel returns a loaded model for an API on memory storage holding
+// the given todos, the first of them completed.
+func newTestModel(t *testing.T, titles ...string) (model, *client.Client) {
+	t.Helper()
+	srv := routertest.NewServer(t)
+
+	c, err := client.New(srv.URL)
+	require.NoError(t, err)
+	ctx := context.Background()
+	for i, title := range titles {
+		todo, err := c.CreateTodo(ctx, client.CreateTodoRequest{Title: title})
+		require.NoError(t, err)
+		if i == 0 {
+			_, _, err = c.ToggleTodo(ctx, todo.ID)
+			require.NoError(t, err)
+		}
+	}
+
+	m := newModel(ctx, c, nil)
+	return update(t, m, m.load()), c
+}
+
+func update(t *testing.T, m model, msg tea.Msg) model {
+	t.Helper()
+	next, _ := m.Update(msg)
+	return next.(model)
+}
+
+// press sends a key and, once back in the list, feeds the result of the
+// API call back into the model.
+func press(t *testing.T, m model, key string) model {
+	t.Helper()
+	var msg tea.KeyMsg
+	switch key {
+	case "enter":
+		msg = tea.KeyMsg{Type: tea.KeyEnter}
+	case "esc":
+		msg = tea.KeyMsg{Type: tea.KeyEsc}
+	case "space":
+		msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+	default:
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+	}
+
+	next, cmd := m.Update(msg)
+	m = next.(model)
+	if cmd != nil && m.mode == modeList {
+		switch result := cmd().(type) {
+		case loadedMsg, savedMsg:
+			m = update(t, m, result)
+		}
+	}
+	return m
+}
+
+func titles(todos []client.Todo) []string {
+	var out []string
+	for _, todo := range todos {
+		out = append(out, todo.Title)
+	}
+	return out
+}
+
+func TestModel_NavigateAndToggle(t *testing.T) {
+	m, _ := newTestModel(t, "a", "b", "c")
+	require.Len(t, m.todos, 3)
+
+	m = press(t, m, "j")
+	m = press(t, m, "j")
+	m = press(t, m, "j")
+	assert.Equal(t, 2, m.cursor, "cursor stops at the last todo")
+	m = press(t, m, "k")
+
+	m = press(t, m, "space")
+	todo, ok := m.selected()
+	require.True(t, ok)
+	assert.Equal(t, "b", todo.Title)
+	assert.True(t, todo.Completed)
+	assert.Contains(t, m.View(), "› [x] #2 b")
+}
+
+func TestModel_Filter(t *testing.T) {
+	m, _ := newTestModel(t, "a", "b", "c")
+
+	m = press(t, m, "f")
+	assert.Equal(t, filterPending, m.filter)
+	assert.Equal(t, []string{"b", "c"}, titles(m.visible()))
+
+	m = press(t, m, "f")
+	assert.Equal(t, []string{"a"}, titles(m.visible()))
+	assert.Contains(t, m.View(), "1 shown, filter: completed")
+
+	// Toggling moves the todo out of the filter.
+	m = press(t, m, "t")
+	assert.Empty(t, m.visible())
+	assert.Contains(t, m.View(), "No todos")
+}
+
+func TestModel_EditAndAdd(t *testing.T) {
+	m, c := newTestModel(t, "a", "b")
+
+	m = press(t, m, "j")
+	m = press(t, m, "e")
+	assert.Equal(t, modeEdit, m.mode)
+	assert.Equal(t, "b", m.input.Value())
+	for _, r := range " updated" {
+		m = press(t, m, string(r))
+	}
+	m = press(t, m, "enter")
+	assert.Equal(t, modeList, m.mode)
+
+	todo, err := c.GetTodo(context.Background(), 2)
+	require.NoError(t, err)
+	assert.Equal(t, "b updated", todo.Title)
+	assert.Equal(t, []string{"a", "b updated"}, titles(m.todos))
+
+	m = press(t, m, "e")
+	m = press(t, m, "esc")
+	assert.Equal(t, modeList, m.mode)
+
+	m = press(t, m, "n")
+	m = press(t, m, "enter")
+	assert.EqualError(t, m.err, "title cannot be empty")
+	for _, r := range "c" {
+		m = press(t, m, string(r))
+	}
+	m = press(t, m, "enter")
+	assert.NoError(t, m.err)
+	assert.Equal(t, []string{"a", "b updated", "c"}, titles(m.todos))
+}
+
+func TestModel_AppliesEvents(t *testing.T) {
+	m, _ := newTestModel(t, "a", "b")
+	events := make(chan tea.Msg, 1)
+	m.events = events
+
+	b := m.todos[1]
+	b.Title = "b from elsewhere"
+	b.Version++
+	m = update(t, m, eventMsg{Type: client.EventTodoUpdated, TodoID: b.ID, Todo: &b})
+	assert.Equal(t, []string{"a", "b from elsewhere"}, titles(m.todos))
+
+	stale := m.todos[1]
+	stale.Title = "stale"
+	stale.Version--
+	m = update(t, m, eventMsg{Type: client.EventTodoUpdated, TodoID: b.ID, Todo: &stale})
+	assert.Equal(t, "b from elsewhere", m.todos[1].Title)
+
+	created := client.Todo{ID: 9, Title: "new"}
+	m = update(t, m, eventMsg{Type: client.EventTodoCreated, TodoID: 9, Todo: &created})
+	m = update(t, m, eventMsg{Type: client.EventTodoDeleted, TodoID: 1})
+	assert.Equal(t, []string{"b from elsewhere", "new"}, titles(m.todos))
+
+	m = update(t, m, liveMsg{connected: true})
+	assert.Contains(t, m.View(), "● live")
+
+	// A reset reloads the list from the server.
+	next, cmd := m.Update(eventMsg{Type: client.EventReset})
+	m = next.(model)
+	require.NotNil(t, cmd)
+	events <- liveMsg{connected: true}
+	for _, msg := range cmd().(tea.BatchMsg) {
+		if loaded, ok := msg().(loadedMsg); ok {
+			m = update(t, m, loaded)
+		}
+	}
+	assert.Equal(t, []string{"a", "b"}, titles(m.todos))
+}
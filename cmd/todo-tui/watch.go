@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/stavagg/petGoApi/pkg/client"
+)
+
+// reconnectDelay is how long the watcher waits after the event stream
+// failed before connecting again.
+const reconnectDelay = 5 * time.Second
+
+// eventMsg is a change from the event stream.
+type eventMsg client.ChangeEvent
+
+// liveMsg reports whether the event stream is connected. err is the reason
+// it is not.
+type liveMsg struct {
+	connected bool
+	err       error
+}
+
+// watch follows the event stream and sends its changes to out until ctx is
+// done. Servers without the stream are left alone after the first attempt;
+// the list can still be reloaded by hand.
+func watch(ctx context.Context, c *client.Client, out chan<- tea.Msg) {
+	send := func(msg tea.Msg) bool {
+		select {
+		case out <- msg:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	var lastID uint64
+	// The stream only counts as connected once the server accepted it.
+	connected := client.OnConnect(func() { send(liveMsg{connected: true}) })
+	for {
+		var streamErr error
+		for event, err := range c.Events(ctx, lastID, connected) {
+			if err != nil {
+				streamErr = err
+				break
+			}
+			lastID = event.ID
+			if !send(eventMsg(event)) {
+				return
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(streamErr, client.ErrNotFound) {
+			send(liveMsg{err: errors.New("live refresh unavailable, press r to reload")})
+			return
+		}
+		if !send(liveMsg{err: streamErr}) {
+			return
+		}
+
+		timer := time.NewTimer(reconnectDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stavagg/petGoApi/internal/cliconfig"
+	"github.com/stavagg/petGoApi/pkg/client"
+)
+
+func (a *app) addCmd() *cobra.Command {
+	var req client.CreateTodoRequest
+	cmd := &cobra.Command{
+		Use:   "add TITLE",
+		Short: "Create a todo",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+			req.Title = args[0]
+			todo, err := c.CreateTodo(cmd.Context(), req)
+			if err != nil {
+				return err
+			}
+			return a.printTodos([]client.Todo{*todo})
+		},
+	}
+	cmd.Flags().StringVarP(&req.Description, "description", "d", "", "description")
+	cmd.Flags().StringVarP(&req.Project, "project", "p", "", "project")
+	return cmd
+}
+
+func (a *app) lsCmd() *cobra.Command {
+	var completed, pending bool
+	var project string
+	cmd := &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List todos",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+			var opts client.ListOptions
+			switch {
+			case completed:
+				opts.Completed = &completed
+			case pending:
+				opts.Completed = new(bool)
+			}
+
+			todos := []client.Todo{}
+			for todo, err := range c.Todos(cmd.Context(), opts) {
+				if err != nil {
+					return err
+				}
+				if project == "" || todo.Project == project {
+					todos = append(todos, todo)
+				}
+			}
+			return a.printTodos(todos)
+		},
+	}
+	cmd.Flags().BoolVar(&completed, "completed", false, "only completed todos")
+	cmd.Flags().BoolVar(&pending, "pending", false, "only pending todos")
+	cmd.Flags().StringVarP(&project, "project", "p", "", "only todos of the project")
+	cmd.MarkFlagsMutuallyExclusive("completed", "pending")
+	return cmd
+}
+
+func (a *app) doneCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "done ID...",
+		Short:             "Mark todos as completed",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: a.completeIDs(false),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+			// Setting completed rather than toggling keeps repeated runs
+			// harmless.
+			completed := true
+			var todos []client.Todo
+			for _, id := range ids {
+				todo, err := c.UpdateTodo(cmd.Context(), id, client.UpdateTodoRequest{Completed: &completed})
+				if err != nil {
+					return fmt.Errorf("todo %d: %w", id, err)
+				}
+				todos = append(todos, *todo)
+			}
+			return a.printTodos(todos)
+		},
+	}
+}
+
+func (a *app) rmCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:               "rm ID...",
+		Short:             "Move todos to the trash",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: a.completeIDs(true),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+
+			type removed struct {
+				ID   uint              `json:"id"`
+				Undo *client.UndoToken `json:"undo,omitempty"`
+			}
+			var results []removed
+			for _, id := range ids {
+				undo, err := c.DeleteTodo(cmd.Context(), id)
+				if err != nil {
+					return fmt.Errorf("todo %d: %w", id, err)
+				}
+				results = append(results, removed{ID: id, Undo: undo})
+			}
+
+			if a.cfg.Output == cliconfig.OutputJSON {
+				return a.printJSON(results)
+			}
+			for _, r := range results {
+				fmt.Fprintf(a.out, "Moved todo %d to the trash", r.ID)
+				if r.Undo != nil {
+					fmt.Fprintf(a.out, " (undo token %s)", r.Undo.Token)
+				}
+				fmt.Fprintln(a.out)
+			}
+			return nil
+		},
+	}
+}
+
+func (a *app) statsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Show todo statistics",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+			stats, err := c.Stats(cmd.Context())
+			if err != nil {
+				return err
+			}
+			return a.printStats(stats)
+		},
+	}
+}
+
+// importFormats are the formats of the import endpoint; the format of a
+// file is guessed from its extension when not given.
+var importFormats = []string{"csv", "jsonl", "todotxt", "todoist", "trello"}
+
+var importExtensions = map[string]string{".csv": "csv", ".jsonl": "jsonl", ".ndjson": "jsonl", ".txt": "todotxt"}
+
+func (a *app) importCmd() *cobra.Command {
+	var format string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import todos from a file, - for stdin",
+		Long: "Import todos from a file, - for stdin. Formats: " + strings.Join(importFormats, ", ") + ".\n" +
+			"Todoist and Trello exports remember the IDs of their items, so importing\n" +
+			"the same export again skips the items imported before.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if format == "" {
+				format = importExtensions[strings.ToLower(filepath.Ext(args[0]))]
+				if format == "" {
+					return errors.New("cannot tell the format of " + args[0] + ", pass --format")
+				}
+			}
+			in := cmd.InOrStdin()
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			c, err := a.client()
+			if err != nil {
+				return err
+			}
+			report, err := c.ImportTodos(cmd.Context(), format, in, dryRun)
+			if err != nil {
+				return err
+			}
+
+			if a.cfg.Output == cliconfig.OutputJSON {
+				return a.printJSON(report)
+			}
+			verb := "Imported"
+			if dryRun {
+				verb = "Would import"
+			}
+			fmt.Fprintf(a.out, "%s %d of %d todos", verb, len(report.Todos), report.Total)
+			if len(report.Skipped) > 0 {
+				fmt.Fprintf(a.out, ", skipped %d imported before", len(report.Skipped))
+			}
+			fmt.Fprintln(a.out)
+			for _, e := range report.Errors {
+				fmt.Fprintln(a.out, "  "+e.String())
+			}
+			if dryRun || len(report.Todos) == 0 {
+				return nil
+			}
+			return a.printTodos(report.Todos)
+		},
+	}
+	cmd.Flags().StringVarP(&format, "format", "f", "", "file format: "+strings.Join(importFormats, ", "))
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only check the file")
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(importFormats, cobra.ShellCompDirectiveNoFileComp))
+	return cmd
+}
+
+func (a *app) configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Show or change the config file",
+	}
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "show",
+			Short: "Print the effective configuration",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				cfg := *a.cfg
+				if cfg.Token != "" {
+					cfg.Token = "***"
+				}
+				if a.cfg.Output == cliconfig.OutputJSON {
+					return a.printJSON(cfg)
+				}
+				fmt.Fprintf(a.out, "file:   %s\nserver: %s\ntoken:  %s\nactor:  %s\noutput: %s\n", a.configPath, cfg.Server, cfg.Token, cfg.Actor, cfg.Output)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:       "set KEY VALUE",
+			Short:     "Save a setting: " + strings.Join(cliconfig.Keys, ", "),
+			Args:      cobra.ExactArgs(2),
+			ValidArgs: cliconfig.Keys,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				// Only the file is rewritten, without the environment and flags.
+				cfg, err := cliconfig.Read(a.configPath)
+				if err != nil {
+					return err
+				}
+				if err := cfg.Set(args[0], args[1]); err != nil {
+					return err
+				}
+				return cliconfig.Save(a.configPath, cfg)
+			},
+		},
+	)
+	return cmd
+}
+
+// completeIDs completes todo IDs with their titles. Pending todos are
+// offered for done, all todos for rm.
+func (a *app) completeIDs(all bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		c, err := a.client()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		var opts client.ListOptions
+		if !all {
+			opts.Completed = new(bool)
+		}
+		todos, err := c.ListTodos(cmd.Context(), opts)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+
+		var ids []string
+		for _, todo := range todos {
+			id := strconv.FormatUint(uint64(todo.ID), 10)
+			if strings.HasPrefix(id, toComplete) {
+				ids = append(ids, id+"\t"+truncate(todo.Title, 40))
+			}
+		}
+		return ids, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+func parseIDs(args []string) ([]uint, error) {
+	ids := make([]uint, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseUint(arg, 10, 32)
+		if err != nil || id == 0 {
+			return nil, errors.New("invalid todo ID " + strconv.Quote(arg))
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
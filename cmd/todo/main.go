@@ -0,0 +1,124 @@
+// Command todo manages todos through the PetGoApi REST API.
+//
+//	todo add "Buy milk" -d "2 liters"
+//	todo ls --pending
+//	todo done 42
+//	todo import --format trello board.json
+//	todo completion bash > /etc/bash_completion.d/todo
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stavagg/petGoApi/internal/cliconfig"
+	"github.com/stavagg/petGoApi/pkg/client"
+)
+
+// app holds the state shared by the commands of one invocation.
+type app struct {
+	out        io.Writer
+	configPath string
+	cfg        *cliconfig.Config
+	// Flags overriding the config.
+	server string
+	token  string
+	actor  string
+	output string
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := newRootCmd(os.Stdout).ExecuteContext(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+func newRootCmd(out io.Writer) *cobra.Command {
+	a := &app{out: out}
+
+	root := &cobra.Command{
+		Use:           "todo",
+		Short:         "Manage todos through the PetGoApi REST API",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return a.loadConfig()
+		},
+	}
+	root.SetOut(out)
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&a.configPath, "config", "", "config file (default $TODO_CONFIG or <user config dir>/todo/config.yaml)")
+	flags.StringVar(&a.server, "server", "", "API base URL (default from config, "+cliconfig.DefaultServer+")")
+	flags.StringVar(&a.token, "token", "", "bearer token sent to the API")
+	flags.StringVar(&a.actor, "actor", "", "author recorded in the todo history")
+	flags.StringVarP(&a.output, "output", "o", "", "output format: table or json")
+	_ = root.RegisterFlagCompletionFunc("output", cobra.FixedCompletions([]string{cliconfig.OutputTable, cliconfig.OutputJSON}, cobra.ShellCompDirectiveNoFileComp))
+
+	root.AddCommand(
+		a.addCmd(),
+		a.lsCmd(),
+		a.doneCmd(),
+		a.rmCmd(),
+		a.statsCmd(),
+		a.importCmd(),
+		a.configCmd(),
+	)
+	return root
+}
+
+func (a *app) loadConfig() error {
+	if a.configPath == "" {
+		path, err := cliconfig.Path()
+		if err != nil {
+			return err
+		}
+		a.configPath = path
+	}
+	cfg, err := cliconfig.Read(a.configPath)
+	if err != nil {
+		return err
+	}
+	cfg.ApplyEnv()
+
+	if a.server != "" {
+		cfg.Server = a.server
+	}
+	if a.token != "" {
+		cfg.Token = a.token
+	}
+	if a.actor != "" {
+		cfg.Actor = a.actor
+	}
+	if a.output != "" {
+		cfg.Output = a.output
+	}
+	if cfg.Output == "" {
+		cfg.Output = cliconfig.OutputTable
+	}
+	if cfg.Output != cliconfig.OutputTable && cfg.Output != cliconfig.OutputJSON {
+		return fmt.Errorf("output must be %s or %s", cliconfig.OutputTable, cliconfig.OutputJSON)
+	}
+	a.cfg = cfg
+	return nil
+}
+
+func (a *app) client() (*client.Client, error) {
+	opts := []client.Option{client.WithUserAgent("todo-cli")}
+	if a.cfg.Token != "" {
+		opts = append(opts, client.WithToken(a.cfg.Token))
+	}
+	if a.cfg.Actor != "" {
+		opts = append(opts, client.WithActor(a.cfg.Actor))
+	}
+	return client.New(a.cfg.Server, opts...)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stavagg/petGoApi/internal/cliconfig"
+	"github.com/stavagg/petGoApi/internal/router/routertest"
+	"github.com/stavagg/petGoApi/pkg/client"
+)
+
+// newServer starts the API on memory storage and points the CLI at it
+// through a config file in a temporary directory.
+func newServer(t *testing.T) string {
+	t.Helper()
+	srv := routertest.NewServer(t)
+
+	t.Setenv("TODO_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	t.Setenv("TODO_SERVER", "")
+	t.Setenv("TODO_TOKEN", "")
+	t.Setenv("TODO_ACTOR", "")
+	run(t, "config", "set", "server", srv.URL)
+	return srv.URL
+}
+
+func run(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := tryRun(args...)
+	require.NoError(t, err, out)
+	return out
+}
+
+func tryRun(args ...string) (string, error) {
+	var out bytes.Buffer
+	cmd := newRootCmd(&out)
+	cmd.SetErr(&out)
+	cmd.SetArgs(args)
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestTodoCLI_Workflow(t *testing.T) {
+	newServer(t)
+
+	out := run(t, "add", "Buy milk", "-d", "2 liters", "-p", "home")
+	assert.Contains(t, out, "Buy milk")
+	run(t, "add", "Write report")
+
+	var todos []client.Todo
+	require.NoError(t, json.Unmarshal([]byte(run(t, "ls", "-o", "json")), &todos))
+	require.Len(t, todos, 2)
+	byTitle := map[string]client.Todo{todos[0].Title: todos[0], todos[1].Title: todos[1]}
+	assert.Equal(t, "2 liters", byTitle["Buy milk"].Description)
+	assert.Equal(t, "home", byTitle["Buy milk"].Project)
+
+	run(t, "done", "1")
+	run(t, "done", "1")
+
+	require.NoError(t, json.Unmarshal([]byte(run(t, "ls", "--completed", "-o", "json")), &todos))
+	require.Len(t, todos, 1)
+	assert.Equal(t, "Buy milk", todos[0].Title)
+
+	out = run(t, "ls", "--pending")
+	assert.Contains(t, out, "Write report")
+	assert.NotContains(t, out, "Buy milk")
+
+	out = run(t, "stats")
+	assert.Contains(t, out, "Completion rate  50.0%")
+
+	out = run(t, "rm", "2")
+	assert.Contains(t, out, "Moved todo 2 to the trash (undo token ")
+
+	require.NoError(t, json.Unmarshal([]byte(run(t, "ls", "--pending", "-o", "json")), &todos))
+	assert.Empty(t, todos)
+}
+
+func TestTodoCLI_Errors(t *testing.T) {
+	newServer(t)
+
+	_, err := tryRun("done", "abc")
+	assert.EqualError(t, err, `invalid todo ID "abc"`)
+
+	_, err = tryRun("rm", "42")
+	assert.ErrorContains(t, err, "todo 42: petgoapi:")
+
+	_, err = tryRun("ls", "--completed", "--pending")
+	assert.Error(t, err)
+
+	_, err = tryRun("ls", "-o", "xml")
+	assert.EqualError(t, err, "output must be table or json")
+}
+
+func TestTodoCLI_Config(t *testing.T) {
+	server := newServer(t)
+
+	run(t, "config", "set", "token", "secret")
+	run(t, "config", "set", "output", "json")
+	_, err := tryRun("config", "set", "color", "red")
+	assert.Error(t, err)
+
+	data, err := os.ReadFile(os.Getenv("TODO_CONFIG"))
+	require.NoError(t, err)
+	assert.Equal(t, "server: "+server+"\ntoken: secret\noutput: json\n", string(data))
+
+	t.Setenv("TODO_ACTOR", "alice")
+	var cfg cliconfig.Config
+	require.NoError(t, json.Unmarshal([]byte(run(t, "config", "show")), &cfg))
+	assert.Equal(t, cliconfig.Config{Server: server, Token: "***", Actor: "alice", Output: "json"}, cfg)
+}
+
+func TestTodoCLI_Completion(t *testing.T) {
+	newServer(t)
+	run(t, "add", "Buy milk")
+	run(t, "add", "Write report")
+	run(t, "done", "2")
+
+	assert.Contains(t, run(t, "completion", "bash"), "__start_todo")
+
+	out := run(t, "__complete", "done", "")
+	assert.Contains(t, out, "1\tBuy milk")
+	assert.NotContains(t, out, "Write report")
+}
+
+func TestTodoCLI_Import(t *testing.T) {
+	newServer(t)
+
+	export := filepath.Join(t.TempDir(), "todoist.json")
+	require.NoError(t, os.WriteFile(export, []byte(`{
+  "projects": [{"id": "p1", "name": "Home"}],
+  "items": [
+    {"id": "i1", "content": "Buy milk", "project_id": "p1"},
+    {"id": "i2", "content": "Call mom", "project_id": "p1", "checked": true}
+  ]
+}`), 0o600))
+
+	_, err := tryRun("import", export)
+	assert.EqualError(t, err, "cannot tell the format of "+export+", pass --format")
+
+	out := run(t, "import", "--format", "todoist", "--dry-run", export)
+	assert.Equal(t, "Would import 2 of 2 todos\n", out)
+
+	out = run(t, "import", "-f", "todoist", export)
+	assert.Contains(t, out, "Imported 2 of 2 todos\n")
+	assert.Contains(t, out, "Buy milk")
+
+	var report client.ImportReport
+	require.NoError(t, json.Unmarshal([]byte(run(t, "import", "-f", "todoist", "-o", "json", export)), &report))
+	assert.Empty(t, report.Todos)
+	assert.Equal(t, []string{"todoist:i1", "todoist:i2"}, report.Skipped)
+
+	var todos []client.Todo
+	require.NoError(t, json.Unmarshal([]byte(run(t, "ls", "-o", "json")), &todos))
+	require.Len(t, todos, 2)
+	byTitle := map[string]client.Todo{todos[0].Title: todos[0], todos[1].Title: todos[1]}
+	assert.Equal(t, "todoist:i1", byTitle["Buy milk"].ExternalID)
+	assert.Equal(t, "Home", byTitle["Buy milk"].Project)
+	assert.True(t, byTitle["Call mom"].Completed)
+
+	broken := filepath.Join(t.TempDir(), "todos.csv")
+	require.NoError(t, os.WriteFile(broken, []byte("title\nok\n\"\"\n"), 0o600))
+	_, err = tryRun("import", broken)
+	assert.ErrorContains(t, err, "Import file has invalid lines: line 3: title is required")
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/stavagg/petGoApi/internal/cliconfig"
+	"github.com/stavagg/petGoApi/pkg/client"
+)
+
+func (a *app) printJSON(v interface{}) error {
+	enc := json.NewEncoder(a.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// printTodos prints todos as a table or as a JSON array.
+func (a *app) printTodos(todos []client.Todo) error {
+	if a.cfg.Output == cliconfig.OutputJSON {
+		if todos == nil {
+			todos = []client.Todo{}
+		}
+		return a.printJSON(todos)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tDONE\tTITLE\tPROJECT\tUPDATED")
+	for _, todo := range todos {
+		done := " "
+		if todo.Completed {
+			done = "x"
+		}
+		fmt.Fprintf(w, "%d\t[%s]\t%s\t%s\t%s\n", todo.ID, done, truncate(todo.Title, 60), todo.Project, todo.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return w.Flush()
+}
+
+func (a *app) printStats(stats *client.Stats) error {
+	if a.cfg.Output == cliconfig.OutputJSON {
+		return a.printJSON(stats)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Total\t%d\n", stats.Total)
+	fmt.Fprintf(w, "Completed\t%d\n", stats.Completed)
+	fmt.Fprintf(w, "Pending\t%d\n", stats.Pending)
+	fmt.Fprintf(w, "Completion rate\t%.1f%%\n", stats.CompletionRate)
+	return w.Flush()
+}
+
+// truncate shortens s to n runes and collapses newlines, keeping table rows
+// on one line.
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
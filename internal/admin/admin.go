@@ -0,0 +1,137 @@
+// Package admin implements the "petgoapi admin" commands, which operate on
+// the database directly with the settings of config.Load.
+//
+// Every command accepts -o json. Commands that change data or write files
+// also accept --dry-run. They run in a transaction, which --dry-run rolls
+// back, so a dry run reports exactly what a real run would do; migrate
+// --dry-run lists the pending changes and export writes nothing.
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"github.com/stavagg/petGoApi/internal/config"
+	"github.com/stavagg/petGoApi/internal/repository"
+)
+
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+// errDryRun rolls back the transaction of a dry run.
+var errDryRun = errors.New("dry run")
+
+type runner struct {
+	cfg    *config.Config
+	dryRun bool
+	output string
+	out    io.Writer
+}
+
+// textResult is a command result with a human-readable form. Results are
+// printed as JSON with -o json.
+type textResult interface {
+	writeText(w io.Writer)
+}
+
+// NewCommand returns the admin command group.
+func NewCommand(cfg *config.Config) *cobra.Command {
+	r := &runner{cfg: cfg}
+
+	cmd := &cobra.Command{
+		Use:           "admin",
+		Short:         "Administer the database configured by the environment",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if r.output != outputText && r.output != outputJSON {
+				return fmt.Errorf("output must be %s or %s", outputText, outputJSON)
+			}
+			r.out = cmd.OutOrStdout()
+			return nil
+		},
+	}
+	cmd.PersistentFlags().StringVarP(&r.output, "output", "o", outputText, "output format: text or json")
+
+	cmd.AddCommand(
+		r.migrateCmd(),
+		r.createUserCmd(),
+		r.createAPIKeyCmd(),
+		r.purgeTrashCmd(),
+		r.statsCmd(),
+		r.exportCmd(),
+		r.importCmd(),
+		r.checkConfigCmd(),
+	)
+	return cmd
+}
+
+// dryRunFlag adds --dry-run to a command that changes data.
+func (r *runner) dryRunFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&r.dryRun, "dry-run", false, "report what would change without changing anything")
+}
+
+// open connects to the database. Callers close it with closeDB.
+func (r *runner) open() (*gorm.DB, error) {
+	if r.cfg.StorageDriver == config.StorageMemory {
+		return nil, errors.New("STORAGE_DRIVER=memory has no database to administer")
+	}
+	db, err := repository.Open(r.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s: %w", r.cfg.StorageDriver, err)
+	}
+	return db, nil
+}
+
+// closeDB closes the connections of db.
+func closeDB(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// write runs fn in a transaction that a dry run rolls back.
+func (r *runner) write(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if r.dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+func (r *runner) print(result textResult) error {
+	return printResult(r.out, r.output, result)
+}
+
+func printResult(w io.Writer, output string, result textResult) error {
+	if output == outputJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	result.writeText(w)
+	return nil
+}
+
+// would prefixes text output of dry runs.
+func would(dryRun bool, done, planned string) string {
+	if dryRun {
+		return planned
+	}
+	return done
+}
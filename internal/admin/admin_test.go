@@ -0,0 +1,328 @@
+package admin_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/stavagg/petGoApi/internal/admin"
+	"github.com/stavagg/petGoApi/internal/config"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+func sqliteConfig(t *testing.T) *config.Config {
+	t.Helper()
+	return &config.Config{
+		Port:           ":8080",
+		StorageDriver:  config.StorageSQLite,
+		SQLitePath:     filepath.Join(t.TempDir(), "test.db"),
+		TrashRetention: 30 * 24 * time.Hour,
+	}
+}
+
+// seed migrates the database of cfg and creates todos of a project through
+// the service, so they come with history. The first todo is completed and
+// the last one is in the trash.
+func seed(t *testing.T, cfg *config.Config, project string, titles ...string) *gorm.DB {
+	t.Helper()
+	db, err := repository.Open(cfg)
+	require.NoError(t, err)
+	require.NoError(t, repository.Migrate(db))
+
+	todos := service.NewTodoService(repository.NewTodoRepository(db), repository.NewTodoEventRepository(db), nil)
+	ctx := context.Background()
+	for i, title := range titles {
+		todo, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: title, Project: project})
+		require.NoError(t, err)
+		switch i {
+		case 0:
+			_, err = todos.ToggleTodo(ctx, todo.ID)
+		case len(titles) - 1:
+			_, err = todos.DeleteTodo(ctx, todo.ID)
+		}
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func run(cfg *config.Config, args ...string) (stdout, stderr string, err error) {
+	var out, errOut bytes.Buffer
+	cmd := admin.NewCommand(cfg)
+	cmd.SetOut(&out)
+	cmd.SetErr(&errOut)
+	cmd.SetArgs(args)
+	err = cmd.Execute()
+	return out.String(), errOut.String(), err
+}
+
+func runJSON(t *testing.T, cfg *config.Config, v interface{}, args ...string) {
+	t.Helper()
+	out, _, err := run(cfg, append(args, "-o", "json")...)
+	require.NoError(t, err, out)
+	require.NoError(t, json.Unmarshal([]byte(out), v), out)
+}
+
+func TestMigrate(t *testing.T) {
+	cfg := sqliteConfig(t)
+
+	var res struct {
+		DryRun  bool                   `json:"dry_run"`
+		Applied []repository.Migration `json:"applied"`
+	}
+	runJSON(t, cfg, &res, "migrate", "--dry-run")
+	assert.True(t, res.DryRun)
+	assert.Contains(t, res.Applied, repository.Migration{Table: "todos"})
+
+	out, _, err := run(cfg, "migrate", "--dry-run")
+	require.NoError(t, err)
+	assert.Contains(t, out, "pending: create table todo_events")
+
+	runJSON(t, cfg, &res, "migrate")
+	assert.Len(t, res.Applied, len(repository.Models()))
+
+	out, _, err = run(cfg, "migrate")
+	require.NoError(t, err)
+	assert.Equal(t, "Schema is up to date\n", out)
+}
+
+func TestPurgeTrash(t *testing.T) {
+	cfg := sqliteConfig(t)
+	db := seed(t, cfg, "work", "a", "b", "c")
+
+	var res struct {
+		Purged []uint `json:"purged"`
+	}
+	runJSON(t, cfg, &res, "purge-trash")
+	assert.Empty(t, res.Purged, "nothing is older than the retention")
+
+	runJSON(t, cfg, &res, "purge-trash", "--older-than", "0", "--dry-run")
+	assert.Equal(t, []uint{3}, res.Purged)
+	var trashed int64
+	require.NoError(t, db.Model(&model.Todo{}).Unscoped().Where("deleted_at IS NOT NULL").Count(&trashed).Error)
+	assert.Equal(t, int64(1), trashed, "a dry run keeps the todo")
+
+	runJSON(t, cfg, &res, "purge-trash", "--older-than", "0")
+	assert.Equal(t, []uint{3}, res.Purged)
+	require.NoError(t, db.Model(&model.Todo{}).Unscoped().Where("deleted_at IS NOT NULL").Count(&trashed).Error)
+	assert.Zero(t, trashed)
+}
+
+func TestStats(t *testing.T) {
+	cfg := sqliteConfig(t)
+	seed(t, cfg, "work", "a", "b", "c")
+	seed(t, cfg, "home", "d", "e")
+
+	var res struct {
+		Total     int64 `json:"total"`
+		Completed int64 `json:"completed"`
+		Projects  []struct {
+			Project string `json:"project"`
+			Total   int64  `json:"total"`
+		} `json:"projects"`
+		Trash int64 `json:"trash"`
+	}
+	runJSON(t, cfg, &res, "stats")
+	assert.Equal(t, int64(3), res.Total)
+	assert.Equal(t, int64(2), res.Completed)
+	assert.Equal(t, int64(2), res.Trash)
+	require.Len(t, res.Projects, 2)
+	assert.Equal(t, "home", res.Projects[0].Project)
+	assert.Equal(t, int64(1), res.Projects[0].Total)
+
+	_, _, err := run(cfg, "stats", "--dry-run")
+	assert.ErrorContains(t, err, "unknown flag: --dry-run", "stats only reads")
+}
+
+func TestExportImport(t *testing.T) {
+	source := sqliteConfig(t)
+	seed(t, source, "work", "a", "b", "c")
+	seed(t, source, "home", "d", "e")
+
+	file := filepath.Join(t.TempDir(), "work.jsonl")
+	var exported struct {
+		Todos  int `json:"todos"`
+		Events int `json:"events"`
+	}
+	runJSON(t, source, &exported, "export", "--project", "work", "--file", file)
+	assert.Equal(t, 3, exported.Todos)
+	assert.Equal(t, 5, exported.Events, "3 created, 1 toggled, 1 deleted")
+
+	stdout, stderr, err := run(source, "export", "--all")
+	require.NoError(t, err)
+	assert.Contains(t, stderr, "Exported 5 todos and 9 events of all projects to -")
+	assert.Contains(t, stdout, `"type":"header"`)
+
+	target := sqliteConfig(t)
+	db := seed(t, target, "existing", "x")
+
+	var imported struct {
+		Todos []struct {
+			OldID uint `json:"old_id"`
+			NewID uint `json:"new_id"`
+		} `json:"todos"`
+		Events int `json:"events"`
+	}
+	runJSON(t, target, &imported, "import", file, "--project", "moved", "--dry-run")
+	assert.Len(t, imported.Todos, 3)
+	var count int64
+	require.NoError(t, db.Model(&model.Todo{}).Unscoped().Count(&count).Error)
+	assert.Equal(t, int64(1), count, "a dry run imports nothing")
+
+	var outbox int64
+	require.NoError(t, db.Model(&model.OutboxMessage{}).Count(&outbox).Error)
+	runJSON(t, target, &imported, "import", file, "--project", "moved")
+	require.Len(t, imported.Todos, 3)
+	assert.Equal(t, uint(1), imported.Todos[0].OldID)
+	assert.Equal(t, uint(2), imported.Todos[0].NewID, "IDs are reassigned")
+	assert.Equal(t, 5, imported.Events)
+
+	var moved []model.Todo
+	require.NoError(t, db.Unscoped().Where("project = ?", "moved").Order("id").Find(&moved).Error)
+	require.Len(t, moved, 3)
+	assert.True(t, moved[0].Completed)
+	assert.True(t, moved[2].DeletedAt.Valid)
+
+	history, err := repository.NewTodoEventRepository(db).GetByTodoID(moved[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, history, 2)
+
+	var messages []model.OutboxMessage
+	require.NoError(t, db.Where("id > ?", outbox).Order("id").Find(&messages).Error)
+	var types []string
+	for _, m := range messages {
+		types = append(types, m.EventType)
+	}
+	assert.Equal(t, []string{"todo.created", "todo.created", "todo.created", "todo.deleted"}, types,
+		"subscribers learn about the imported todos, and that the trashed one is gone")
+}
+
+func TestImport_ClearsTakenExternalIDs(t *testing.T) {
+	cfg := sqliteConfig(t)
+	db := seed(t, cfg, "")
+
+	file := filepath.Join(t.TempDir(), "tracked.jsonl")
+	require.NoError(t, os.WriteFile(file, []byte(
+		`{"type":"header","header":{"version":1,"project":"work"}}`+"\n"+
+			`{"type":"todo","todo":{"id":1,"title":"Card","external_id":"trello:card:1"}}`+"\n"), 0o600))
+
+	var imported struct {
+		Todos []struct {
+			NewID             uint   `json:"new_id"`
+			ClearedExternalID string `json:"cleared_external_id"`
+		} `json:"todos"`
+	}
+	runJSON(t, cfg, &imported, "import", file)
+	require.Len(t, imported.Todos, 1)
+	assert.Empty(t, imported.Todos[0].ClearedExternalID)
+
+	runJSON(t, cfg, &imported, "import", file)
+	require.Len(t, imported.Todos, 1)
+	assert.Equal(t, "trello:card:1", imported.Todos[0].ClearedExternalID, "the second copy cannot keep the external ID")
+
+	var copies []model.Todo
+	require.NoError(t, db.Order("id").Find(&copies).Error)
+	require.Len(t, copies, 2)
+	require.NotNil(t, copies[0].ExternalID)
+	assert.Nil(t, copies[1].ExternalID)
+}
+
+func TestImport_RejectsInvalidFiles(t *testing.T) {
+	cfg := sqliteConfig(t)
+	db := seed(t, cfg, "")
+
+	file := filepath.Join(t.TempDir(), "bad.jsonl")
+	require.NoError(t, os.WriteFile(file, []byte(
+		`{"type":"header","header":{"version":1,"project":"work"}}`+"\n"+
+			`{"type":"todo","todo":{"id":1,"title":"ok"}}`+"\n"+
+			`{"type":"todo","todo":{"id":2,"title":""}}`+"\n"), 0o600))
+
+	_, _, err := run(cfg, "import", file)
+	assert.EqualError(t, err, "import: line 3: title is required")
+
+	var count int64
+	require.NoError(t, db.Model(&model.Todo{}).Count(&count).Error)
+	assert.Zero(t, count, "the import is rolled back as a whole")
+}
+
+func TestUsersAndAPIKeys(t *testing.T) {
+	cfg := sqliteConfig(t)
+	db := seed(t, cfg, "")
+	users := service.NewUserService(repository.NewUserRepository(db))
+	ctx := context.Background()
+
+	out, _, err := run(cfg, "create-user", "alice", "--dry-run")
+	require.NoError(t, err)
+	assert.Equal(t, "Would create user \"alice\"\n", out)
+	_, _, err = run(cfg, "create-api-key", "alice")
+	assert.EqualError(t, err, "create API key: user not found", "the dry run created nothing")
+
+	_, _, err = run(cfg, "create-user", "alice")
+	require.NoError(t, err)
+	_, _, err = run(cfg, "create-user", "alice")
+	assert.EqualError(t, err, "create user: user already exists")
+
+	var dryRun struct {
+		Key string `json:"key"`
+	}
+	runJSON(t, cfg, &dryRun, "create-api-key", "alice", "--dry-run")
+	assert.Empty(t, dryRun.Key)
+
+	var res struct {
+		Key    string `json:"key"`
+		APIKey struct {
+			Prefix string `json:"prefix"`
+		} `json:"api_key"`
+	}
+	runJSON(t, cfg, &res, "create-api-key", "alice")
+	assert.Equal(t, res.APIKey.Prefix, res.Key[:len(res.APIKey.Prefix)])
+
+	user, err := users.Authenticate(ctx, res.Key)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Name)
+	var keys int64
+	require.NoError(t, db.Model(&model.APIKey{}).Count(&keys).Error)
+	assert.Equal(t, int64(1), keys)
+}
+
+func TestCheckConfig(t *testing.T) {
+	cfg := sqliteConfig(t)
+
+	out, _, err := run(cfg, "check-config")
+	assert.ErrorContains(t, err, "configuration check failed")
+	assert.Contains(t, out, "FAIL migrations: 9 pending, run petgoapi admin migrate")
+
+	_, _, err = run(cfg, "migrate")
+	require.NoError(t, err)
+	t.Setenv("SYNC_OVERLAP", "soon")
+
+	var res struct {
+		OK     bool `json:"ok"`
+		Checks []struct {
+			Name    string `json:"name"`
+			OK      bool   `json:"ok"`
+			Message string `json:"message"`
+		} `json:"checks"`
+	}
+	out, _, err = run(cfg, "check-config", "-o", "json")
+	assert.Error(t, err)
+	require.NoError(t, json.Unmarshal([]byte(out), &res))
+	assert.False(t, res.OK)
+	assert.Equal(t, `SYNC_OVERLAP="soon" is not a positive duration, the default is used`, res.Checks[0].Message)
+	assert.Equal(t, "database", res.Checks[1].Name)
+	assert.True(t, res.Checks[2].OK)
+}
+
+func TestRejectsMemoryStorage(t *testing.T) {
+	_, _, err := run(&config.Config{StorageDriver: config.StorageMemory}, "stats")
+	assert.EqualError(t, err, "STORAGE_DRIVER=memory has no database to administer")
+}
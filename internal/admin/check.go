@@ -0,0 +1,110 @@
+package admin
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stavagg/petGoApi/internal/config"
+	"github.com/stavagg/petGoApi/internal/repository"
+)
+
+type check struct {
+	Name    string `json:"name"`
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+type checkResult struct {
+	OK     bool    `json:"ok"`
+	Checks []check `json:"checks"`
+}
+
+func (res checkResult) writeText(w io.Writer) {
+	for _, c := range res.Checks {
+		mark := "ok  "
+		if !c.OK {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(w, "%s %s: %s\n", mark, c.Name, c.Message)
+	}
+}
+
+// errCheckFailed sets a non-zero exit status after the report is printed.
+var errCheckFailed = errors.New("configuration check failed")
+
+func (r *runner) checkConfigCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "check-config",
+		Short: "Check the configuration and the database connection",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			res := checkResult{OK: true}
+			add := func(name string, err error, message string) {
+				c := check{Name: name, OK: err == nil, Message: message}
+				if err != nil {
+					c.Message = err.Error()
+					res.OK = false
+				}
+				res.Checks = append(res.Checks, c)
+			}
+
+			problems := r.cfg.Check()
+			for _, err := range problems {
+				add("config", err, "")
+			}
+			if len(problems) == 0 {
+				add("config", nil, "environment parsed")
+			}
+			r.checkDatabase(add)
+
+			if err := r.print(res); err != nil {
+				return err
+			}
+			if !res.OK {
+				return errCheckFailed
+			}
+			return nil
+		},
+	}
+}
+
+func (r *runner) checkDatabase(add func(name string, err error, message string)) {
+	switch r.cfg.StorageDriver {
+	case config.StorageMemory:
+		add("database", nil, "memory storage, data is lost on restart")
+		return
+	case config.StoragePostgres, config.StorageSQLite:
+	default:
+		return
+	}
+
+	db, err := repository.Open(r.cfg)
+	if err != nil {
+		add("database", err, "")
+		return
+	}
+	defer closeDB(db)
+
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.Ping()
+	}
+	if err != nil {
+		add("database", err, "")
+		return
+	}
+	add("database", nil, "connected to "+r.cfg.StorageDriver)
+
+	pending, err := repository.PendingMigrations(db)
+	switch {
+	case err != nil:
+		add("migrations", err, "")
+	case len(pending) > 0:
+		add("migrations", fmt.Errorf("%d pending, run petgoapi admin migrate", len(pending)), "")
+	default:
+		add("migrations", nil, "schema is up to date")
+	}
+}
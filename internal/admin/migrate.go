@@ -0,0 +1,56 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/stavagg/petGoApi/internal/repository"
+)
+
+type migrateResult struct {
+	DryRun  bool                   `json:"dry_run"`
+	Applied []repository.Migration `json:"applied"`
+}
+
+func (res migrateResult) writeText(w io.Writer) {
+	if len(res.Applied) == 0 {
+		fmt.Fprintln(w, "Schema is up to date")
+		return
+	}
+	for _, m := range res.Applied {
+		fmt.Fprintf(w, "%s %s\n", would(res.DryRun, "applied:", "pending:"), m)
+	}
+}
+
+func (r *runner) migrateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Create missing tables and columns",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := r.open()
+			if err != nil {
+				return err
+			}
+			defer closeDB(db)
+
+			pending, err := repository.PendingMigrations(db)
+			if err != nil {
+				return err
+			}
+			if !r.dryRun {
+				if err := repository.Migrate(db); err != nil {
+					return fmt.Errorf("migrate: %w", err)
+				}
+			}
+			if pending == nil {
+				pending = []repository.Migration{}
+			}
+			return r.print(migrateResult{DryRun: r.dryRun, Applied: pending})
+		},
+	}
+	r.dryRunFlag(cmd)
+	return cmd
+}
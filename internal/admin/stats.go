@@ -0,0 +1,97 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+type projectStats struct {
+	Project   string `json:"project"`
+	Total     int64  `json:"total"`
+	Completed int64  `json:"completed"`
+}
+
+type statsResult struct {
+	Total          int64          `json:"total"`
+	Completed      int64          `json:"completed"`
+	Pending        int64          `json:"pending"`
+	CompletionRate float64        `json:"completion_rate"`
+	Projects       []projectStats `json:"projects"`
+	Trash          int64          `json:"trash"`
+	Events         int64          `json:"events"`
+	// OutboxBacklog counts change events not yet relayed.
+	OutboxBacklog int64 `json:"outbox_backlog"`
+}
+
+func (res statsResult) writeText(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Todos\t%d (%d completed, %d pending, %.1f%%)\n", res.Total, res.Completed, res.Pending, res.CompletionRate)
+	for _, p := range res.Projects {
+		name := p.Project
+		if name == "" {
+			name = "(no project)"
+		}
+		fmt.Fprintf(tw, "  %s\t%d (%d completed)\n", name, p.Total, p.Completed)
+	}
+	fmt.Fprintf(tw, "Trash\t%d\n", res.Trash)
+	fmt.Fprintf(tw, "History events\t%d\n", res.Events)
+	fmt.Fprintf(tw, "Outbox backlog\t%d\n", res.OutboxBacklog)
+	tw.Flush()
+}
+
+func (r *runner) statsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stats",
+		Short: "Recompute todo statistics from the tables",
+		Long: "Recompute todo statistics from the tables. Statistics are not stored, so this\n" +
+			"only reads; unlike GET /api/v1/todos/stats it also breaks them down by project.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := r.open()
+			if err != nil {
+				return err
+			}
+			defer closeDB(db)
+
+			res, err := computeStats(db)
+			if err != nil {
+				return fmt.Errorf("stats: %w", err)
+			}
+			return r.print(res)
+		},
+	}
+}
+
+func computeStats(db *gorm.DB) (statsResult, error) {
+	res := statsResult{Projects: []projectStats{}}
+	err := db.Model(&model.Todo{}).
+		Select("project, COUNT(*) AS total, SUM(CASE WHEN completed THEN 1 ELSE 0 END) AS completed").
+		Group("project").Order("project").
+		Scan(&res.Projects).Error
+	if err != nil {
+		return res, err
+	}
+	for _, p := range res.Projects {
+		res.Total += p.Total
+		res.Completed += p.Completed
+	}
+	res.Pending = res.Total - res.Completed
+	if res.Total > 0 {
+		res.CompletionRate = float64(res.Completed) / float64(res.Total) * 100
+	}
+
+	if err := db.Model(&model.Todo{}).Unscoped().Where("deleted_at IS NOT NULL").Count(&res.Trash).Error; err != nil {
+		return res, err
+	}
+	if err := db.Model(&model.TodoEvent{}).Count(&res.Events).Error; err != nil {
+		return res, err
+	}
+	err = db.Model(&model.OutboxMessage{}).Where("published_at IS NULL").Count(&res.OutboxBacklog).Error
+	return res, err
+}
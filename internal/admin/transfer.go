@@ -0,0 +1,326 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// exportVersion is the format version in the header of export files.
+const exportVersion = 1
+
+// Export files are JSON Lines: a header record followed by the todos of the
+// project, trashed ones included, each followed by its history events.
+type record struct {
+	Type   string           `json:"type"`
+	Header *exportHeader    `json:"header,omitempty"`
+	Todo   *model.Todo      `json:"todo,omitempty"`
+	Event  *model.TodoEvent `json:"event,omitempty"`
+}
+
+type exportHeader struct {
+	Version int `json:"version"`
+	// Project is the exported project; empty for the whole database.
+	Project    string    `json:"project"`
+	All        bool      `json:"all"`
+	ExportedAt time.Time `json:"exported_at"`
+}
+
+const (
+	recordHeader = "header"
+	recordTodo   = "todo"
+	recordEvent  = "event"
+)
+
+type exportResult struct {
+	DryRun  bool   `json:"dry_run"`
+	Project string `json:"project"`
+	All     bool   `json:"all"`
+	File    string `json:"file"`
+	Todos   int    `json:"todos"`
+	Events  int    `json:"events"`
+}
+
+func (res exportResult) writeText(w io.Writer) {
+	scope := "project " + fmt.Sprintf("%q", res.Project)
+	if res.All {
+		scope = "all projects"
+	}
+	fmt.Fprintf(w, "%s %d todos and %d events of %s to %s\n", would(res.DryRun, "Exported", "Would export"), res.Todos, res.Events, scope, res.File)
+}
+
+// exportCmd exports a project, the unit moved between databases. Todos do
+// not belong to users, so there are no tenants to export on their own.
+func (r *runner) exportCmd() *cobra.Command {
+	var project, file string
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export the todos and history of a project as JSON Lines",
+		Long: "Export the todos and history of a project as JSON Lines. A project is the\n" +
+			"unit moved between databases: todos do not belong to users, so there are\n" +
+			"no tenants to export on their own. Users and API keys are not exported;\n" +
+			"only hashes of the keys are stored, so they are issued again.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == cmd.Flags().Changed("project") {
+				return errors.New("pass either --project or --all")
+			}
+			db, err := r.open()
+			if err != nil {
+				return err
+			}
+			defer closeDB(db)
+
+			query := db.Unscoped().Order("id")
+			if !all {
+				query = query.Where("project = ?", project)
+			}
+			var todos []model.Todo
+			if err := query.Find(&todos).Error; err != nil {
+				return fmt.Errorf("export: %w", err)
+			}
+
+			res := exportResult{DryRun: r.dryRun, Project: project, All: all, File: file, Todos: len(todos)}
+			// With the export on stdout, the summary goes to stderr.
+			summary := r.out
+			var w io.Writer = io.Discard
+			if !r.dryRun {
+				if file == "-" {
+					w = r.out
+					summary = cmd.ErrOrStderr()
+				} else {
+					f, err := os.Create(file)
+					if err != nil {
+						return err
+					}
+					defer f.Close()
+					w = f
+				}
+			}
+
+			buf := bufio.NewWriter(w)
+			enc := json.NewEncoder(buf)
+			header := exportHeader{Version: exportVersion, Project: project, All: all, ExportedAt: time.Now().UTC()}
+			if err := enc.Encode(record{Type: recordHeader, Header: &header}); err != nil {
+				return err
+			}
+			for i := range todos {
+				if err := enc.Encode(record{Type: recordTodo, Todo: &todos[i]}); err != nil {
+					return err
+				}
+				var events []model.TodoEvent
+				if err := db.Where("todo_id = ?", todos[i].ID).Order("revision").Find(&events).Error; err != nil {
+					return fmt.Errorf("export: %w", err)
+				}
+				for j := range events {
+					if err := enc.Encode(record{Type: recordEvent, Event: &events[j]}); err != nil {
+						return err
+					}
+				}
+				res.Events += len(events)
+			}
+			if err := buf.Flush(); err != nil {
+				return err
+			}
+			return printResult(summary, r.output, res)
+		},
+	}
+	cmd.Flags().StringVarP(&project, "project", "p", "", "project to export")
+	cmd.Flags().BoolVar(&all, "all", false, "export every project")
+	cmd.Flags().StringVarP(&file, "file", "f", "-", "file to write, - for stdout")
+	r.dryRunFlag(cmd)
+	return cmd
+}
+
+type importedTodo struct {
+	Line  int  `json:"line"`
+	OldID uint `json:"old_id"`
+	NewID uint `json:"new_id"`
+	// ClearedExternalID is the external ID the todo lost because another
+	// todo already had it.
+	ClearedExternalID string `json:"cleared_external_id,omitempty"`
+}
+
+type importResult struct {
+	DryRun bool           `json:"dry_run"`
+	File   string         `json:"file"`
+	Todos  []importedTodo `json:"todos"`
+	Events int            `json:"events"`
+}
+
+func (res importResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "%s %d todos and %d events from %s\n", would(res.DryRun, "Imported", "Would import"), len(res.Todos), res.Events, res.File)
+	for _, t := range res.Todos {
+		if t.ClearedExternalID != "" {
+			fmt.Fprintf(w, "  todo %d -> %d, external ID %s cleared\n", t.OldID, t.NewID, t.ClearedExternalID)
+			continue
+		}
+		fmt.Fprintf(w, "  todo %d -> %d\n", t.OldID, t.NewID)
+	}
+}
+
+func (r *runner) importCmd() *cobra.Command {
+	var project string
+	cmd := &cobra.Command{
+		Use:   "import FILE",
+		Short: "Import a file written by export, - for stdin",
+		Long: "Import a file written by export, - for stdin. Todos get new IDs, so the file\n" +
+			"can be imported into a database that already has todos; importing it twice\n" +
+			"creates the todos twice. External IDs are unique, so a todo whose external ID\n" +
+			"another todo already has is imported without it. The whole file is imported\n" +
+			"in one transaction.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in := cmd.InOrStdin()
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				in = f
+			}
+			db, err := r.open()
+			if err != nil {
+				return err
+			}
+			defer closeDB(db)
+
+			res := importResult{DryRun: r.dryRun, File: args[0], Todos: []importedTodo{}}
+			err = r.write(db, func(tx *gorm.DB) error {
+				return importRecords(cmd.Context(), repository.NewTodoRepository(tx), in, project, &res)
+			})
+			if err != nil {
+				return fmt.Errorf("import: %w", err)
+			}
+			return r.print(res)
+		},
+	}
+	cmd.Flags().StringVarP(&project, "project", "p", "", "move the imported todos into this project")
+	r.dryRunFlag(cmd)
+	return cmd
+}
+
+// importRecords stores the records of in through repo, which runs in the
+// transaction of the import. Every todo gets outbox messages like one
+// created through the API, so subscribers and webhooks learn about it.
+func importRecords(ctx context.Context, repo repository.TodoRepositoryInterface, in io.Reader, project string, res *importResult) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+
+	ids := make(map[uint]uint)
+	var messages []*model.OutboxMessage
+	var events []*model.TodoEvent
+	now := time.Now()
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var rec record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+
+		switch {
+		case line == 1:
+			if rec.Type != recordHeader || rec.Header == nil {
+				return errors.New("line 1: not an export file")
+			}
+			if rec.Header.Version != exportVersion {
+				return fmt.Errorf("line 1: unsupported export version %d", rec.Header.Version)
+			}
+
+		case rec.Type == recordTodo && rec.Todo != nil:
+			todo := *rec.Todo
+			if err := validateTodo(todo); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			oldID := todo.ID
+			todo.ID = 0
+			if project != "" {
+				todo.Project = project
+			}
+			// Sync clients only see todos updated after their change token.
+			todo.UpdatedAt = now
+			cleared, err := clearTakenExternalID(repo, &todo)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if err := repo.Create(&todo); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			copied, err := service.CopiedMessages(ctx, &todo)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			messages = append(messages, copied...)
+			ids[oldID] = todo.ID
+			res.Todos = append(res.Todos, importedTodo{Line: line, OldID: oldID, NewID: todo.ID, ClearedExternalID: cleared})
+
+		case rec.Type == recordEvent && rec.Event != nil:
+			event := *rec.Event
+			newID, ok := ids[event.TodoID]
+			if !ok {
+				return fmt.Errorf("line %d: event of todo %d before the todo", line, event.TodoID)
+			}
+			event.ID = 0
+			event.TodoID = newID
+			events = append(events, &event)
+
+		default:
+			return fmt.Errorf("line %d: unexpected %q record", line, rec.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if line == 0 {
+		return errors.New("empty file")
+	}
+	// The outbox messages and events are stored after all todos, in as few
+	// statements as possible.
+	if err := repo.AppendOutbox(messages...); err != nil {
+		return err
+	}
+	if err := repo.AppendEvents(events); err != nil {
+		return err
+	}
+	res.Events = len(events)
+	return nil
+}
+
+// clearTakenExternalID drops the external ID of todo when another todo,
+// trashed ones included, already has it, and returns the dropped ID.
+func clearTakenExternalID(repo repository.TodoRepositoryInterface, todo *model.Todo) (string, error) {
+	if todo.ExternalID == nil {
+		return "", nil
+	}
+	taken, err := repo.GetByExternalIDs([]string{*todo.ExternalID})
+	if err != nil || len(taken) == 0 {
+		return "", err
+	}
+	cleared := *todo.ExternalID
+	todo.ExternalID = nil
+	return cleared, nil
+}
+
+// validateTodo applies the limits of CreateTodoRequest.
+func validateTodo(todo model.Todo) error {
+	return model.CreateTodoRequest{Title: todo.Title, Description: todo.Description, Project: todo.Project}.Validate()
+}
@@ -0,0 +1,58 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+)
+
+type purgeResult struct {
+	DryRun bool      `json:"dry_run"`
+	Cutoff time.Time `json:"cutoff"`
+	Purged []uint    `json:"purged"`
+}
+
+func (res purgeResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "%s %d todos deleted before %s\n", would(res.DryRun, "Purged", "Would purge"), len(res.Purged), res.Cutoff.Format(time.RFC3339))
+}
+
+func (r *runner) purgeTrashCmd() *cobra.Command {
+	var olderThan time.Duration
+	cmd := &cobra.Command{
+		Use:   "purge-trash",
+		Short: "Permanently remove todos that stayed in the trash too long",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := r.open()
+			if err != nil {
+				return err
+			}
+			defer closeDB(db)
+
+			res := purgeResult{DryRun: r.dryRun, Cutoff: time.Now().Add(-olderThan), Purged: []uint{}}
+			err = r.write(db, func(tx *gorm.DB) error {
+				err := tx.Model(&model.Todo{}).Unscoped().
+					Where("deleted_at IS NOT NULL AND deleted_at < ?", res.Cutoff).
+					Order("id").Pluck("id", &res.Purged).Error
+				if err != nil {
+					return err
+				}
+				_, err = repository.NewTodoRepository(tx).PurgeDeletedBefore(res.Cutoff)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("purge trash: %w", err)
+			}
+			return r.print(res)
+		},
+	}
+	cmd.Flags().DurationVar(&olderThan, "older-than", r.cfg.TrashRetention, "purge todos deleted longer ago than this; 0 empties the trash")
+	r.dryRunFlag(cmd)
+	return cmd
+}
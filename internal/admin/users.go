@@ -0,0 +1,108 @@
+package admin
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+type userResult struct {
+	DryRun bool       `json:"dry_run"`
+	User   model.User `json:"user"`
+}
+
+func (res userResult) writeText(w io.Writer) {
+	fmt.Fprintf(w, "%s user %q\n", would(res.DryRun, "Created", "Would create"), res.User.Name)
+}
+
+func (r *runner) createUserCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create-user NAME",
+		Short: "Create a user of the API",
+		Long: "Create a user of the API. Changes made with the user's API keys are\n" +
+			"attributed to NAME in the todo history.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := r.open()
+			if err != nil {
+				return err
+			}
+			defer closeDB(db)
+
+			res := userResult{DryRun: r.dryRun}
+			err = r.write(db, func(tx *gorm.DB) error {
+				user, err := service.NewUserService(repository.NewUserRepository(tx)).CreateUser(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				res.User = *user
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("create user: %w", err)
+			}
+			return r.print(res)
+		},
+	}
+	r.dryRunFlag(cmd)
+	return cmd
+}
+
+type apiKeyResult struct {
+	DryRun bool   `json:"dry_run"`
+	User   string `json:"user"`
+	// Key is only shown once, and not on a dry run.
+	Key    string       `json:"key,omitempty"`
+	APIKey model.APIKey `json:"api_key"`
+}
+
+func (res apiKeyResult) writeText(w io.Writer) {
+	if res.DryRun {
+		fmt.Fprintf(w, "Would create an API key for %q\n", res.User)
+		return
+	}
+	fmt.Fprintf(w, "Created API key %s... for %q. It is not shown again:\n%s\n", res.APIKey.Prefix, res.User, res.Key)
+}
+
+func (r *runner) createAPIKeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create-api-key USER",
+		Short: "Create an API key for a user",
+		Long: "Create an API key for a user. Only its hash is stored, so the key is printed\n" +
+			"once. Clients send it as a bearer token; the server requires one with\n" +
+			"API_KEYS_REQUIRED=true.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := r.open()
+			if err != nil {
+				return err
+			}
+			defer closeDB(db)
+
+			res := apiKeyResult{DryRun: r.dryRun, User: args[0]}
+			err = r.write(db, func(tx *gorm.DB) error {
+				key, apiKey, err := service.NewUserService(repository.NewUserRepository(tx)).CreateAPIKey(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				res.APIKey = *apiKey
+				if !r.dryRun {
+					res.Key = key
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("create API key: %w", err)
+			}
+			return r.print(res)
+		},
+	}
+	r.dryRunFlag(cmd)
+	return cmd
+}
@@ -0,0 +1,486 @@
+// Package caldav serves todos to calendar clients such as Thunderbird and
+// Apple Reminders over CalDAV (RFC 4791) with collection sync (RFC 6578).
+//
+// Todos have no owner, so the calendars are shared: every client, and with
+// API keys every key holder, sees the same ones, one per project plus one
+// for the todos without a project. A key only decides who a change is
+// attributed to, as it does for the REST API.
+//
+//	/caldav/                             principal and calendar home
+//	/caldav/default/                     todos without a project
+//	/caldav/project-<name>/              todos of a project
+//	/caldav/project-<name>/<uid>.ics     one todo as a VTODO
+//
+// Reads go through TodoService. Edits go through the sync protocol of
+// SyncService, so they are validated, versioned and recorded like edits
+// made through the REST API, and todos created by a client keep its UID in
+// their external ID.
+package caldav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/ical"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+const (
+	// Prefix is the path of the calendar home.
+	Prefix = "/caldav/"
+	// WellKnown redirects to Prefix, so clients find the server from its
+	// host name alone (RFC 6764).
+	WellKnown = "/.well-known/caldav"
+
+	defaultCollection = "default"
+	projectPrefix     = "project-"
+	objectSuffix      = ".ics"
+
+	// syncTokenPrefix turns change tokens of SyncService into the URIs
+	// sync-collection expects.
+	syncTokenPrefix = "urn:petgoapi:sync:"
+	syncPageSize    = 500
+
+	maxObjectBytes = 1 << 20
+	allowedMethods = "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT"
+)
+
+type Handler struct {
+	todos service.TodoServiceInterface
+	sync  service.SyncServiceInterface
+}
+
+func NewHandler(todos service.TodoServiceInterface, sync service.SyncServiceInterface) *Handler {
+	return &Handler{todos: todos, sync: sync}
+}
+
+type kind int
+
+const (
+	home kind = iota
+	collection
+	object
+)
+
+// target is the resource a request path names. Collections and objects
+// are named by project; uid is set for objects.
+type target struct {
+	kind    kind
+	project string
+	uid     string
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == WellKnown {
+		http.Redirect(w, r, Prefix, http.StatusMovedPermanently)
+		return
+	}
+	t, ok := parsePath(r.URL.EscapedPath())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	r = r.WithContext(withActor(r))
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		w.Header().Set("Allow", allowedMethods)
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		h.propfind(w, r, t)
+	case "REPORT":
+		h.report(w, r, t)
+	case http.MethodGet, http.MethodHead:
+		h.get(w, r, t)
+	case http.MethodPut:
+		h.put(w, r, t)
+	case http.MethodDelete:
+		h.delete(w, r, t)
+	default:
+		w.Header().Set("Allow", allowedMethods)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// withActor attributes edits to the user name of Basic authentication,
+// which is what calendar clients send, or else to the X-Actor header.
+// Passwords are only checked when the API requires keys; the password is
+// then the key, and edits are attributed to its user.
+func withActor(r *http.Request) context.Context {
+	if service.UserFromContext(r.Context()) != nil {
+		return r.Context()
+	}
+	actor := r.Header.Get(handler.ActorHeader)
+	if user, _, ok := r.BasicAuth(); ok && user != "" {
+		actor = user
+	}
+	if actor == "" {
+		return r.Context()
+	}
+	return service.WithActor(r.Context(), actor)
+}
+
+// parsePath reads an escaped request path. Project names are escaped as a
+// single segment, so they may contain slashes.
+func parsePath(path string) (target, bool) {
+	if path == strings.TrimSuffix(Prefix, "/") {
+		return target{kind: home}, true
+	}
+	rest, ok := strings.CutPrefix(path, Prefix)
+	if !ok {
+		return target{}, false
+	}
+	if rest == "" {
+		return target{kind: home}, true
+	}
+
+	segments := strings.Split(strings.TrimSuffix(rest, "/"), "/")
+	if len(segments) > 2 {
+		return target{}, false
+	}
+	t := target{kind: collection}
+	switch name := segments[0]; {
+	case name == defaultCollection:
+	case strings.HasPrefix(name, projectPrefix) && len(name) > len(projectPrefix):
+		project, err := url.PathUnescape(strings.TrimPrefix(name, projectPrefix))
+		if err != nil {
+			return target{}, false
+		}
+		t.project = project
+	default:
+		return target{}, false
+	}
+
+	if len(segments) == 2 {
+		name, err := url.PathUnescape(segments[1])
+		if err != nil || !strings.HasSuffix(name, objectSuffix) || len(name) == len(objectSuffix) {
+			return target{}, false
+		}
+		t.kind = object
+		t.uid = strings.TrimSuffix(name, objectSuffix)
+	}
+	return t, true
+}
+
+func collectionHref(project string) string {
+	if project == "" {
+		return Prefix + defaultCollection + "/"
+	}
+	return Prefix + projectPrefix + url.PathEscape(project) + "/"
+}
+
+func objectHref(project, uid string) string {
+	return collectionHref(project) + url.PathEscape(uid) + objectSuffix
+}
+
+func todoHref(todo model.Todo) string {
+	return objectHref(todo.Project, ical.TodoUID(todo))
+}
+
+func displayName(project string) string {
+	if project == "" {
+		return "Todos"
+	}
+	return project
+}
+
+// etag changes with every edit, as the version of a todo does.
+func etag(todo model.Todo) string {
+	return `"` + strconv.FormatUint(uint64(todo.Version), 10) + `"`
+}
+
+// ifMatchVersion reads the version of an If-Match header sent back from
+// etag. "*" and unknown tags read as 0. The version is checked once more
+// by the sync mutation, so an edit that slips in between still conflicts.
+func ifMatchVersion(header string) uint {
+	tag := strings.Trim(strings.TrimPrefix(strings.TrimSpace(header), "W/"), `"`)
+	version, err := strconv.ParseUint(tag, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(version)
+}
+
+// lookup finds the todo of a UID, in any collection.
+func (h *Handler) lookup(ctx context.Context, uid string) (*model.Todo, error) {
+	var todo *model.Todo
+	var err error
+	if id, ok := ical.ParseUID(uid); ok {
+		todo, err = h.todos.GetTodoByID(ctx, id)
+	} else {
+		todo, err = h.todos.GetTodoByExternalID(ctx, ical.ExternalID(uid))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ical.TodoUID(*todo) != uid {
+		return nil, service.ErrTodoNotFound
+	}
+	return todo, nil
+}
+
+// find returns the todo a target names, or nil when there is none.
+func (h *Handler) find(ctx context.Context, t target) (*model.Todo, error) {
+	todo, err := h.lookup(ctx, t.uid)
+	if errors.Is(err, service.ErrTodoNotFound) || (err == nil && todo.Project != t.project) {
+		return nil, nil
+	}
+	return todo, err
+}
+
+// collections sums up the collections, by project. The default collection
+// is always there, even when empty.
+func (h *Handler) collections(ctx context.Context) ([]model.ProjectSummary, error) {
+	projects, err := h.todos.GetProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(projects) == 0 || projects[0].Project != "" {
+		projects = append([]model.ProjectSummary{{}}, projects...)
+	}
+	return projects, nil
+}
+
+// members returns the todos of a collection in ID order. ok is false for
+// projects without todos.
+func (h *Handler) members(ctx context.Context, project string) ([]model.Todo, bool, error) {
+	todos, err := h.todos.GetTodosByProject(ctx, project)
+	if err != nil {
+		return nil, false, err
+	}
+	return todos, project == "" || len(todos) > 0, nil
+}
+
+// summarize sums up members the way TodoService.GetProjects does.
+func summarize(project string, members []model.Todo) model.ProjectSummary {
+	summary := model.ProjectSummary{Project: project, Todos: int64(len(members))}
+	for _, todo := range members {
+		summary.IDSum += int64(todo.ID)
+		summary.VersionSum += int64(todo.Version)
+	}
+	return summary
+}
+
+// ctag changes whenever a member of the collection is added, edited or
+// removed.
+func ctag(summary model.ProjectSummary) string {
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d/%d/%d", summary.Todos, summary.IDSum, summary.VersionSum)
+	return strconv.FormatUint(hash.Sum64(), 16)
+}
+
+func calendarData(todos ...model.Todo) string {
+	var b strings.Builder
+	w := ical.NewWriter(&b)
+	_ = w.Begin()
+	for _, todo := range todos {
+		_ = w.WriteTodo(todo)
+	}
+	_ = w.End()
+	return b.String()
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, t target) {
+	switch t.kind {
+	case home:
+		w.Header().Set("Allow", allowedMethods)
+		http.Error(w, "the calendar home has no content", http.StatusMethodNotAllowed)
+		return
+	case collection:
+		// The whole calendar, as clients that subscribe to a URL read it.
+		todos, ok, err := h.members(r.Context(), t.project)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		_, _ = io.WriteString(w, calendarData(todos...))
+		return
+	}
+
+	todo, err := h.find(r.Context(), t)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if todo == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8; component=VTODO")
+	w.Header().Set("ETag", etag(*todo))
+	w.Header().Set("Last-Modified", todo.UpdatedAt.UTC().Format(http.TimeFormat))
+	_, _ = io.WriteString(w, calendarData(*todo))
+}
+
+// put creates or replaces a todo. The resource name has to be the UID of
+// the VTODO, so the todo keeps the name the client gave it. A replaced todo
+// takes every field from the VTODO: a missing DESCRIPTION or DUE clears it.
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, t target) {
+	if t.kind != object {
+		http.Error(w, "only calendar object resources can be written", http.StatusMethodNotAllowed)
+		return
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType != "text/calendar" {
+			http.Error(w, "the body must be text/calendar", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	vtodo, err := ical.ParseTodo(http.MaxBytesReader(w, r.Body, maxObjectBytes))
+	switch {
+	case errors.Is(err, ical.ErrNoTodo):
+		writeCondition(w, http.StatusForbidden, calDAVName("supported-calendar-component"), "")
+		return
+	case err != nil:
+		writeCondition(w, http.StatusForbidden, calDAVName("valid-calendar-data"), text(err.Error()))
+		return
+	case vtodo.UID != t.uid:
+		http.Error(w, "the resource name must be the UID of the VTODO followed by .ics", http.StatusConflict)
+		return
+	}
+
+	ctx := r.Context()
+	current, err := h.lookup(ctx, t.uid)
+	if err != nil && !errors.Is(err, service.ErrTodoNotFound) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if current != nil && current.Project != t.project {
+		// Moving a todo is a DELETE and a PUT elsewhere, in that order.
+		writeCondition(w, http.StatusForbidden, calDAVName("no-uid-conflict"), href(todoHref(*current)))
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if current == nil {
+		if ifMatch != "" {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		h.create(w, r, t, vtodo)
+		return
+	}
+	if r.Header.Get("If-None-Match") == "*" || (ifMatch != "" && ifMatch != "*" && ifMatchVersion(ifMatch) != current.Version) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	completed := vtodo.Completed
+	results, err := h.sync.Push(ctx, []model.SyncMutation{{
+		Op:          model.SyncUpdate,
+		ID:          current.ID,
+		BaseVersion: ifMatchVersion(ifMatch),
+		Data: model.UpdateTodoRequest{
+			Title:            vtodo.Summary,
+			Description:      vtodo.Description,
+			ClearDescription: vtodo.Description == "",
+			Completed:        &completed,
+			DueAt:            vtodo.Due,
+			ClearDueAt:       vtodo.Due == nil,
+		},
+	}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeResult(w, results[0], http.StatusNoContent)
+}
+
+func (h *Handler) create(w http.ResponseWriter, r *http.Request, t target, vtodo *ical.Todo) {
+	if _, ours := ical.ParseUID(vtodo.UID); ours {
+		// The UID of a todo that was deleted.
+		http.Error(w, "the todo was deleted", http.StatusConflict)
+		return
+	}
+
+	report, err := h.todos.ImportTodos(r.Context(), []model.ImportRow{{
+		ExternalID: ical.ExternalID(vtodo.UID),
+		Request: model.CreateTodoRequest{
+			Title:       vtodo.Summary,
+			Description: vtodo.Description,
+			Project:     t.project,
+			DueAt:       vtodo.Due,
+		},
+		Completed:   vtodo.Completed,
+		CreatedAt:   vtodo.Created,
+		CompletedAt: vtodo.CompletedAt,
+	}}, false)
+	switch {
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	case len(report.Errors) > 0:
+		http.Error(w, report.Errors[0].Error, http.StatusBadRequest)
+	case len(report.Skipped) > 0:
+		http.Error(w, "a todo with this UID is in the trash", http.StatusConflict)
+	default:
+		w.Header().Set("ETag", etag(report.Todos[0]))
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, t target) {
+	if t.kind != object {
+		http.Error(w, "calendars cannot be deleted", http.StatusForbidden)
+		return
+	}
+	todo, err := h.find(r.Context(), t)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if todo == nil {
+		http.NotFound(w, r)
+		return
+	}
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch != "" && ifMatch != "*" && ifMatchVersion(ifMatch) != todo.Version {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	results, err := h.sync.Push(r.Context(), []model.SyncMutation{{
+		Op:          model.SyncDelete,
+		ID:          todo.ID,
+		BaseVersion: ifMatchVersion(ifMatch),
+	}})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeResult(w, results[0], http.StatusNoContent)
+}
+
+// writeResult answers an edit with the outcome of its sync mutation. A
+// version conflict means the If-Match tag no longer matches.
+func writeResult(w http.ResponseWriter, result model.SyncResult, applied int) {
+	switch result.Status {
+	case model.SyncApplied:
+		if result.Todo != nil {
+			w.Header().Set("ETag", etag(*result.Todo))
+		}
+		w.WriteHeader(applied)
+	case model.SyncConflict:
+		w.WriteHeader(http.StatusPreconditionFailed)
+	case model.SyncNotFound:
+		http.Error(w, result.Error, http.StatusNotFound)
+	default:
+		http.Error(w, result.Error, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,243 @@
+package caldav_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stavagg/petGoApi/internal/caldav"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+func newHandler() (*caldav.Handler, *service.TodoService) {
+	repo := repository.NewMemoryTodoRepository()
+	todos := service.NewTodoService(repo, repo.Events(), nil)
+	return caldav.NewHandler(todos, service.NewSyncService(todos, repo, 0)), todos
+}
+
+func do(h http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func vtodo(uid, summary, extra string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nBEGIN:VTODO\r\n" +
+		"UID:" + uid + "\r\nSUMMARY:" + summary + "\r\n" + extra +
+		"END:VTODO\r\nEND:VCALENDAR\r\n"
+}
+
+func TestCalDAV_PutGetDelete(t *testing.T) {
+	h, todos := newHandler()
+	path := "/caldav/project-work/abc-123.ics"
+
+	w := do(h, "PUT", path, vtodo("abc-123", "Write report", "DESCRIPTION:Q3 numbers\r\nDUE:20261101T090000Z\r\n"),
+		"Content-Type", "text/calendar", "If-None-Match", "*")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	created := w.Header().Get("ETag")
+	assert.Equal(t, `"1"`, created)
+
+	all, err := todos.GetAllTodos(context.Background())
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	assert.Equal(t, "Write report", all[0].Title)
+	assert.Equal(t, "work", all[0].Project)
+
+	w = do(h, "GET", path, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, created, w.Header().Get("ETag"))
+	assert.Contains(t, w.Body.String(), "UID:abc-123\r\n")
+
+	w = do(h, "PUT", path, vtodo("abc-123", "Write report", "STATUS:COMPLETED\r\n"), "If-Match", created)
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	updated := w.Header().Get("ETag")
+	assert.NotEqual(t, created, updated)
+
+	todo, err := todos.GetTodoByID(context.Background(), all[0].ID)
+	require.NoError(t, err)
+	assert.True(t, todo.Completed)
+	assert.Empty(t, todo.Description, "a PUT replaces the whole todo")
+	assert.Nil(t, todo.DueAt)
+
+	w = do(h, "PUT", path, vtodo("abc-123", "Stale edit", ""), "If-Match", created)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	w = do(h, "DELETE", path, "", "If-Match", created)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = do(h, "PUT", "/caldav/default/abc-123.ics", vtodo("abc-123", "Moved", ""))
+	assert.Equal(t, http.StatusForbidden, w.Code, "the UID belongs to another calendar")
+	assert.Contains(t, w.Body.String(), "no-uid-conflict")
+
+	w = do(h, "DELETE", path, "", "If-Match", updated)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, http.StatusNotFound, do(h, "GET", path, "").Code)
+}
+
+func TestCalDAV_PutRejectsInvalidObjects(t *testing.T) {
+	h, _ := newHandler()
+
+	w := do(h, "PUT", "/caldav/default/other.ics", vtodo("abc", "Title", ""))
+	assert.Equal(t, http.StatusConflict, w.Code, "the resource name must be the UID")
+
+	w = do(h, "PUT", "/caldav/default/abc.ics", vtodo("abc", "", ""))
+	assert.Equal(t, http.StatusBadRequest, w.Code, "the title is validated like any other")
+
+	event := "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nUID:abc\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	w = do(h, "PUT", "/caldav/default/abc.ics", event)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "supported-calendar-component")
+
+	w = do(h, "PUT", "/caldav/default/abc.ics", "{}", "Content-Type", "application/json")
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestCalDAV_PropfindAndQuery(t *testing.T) {
+	h, todos := newHandler()
+	ctx := context.Background()
+	_, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Inbox"})
+	require.NoError(t, err)
+	_, err = todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Ship release", Project: "work"})
+	require.NoError(t, err)
+	_, err = todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Plan sprint", Project: "work"})
+	require.NoError(t, err)
+
+	w := do(h, "OPTIONS", "/caldav/", "")
+	assert.Contains(t, w.Header().Get("DAV"), "calendar-access")
+
+	w = do(h, "PROPFIND", "/caldav/", "", "Depth", "1")
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "/caldav/default/")
+	assert.Contains(t, body, "/caldav/project-work/")
+	assert.Contains(t, body, "calendar-home-set")
+
+	w = do(h, "PROPFIND", "/caldav/project-work/", `<?xml version="1.0"?>
+<d:propfind xmlns:d="DAV:" xmlns:cs="http://calendarserver.org/ns/">
+  <d:prop><d:displayname/><d:sync-token/><cs:getctag/><d:quota-used-bytes/></d:prop>
+</d:propfind>`, "Depth", "0")
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+	body = w.Body.String()
+	assert.Contains(t, body, ">work</displayname>")
+	assert.Contains(t, body, "urn:petgoapi:sync:")
+	assert.Contains(t, body, "HTTP/1.1 404 Not Found", "unknown properties are reported missing")
+	assert.NotContains(t, body, "Ship release", "Depth 0 leaves out the members")
+
+	assert.Equal(t, http.StatusNotFound, do(h, "PROPFIND", "/caldav/project-none/", "").Code)
+
+	w = do(h, "REPORT", "/caldav/project-work/", `<?xml version="1.0"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop><d:getetag/><c:calendar-data/></d:prop>
+  <c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VTODO">
+    <c:prop-filter name="SUMMARY"><c:text-match>SHIP</c:text-match></c:prop-filter>
+  </c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`)
+	require.Equal(t, http.StatusMultiStatus, w.Code)
+	body = w.Body.String()
+	assert.Contains(t, body, "SUMMARY:Ship release")
+	assert.NotContains(t, body, "Plan sprint")
+	assert.NotContains(t, body, "Inbox")
+}
+
+var syncToken = regexp.MustCompile(`<sync-token>([^<]+)</sync-token>`)
+
+func TestCalDAV_SyncCollection(t *testing.T) {
+	h, todos := newHandler()
+	ctx := context.Background()
+	kept, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Kept"})
+	require.NoError(t, err)
+
+	report := func(token string) string {
+		w := do(h, "REPORT", "/caldav/default/", `<?xml version="1.0"?>
+<d:sync-collection xmlns:d="DAV:">
+  <d:sync-token>`+token+`</d:sync-token><d:sync-level>1</d:sync-level><d:prop><d:getetag/></d:prop>
+</d:sync-collection>`)
+		require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+		return w.Body.String()
+	}
+
+	body := report("")
+	assert.Contains(t, body, "/caldav/default/todo-1@petgoapi.ics")
+	match := syncToken.FindStringSubmatch(body)
+	require.NotNil(t, match)
+
+	w := do(h, "PUT", "/caldav/default/new-1.ics", vtodo("new-1", "From phone", ""))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	_, err = todos.DeleteTodo(ctx, kept.ID)
+	require.NoError(t, err)
+
+	body = report(match[1])
+	assert.Contains(t, body, "/caldav/default/new-1.ics")
+	assert.Regexp(t, `todo-1@petgoapi.ics</href><status>HTTP/1.1 404 Not Found`, body)
+
+	w = do(h, "REPORT", "/caldav/default/", `<d:sync-collection xmlns:d="DAV:"><d:sync-token>bogus</d:sync-token></d:sync-collection>`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "valid-sync-token")
+}
+
+func TestCalDAV_SyncCollectionIsScopedAndTruncated(t *testing.T) {
+	h, todos := newHandler()
+	ctx := context.Background()
+	moved, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Moved", Project: "work"})
+	require.NoError(t, err)
+
+	report := func(token, limit string) string {
+		w := do(h, "REPORT", "/caldav/project-work/", `<?xml version="1.0"?>
+<d:sync-collection xmlns:d="DAV:">
+  <d:sync-token>`+token+`</d:sync-token><d:sync-level>1</d:sync-level>`+limit+`<d:prop><d:getetag/></d:prop>
+</d:sync-collection>`)
+		require.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+		return w.Body.String()
+	}
+	token := syncToken.FindStringSubmatch(report("", ""))[1]
+
+	for _, title := range []string{"One", "Two", "Three"} {
+		_, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: title, Project: "work"})
+		require.NoError(t, err)
+	}
+	other, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Elsewhere", Project: "home"})
+	require.NoError(t, err)
+	_, err = todos.DeleteTodo(ctx, other.ID)
+	require.NoError(t, err)
+	require.NoError(t, todos.PurgeTodo(ctx, other.ID))
+	_, err = todos.UpdateTodo(ctx, moved.ID, model.UpdateTodoRequest{Project: "home"})
+	require.NoError(t, err)
+
+	var bodies []string
+	for page := 0; ; page++ {
+		require.Less(t, page, 10)
+		body := report(token, `<d:limit><d:nresults>2</d:nresults></d:limit>`)
+		bodies = append(bodies, body)
+		token = syncToken.FindStringSubmatch(body)[1]
+		if !strings.Contains(body, "507 Insufficient Storage") {
+			break
+		}
+		assert.Contains(t, body, "<href>/caldav/project-work/</href><status>HTTP/1.1 507 Insufficient Storage</status><error><number-of-matches-within-limits")
+	}
+	assert.Greater(t, len(bodies), 1, "the changes are reported a limited page at a time")
+
+	all := strings.Join(bodies, "")
+	assert.Regexp(t, `todo-1@petgoapi.ics</href><status>HTTP/1.1 404 Not Found`, all, "a todo moved out is removed")
+	assert.NotContains(t, all, "todo-5@petgoapi.ics", "todos of other collections are left out")
+	for _, id := range []string{"2", "3", "4"} {
+		assert.Contains(t, all, "/caldav/project-work/todo-"+id+"@petgoapi.ics")
+	}
+}
+
+func TestCalDAV_WellKnownRedirects(t *testing.T) {
+	h, _ := newHandler()
+	w := do(h, "PROPFIND", caldav.WellKnown, "")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, caldav.Prefix, w.Header().Get("Location"))
+}
@@ -0,0 +1,231 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+// resource is what a response describes: the home, a collection with the
+// summary of its members or a single todo.
+type resource struct {
+	kind    kind
+	project string
+	summary model.ProjectSummary
+	todo    *model.Todo
+}
+
+func (res resource) href() string {
+	switch res.kind {
+	case home:
+		return Prefix
+	case collection:
+		return collectionHref(res.project)
+	}
+	return todoHref(*res.todo)
+}
+
+// allProps are the properties allprop and propname return for each kind of
+// resource.
+var allProps = map[kind][]xml.Name{
+	home: {
+		davName("resourcetype"),
+		davName("displayname"),
+		davName("current-user-principal"),
+		davName("principal-URL"),
+		calDAVName("calendar-home-set"),
+	},
+	collection: {
+		davName("resourcetype"),
+		davName("displayname"),
+		calDAVName("supported-calendar-component-set"),
+		davName("supported-report-set"),
+		davName("current-user-privilege-set"),
+		davName("sync-token"),
+		{Space: nsCS, Local: "getctag"},
+	},
+	object: {
+		davName("resourcetype"),
+		davName("getetag"),
+		davName("getcontenttype"),
+		davName("getlastmodified"),
+	},
+}
+
+// property returns the value of a property of res, or false when res has no
+// such property.
+func (h *Handler) property(res resource, name xml.Name) (string, bool) {
+	switch name {
+	case davName("resourcetype"):
+		switch res.kind {
+		case home:
+			return empty(davName("collection")) + empty(davName("principal")), true
+		case collection:
+			return empty(davName("collection")) + empty(calDAVName("calendar")), true
+		}
+		return "", true
+	case davName("current-user-principal"), davName("principal-URL"), calDAVName("calendar-home-set"):
+		// The home is the principal of the single, anonymous user.
+		return href(Prefix), true
+	}
+
+	switch res.kind {
+	case home:
+		if name == davName("displayname") {
+			return "petGoApi", true
+		}
+	case collection:
+		switch name {
+		case davName("displayname"):
+			return text(displayName(res.project)), true
+		case calDAVName("supported-calendar-component-set"):
+			return `<comp xmlns="` + nsCalDAV + `" name="VTODO"/>`, true
+		case davName("supported-report-set"):
+			var b strings.Builder
+			for _, report := range []xml.Name{calDAVName("calendar-query"), calDAVName("calendar-multiget"), davName("sync-collection")} {
+				b.WriteString(`<supported-report xmlns="DAV:"><report>` + empty(report) + `</report></supported-report>`)
+			}
+			return b.String(), true
+		case davName("current-user-privilege-set"):
+			var b strings.Builder
+			for _, privilege := range []string{"read", "write", "write-content", "bind", "unbind", "read-current-user-privilege-set"} {
+				b.WriteString(`<privilege xmlns="DAV:">` + empty(davName(privilege)) + `</privilege>`)
+			}
+			return b.String(), true
+		case davName("sync-token"):
+			return text(syncTokenPrefix + h.sync.Token()), true
+		case xml.Name{Space: nsCS, Local: "getctag"}:
+			return ctag(res.summary), true
+		}
+	case object:
+		switch name {
+		case davName("getetag"):
+			return text(etag(*res.todo)), true
+		case davName("getcontenttype"):
+			return "text/calendar; charset=utf-8; component=VTODO", true
+		case davName("getlastmodified"):
+			return res.todo.UpdatedAt.UTC().Format(http.TimeFormat), true
+		case calDAVName("calendar-data"):
+			return text(calendarData(*res.todo)), true
+		}
+	}
+	return "", false
+}
+
+// describe answers a request for the named properties of res: found ones
+// with 200, unknown ones with 404. With namesOnly, as for propname, the
+// values are left out.
+func (h *Handler) describe(res resource, names []xml.Name, namesOnly bool) response {
+	var found, missing props
+	for _, name := range names {
+		value, ok := h.property(res, name)
+		switch {
+		case !ok:
+			missing.Values = append(missing.Values, property{XMLName: name})
+		case namesOnly:
+			found.Values = append(found.Values, property{XMLName: name})
+		default:
+			found.Values = append(found.Values, property{XMLName: name, Inner: value})
+		}
+	}
+
+	resp := response{Href: res.href()}
+	if len(found.Values) > 0 || len(missing.Values) == 0 {
+		resp.Propstats = append(resp.Propstats, propstat{Prop: found, Status: status(http.StatusOK)})
+	}
+	if len(missing.Values) > 0 {
+		resp.Propstats = append(resp.Propstats, propstat{Prop: missing, Status: status(http.StatusNotFound)})
+	}
+	return resp
+}
+
+// readXML decodes a request body into v. It returns false for an empty
+// body, which stands for the default request.
+func readXML(r *http.Request, v interface{}) (bool, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxObjectBytes))
+	if err != nil {
+		return false, err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return false, nil
+	}
+	return true, xml.Unmarshal(body, v)
+}
+
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request, t target) {
+	var req propfindRequest
+	if _, err := readXML(r, &req); err != nil {
+		http.Error(w, "malformed PROPFIND body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Depth infinity is answered as 1, which covers the whole tree below
+	// a collection.
+	depth := 1
+	if r.Header.Get("Depth") == "0" {
+		depth = 0
+	}
+
+	resources, ok, err := h.resources(r.Context(), t, depth)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var ms multistatus
+	for _, res := range resources {
+		names := req.Prop.names()
+		if req.Prop == nil {
+			names = allProps[res.kind]
+		}
+		ms.Responses = append(ms.Responses, h.describe(res, names, req.PropName != nil))
+	}
+	writeXML(w, http.StatusMultiStatus, ms)
+}
+
+// resources returns the resource of t, followed by its members at depth 1.
+// ok is false when t does not exist.
+func (h *Handler) resources(ctx context.Context, t target, depth int) ([]resource, bool, error) {
+	switch t.kind {
+	case home:
+		resources := []resource{{kind: home}}
+		if depth == 0 {
+			return resources, true, nil
+		}
+		projects, err := h.collections(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, summary := range projects {
+			resources = append(resources, resource{kind: collection, project: summary.Project, summary: summary})
+		}
+		return resources, true, nil
+	case collection:
+		todos, ok, err := h.members(ctx, t.project)
+		if err != nil || !ok {
+			return nil, ok, err
+		}
+		resources := []resource{{kind: collection, project: t.project, summary: summarize(t.project, todos)}}
+		if depth == 0 {
+			return resources, true, nil
+		}
+		for i := range todos {
+			resources = append(resources, resource{kind: object, todo: &todos[i]})
+		}
+		return resources, true, nil
+	}
+
+	todo, err := h.find(ctx, t)
+	if err != nil || todo == nil {
+		return nil, false, err
+	}
+	return []resource{{kind: object, todo: todo}}, true, nil
+}
@@ -0,0 +1,403 @@
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/ical"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+const timeRangeFormat = "20060102T150405Z"
+
+func (h *Handler) report(w http.ResponseWriter, r *http.Request, t target) {
+	var req reportRequest
+	if ok, err := readXML(r, &req); err != nil || !ok {
+		http.Error(w, "malformed REPORT body", http.StatusBadRequest)
+		return
+	}
+	if t.kind != collection {
+		writeCondition(w, http.StatusForbidden, davName("supported-report"), "")
+		return
+	}
+	// Clients that sync ask for the ETag and fetch changed objects later.
+	names := req.Prop.names()
+	if req.Prop == nil {
+		names = []xml.Name{davName("getetag")}
+	}
+
+	switch req.XMLName {
+	case calDAVName("calendar-query"):
+		h.calendarQuery(w, r, t, &req, names)
+	case calDAVName("calendar-multiget"):
+		h.calendarMultiget(w, r, t, &req, names)
+	case davName("sync-collection"):
+		h.syncCollection(w, r, t, &req, names)
+	default:
+		writeCondition(w, http.StatusForbidden, davName("supported-report"), "")
+	}
+}
+
+func (h *Handler) calendarQuery(w http.ResponseWriter, r *http.Request, t target, req *reportRequest, names []xml.Name) {
+	todos, ok, err := h.members(r.Context(), t.project)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if req.Filter != nil && req.Filter.CompFilter != nil && req.Filter.CompFilter.Name != "VCALENDAR" {
+		writeCondition(w, http.StatusForbidden, calDAVName("valid-filter"), "")
+		return
+	}
+
+	ms := multistatus{Responses: []response{}}
+	for i := range todos {
+		if req.Filter != nil && !matchCalendar(todos[i], req.Filter.CompFilter) {
+			continue
+		}
+		ms.Responses = append(ms.Responses, h.describe(resource{kind: object, todo: &todos[i]}, names, false))
+	}
+	writeXML(w, http.StatusMultiStatus, ms)
+}
+
+func (h *Handler) calendarMultiget(w http.ResponseWriter, r *http.Request, t target, req *reportRequest, names []xml.Name) {
+	ms := multistatus{Responses: []response{}}
+	for _, ref := range req.Hrefs {
+		// Hrefs may be absolute URLs.
+		path := ref
+		if u, err := url.Parse(ref); err == nil {
+			path = u.EscapedPath()
+		}
+		named, ok := parsePath(path)
+		if !ok || named.kind != object || named.project != t.project {
+			ms.Responses = append(ms.Responses, response{Href: ref, Status: status(http.StatusNotFound)})
+			continue
+		}
+		todo, err := h.find(r.Context(), named)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if todo == nil {
+			ms.Responses = append(ms.Responses, response{Href: ref, Status: status(http.StatusNotFound)})
+			continue
+		}
+		ms.Responses = append(ms.Responses, h.describe(resource{kind: object, todo: todo}, names, false))
+	}
+	writeXML(w, http.StatusMultiStatus, ms)
+}
+
+// syncCollection reports the members changed since a sync token. Todos
+// that were deleted, purged or moved to another project are reported as
+// removed with a 404. A limit from the client lowers the page size.
+func (h *Handler) syncCollection(w http.ResponseWriter, r *http.Request, t target, req *reportRequest, names []xml.Name) {
+	ctx := r.Context()
+	token := ""
+	if req.SyncToken != nil && *req.SyncToken != "" {
+		var ok bool
+		token, ok = strings.CutPrefix(strings.TrimSpace(*req.SyncToken), syncTokenPrefix)
+		if !ok {
+			writeCondition(w, http.StatusForbidden, davName("valid-sync-token"), "")
+			return
+		}
+	}
+
+	var ms multistatus
+	var err error
+	if token == "" {
+		ms, err = h.initialSync(ctx, t, names)
+	} else {
+		limit := syncPageSize
+		if req.Limit != nil && req.Limit.NResults > 0 && req.Limit.NResults < limit {
+			limit = req.Limit.NResults
+		}
+		ms, err = h.changesSince(ctx, t, token, limit, names)
+	}
+	switch {
+	case errors.Is(err, service.ErrInvalidSyncToken):
+		writeCondition(w, http.StatusForbidden, davName("valid-sync-token"), "")
+	case errors.Is(err, service.ErrTodoNotFound):
+		http.NotFound(w, r)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		writeXML(w, http.StatusMultiStatus, ms)
+	}
+}
+
+// initialSync lists every member. The token is taken first, so edits made
+// while the members are read are reported again by the next sync.
+func (h *Handler) initialSync(ctx context.Context, t target, names []xml.Name) (multistatus, error) {
+	token := h.sync.Token()
+	todos, ok, err := h.members(ctx, t.project)
+	if err != nil {
+		return multistatus{}, err
+	}
+	if !ok {
+		return multistatus{}, service.ErrTodoNotFound
+	}
+	ms := multistatus{Responses: []response{}, SyncToken: syncTokenPrefix + token}
+	for i := range todos {
+		ms.Responses = append(ms.Responses, h.describe(resource{kind: object, todo: &todos[i]}, names, false))
+	}
+	return ms, nil
+}
+
+// changesSince reports one page of the changes since token, at most limit
+// todos. When more are left, the collection itself is reported with a 507,
+// which is how RFC 6578 truncates a report, and the client syncs again from
+// the token of the response.
+func (h *Handler) changesSince(ctx context.Context, t target, token string, limit int, names []xml.Name) (multistatus, error) {
+	changes, err := h.sync.Changes(ctx, token, limit)
+	if err != nil {
+		return multistatus{}, err
+	}
+
+	var others []uint
+	for _, todos := range [][]model.Todo{changes.Created, changes.Updated} {
+		for _, todo := range todos {
+			if todo.Project != t.project {
+				others = append(others, todo.ID)
+			}
+		}
+	}
+	for _, tombstone := range changes.Deleted {
+		if tombstone.Project != t.project {
+			others = append(others, tombstone.ID)
+		}
+	}
+	movedOut, err := h.movedOut(ctx, t.project, others)
+	if err != nil {
+		return multistatus{}, err
+	}
+
+	// A todo may be both trashed and purged since the token; it is reported
+	// once.
+	var order []string
+	byHref := make(map[string]response)
+	add := func(resp response) {
+		if _, seen := byHref[resp.Href]; !seen {
+			order = append(order, resp.Href)
+		}
+		byHref[resp.Href] = resp
+	}
+	for _, todos := range [][]model.Todo{changes.Created, changes.Updated} {
+		for i := range todos {
+			todo := todos[i]
+			if todo.Project != t.project {
+				if movedOut[todo.ID] {
+					add(response{Href: objectHref(t.project, ical.TodoUID(todo)), Status: status(http.StatusNotFound)})
+				}
+				continue
+			}
+			add(h.describe(resource{kind: object, todo: &todo}, names, false))
+		}
+	}
+	for _, tombstone := range changes.Deleted {
+		if tombstone.Project != t.project && !movedOut[tombstone.ID] {
+			continue
+		}
+		var externalID *string
+		if tombstone.ExternalID != "" {
+			externalID = &tombstone.ExternalID
+		}
+		uid := ical.TodoUID(model.Todo{ID: tombstone.ID, ExternalID: externalID})
+		add(response{Href: objectHref(t.project, uid), Status: status(http.StatusNotFound)})
+	}
+
+	ms := multistatus{Responses: []response{}, SyncToken: syncTokenPrefix + changes.Token}
+	for _, ref := range order {
+		ms.Responses = append(ms.Responses, byHref[ref])
+	}
+	if changes.HasMore {
+		ms.Responses = append(ms.Responses, response{
+			Href:   collectionHref(t.project),
+			Status: status(http.StatusInsufficientStorage),
+			Error:  &responseError{Inner: empty(davName("number-of-matches-within-limits"))},
+		})
+	}
+	return ms, nil
+}
+
+// movedOut tells which of the todos, now in other collections or deleted
+// there, were in the collection of project before. Only those are reported
+// as removed from it.
+func (h *Handler) movedOut(ctx context.Context, project string, ids []uint) (map[uint]bool, error) {
+	moved := make(map[uint]bool)
+	if len(ids) == 0 {
+		return moved, nil
+	}
+	histories, err := h.todos.GetHistories(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for id, events := range histories {
+		for _, event := range events {
+			if event.Snapshot.Project == project {
+				moved[id] = true
+				break
+			}
+		}
+	}
+	return moved, nil
+}
+
+// matchCalendar applies the comp-filter of a calendar-query, whose top
+// level names VCALENDAR, to a todo.
+func matchCalendar(todo model.Todo, f *compFilter) bool {
+	if f == nil {
+		return true
+	}
+	if f.IsNotDefined != nil {
+		return false
+	}
+	for i := range f.CompFilters {
+		child := &f.CompFilters[i]
+		if child.Name != "VTODO" {
+			// A todo has no events or journals.
+			if child.IsNotDefined == nil {
+				return false
+			}
+			continue
+		}
+		if !matchTodo(todo, child) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchTodo(todo model.Todo, f *compFilter) bool {
+	if f.IsNotDefined != nil {
+		return false
+	}
+	if f.TimeRange != nil {
+		start, end, ok := f.TimeRange.bounds()
+		if !ok || !overlaps(todo, start, end) {
+			return false
+		}
+	}
+	for i := range f.PropFilters {
+		if !matchProp(todo, &f.PropFilters[i]) {
+			return false
+		}
+	}
+	// Alarms and other nested components are not kept.
+	for _, child := range f.CompFilters {
+		if child.IsNotDefined == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps is the time-range test for a VTODO of RFC 4791, section 9.9.
+// Todos have no DTSTART or DURATION, and always have CREATED.
+func overlaps(todo model.Todo, start, end time.Time) bool {
+	if todo.DueAt != nil {
+		return !start.After(*todo.DueAt) && !end.Before(*todo.DueAt)
+	}
+	if completedAt, ok := completedAt(todo); ok {
+		return (!start.After(todo.CreatedAt) || !start.After(completedAt)) &&
+			(!end.Before(todo.CreatedAt) || !end.Before(completedAt))
+	}
+	return end.After(todo.CreatedAt)
+}
+
+func completedAt(todo model.Todo) (time.Time, bool) {
+	if !todo.Completed {
+		return time.Time{}, false
+	}
+	if at, ok := todo.FieldTimes["completed"]; ok {
+		return at, true
+	}
+	return todo.UpdatedAt, true
+}
+
+// bounds reads a time-range; a missing end is open.
+func (tr *timeRange) bounds() (time.Time, time.Time, bool) {
+	start := time.Time{}
+	end := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	var err error
+	if tr.Start != "" {
+		if start, err = time.Parse(timeRangeFormat, tr.Start); err != nil {
+			return start, end, false
+		}
+	}
+	if tr.End != "" {
+		if end, err = time.Parse(timeRangeFormat, tr.End); err != nil {
+			return start, end, false
+		}
+	}
+	return start, end, true
+}
+
+func matchProp(todo model.Todo, f *propFilter) bool {
+	text, at, defined := todoProperty(todo, strings.ToUpper(f.Name))
+	if f.IsNotDefined != nil {
+		return !defined
+	}
+	if !defined {
+		return false
+	}
+	if f.TimeRange != nil {
+		start, end, ok := f.TimeRange.bounds()
+		if !ok || at.IsZero() || at.Before(start) || !at.Before(end) {
+			return false
+		}
+	}
+	if f.TextMatch != nil {
+		// The default collation, i;ascii-casemap, ignores case.
+		contains := strings.Contains(strings.ToLower(text), strings.ToLower(f.TextMatch.Text))
+		if contains == (f.TextMatch.NegateCondition == "yes") {
+			return false
+		}
+	}
+	return true
+}
+
+// todoProperty returns a VTODO property of a todo, as text and, for dates,
+// as a time. defined is false when the VTODO leaves it out.
+func todoProperty(todo model.Todo, name string) (text string, at time.Time, defined bool) {
+	switch name {
+	case "UID":
+		return ical.TodoUID(todo), at, true
+	case "SUMMARY":
+		return todo.Title, at, true
+	case "DESCRIPTION":
+		return todo.Description, at, todo.Description != ""
+	case "CATEGORIES":
+		return todo.Project, at, todo.Project != ""
+	case "STATUS":
+		if todo.Completed {
+			return "COMPLETED", at, true
+		}
+		return "NEEDS-ACTION", at, true
+	case "DUE":
+		if todo.DueAt == nil {
+			return "", at, false
+		}
+		at = *todo.DueAt
+	case "COMPLETED":
+		var ok bool
+		if at, ok = completedAt(todo); !ok {
+			return "", at, false
+		}
+	case "CREATED":
+		at = todo.CreatedAt
+	case "LAST-MODIFIED":
+		at = todo.UpdatedAt
+	default:
+		return "", at, false
+	}
+	return at.UTC().Format(timeRangeFormat), at, true
+}
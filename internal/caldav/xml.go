@@ -0,0 +1,177 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	// nsCS holds getctag, which clients from before sync-collection use to
+	// tell whether a calendar changed.
+	nsCS = "http://calendarserver.org/ns/"
+)
+
+func davName(local string) xml.Name    { return xml.Name{Space: nsDAV, Local: local} }
+func calDAVName(local string) xml.Name { return xml.Name{Space: nsCalDAV, Local: local} }
+
+// Requests.
+
+type propfindRequest struct {
+	XMLName  xml.Name   `xml:"DAV: propfind"`
+	AllProp  *struct{}  `xml:"DAV: allprop"`
+	PropName *struct{}  `xml:"DAV: propname"`
+	Prop     *propNames `xml:"DAV: prop"`
+}
+
+type propNames struct {
+	Names []element `xml:",any"`
+}
+
+type element struct {
+	XMLName xml.Name
+}
+
+func (p *propNames) names() []xml.Name {
+	if p == nil {
+		return nil
+	}
+	names := make([]xml.Name, 0, len(p.Names))
+	for _, e := range p.Names {
+		names = append(names, e.XMLName)
+	}
+	return names
+}
+
+// reportRequest holds the fields of the supported reports; XMLName tells
+// which one was asked for.
+type reportRequest struct {
+	XMLName xml.Name
+	AllProp *struct{}  `xml:"DAV: allprop"`
+	Prop    *propNames `xml:"DAV: prop"`
+	// calendar-query
+	Filter *filter `xml:"urn:ietf:params:xml:ns:caldav filter"`
+	// calendar-multiget
+	Hrefs []string `xml:"DAV: href"`
+	// sync-collection
+	SyncToken *string    `xml:"DAV: sync-token"`
+	Limit     *syncLimit `xml:"DAV: limit"`
+}
+
+type syncLimit struct {
+	NResults int `xml:"DAV: nresults"`
+}
+
+type filter struct {
+	CompFilter *compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type compFilter struct {
+	Name         string       `xml:"name,attr"`
+	IsNotDefined *struct{}    `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TimeRange    *timeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	PropFilters  []propFilter `xml:"urn:ietf:params:xml:ns:caldav prop-filter"`
+	CompFilters  []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type propFilter struct {
+	Name         string     `xml:"name,attr"`
+	IsNotDefined *struct{}  `xml:"urn:ietf:params:xml:ns:caldav is-not-defined"`
+	TimeRange    *timeRange `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	TextMatch    *textMatch `xml:"urn:ietf:params:xml:ns:caldav text-match"`
+}
+
+type textMatch struct {
+	Text            string `xml:",chardata"`
+	NegateCondition string `xml:"negate-condition,attr"`
+}
+
+type timeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+// Responses.
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"response"`
+	SyncToken string     `xml:"sync-token,omitempty"`
+}
+
+// response is a resource with its properties, or with only a status when
+// it is missing or was removed.
+type response struct {
+	Href      string         `xml:"href"`
+	Status    string         `xml:"status,omitempty"`
+	Propstats []propstat     `xml:"propstat"`
+	Error     *responseError `xml:"error,omitempty"`
+}
+
+// responseError names the condition a response status stands for.
+type responseError struct {
+	Inner string `xml:",innerxml"`
+}
+
+type propstat struct {
+	Prop   props  `xml:"prop"`
+	Status string `xml:"status"`
+}
+
+type props struct {
+	Values []property
+}
+
+// property is an element whose content is already XML.
+type property struct {
+	XMLName xml.Name
+	Inner   string `xml:",innerxml"`
+}
+
+// davError is the body of an error response naming the precondition that
+// failed.
+type davError struct {
+	XMLName   xml.Name `xml:"DAV: error"`
+	Condition property
+}
+
+func status(code int) string {
+	return "HTTP/1.1 " + strconv.Itoa(code) + " " + http.StatusText(code)
+}
+
+// text escapes character data for a property.
+func text(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// empty is an empty element, as in resource types and privileges.
+func empty(name xml.Name) string {
+	return `<` + name.Local + ` xmlns="` + name.Space + `"/>`
+}
+
+func href(path string) string {
+	return `<href xmlns="DAV:">` + text(path) + `</href>`
+}
+
+func writeXML(w http.ResponseWriter, code int, v interface{}) {
+	body, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(code)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(body)
+}
+
+// writeCondition fails a request with the precondition it broke, like
+// CALDAV:supported-calendar-component.
+func writeCondition(w http.ResponseWriter, code int, condition xml.Name, inner string) {
+	writeXML(w, code, davError{Condition: property{XMLName: condition, Inner: inner}})
+}
@@ -0,0 +1,110 @@
+// Package cliconfig is the configuration shared by the todo and todo-tui
+// commands.
+package cliconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+const DefaultServer = "http://localhost:8080"
+
+// Output formats of the todo command.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+)
+
+// Config is stored in config.yaml under the user config directory, or in
+// the file named by $TODO_CONFIG. TODO_SERVER, TODO_TOKEN and TODO_ACTOR
+// override the file, and command-line flags override both.
+type Config struct {
+	Server string `yaml:"server,omitempty" json:"server"`
+	Token  string `yaml:"token,omitempty" json:"token,omitempty"`
+	Actor  string `yaml:"actor,omitempty" json:"actor,omitempty"`
+	// Output is the default output format: table or json.
+	Output string `yaml:"output,omitempty" json:"output,omitempty"`
+}
+
+// Keys are the settings Set accepts.
+var Keys = []string{"server", "token", "actor", "output"}
+
+func Path() (string, error) {
+	if path := os.Getenv("TODO_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "todo", "config.yaml"), nil
+}
+
+// Read reads the config file. A missing file is an empty config.
+func Read(path string) (*Config, error) {
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	return cfg, nil
+}
+
+// ApplyEnv overrides the file with the environment and fills in defaults.
+func (cfg *Config) ApplyEnv() {
+	if v := os.Getenv("TODO_SERVER"); v != "" {
+		cfg.Server = v
+	}
+	if v := os.Getenv("TODO_TOKEN"); v != "" {
+		cfg.Token = v
+	}
+	if v := os.Getenv("TODO_ACTOR"); v != "" {
+		cfg.Actor = v
+	}
+	if cfg.Server == "" {
+		cfg.Server = DefaultServer
+	}
+}
+
+// Save writes cfg to path. The file may hold a token, so it is only
+// readable by the user.
+func Save(path string, cfg *Config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func (cfg *Config) Set(key, value string) error {
+	switch key {
+	case "server":
+		cfg.Server = value
+	case "token":
+		cfg.Token = value
+	case "actor":
+		cfg.Actor = value
+	case "output":
+		if value != OutputTable && value != OutputJSON {
+			return fmt.Errorf("output must be %s or %s", OutputTable, OutputJSON)
+		}
+		cfg.Output = value
+	default:
+		return fmt.Errorf("unknown key %q, expected one of %v", key, Keys)
+	}
+	return nil
+}
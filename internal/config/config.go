@@ -1,25 +1,191 @@
 package config
 
-import "os"
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	StoragePostgres = "postgres"
+	StorageSQLite   = "sqlite"
+	StorageMemory   = "memory"
+)
 
 type Config struct {
-	Port   string
-	DBHost string
-	DBPort string
-	DBUser string
-	DBPass string
-	DBName string
+	Port          string
+	StorageDriver string
+	SQLitePath    string
+	DBHost        string
+	DBPort        string
+	DBUser        string
+	DBPass        string
+	DBName        string
+
+	TrashRetention     time.Duration
+	TrashPurgeInterval time.Duration
+	UndoWindow         time.Duration
+
+	EventReplaySize int
+	SSEHeartbeat    time.Duration
+	// PGNotify shares change events between instances through Postgres
+	// LISTEN/NOTIFY. It only applies to the postgres storage driver.
+	PGNotify bool
+
+	OutboxPollInterval time.Duration
+	OutboxRetention    time.Duration
+	// OutboxMaxAttempts is how often an event is tried before the relay
+	// gives up on it.
+	OutboxMaxAttempts int
+	// OutboxLogEvents adds a sink that logs every relayed event.
+	OutboxLogEvents bool
+
+	WebhookTimeout      time.Duration
+	WebhookPollInterval time.Duration
+	WebhookMaxAttempts  int
+	WebhookDisableAfter int
+	WebhookRetryBase    time.Duration
+	// WebhookAllowPrivate lets webhooks target loopback, private and
+	// link-local addresses, which are refused by default.
+	WebhookAllowPrivate bool
+
+	// SyncOverlap holds sync change tokens back so that changes still being
+	// committed are not skipped.
+	SyncOverlap time.Duration
+
+	// GraphQLComplexityLimit rejects GraphQL queries scoring higher. Every
+	// field scores 1 and list fields are assumed to hold 10 elements.
+	GraphQLComplexityLimit int
+	// GraphQLAllowedOrigins are the origins browsers may open GraphQL
+	// subscriptions from. Empty allows only the API's own origin.
+	GraphQLAllowedOrigins []string
+	// GraphQLIntrospection lets clients query the schema.
+	GraphQLIntrospection bool
+
+	// WebSocketAllowedOrigins are the origins browsers may open the
+	// /api/v1/ws channel from. Empty allows only the API's own origin.
+	WebSocketAllowedOrigins []string
+
+	// ValidateResponses checks every response against the OpenAPI document,
+	// turning a mismatch into a 500.
+	ValidateResponses bool
+
+	// APIKeysRequired makes every request authenticate with an API key
+	// created by petgoapi admin create-api-key.
+	APIKeysRequired bool
+
+	// GRPCPort is where the gRPC API listens. Empty disables it.
+	GRPCPort string
 }
 
 func Load() *Config {
 	return &Config{
-		Port:   getEnv("PORT", ":8080"),
-		DBHost: getEnv("DB_HOST", "localhost"),
-		DBPort: getEnv("DB_PORT", "5432"),
-		DBUser: getEnv("DB_USER", "postgres"),
-		DBPass: getEnv("DB_PASS", "password"),
-		DBName: getEnv("DB_NAME", "mydb"),
+		Port:          getEnv("PORT", ":8080"),
+		StorageDriver: getEnv("STORAGE_DRIVER", StoragePostgres),
+		SQLitePath:    getEnv("SQLITE_PATH", "petgoapi.db"),
+		DBHost:        getEnv("DB_HOST", "localhost"),
+		DBPort:        getEnv("DB_PORT", "5432"),
+		DBUser:        getEnv("DB_USER", "postgres"),
+		DBPass:        getEnv("DB_PASS", "password"),
+		DBName:        getEnv("DB_NAME", "mydb"),
+
+		TrashRetention:     getDurationEnv("TRASH_RETENTION", 30*24*time.Hour),
+		TrashPurgeInterval: getDurationEnv("TRASH_PURGE_INTERVAL", time.Hour),
+		UndoWindow:         getDurationEnv("UNDO_WINDOW", 30*time.Second),
+
+		EventReplaySize: getIntEnv("EVENT_REPLAY_SIZE", 1000),
+		SSEHeartbeat:    getDurationEnv("SSE_HEARTBEAT", 15*time.Second),
+		PGNotify:        getBoolEnv("PG_NOTIFY", true),
+
+		OutboxPollInterval: getDurationEnv("OUTBOX_POLL_INTERVAL", time.Second),
+		OutboxRetention:    getDurationEnv("OUTBOX_RETENTION", 24*time.Hour),
+		OutboxMaxAttempts:  getIntEnv("OUTBOX_MAX_ATTEMPTS", 20),
+		OutboxLogEvents:    getBoolEnv("OUTBOX_LOG_EVENTS", false),
+
+		WebhookTimeout:      getDurationEnv("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookPollInterval: getDurationEnv("WEBHOOK_POLL_INTERVAL", 5*time.Second),
+		WebhookMaxAttempts:  getIntEnv("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookDisableAfter: getIntEnv("WEBHOOK_DISABLE_AFTER", 20),
+		WebhookRetryBase:    getDurationEnv("WEBHOOK_RETRY_BASE", 30*time.Second),
+		WebhookAllowPrivate: getBoolEnv("WEBHOOK_ALLOW_PRIVATE", false),
+
+		SyncOverlap: getDurationEnv("SYNC_OVERLAP", 5*time.Second),
+
+		GraphQLComplexityLimit: getIntEnv("GRAPHQL_COMPLEXITY_LIMIT", 1000),
+		GraphQLAllowedOrigins:  getListEnv("GRAPHQL_ALLOWED_ORIGINS"),
+		GraphQLIntrospection:   getBoolEnv("GRAPHQL_INTROSPECTION", false),
+
+		WebSocketAllowedOrigins: getListEnv("WS_ALLOWED_ORIGINS"),
+
+		ValidateResponses: getBoolEnv("VALIDATE_RESPONSES", false),
+
+		APIKeysRequired: getBoolEnv("API_KEYS_REQUIRED", false),
+
+		GRPCPort: getEnv("GRPC_PORT", ":9090"),
+	}
+}
+
+// Environment variables parsed as durations, non-negative integers and
+// booleans. Load falls back to the default when they do not parse.
+var (
+	durationVars = []string{"TRASH_RETENTION", "TRASH_PURGE_INTERVAL", "UNDO_WINDOW", "SSE_HEARTBEAT", "OUTBOX_POLL_INTERVAL", "OUTBOX_RETENTION", "WEBHOOK_TIMEOUT", "WEBHOOK_POLL_INTERVAL", "WEBHOOK_RETRY_BASE", "SYNC_OVERLAP"}
+	intVars      = []string{"EVENT_REPLAY_SIZE", "OUTBOX_MAX_ATTEMPTS", "WEBHOOK_MAX_ATTEMPTS", "WEBHOOK_DISABLE_AFTER", "GRAPHQL_COMPLEXITY_LIMIT"}
+	boolVars     = []string{"PG_NOTIFY", "OUTBOX_LOG_EVENTS", "WEBHOOK_ALLOW_PRIVATE", "GRAPHQL_INTROSPECTION", "VALIDATE_RESPONSES", "API_KEYS_REQUIRED"}
+)
+
+// Check reports problems with the configuration: environment variables
+// Load ignored because they did not parse, and settings that cannot work.
+func (c *Config) Check() []error {
+	var problems []error
+	for _, key := range durationVars {
+		if value, ok := os.LookupEnv(key); ok {
+			if d, err := time.ParseDuration(value); err != nil || d <= 0 {
+				problems = append(problems, fmt.Errorf("%s=%q is not a positive duration, the default is used", key, value))
+			}
+		}
+	}
+	for _, key := range intVars {
+		if value, ok := os.LookupEnv(key); ok {
+			if n, err := strconv.Atoi(value); err != nil || n < 0 {
+				problems = append(problems, fmt.Errorf("%s=%q is not a non-negative integer, the default is used", key, value))
+			}
+		}
 	}
+	for _, key := range boolVars {
+		if value, ok := os.LookupEnv(key); ok {
+			if _, err := strconv.ParseBool(value); err != nil {
+				problems = append(problems, fmt.Errorf("%s=%q is not a boolean, the default is used", key, value))
+			}
+		}
+	}
+
+	switch c.StorageDriver {
+	case StoragePostgres, StorageSQLite, StorageMemory:
+	default:
+		problems = append(problems, fmt.Errorf("STORAGE_DRIVER=%q is not one of %s, %s, %s", c.StorageDriver, StoragePostgres, StorageSQLite, StorageMemory))
+	}
+	if c.APIKeysRequired && c.StorageDriver == StorageMemory {
+		problems = append(problems, fmt.Errorf("API_KEYS_REQUIRED needs a database, STORAGE_DRIVER=%s has none", StorageMemory))
+	}
+	if _, _, err := net.SplitHostPort(c.Port); err != nil {
+		problems = append(problems, fmt.Errorf("PORT=%q is not [host]:port", c.Port))
+	}
+	if c.GRPCPort != "" {
+		if _, _, err := net.SplitHostPort(c.GRPCPort); err != nil {
+			problems = append(problems, fmt.Errorf("GRPC_PORT=%q is not [host]:port", c.GRPCPort))
+		} else if c.GRPCPort == c.Port {
+			problems = append(problems, fmt.Errorf("GRPC_PORT and PORT are both %q", c.Port))
+		}
+	}
+	return problems
+}
+
+func (c *Config) PostgresDSN() string {
+	return fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%s sslmode=disable",
+		c.DBHost, c.DBUser, c.DBPass, c.DBName, c.DBPort)
 }
 
 func getEnv(key, defaultVal string) string {
@@ -28,3 +194,41 @@ func getEnv(key, defaultVal string) string {
 	}
 	return defaultVal
 }
+
+func getDurationEnv(key string, defaultVal time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			return d
+		}
+	}
+	return defaultVal
+}
+
+func getIntEnv(key string, defaultVal int) int {
+	if value, exists := os.LookupEnv(key); exists {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			return n
+		}
+	}
+	return defaultVal
+}
+
+func getBoolEnv(key string, defaultVal bool) bool {
+	if value, exists := os.LookupEnv(key); exists {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultVal
+}
+
+// getListEnv splits a comma-separated variable, skipping empty items.
+func getListEnv(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
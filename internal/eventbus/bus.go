@@ -0,0 +1,143 @@
+// Package eventbus fans out todo change events to in-process subscribers
+// and keeps a bounded buffer of recent events for clients that reconnect.
+package eventbus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+const (
+	TodoCreated = "todo.created"
+	TodoUpdated = "todo.updated"
+	TodoToggled = "todo.toggled"
+	TodoDeleted = "todo.deleted"
+)
+
+type Event struct {
+	ID         uint64      `json:"id"`
+	Type       string      `json:"type"`
+	TodoID     uint        `json:"todo_id"`
+	Actor      string      `json:"actor"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Todo       *model.Todo `json:"todo"`
+	// PreviousProject is set when the change moved the todo out of another
+	// project, so subscribers of that project learn it is gone.
+	PreviousProject string `json:"previous_project,omitempty"`
+	// OutboxID is the ID of the outbox message the event was published
+	// from. It is shared by every instance, unlike ID.
+	OutboxID uint `json:"outbox_id,omitempty"`
+}
+
+type Publisher interface {
+	Publish(event Event) Event
+}
+
+// Bus is an in-memory publish/subscribe hub. Event IDs are assigned in
+// publish order and are only meaningful within one process.
+type Bus struct {
+	mu          sync.Mutex
+	lastID      uint64
+	replay      []Event
+	replaySize  int
+	subscribers map[*Subscription]struct{}
+}
+
+func NewBus(replaySize int) *Bus {
+	return &Bus{
+		replaySize:  replaySize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the next ID to event, stores it in the replay buffer and
+// delivers it to every subscriber. Subscribers whose buffer is full are
+// dropped instead of blocking the publisher; they can resume with
+// Last-Event-ID.
+func (b *Bus) Publish(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	if b.replaySize > 0 {
+		if len(b.replay) == b.replaySize {
+			copy(b.replay, b.replay[1:])
+			b.replay = b.replay[:len(b.replay)-1]
+		}
+		b.replay = append(b.replay, event)
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.ch <- event:
+		default:
+			b.remove(sub)
+		}
+	}
+
+	return event
+}
+
+// Subscribe registers a subscriber with room for buffer pending events. When
+// afterID is non-zero the events published after it are returned for replay;
+// complete is false if some of them have already left the replay buffer or
+// afterID comes from a previous process.
+func (b *Bus) Subscribe(afterID uint64, buffer int) (sub *Subscription, replay []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	sub = &Subscription{bus: b, ch: make(chan Event, buffer)}
+	b.subscribers[sub] = struct{}{}
+
+	complete = afterID <= b.lastID
+	if afterID > 0 && afterID < b.lastID {
+		if len(b.replay) == 0 || b.replay[0].ID > afterID+1 {
+			complete = false
+		}
+		for _, event := range b.replay {
+			if event.ID > afterID {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	return sub, replay, complete
+}
+
+// LastID returns the ID of the most recently published event.
+func (b *Bus) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+func (b *Bus) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+type Subscription struct {
+	bus *Bus
+	ch  chan Event
+}
+
+// Events is closed when the subscription is closed or dropped for being too
+// slow.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.remove(s)
+}
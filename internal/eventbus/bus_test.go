@@ -0,0 +1,83 @@
+package eventbus_test
+
+import (
+	"testing"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublish_DeliversToSubscribers(t *testing.T) {
+	bus := eventbus.NewBus(10)
+	sub, replay, complete := bus.Subscribe(0, 4)
+	defer sub.Close()
+
+	assert.Empty(t, replay)
+	assert.True(t, complete)
+
+	published := bus.Publish(eventbus.Event{Type: eventbus.TodoCreated, TodoID: 1})
+	assert.Equal(t, uint64(1), published.ID)
+	assert.False(t, published.OccurredAt.IsZero())
+
+	event := <-sub.Events()
+	assert.Equal(t, published, event)
+}
+
+func TestSubscribe_ReplaysAfterID(t *testing.T) {
+	bus := eventbus.NewBus(10)
+	for i := 1; i <= 5; i++ {
+		bus.Publish(eventbus.Event{Type: eventbus.TodoUpdated, TodoID: uint(i)})
+	}
+
+	sub, replay, complete := bus.Subscribe(3, 4)
+	defer sub.Close()
+
+	assert.True(t, complete)
+	require.Len(t, replay, 2)
+	assert.Equal(t, uint64(4), replay[0].ID)
+	assert.Equal(t, uint64(5), replay[1].ID)
+}
+
+func TestSubscribe_ReportsGapBeyondReplayBuffer(t *testing.T) {
+	bus := eventbus.NewBus(2)
+	for i := 1; i <= 5; i++ {
+		bus.Publish(eventbus.Event{Type: eventbus.TodoUpdated, TodoID: uint(i)})
+	}
+
+	sub, replay, complete := bus.Subscribe(1, 4)
+	defer sub.Close()
+
+	assert.False(t, complete)
+	require.Len(t, replay, 2)
+	assert.Equal(t, uint64(4), replay[0].ID)
+}
+
+func TestSubscribe_UnknownFutureID(t *testing.T) {
+	bus := eventbus.NewBus(2)
+	bus.Publish(eventbus.Event{Type: eventbus.TodoCreated})
+
+	sub, replay, complete := bus.Subscribe(100, 4)
+	defer sub.Close()
+
+	assert.False(t, complete)
+	assert.Empty(t, replay)
+}
+
+func TestPublish_DropsSlowSubscriber(t *testing.T) {
+	bus := eventbus.NewBus(0)
+	slow, _, _ := bus.Subscribe(0, 1)
+	fast, _, _ := bus.Subscribe(0, 8)
+	defer fast.Close()
+
+	for i := 0; i < 3; i++ {
+		bus.Publish(eventbus.Event{Type: eventbus.TodoToggled})
+	}
+
+	<-slow.Events()
+	_, open := <-slow.Events()
+	assert.False(t, open, "slow subscriber should be disconnected")
+	assert.Len(t, fast.Events(), 3)
+
+	slow.Close()
+}
@@ -0,0 +1,92 @@
+package eventbus
+
+import (
+	"sort"
+	"sync"
+)
+
+// Filter selects the events of some todos and projects. A change that moves
+// a todo out of a project matches the filters of that project too, so their
+// subscribers learn it is gone. Filter is safe for concurrent use: a client
+// may change what it follows while its events are being matched. The zero
+// value matches nothing.
+type Filter struct {
+	mu       sync.RWMutex
+	all      bool
+	projects map[string]bool
+	todoIDs  map[uint]bool
+}
+
+// NewFilter returns a filter for the given projects and todos. Without
+// either it matches every event.
+func NewFilter(projects []string, todoIDs []uint) *Filter {
+	f := &Filter{}
+	f.Add(len(projects) == 0 && len(todoIDs) == 0, projects, todoIDs)
+	return f
+}
+
+// Add follows the projects and todos, or every event when all is set.
+func (f *Filter) Add(all bool, projects []string, todoIDs []uint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.projects == nil {
+		f.projects = make(map[string]bool)
+		f.todoIDs = make(map[uint]bool)
+	}
+	f.all = f.all || all
+	for _, p := range projects {
+		f.projects[p] = true
+	}
+	for _, id := range todoIDs {
+		f.todoIDs[id] = true
+	}
+}
+
+// Remove stops following the projects and todos. Setting all stops matching
+// every event, but keeps the projects and todos followed.
+func (f *Filter) Remove(all bool, projects []string, todoIDs []uint) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if all {
+		f.all = false
+	}
+	for _, p := range projects {
+		delete(f.projects, p)
+	}
+	for _, id := range todoIDs {
+		delete(f.todoIDs, id)
+	}
+}
+
+func (f *Filter) Matches(event Event) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	if f.all || f.todoIDs[event.TodoID] {
+		return true
+	}
+	if event.Todo != nil && f.projects[event.Todo.Project] {
+		return true
+	}
+	return event.PreviousProject != "" && f.projects[event.PreviousProject]
+}
+
+// State returns what the filter follows, sorted.
+func (f *Filter) State() (all bool, projects []string, todoIDs []uint) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	projects = make([]string, 0, len(f.projects))
+	for p := range f.projects {
+		projects = append(projects, p)
+	}
+	sort.Strings(projects)
+	todoIDs = make([]uint, 0, len(f.todoIDs))
+	for id := range f.todoIDs {
+		todoIDs = append(todoIDs, id)
+	}
+	sort.Slice(todoIDs, func(i, j int) bool { return todoIDs[i] < todoIDs[j] })
+	return f.all, projects, todoIDs
+}
@@ -0,0 +1,34 @@
+package eventbus_test
+
+import (
+	"testing"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter_Matches(t *testing.T) {
+	home := eventbus.Event{TodoID: 1, Todo: &model.Todo{ID: 1, Project: "home"}}
+	moved := eventbus.Event{TodoID: 2, Todo: &model.Todo{ID: 2, Project: "home"}, PreviousProject: "work"}
+	other := eventbus.Event{TodoID: 3, Todo: &model.Todo{ID: 3, Project: "misc"}}
+
+	assert.True(t, eventbus.NewFilter(nil, nil).Matches(other), "an empty filter follows everything")
+	assert.False(t, (&eventbus.Filter{}).Matches(other), "the zero value follows nothing")
+
+	filter := eventbus.NewFilter([]string{"work"}, []uint{1})
+	assert.True(t, filter.Matches(home))
+	assert.True(t, filter.Matches(moved), "todos moved out of a project are reported to it")
+	assert.False(t, filter.Matches(other))
+
+	filter.Remove(false, []string{"work"}, []uint{1})
+	assert.False(t, filter.Matches(home))
+	assert.False(t, filter.Matches(moved))
+
+	filter.Add(true, []string{"misc"}, nil)
+	assert.True(t, filter.Matches(home))
+	all, projects, todoIDs := filter.State()
+	assert.True(t, all)
+	assert.Equal(t, []string{"misc"}, projects)
+	assert.Empty(t, todoIDs)
+}
@@ -0,0 +1,40 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+// NewOutboxMessage encodes event for the outbox. The bus ID is left out; it
+// is assigned when the relay publishes the message.
+func NewOutboxMessage(event Event) (*model.OutboxMessage, error) {
+	now := time.Now().UTC()
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = now
+	}
+	event.ID = 0
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return &model.OutboxMessage{
+		AggregateID: event.TodoID,
+		EventType:   event.Type,
+		Payload:     string(payload),
+		AvailableAt: now,
+	}, nil
+}
+
+// FromOutbox decodes the event stored in an outbox message.
+func FromOutbox(message model.OutboxMessage) (Event, error) {
+	var event Event
+	if err := json.Unmarshal([]byte(message.Payload), &event); err != nil {
+		return Event{}, err
+	}
+	event.ID = 0
+	event.OutboxID = message.ID
+	return event, nil
+}
@@ -0,0 +1,188 @@
+// Package export writes todos in the file formats served by
+// GET /api/v1/todos/export. Writers buffer their output and write it as
+// they go, so a list of any size can be streamed.
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/ical"
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+const (
+	CSV       = "csv"
+	JSONL     = "jsonl"
+	Markdown  = "md"
+	ICalendar = "ics"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{CSV, JSONL, Markdown, ICalendar}
+
+var contentTypes = map[string]string{
+	CSV:       "text/csv; charset=utf-8",
+	JSONL:     "application/x-ndjson",
+	Markdown:  "text/markdown; charset=utf-8",
+	ICalendar: "text/calendar; charset=utf-8",
+}
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// Writer writes todos one at a time. Close writes what ends the document
+// and flushes; it does not close the underlying writer.
+type Writer interface {
+	Write(todo model.Todo) error
+	Close() error
+}
+
+// ContentType returns the media type of format.
+func ContentType(format string) string {
+	return contentTypes[format]
+}
+
+// NewWriter returns a writer of format to w.
+func NewWriter(format string, w io.Writer) (Writer, error) {
+	buf := bufio.NewWriter(w)
+	switch format {
+	case CSV:
+		return newCSVWriter(buf), nil
+	case JSONL:
+		return &jsonlWriter{buf: buf, enc: json.NewEncoder(buf)}, nil
+	case Markdown:
+		return newMarkdownWriter(buf), nil
+	case ICalendar:
+		return newICalendarWriter(buf), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+// CSVHeader is the first row of CSV exports.
+var CSVHeader = []string{"id", "title", "description", "project", "completed", "due_at", "created_at", "updated_at"}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(buf *bufio.Writer) *csvWriter {
+	w := &csvWriter{w: csv.NewWriter(buf)}
+	_ = w.w.Write(CSVHeader)
+	return w
+}
+
+func (w *csvWriter) Write(todo model.Todo) error {
+	due := ""
+	if todo.DueAt != nil {
+		due = todo.DueAt.UTC().Format(time.RFC3339)
+	}
+	return w.w.Write([]string{
+		strconv.FormatUint(uint64(todo.ID), 10),
+		todo.Title,
+		todo.Description,
+		todo.Project,
+		strconv.FormatBool(todo.Completed),
+		due,
+		todo.CreatedAt.UTC().Format(time.RFC3339),
+		todo.UpdatedAt.UTC().Format(time.RFC3339),
+	})
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	return w.w.Error()
+}
+
+type jsonlWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func (w *jsonlWriter) Write(todo model.Todo) error {
+	return w.enc.Encode(todo)
+}
+
+func (w *jsonlWriter) Close() error {
+	return w.buf.Flush()
+}
+
+// markdownWriter writes a task list, one item per todo with the description
+// indented under it.
+type markdownWriter struct {
+	buf *bufio.Writer
+}
+
+func newMarkdownWriter(buf *bufio.Writer) *markdownWriter {
+	buf.WriteString("# Todos\n\n")
+	return &markdownWriter{buf: buf}
+}
+
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`,
+	"[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`,
+)
+
+func (w *markdownWriter) Write(todo model.Todo) error {
+	var b strings.Builder
+	box := "[ ]"
+	if todo.Completed {
+		box = "[x]"
+	}
+	b.WriteString("- " + box + " " + markdownEscaper.Replace(singleLine(todo.Title)))
+
+	var details []string
+	if todo.Project != "" {
+		details = append(details, "project: "+markdownEscaper.Replace(singleLine(todo.Project)))
+	}
+	if todo.DueAt != nil {
+		details = append(details, "due: "+todo.DueAt.UTC().Format(time.RFC3339))
+	}
+	if len(details) > 0 {
+		b.WriteString(" (" + strings.Join(details, ", ") + ")")
+	}
+	b.WriteString("\n")
+
+	if todo.Description != "" {
+		for _, line := range strings.Split(strings.ReplaceAll(todo.Description, "\r\n", "\n"), "\n") {
+			b.WriteString("  " + markdownEscaper.Replace(line) + "\n")
+		}
+	}
+	_, err := w.buf.WriteString(b.String())
+	return err
+}
+
+func (w *markdownWriter) Close() error {
+	return w.buf.Flush()
+}
+
+func singleLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+type icalendarWriter struct {
+	buf *bufio.Writer
+	cal *ical.Writer
+}
+
+func newICalendarWriter(buf *bufio.Writer) *icalendarWriter {
+	w := &icalendarWriter{buf: buf, cal: ical.NewWriter(buf)}
+	_ = w.cal.Begin()
+	return w
+}
+
+func (w *icalendarWriter) Write(todo model.Todo) error {
+	return w.cal.WriteTodo(todo)
+}
+
+func (w *icalendarWriter) Close() error {
+	if err := w.cal.End(); err != nil {
+		return err
+	}
+	return w.buf.Flush()
+}
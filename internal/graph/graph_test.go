@@ -0,0 +1,244 @@
+package graph_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/graph"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingService counts the GetHistories calls to check the batching.
+type countingService struct {
+	*service.TodoService
+	historyCalls atomic.Int32
+}
+
+func (s *countingService) GetHistories(ctx context.Context, ids []uint) (map[uint][]model.TodoEvent, error) {
+	s.historyCalls.Add(1)
+	return s.TodoService.GetHistories(ctx, ids)
+}
+
+func newGraph(t *testing.T, opts graph.Options) (*countingService, *eventbus.Bus, http.Handler) {
+	t.Helper()
+
+	repo := repository.NewMemoryTodoRepository()
+	todos := service.NewTodoService(repo, repo.Events(), nil)
+	svc := &countingService{TodoService: todos}
+	bus := eventbus.NewBus(10)
+	return svc, bus, graph.NewHandler(svc, bus, opts)
+}
+
+type gqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func post(t *testing.T, h http.Handler, query string, variables map[string]interface{}) gqlResponse {
+	t.Helper()
+
+	body, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	require.NoError(t, err)
+	req := httptest.NewRequest("POST", "/graphql", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	var resp gqlResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp), w.Body.String())
+	return resp
+}
+
+func TestGraph_MutationsAndQueries(t *testing.T) {
+	_, _, h := newGraph(t, graph.Options{ComplexityLimit: 1000})
+
+	resp := post(t, h, `mutation($title: String!) { createTodo(input: {title: $title, project: "home"}) { id title project completed } }`,
+		map[string]interface{}{"title": "Buy milk"})
+	require.Empty(t, resp.Errors)
+
+	var created struct {
+		CreateTodo struct {
+			ID      string `json:"id"`
+			Title   string `json:"title"`
+			Project string `json:"project"`
+		} `json:"createTodo"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &created))
+	assert.Equal(t, "Buy milk", created.CreateTodo.Title)
+
+	resp = post(t, h, `mutation($id: ID!) { toggleTodo(id: $id) { completed version } }`,
+		map[string]interface{}{"id": created.CreateTodo.ID})
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"toggleTodo": {"completed": true, "version": 2}}`, string(resp.Data))
+
+	resp = post(t, h, `{ stats { total completed } projects { name total todos { title } } }`, nil)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{
+		"stats": {"total": 1, "completed": 1},
+		"projects": [{"name": "home", "total": 1, "todos": [{"title": "Buy milk"}]}]
+	}`, string(resp.Data))
+
+	resp = post(t, h, `{ todo(id: 999) { id } }`, nil)
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "not found")
+}
+
+func TestGraph_DueDates(t *testing.T) {
+	_, _, h := newGraph(t, graph.Options{ComplexityLimit: 1000})
+
+	resp := post(t, h, `mutation { createTodo(input: {title: "Pay rent", dueAt: "2026-11-01T09:00:00Z"}) { id dueAt } }`, nil)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"createTodo": {"id": "1", "dueAt": "2026-11-01T09:00:00Z"}}`, string(resp.Data))
+
+	resp = post(t, h, `mutation { updateTodo(id: 1, input: {dueAt: "2026-11-02T09:00:00Z", clearDueAt: true}) { dueAt } }`, nil)
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "cannot be combined")
+
+	resp = post(t, h, `mutation { updateTodo(id: 1, input: {clearDueAt: true}) { dueAt } }`, nil)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"updateTodo": {"dueAt": null}}`, string(resp.Data))
+}
+
+func TestGraph_HistoryIsBatched(t *testing.T) {
+	svc, _, h := newGraph(t, graph.Options{ComplexityLimit: 1000})
+
+	ctx := context.Background()
+	for _, title := range []string{"A", "B", "C"} {
+		todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: title})
+		require.NoError(t, err)
+		_, err = svc.ToggleTodo(ctx, todo.ID)
+		require.NoError(t, err)
+	}
+
+	resp := post(t, h, `{ todos { title history { revision action } } }`, nil)
+	require.Empty(t, resp.Errors)
+
+	var data struct {
+		Todos []struct {
+			History []struct {
+				Action string `json:"action"`
+			} `json:"history"`
+		} `json:"todos"`
+	}
+	require.NoError(t, json.Unmarshal(resp.Data, &data))
+	require.Len(t, data.Todos, 3)
+	for _, todo := range data.Todos {
+		require.Len(t, todo.History, 2)
+		assert.Equal(t, model.TodoToggled, todo.History[1].Action)
+	}
+	assert.Equal(t, int32(1), svc.historyCalls.Load(), "history of all todos is loaded in one batch")
+}
+
+func TestGraph_ComplexityLimit(t *testing.T) {
+	_, _, h := newGraph(t, graph.Options{ComplexityLimit: 50})
+
+	resp := post(t, h, `{ stats { total } }`, nil)
+	assert.Empty(t, resp.Errors)
+
+	resp = post(t, h, `{ projects { todos { title history { action changes { field } } } } }`, nil)
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "complexity")
+}
+
+func TestGraph_Subscription(t *testing.T) {
+	_, bus, h := newGraph(t, graph.Options{ComplexityLimit: 1000})
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"type": "connection_init"}))
+	var msg struct {
+		Type    string          `json:"type"`
+		Payload json.RawMessage `json:"payload"`
+	}
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.Equal(t, "connection_ack", msg.Type)
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":      "1",
+		"type":    "subscribe",
+		"payload": map[string]interface{}{"query": `subscription { todoChanged(projects: ["work"]) { type todoId todo { title } } }`},
+	}))
+
+	// The subscription starts asynchronously, so keep publishing until the
+	// first event arrives. Events of other projects are filtered out.
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			bus.Publish(eventbus.Event{Type: eventbus.TodoCreated, TodoID: 1, Todo: &model.Todo{ID: 1, Title: "Other", Project: "home"}})
+			bus.Publish(eventbus.Event{Type: eventbus.TodoCreated, TodoID: 2, Todo: &model.Todo{ID: 2, Title: "Report", Project: "work"}})
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}()
+
+	for {
+		require.NoError(t, conn.ReadJSON(&msg))
+		if msg.Type == "ping" {
+			continue
+		}
+		require.Equal(t, "next", msg.Type, string(msg.Payload))
+		assert.JSONEq(t, `{"data": {"todoChanged": {"type": "todo.created", "todoId": "2", "todo": {"title": "Report"}}}}`, string(msg.Payload))
+		return
+	}
+}
+
+func TestGraph_IntrospectionIsOptIn(t *testing.T) {
+	const query = `{ __schema { queryType { name } } }`
+
+	_, _, h := newGraph(t, graph.Options{ComplexityLimit: 1000})
+	resp := post(t, h, query, nil)
+	require.Len(t, resp.Errors, 1)
+	assert.Contains(t, resp.Errors[0].Message, "introspection disabled")
+
+	_, _, h = newGraph(t, graph.Options{ComplexityLimit: 1000, Introspection: true})
+	resp = post(t, h, query, nil)
+	require.Empty(t, resp.Errors)
+	assert.JSONEq(t, `{"__schema": {"queryType": {"name": "Query"}}}`, string(resp.Data))
+}
+
+func TestGraph_SubscriptionOrigins(t *testing.T) {
+	dial := func(opts graph.Options, origin string) error {
+		_, _, h := newGraph(t, opts)
+		srv := httptest.NewServer(h)
+		defer srv.Close()
+
+		dialer := websocket.Dialer{Subprotocols: []string{"graphql-transport-ws"}}
+		header := http.Header{"Origin": []string{origin}}
+		conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+
+	assert.Error(t, dial(graph.Options{}, "https://evil.example"), "other origins are refused by default")
+	assert.NoError(t, dial(graph.Options{AllowedOrigins: []string{"https://app.example"}}, "https://app.example"))
+	assert.Error(t, dial(graph.Options{AllowedOrigins: []string{"https://app.example"}}, "https://evil.example"))
+	assert.NoError(t, dial(graph.Options{AllowedOrigins: []string{"*"}}, "https://evil.example"))
+}
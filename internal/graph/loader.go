@@ -0,0 +1,114 @@
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// historyLoader batches Todo.history lookups into a single GetHistories
+// call, so a list of N todos costs one query instead of N. The resolvers of
+// list fields prime it with the IDs of the todos they return; the first
+// history lookup then loads all primed todos at once, and the others wait
+// for that batch.
+type historyLoader struct {
+	todos service.TodoServiceInterface
+	// ctx is the context of the response the loader belongs to. Batches run
+	// with it rather than with the context of the field that started them.
+	ctx context.Context
+
+	mu      sync.Mutex
+	primed  []uint
+	batches map[uint]*historyBatch
+}
+
+type historyBatch struct {
+	done      chan struct{}
+	histories map[uint][]model.TodoEvent
+	err       error
+}
+
+func newHistoryLoader(ctx context.Context, todos service.TodoServiceInterface) *historyLoader {
+	return &historyLoader{todos: todos, ctx: ctx, batches: make(map[uint]*historyBatch)}
+}
+
+// Prime adds the todos to the next batch.
+func (l *historyLoader) Prime(todos []model.Todo) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, todo := range todos {
+		if l.batches[todo.ID] == nil {
+			l.primed = append(l.primed, todo.ID)
+		}
+	}
+}
+
+// Load returns the history of the todo. It runs the primed batch, or waits
+// for the batch that already covers the todo. Batches are kept for the
+// lifetime of the loader, which is a single response.
+func (l *historyLoader) Load(ctx context.Context, id uint) ([]model.TodoEvent, error) {
+	l.mu.Lock()
+	batch := l.batches[id]
+	if batch == nil {
+		batch = l.start(id)
+	} else {
+		l.mu.Unlock()
+		select {
+		case <-batch.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if batch.err != nil {
+		return nil, batch.err
+	}
+
+	history := batch.histories[id]
+	if history == nil {
+		history = []model.TodoEvent{}
+	}
+	return history, nil
+}
+
+// start runs a batch of the primed todos and id. It is called with mu held
+// and returns with it released.
+func (l *historyLoader) start(id uint) *historyBatch {
+	ids := append(l.primed, id)
+	l.primed = nil
+	batch := &historyBatch{done: make(chan struct{})}
+	for _, id := range ids {
+		l.batches[id] = batch
+	}
+	l.mu.Unlock()
+
+	batch.histories, batch.err = l.todos.GetHistories(l.ctx, ids)
+	close(batch.done)
+	return batch
+}
+
+type loadersKey struct{}
+
+// withLoaders gives every response its own loaders, so batches never mix
+// the data of different requests, nor of different events of one
+// subscription.
+func withLoaders(todos service.TodoServiceInterface) graphql.ResponseMiddleware {
+	return func(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+		return next(context.WithValue(ctx, loadersKey{}, newHistoryLoader(ctx, todos)))
+	}
+}
+
+func historyLoaderFrom(ctx context.Context) *historyLoader {
+	loader, _ := ctx.Value(loadersKey{}).(*historyLoader)
+	return loader
+}
+
+// primeHistories primes the loader of the request, if any, with todos.
+func primeHistories(ctx context.Context, todos []model.Todo) {
+	if loader := historyLoaderFrom(ctx); loader != nil {
+		loader.Prime(todos)
+	}
+}
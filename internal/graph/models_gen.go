@@ -0,0 +1,52 @@
+// Code generated by github.com/99designs/gqlgen, DO NOT EDIT.
+
+package graph
+
+import (
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+type CreateTodoInput struct {
+	Title       string     `json:"title"`
+	Description *string    `json:"description,omitempty"`
+	Project     *string    `json:"project,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+}
+
+type Mutation struct {
+}
+
+type Project struct {
+	Name      string       `json:"name"`
+	Total     int          `json:"total"`
+	Completed int          `json:"completed"`
+	Todos     []model.Todo `json:"todos"`
+}
+
+type Query struct {
+}
+
+type Stats struct {
+	Total          int     `json:"total"`
+	Completed      int     `json:"completed"`
+	Pending        int     `json:"pending"`
+	CompletionRate float64 `json:"completionRate"`
+}
+
+type Subscription struct {
+}
+
+// Unset fields are left unchanged.
+type UpdateTodoInput struct {
+	Title       *string    `json:"title,omitempty"`
+	Description *string    `json:"description,omitempty"`
+	Project     *string    `json:"project,omitempty"`
+	Completed   *bool      `json:"completed,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	// Removes the due date. Cannot be combined with dueAt.
+	ClearDueAt *bool `json:"clearDueAt,omitempty"`
+	// Removes the description. Cannot be combined with description.
+	ClearDescription *bool `json:"clearDescription,omitempty"`
+}
@@ -0,0 +1,26 @@
+package graph
+
+//go:generate go run github.com/99designs/gqlgen generate
+
+import (
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// Resolver is the root of the GraphQL resolvers. It only talks to the
+// service layer, like the REST handlers do.
+type Resolver struct {
+	todos service.TodoServiceInterface
+	bus   *eventbus.Bus
+}
+
+// subscriptionBuffer is how many events a subscription may fall behind
+// before the bus drops it.
+const subscriptionBuffer = 64
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
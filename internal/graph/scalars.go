@@ -0,0 +1,43 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/99designs/gqlgen/graphql"
+)
+
+// MarshalID writes a database ID as a GraphQL ID, which is serialized as a
+// string.
+func MarshalID(id uint) graphql.Marshaler {
+	return graphql.WriterFunc(func(w io.Writer) {
+		_, _ = io.WriteString(w, strconv.Quote(strconv.FormatUint(uint64(id), 10)))
+	})
+}
+
+// UnmarshalID accepts an ID given as a string or as a number.
+func UnmarshalID(v interface{}) (uint, error) {
+	var raw string
+	switch v := v.(type) {
+	case string:
+		raw = v
+	case json.Number:
+		raw = v.String()
+	case int64:
+		raw = strconv.FormatInt(v, 10)
+	case int:
+		raw = strconv.Itoa(v)
+	case float64:
+		raw = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return 0, fmt.Errorf("%T is not a valid ID", v)
+	}
+
+	id, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid ID", raw)
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,231 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen version v0.17.66
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+// CreateTodo is the resolver for the createTodo field.
+func (r *mutationResolver) CreateTodo(ctx context.Context, input CreateTodoInput) (*model.Todo, error) {
+	return r.todos.CreateTodo(ctx, model.CreateTodoRequest{
+		Title:       input.Title,
+		Description: deref(input.Description),
+		Project:     deref(input.Project),
+		DueAt:       input.DueAt,
+	})
+}
+
+// UpdateTodo is the resolver for the updateTodo field.
+func (r *mutationResolver) UpdateTodo(ctx context.Context, id uint, input UpdateTodoInput) (*model.Todo, error) {
+	return r.todos.UpdateTodo(ctx, id, model.UpdateTodoRequest{
+		Title:            deref(input.Title),
+		Description:      deref(input.Description),
+		ClearDescription: input.ClearDescription != nil && *input.ClearDescription,
+		Project:          deref(input.Project),
+		Completed:        input.Completed,
+		DueAt:            input.DueAt,
+		ClearDueAt:       input.ClearDueAt != nil && *input.ClearDueAt,
+	})
+}
+
+// DeleteTodo is the resolver for the deleteTodo field.
+func (r *mutationResolver) DeleteTodo(ctx context.Context, id uint) (*model.Todo, error) {
+	return r.todos.DeleteTodo(ctx, id)
+}
+
+// ToggleTodo is the resolver for the toggleTodo field.
+func (r *mutationResolver) ToggleTodo(ctx context.Context, id uint) (*model.Todo, error) {
+	return r.todos.ToggleTodo(ctx, id)
+}
+
+// MarkAllCompleted is the resolver for the markAllCompleted field.
+func (r *mutationResolver) MarkAllCompleted(ctx context.Context) ([]model.Todo, error) {
+	todos, err := r.todos.MarkAllCompleted(ctx)
+	primeHistories(ctx, todos)
+	return todos, err
+}
+
+// DeleteCompleted is the resolver for the deleteCompleted field.
+func (r *mutationResolver) DeleteCompleted(ctx context.Context) (bool, error) {
+	if err := r.todos.DeleteCompleted(ctx); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RestoreTodo is the resolver for the restoreTodo field.
+func (r *mutationResolver) RestoreTodo(ctx context.Context, id uint) (*model.Todo, error) {
+	return r.todos.RestoreTodo(ctx, id)
+}
+
+// PurgeTodo is the resolver for the purgeTodo field.
+func (r *mutationResolver) PurgeTodo(ctx context.Context, id uint) (bool, error) {
+	if err := r.todos.PurgeTodo(ctx, id); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// RevertTodo is the resolver for the revertTodo field.
+func (r *mutationResolver) RevertTodo(ctx context.Context, id uint, revision int) (*model.Todo, error) {
+	return r.todos.RevertTodo(ctx, id, uint(revision))
+}
+
+// Todos is the resolver for the todos field.
+func (r *queryResolver) Todos(ctx context.Context, completed *bool, project *string) ([]model.Todo, error) {
+	var todos []model.Todo
+	var err error
+	if completed != nil {
+		todos, err = r.todos.GetTodosByCompleted(ctx, *completed)
+	} else {
+		todos, err = r.todos.GetAllTodos(ctx)
+	}
+	if err != nil || project == nil {
+		primeHistories(ctx, todos)
+		return todos, err
+	}
+
+	filtered := []model.Todo{}
+	for _, todo := range todos {
+		if todo.Project == *project {
+			filtered = append(filtered, todo)
+		}
+	}
+	primeHistories(ctx, filtered)
+	return filtered, nil
+}
+
+// Todo is the resolver for the todo field.
+func (r *queryResolver) Todo(ctx context.Context, id uint) (*model.Todo, error) {
+	return r.todos.GetTodoByID(ctx, id)
+}
+
+// Trash is the resolver for the trash field.
+func (r *queryResolver) Trash(ctx context.Context) ([]model.Todo, error) {
+	todos, err := r.todos.GetTrash(ctx)
+	primeHistories(ctx, todos)
+	return todos, err
+}
+
+// Stats is the resolver for the stats field.
+func (r *queryResolver) Stats(ctx context.Context) (*Stats, error) {
+	stats, err := r.todos.GetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	total, _ := stats["total"].(int)
+	completed, _ := stats["completed"].(int)
+	pending, _ := stats["pending"].(int)
+	rate, _ := stats["completion_rate"].(float64)
+	return &Stats{Total: total, Completed: completed, Pending: pending, CompletionRate: rate}, nil
+}
+
+// Projects is the resolver for the projects field. Todos without a
+// project are grouped under the empty name.
+func (r *queryResolver) Projects(ctx context.Context) ([]Project, error) {
+	todos, err := r.todos.GetAllTodos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	primeHistories(ctx, todos)
+
+	byName := make(map[string]*Project)
+	for _, todo := range todos {
+		project, ok := byName[todo.Project]
+		if !ok {
+			project = &Project{Name: todo.Project}
+			byName[todo.Project] = project
+		}
+		project.Total++
+		if todo.Completed {
+			project.Completed++
+		}
+		project.Todos = append(project.Todos, todo)
+	}
+
+	projects := make([]Project, 0, len(byName))
+	for _, project := range byName {
+		projects = append(projects, *project)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects, nil
+}
+
+// TodoChanged is the resolver for the todoChanged field.
+func (r *subscriptionResolver) TodoChanged(ctx context.Context, projects []string, todoIds []uint) (<-chan *eventbus.Event, error) {
+	sub, _, _ := r.bus.Subscribe(0, subscriptionBuffer)
+	filter := eventbus.NewFilter(projects, todoIds)
+
+	changes := make(chan *eventbus.Event)
+	go func() {
+		defer close(changes)
+		defer sub.Close()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				if !filter.Matches(event) {
+					continue
+				}
+				select {
+				case changes <- &event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// DeletedAt is the resolver for the deletedAt field.
+func (r *todoResolver) DeletedAt(ctx context.Context, obj *model.Todo) (*time.Time, error) {
+	if !obj.DeletedAt.Valid {
+		return nil, nil
+	}
+	return &obj.DeletedAt.Time, nil
+}
+
+// History is the resolver for the history field.
+func (r *todoResolver) History(ctx context.Context, obj *model.Todo) ([]model.TodoEvent, error) {
+	if loader := historyLoaderFrom(ctx); loader != nil {
+		return loader.Load(ctx, obj.ID)
+	}
+
+	histories, err := r.todos.GetHistories(ctx, []uint{obj.ID})
+	if err != nil {
+		return nil, err
+	}
+	return append([]model.TodoEvent{}, histories[obj.ID]...), nil
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// Todo returns TodoResolver implementation.
+func (r *Resolver) Todo() TodoResolver { return &todoResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type todoResolver struct{ *Resolver }
@@ -0,0 +1,62 @@
+package graph
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gorilla/websocket"
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/origin"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// listComplexity is the number of elements a list field is assumed to
+// return when scoring a query against the complexity limit.
+const listComplexity = 10
+
+// Options configure the GraphQL handler.
+type Options struct {
+	// ComplexityLimit rejects queries scoring higher before they run.
+	ComplexityLimit int
+	// AllowedOrigins are the origins browsers may open subscriptions from.
+	// Empty allows only the server's own origin, "*" allows any.
+	AllowedOrigins []string
+	// Introspection lets clients query the schema, which the playground
+	// needs.
+	Introspection bool
+}
+
+// NewHandler serves the GraphQL API over HTTP GET/POST and over WebSocket
+// for subscriptions.
+func NewHandler(todos service.TodoServiceInterface, bus *eventbus.Bus, opts Options) http.Handler {
+	cfg := Config{Resolvers: &Resolver{todos: todos, bus: bus}}
+
+	list := func(childComplexity int) int { return listComplexity * (childComplexity + 1) }
+	cfg.Complexity.Query.Todos = func(childComplexity int, _ *bool, _ *string) int { return list(childComplexity) }
+	cfg.Complexity.Query.Trash = list
+	cfg.Complexity.Query.Projects = list
+	cfg.Complexity.Project.Todos = list
+	cfg.Complexity.Todo.History = list
+	cfg.Complexity.Mutation.MarkAllCompleted = list
+
+	srv := handler.New(NewExecutableSchema(cfg))
+	srv.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: origin.Check(opts.AllowedOrigins),
+		},
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	if opts.Introspection {
+		srv.Use(extension.Introspection{})
+	}
+	srv.Use(extension.FixedComplexityLimit(opts.ComplexityLimit))
+	srv.AroundResponses(withLoaders(todos))
+
+	return srv
+}
@@ -0,0 +1,173 @@
+package grpcserver
+
+import (
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	todov1 "github.com/stavagg/petGoApi/internal/gen/todo/v1"
+	"github.com/stavagg/petGoApi/internal/model"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toTodo(todo *model.Todo) *todov1.Todo {
+	if todo == nil {
+		return nil
+	}
+
+	out := &todov1.Todo{
+		Id:          uint32(todo.ID),
+		Title:       todo.Title,
+		Description: todo.Description,
+		Project:     todo.Project,
+		Completed:   todo.Completed,
+		Version:     uint32(todo.Version),
+		CreatedAt:   timestamppb.New(todo.CreatedAt),
+		UpdatedAt:   timestamppb.New(todo.UpdatedAt),
+	}
+	if todo.DeletedAt.Valid {
+		out.DeletedAt = timestamppb.New(todo.DeletedAt.Time)
+	}
+	if todo.DueAt != nil {
+		out.DueAt = timestamppb.New(*todo.DueAt)
+	}
+	if todo.ExternalID != nil {
+		out.ExternalId = *todo.ExternalID
+	}
+	return out
+}
+
+func toTodoPage(todos []model.Todo, total int) *todov1.TodoPage {
+	return &todov1.TodoPage{Todos: toTodoList(todos).Todos, Total: int64(total)}
+}
+
+func toProjects(summaries []model.ProjectSummary) *todov1.ProjectList {
+	out := &todov1.ProjectList{Projects: make([]*todov1.ProjectSummary, 0, len(summaries))}
+	for _, summary := range summaries {
+		out.Projects = append(out.Projects, &todov1.ProjectSummary{
+			Project:    summary.Project,
+			Todos:      summary.Todos,
+			IdSum:      summary.IDSum,
+			VersionSum: summary.VersionSum,
+		})
+	}
+	return out
+}
+
+// toPagination converts a page, which is nil for the whole list. The
+// limit is bounded like the limit query parameter of the REST lists.
+func toPagination(page *todov1.Page) (*model.Pagination, error) {
+	if page == nil {
+		return nil, nil
+	}
+	if page.GetLimit() == 0 || page.GetLimit() > maxPageLimit {
+		return nil, status.Errorf(codes.InvalidArgument, "page limit must be between 1 and %d", maxPageLimit)
+	}
+	return &model.Pagination{Limit: int(page.GetLimit()), Offset: int(page.GetOffset())}, nil
+}
+
+func toTodoList(todos []model.Todo) *todov1.TodoList {
+	out := &todov1.TodoList{Todos: make([]*todov1.Todo, 0, len(todos))}
+	for i := range todos {
+		out.Todos = append(out.Todos, toTodo(&todos[i]))
+	}
+	return out
+}
+
+func toHistory(events []model.TodoEvent) *todov1.History {
+	out := &todov1.History{Events: make([]*todov1.TodoEvent, 0, len(events))}
+	for _, event := range events {
+		changes := make([]*todov1.FieldChange, 0, len(event.Changes))
+		for _, change := range event.Changes {
+			changes = append(changes, &todov1.FieldChange{
+				Field: change.Field,
+				From:  toValue(change.From),
+				To:    toValue(change.To),
+			})
+		}
+		out.Events = append(out.Events, &todov1.TodoEvent{
+			Id:        uint32(event.ID),
+			TodoId:    uint32(event.TodoID),
+			Revision:  uint32(event.Revision),
+			Action:    event.Action,
+			Actor:     event.Actor,
+			Changes:   changes,
+			CreatedAt: timestamppb.New(event.CreatedAt),
+		})
+	}
+	return out
+}
+
+func toConflicts(conflicts []model.FieldConflict) []*todov1.FieldConflict {
+	out := make([]*todov1.FieldConflict, 0, len(conflicts))
+	for _, conflict := range conflicts {
+		out = append(out, &todov1.FieldConflict{
+			Field:            conflict.Field,
+			Server:           toValue(conflict.Server),
+			Client:           toValue(conflict.Client),
+			ServerModifiedAt: timestamppb.New(conflict.ServerModifiedAt),
+		})
+	}
+	return out
+}
+
+func toChange(event eventbus.Event) *todov1.TodoChange {
+	return &todov1.TodoChange{
+		Type:            event.Type,
+		TodoId:          uint32(event.TodoID),
+		Actor:           event.Actor,
+		OccurredAt:      timestamppb.New(event.OccurredAt),
+		Todo:            toTodo(event.Todo),
+		PreviousProject: event.PreviousProject,
+	}
+}
+
+// toValue converts a field value of a change or conflict. Values that
+// structpb cannot represent are sent as null.
+func toValue(v interface{}) *structpb.Value {
+	value, err := structpb.NewValue(v)
+	if err != nil {
+		return structpb.NewNullValue()
+	}
+	return value
+}
+
+func toUpdateRequest(req *todov1.UpdateTodoRequest) model.UpdateTodoRequest {
+	return model.UpdateTodoRequest{
+		Title:            req.GetTitle(),
+		Description:      req.GetDescription(),
+		ClearDescription: req.GetClearDescription(),
+		Project:          req.GetProject(),
+		Completed:        req.Completed,
+		DueAt:            toTime(req.GetDueAt()),
+		ClearDueAt:       req.GetClearDueAt(),
+	}
+}
+
+func toCreateRequest(req *todov1.CreateTodoRequest) model.CreateTodoRequest {
+	return model.CreateTodoRequest{
+		Title:       req.GetTitle(),
+		Description: req.GetDescription(),
+		Project:     req.GetProject(),
+		DueAt:       toTime(req.GetDueAt()),
+	}
+}
+
+// toTime returns nil for an unset timestamp.
+func toTime(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func toIDs(ids []uint32) []uint {
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, uint(id))
+	}
+	return result
+}
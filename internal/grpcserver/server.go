@@ -0,0 +1,426 @@
+// Package grpcserver serves the todo.v1 gRPC API on top of the service
+// layer.
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	todov1 "github.com/stavagg/petGoApi/internal/gen/todo/v1"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// ActorMetadata is the metadata key that names the author of a change,
+// like the X-Actor header of the REST API.
+const ActorMetadata = "x-actor"
+
+// maxPageLimit bounds the limit of a page, like the REST lists.
+const maxPageLimit = 1000
+
+// watchBuffer is how many events a WatchTodos stream may fall behind before
+// the bus drops it.
+const watchBuffer = 64
+
+// AuthorizationMetadata carries the API key as "Bearer KEY", like the
+// Authorization header of the REST API.
+const AuthorizationMetadata = "authorization"
+
+// New returns a gRPC server with the todo service, health checking and
+// reflection registered. With users, the todo service requires an API key.
+func New(todos service.TodoServiceInterface, bus *eventbus.Bus, users service.UserServiceInterface) *grpc.Server {
+	unary := []grpc.UnaryServerInterceptor{actorUnary}
+	stream := []grpc.StreamServerInterceptor{actorStream}
+	if users != nil {
+		a := authenticator{users: users}
+		unary = append([]grpc.UnaryServerInterceptor{a.unary}, unary...)
+		stream = append([]grpc.StreamServerInterceptor{a.stream}, stream...)
+	}
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	)
+
+	todov1.RegisterTodoServiceServer(srv, &Server{todos: todos, bus: bus})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(todov1.TodoService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthServer)
+
+	reflection.Register(srv)
+	return srv
+}
+
+// Server implements todov1.TodoServiceServer.
+type Server struct {
+	todov1.UnimplementedTodoServiceServer
+
+	todos service.TodoServiceInterface
+	bus   *eventbus.Bus
+}
+
+func (s *Server) CreateTodo(ctx context.Context, req *todov1.CreateTodoRequest) (*todov1.Todo, error) {
+	todo, err := s.todos.CreateTodo(ctx, toCreateRequest(req))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodo(todo), nil
+}
+
+func (s *Server) GetAllTodos(ctx context.Context, _ *todov1.GetAllTodosRequest) (*todov1.TodoList, error) {
+	todos, err := s.todos.GetAllTodos(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodoList(todos), nil
+}
+
+func (s *Server) ListTodos(ctx context.Context, req *todov1.ListTodosRequest) (*todov1.TodoPage, error) {
+	page, err := toPagination(req.GetPage())
+	if err != nil {
+		return nil, err
+	}
+	todos, total, err := s.todos.ListTodos(ctx, req.Completed, page)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodoPage(todos, total), nil
+}
+
+func (s *Server) GetTodo(ctx context.Context, req *todov1.GetTodoRequest) (*todov1.Todo, error) {
+	todo, err := s.todos.GetTodoByID(ctx, uint(req.GetId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodo(todo), nil
+}
+
+func (s *Server) GetTodoByExternalID(ctx context.Context, req *todov1.GetTodoByExternalIDRequest) (*todov1.Todo, error) {
+	todo, err := s.todos.GetTodoByExternalID(ctx, req.GetExternalId())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodo(todo), nil
+}
+
+func (s *Server) UpdateTodo(ctx context.Context, req *todov1.UpdateTodoRequest) (*todov1.Todo, error) {
+	todo, err := s.todos.UpdateTodo(ctx, uint(req.GetId()), toUpdateRequest(req))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodo(todo), nil
+}
+
+func (s *Server) DeleteTodo(ctx context.Context, req *todov1.DeleteTodoRequest) (*todov1.Todo, error) {
+	todo, err := s.todos.DeleteTodo(ctx, uint(req.GetId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodo(todo), nil
+}
+
+func (s *Server) GetTodosByCompleted(ctx context.Context, req *todov1.GetTodosByCompletedRequest) (*todov1.TodoList, error) {
+	todos, err := s.todos.GetTodosByCompleted(ctx, req.GetCompleted())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodoList(todos), nil
+}
+
+func (s *Server) GetTodosByProject(ctx context.Context, req *todov1.GetTodosByProjectRequest) (*todov1.TodoList, error) {
+	todos, err := s.todos.GetTodosByProject(ctx, req.GetProject())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodoList(todos), nil
+}
+
+func (s *Server) GetProjects(ctx context.Context, _ *todov1.GetProjectsRequest) (*todov1.ProjectList, error) {
+	summaries, err := s.todos.GetProjects(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toProjects(summaries), nil
+}
+
+func (s *Server) ExportTodos(req *todov1.ExportTodosRequest, stream grpc.ServerStreamingServer[todov1.Todo]) error {
+	err := s.todos.ExportTodos(stream.Context(), req.Completed, func(todo model.Todo) error {
+		return stream.Send(toTodo(&todo))
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return err
+		}
+		return statusError(err)
+	}
+	return nil
+}
+
+func (s *Server) GetStats(ctx context.Context, _ *todov1.GetStatsRequest) (*todov1.Stats, error) {
+	stats, err := s.todos.GetStats(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	total, _ := stats["total"].(int)
+	completed, _ := stats["completed"].(int)
+	pending, _ := stats["pending"].(int)
+	rate, _ := stats["completion_rate"].(float64)
+	return &todov1.Stats{
+		Total:          int64(total),
+		Completed:      int64(completed),
+		Pending:        int64(pending),
+		CompletionRate: rate,
+	}, nil
+}
+
+func (s *Server) ToggleTodo(ctx context.Context, req *todov1.ToggleTodoRequest) (*todov1.Todo, error) {
+	todo, err := s.todos.ToggleTodo(ctx, uint(req.GetId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodo(todo), nil
+}
+
+func (s *Server) MarkAllCompleted(ctx context.Context, _ *todov1.MarkAllCompletedRequest) (*todov1.TodoList, error) {
+	todos, err := s.todos.MarkAllCompleted(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodoList(todos), nil
+}
+
+func (s *Server) DeleteCompleted(ctx context.Context, _ *todov1.DeleteCompletedRequest) (*todov1.DeleteCompletedResponse, error) {
+	if err := s.todos.DeleteCompleted(ctx); err != nil {
+		return nil, statusError(err)
+	}
+	return &todov1.DeleteCompletedResponse{}, nil
+}
+
+func (s *Server) GetTrash(ctx context.Context, _ *todov1.GetTrashRequest) (*todov1.TodoList, error) {
+	todos, err := s.todos.GetTrash(ctx)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodoList(todos), nil
+}
+
+func (s *Server) ListTrash(ctx context.Context, req *todov1.ListTrashRequest) (*todov1.TodoPage, error) {
+	page, err := toPagination(req.GetPage())
+	if err != nil {
+		return nil, err
+	}
+	todos, total, err := s.todos.ListTrash(ctx, page)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodoPage(todos, total), nil
+}
+
+func (s *Server) RestoreTodo(ctx context.Context, req *todov1.RestoreTodoRequest) (*todov1.Todo, error) {
+	todo, err := s.todos.RestoreTodo(ctx, uint(req.GetId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodo(todo), nil
+}
+
+func (s *Server) PurgeTodo(ctx context.Context, req *todov1.PurgeTodoRequest) (*todov1.PurgeTodoResponse, error) {
+	if err := s.todos.PurgeTodo(ctx, uint(req.GetId())); err != nil {
+		return nil, statusError(err)
+	}
+	return &todov1.PurgeTodoResponse{}, nil
+}
+
+func (s *Server) PurgeTrash(ctx context.Context, req *todov1.PurgeTrashRequest) (*todov1.PurgeTrashResponse, error) {
+	if req.GetRetentionSeconds() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "retention_seconds must not be negative")
+	}
+
+	purged, err := s.todos.PurgeTrash(ctx, time.Duration(req.GetRetentionSeconds())*time.Second)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &todov1.PurgeTrashResponse{Purged: purged}, nil
+}
+
+func (s *Server) GetHistory(ctx context.Context, req *todov1.GetHistoryRequest) (*todov1.History, error) {
+	events, err := s.todos.GetHistory(ctx, uint(req.GetId()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toHistory(events), nil
+}
+
+func (s *Server) ListHistory(ctx context.Context, req *todov1.ListHistoryRequest) (*todov1.HistoryPage, error) {
+	page, err := toPagination(req.GetPage())
+	if err != nil {
+		return nil, err
+	}
+	events, total, err := s.todos.ListHistory(ctx, uint(req.GetId()), page)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &todov1.HistoryPage{Events: toHistory(events).Events, Total: int64(total)}, nil
+}
+
+func (s *Server) GetHistories(ctx context.Context, req *todov1.GetHistoriesRequest) (*todov1.GetHistoriesResponse, error) {
+	ids := make([]uint, 0, len(req.GetIds()))
+	for _, id := range req.GetIds() {
+		ids = append(ids, uint(id))
+	}
+
+	histories, err := s.todos.GetHistories(ctx, ids)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	out := &todov1.GetHistoriesResponse{Histories: make(map[uint32]*todov1.History, len(histories))}
+	for id, events := range histories {
+		out.Histories[uint32(id)] = toHistory(events)
+	}
+	return out, nil
+}
+
+func (s *Server) RevertTodo(ctx context.Context, req *todov1.RevertTodoRequest) (*todov1.Todo, error) {
+	todo, err := s.todos.RevertTodo(ctx, uint(req.GetId()), uint(req.GetRevision()))
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return toTodo(todo), nil
+}
+
+func (s *Server) MergeTodo(ctx context.Context, req *todov1.MergeTodoRequest) (*todov1.MergeTodoResponse, error) {
+	if req.GetUpdate() == nil || req.GetBase() == nil {
+		return nil, status.Error(codes.InvalidArgument, "update and base are required")
+	}
+
+	todo, conflicts, err := s.todos.MergeTodo(ctx, uint(req.GetUpdate().GetId()), toUpdateRequest(req.GetUpdate()), req.GetBase().AsTime())
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &todov1.MergeTodoResponse{Todo: toTodo(todo), Conflicts: toConflicts(conflicts)}, nil
+}
+
+// WatchTodos streams the changes matching the request until the client
+// goes away. A client that falls too far behind is disconnected with
+// ResourceExhausted and should call again.
+func (s *Server) WatchTodos(req *todov1.WatchTodosRequest, stream grpc.ServerStreamingServer[todov1.TodoChange]) error {
+	sub, _, _ := s.bus.Subscribe(0, watchBuffer)
+	defer sub.Close()
+
+	filter := eventbus.NewFilter(req.GetProjects(), toIDs(req.GetTodoIds()))
+	ctx := stream.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell behind, call WatchTodos again")
+			}
+			if !filter.Matches(event) {
+				continue
+			}
+			if err := stream.Send(toChange(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// statusError maps the service errors to gRPC codes.
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrTodoNotFound),
+		errors.Is(err, service.ErrNotInTrash),
+		errors.Is(err, service.ErrRevisionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrVersionConflict):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, service.ErrInvalidTodo):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// authenticator requires an API key for the methods of the todo service.
+// Health checks and reflection stay open.
+type authenticator struct {
+	users service.UserServiceInterface
+}
+
+func (a authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a authenticator) stream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(stream.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &actorServerStream{ServerStream: stream, ctx: ctx})
+}
+
+func (a authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if !strings.HasPrefix(method, "/"+todov1.TodoService_ServiceDesc.ServiceName+"/") {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var key string
+	if values := md.Get(AuthorizationMetadata); len(values) > 0 {
+		key, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	user, err := a.users.Authenticate(ctx, key)
+	if errors.Is(err, service.ErrInvalidAPIKey) {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return service.WithUser(ctx, user), nil
+}
+
+func actorUnary(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withActor(ctx), req)
+}
+
+func actorStream(srv interface{}, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &actorServerStream{ServerStream: stream, ctx: withActor(stream.Context())})
+}
+
+type actorServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *actorServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func withActor(ctx context.Context) context.Context {
+	if service.UserFromContext(ctx) != nil {
+		return ctx
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(ActorMetadata); len(values) > 0 && values[0] != "" {
+		return service.WithActor(ctx, values[0])
+	}
+	return ctx
+}
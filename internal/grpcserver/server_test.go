@@ -0,0 +1,307 @@
+package grpcserver_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	todov1 "github.com/stavagg/petGoApi/internal/gen/todo/v1"
+	"github.com/stavagg/petGoApi/internal/grpcserver"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type fixture struct {
+	bus    *eventbus.Bus
+	conn   *grpc.ClientConn
+	client todov1.TodoServiceClient
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	repo := repository.NewMemoryTodoRepository()
+	return newFixtureWith(t, service.NewTodoService(repo, repo.Events(), nil), nil)
+}
+
+func newFixtureWith(t *testing.T, todos service.TodoServiceInterface, users service.UserServiceInterface) *fixture {
+	t.Helper()
+
+	bus := eventbus.NewBus(10)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpcserver.New(todos, bus, users)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return &fixture{bus: bus, conn: conn, client: todov1.NewTodoServiceClient(conn)}
+}
+
+func TestServer_TodoLifecycle(t *testing.T) {
+	f := newFixture(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), grpcserver.ActorMetadata, "alice")
+
+	created, err := f.client.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "Write proto", Project: "api"})
+	require.NoError(t, err)
+	assert.NotZero(t, created.GetId())
+	assert.Equal(t, "api", created.GetProject())
+
+	completed := true
+	updated, err := f.client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: created.GetId(), Title: "Write todo.proto", Completed: &completed})
+	require.NoError(t, err)
+	assert.Equal(t, "Write todo.proto", updated.GetTitle())
+	assert.True(t, updated.GetCompleted())
+	assert.Equal(t, "api", updated.GetProject(), "unset fields are left unchanged")
+
+	stats, err := f.client.GetStats(ctx, &todov1.GetStatsRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.GetTotal())
+	assert.Equal(t, int64(1), stats.GetCompleted())
+
+	history, err := f.client.GetHistory(ctx, &todov1.GetHistoryRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	require.Len(t, history.GetEvents(), 2)
+	assert.Equal(t, "alice", history.GetEvents()[1].GetActor(), "the actor comes from metadata")
+
+	deleted, err := f.client.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, created.GetId(), deleted.GetId())
+
+	trash, err := f.client.GetTrash(ctx, &todov1.GetTrashRequest{})
+	require.NoError(t, err)
+	require.Len(t, trash.GetTodos(), 1)
+	assert.NotNil(t, trash.GetTodos()[0].GetDeletedAt())
+
+	restored, err := f.client.RestoreTodo(ctx, &todov1.RestoreTodoRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Nil(t, restored.GetDeletedAt())
+}
+
+func TestServer_DueDates(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+	due := time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)
+
+	created, err := f.client.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "Pay rent", DueAt: timestamppb.New(due)})
+	require.NoError(t, err)
+	assert.True(t, due.Equal(created.GetDueAt().AsTime()))
+
+	_, err = f.client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: created.GetId(), DueAt: timestamppb.New(due), ClearDueAt: true})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	updated, err := f.client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: created.GetId(), ClearDueAt: true})
+	require.NoError(t, err)
+	assert.Nil(t, updated.GetDueAt())
+}
+
+func TestServer_ListsAndExport(t *testing.T) {
+	repo := repository.NewMemoryTodoRepository()
+	svc := service.NewTodoService(repo, repo.Events(), nil)
+	f := newFixtureWith(t, svc, nil)
+	ctx := context.Background()
+
+	_, err := svc.ImportTodos(ctx, []model.ImportRow{
+		{Line: 1, ExternalID: "gh-1", Request: model.CreateTodoRequest{Title: "Imported", Project: "work"}},
+	}, false)
+	require.NoError(t, err)
+	for _, title := range []string{"Second", "Third"} {
+		_, err := f.client.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: title, Project: "work"})
+		require.NoError(t, err)
+	}
+
+	byExternalID, err := f.client.GetTodoByExternalID(ctx, &todov1.GetTodoByExternalIDRequest{ExternalId: "gh-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "Imported", byExternalID.GetTitle())
+	assert.Equal(t, "gh-1", byExternalID.GetExternalId())
+	_, err = f.client.GetTodoByExternalID(ctx, &todov1.GetTodoByExternalIDRequest{ExternalId: "gh-2"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	page, err := f.client.ListTodos(ctx, &todov1.ListTodosRequest{Page: &todov1.Page{Limit: 2}})
+	require.NoError(t, err)
+	assert.Len(t, page.GetTodos(), 2)
+	assert.Equal(t, int64(3), page.GetTotal())
+	all, err := f.client.ListTodos(ctx, &todov1.ListTodosRequest{})
+	require.NoError(t, err)
+	assert.Len(t, all.GetTodos(), 3, "without a page the whole list is returned")
+	_, err = f.client.ListTodos(ctx, &todov1.ListTodosRequest{Page: &todov1.Page{Limit: 1001}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	project, err := f.client.GetTodosByProject(ctx, &todov1.GetTodosByProjectRequest{Project: "work"})
+	require.NoError(t, err)
+	assert.Len(t, project.GetTodos(), 3)
+	projects, err := f.client.GetProjects(ctx, &todov1.GetProjectsRequest{})
+	require.NoError(t, err)
+	require.Len(t, projects.GetProjects(), 1)
+	assert.Equal(t, "work", projects.GetProjects()[0].GetProject())
+	assert.Equal(t, int64(3), projects.GetProjects()[0].GetTodos())
+
+	history, err := f.client.ListHistory(ctx, &todov1.ListHistoryRequest{Id: byExternalID.GetId(), Page: &todov1.Page{Limit: 10}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), history.GetTotal())
+
+	_, err = f.client.DeleteTodo(ctx, &todov1.DeleteTodoRequest{Id: byExternalID.GetId()})
+	require.NoError(t, err)
+	trash, err := f.client.ListTrash(ctx, &todov1.ListTrashRequest{Page: &todov1.Page{Limit: 10}})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), trash.GetTotal())
+
+	stream, err := f.client.ExportTodos(ctx, &todov1.ExportTodosRequest{})
+	require.NoError(t, err)
+	var exported []string
+	for {
+		todo, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		exported = append(exported, todo.GetTitle())
+	}
+	assert.Equal(t, []string{"Second", "Third"}, exported, "trashed todos are not exported")
+}
+
+func TestServer_ErrorCodes(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	_, err := f.client.GetTodo(ctx, &todov1.GetTodoRequest{Id: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = f.client.CreateTodo(ctx, &todov1.CreateTodoRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = f.client.PurgeTodo(ctx, &todov1.PurgeTodoRequest{Id: 42})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = f.client.MergeTodo(ctx, &todov1.MergeTodoRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	created, err := f.client.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+	long := strings.Repeat("я", 256)
+	_, err = f.client.UpdateTodo(ctx, &todov1.UpdateTodoRequest{Id: created.GetId(), Title: long})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+// brokenService fails like a service whose database is down.
+type brokenService struct {
+	service.TodoServiceInterface
+}
+
+func (brokenService) CreateTodo(context.Context, model.CreateTodoRequest) (*model.Todo, error) {
+	return nil, errors.New("failed to create todo: connection refused")
+}
+
+func TestServer_StorageErrorsAreInternal(t *testing.T) {
+	f := newFixtureWith(t, brokenService{}, nil)
+
+	_, err := f.client.CreateTodo(context.Background(), &todov1.CreateTodoRequest{Title: "Task"})
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestServer_RequiresAPIKeys(t *testing.T) {
+	users := service.NewUserService(repository.NewMemoryUserRepository())
+	ctx := context.Background()
+	_, err := users.CreateUser(ctx, "alice")
+	require.NoError(t, err)
+	key, _, err := users.CreateAPIKey(ctx, "alice")
+	require.NoError(t, err)
+	repo := repository.NewMemoryTodoRepository()
+	f := newFixtureWith(t, service.NewTodoService(repo, repo.Events(), nil), users)
+
+	_, err = f.client.CreateTodo(ctx, &todov1.CreateTodoRequest{Title: "Task"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	_, err = healthpb.NewHealthClient(f.conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err, "health checks need no key")
+
+	authed := metadata.AppendToOutgoingContext(ctx, grpcserver.AuthorizationMetadata, "Bearer "+key, grpcserver.ActorMetadata, "mallory")
+	created, err := f.client.CreateTodo(authed, &todov1.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+	history, err := f.client.GetHistory(authed, &todov1.GetHistoryRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", history.GetEvents()[0].GetActor(), "changes are attributed to the key's user")
+
+	stream, err := f.client.WatchTodos(ctx, &todov1.WatchTodosRequest{})
+	require.NoError(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestServer_WatchTodos(t *testing.T) {
+	f := newFixture(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := f.client.WatchTodos(ctx, &todov1.WatchTodosRequest{Projects: []string{"work"}})
+	require.NoError(t, err)
+
+	// The subscription is set up once the call reaches the server, so
+	// keep publishing until the first change arrives.
+	received := make(chan *todov1.TodoChange, 1)
+	go func() {
+		change, err := stream.Recv()
+		if err == nil {
+			received <- change
+		}
+	}()
+
+	for {
+		f.bus.Publish(eventbus.Event{Type: eventbus.TodoCreated, TodoID: 1, Todo: &model.Todo{ID: 1, Project: "home"}})
+		f.bus.Publish(eventbus.Event{Type: eventbus.TodoCreated, TodoID: 2, Todo: &model.Todo{ID: 2, Project: "work"}})
+		select {
+		case change := <-received:
+			assert.Equal(t, eventbus.TodoCreated, change.GetType())
+			assert.Equal(t, uint32(2), change.GetTodoId(), "changes of other projects are filtered out")
+			return
+		case <-time.After(20 * time.Millisecond):
+		case <-ctx.Done():
+			t.Fatal("no change received")
+		}
+	}
+}
+
+func TestServer_HealthAndReflection(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	resp, err := healthpb.NewHealthClient(f.conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "todo.v1.TodoService"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+
+	stream, err := reflectionpb.NewServerReflectionClient(f.conn).ServerReflectionInfo(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	reply, err := stream.Recv()
+	require.NoError(t, err)
+
+	var services []string
+	for _, svc := range reply.GetListServicesResponse().GetService() {
+		services = append(services, svc.GetName())
+	}
+	assert.Contains(t, services, "todo.v1.TodoService")
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/eventbus"
+)
+
+// subscriberBuffer is how many events may queue up for one client before it
+// is considered too slow and disconnected.
+const subscriberBuffer = 64
+
+type EventHandler struct {
+	bus       *eventbus.Bus
+	heartbeat time.Duration
+}
+
+func NewEventHandler(bus *eventbus.Bus, heartbeat time.Duration) *EventHandler {
+	return &EventHandler{bus: bus, heartbeat: heartbeat}
+}
+
+// StreamTodoEvents streams todo changes as Server-Sent Events. Clients
+// resume with the Last-Event-ID header (or last_event_id query parameter);
+// if the replay buffer no longer covers the gap a "reset" event tells them
+// to reload the full list.
+func (h *EventHandler) StreamTodoEvents(c *gin.Context) {
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("last_event_id")
+	}
+
+	var afterID uint64
+	if lastID != "" {
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+		afterID = id
+	}
+
+	sub, replay, complete := h.bus.Subscribe(afterID, subscriberBuffer)
+	defer sub.Close()
+
+	w := c.Writer
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", (3 * time.Second).Milliseconds())
+	if !complete {
+		fmt.Fprintf(w, "id: %d\nevent: reset\ndata: {}\n\n", h.bus.LastID())
+	}
+	for _, event := range replay {
+		if err := writeEvent(w, event); err != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+			w.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+			w.Flush()
+		}
+	}
+}
+
+func writeEvent(w gin.ResponseWriter, event eventbus.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
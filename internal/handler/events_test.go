@@ -0,0 +1,88 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamTodoEvents_ResumesFromLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	bus := eventbus.NewBus(10)
+	bus.Publish(eventbus.Event{Type: eventbus.TodoCreated, TodoID: 1, Todo: &model.Todo{ID: 1, Title: "old"}})
+	bus.Publish(eventbus.Event{Type: eventbus.TodoToggled, TodoID: 1, Todo: &model.Todo{ID: 1, Title: "old", Completed: true}})
+
+	r := gin.New()
+	r.GET("/events", handler.NewEventHandler(bus, 20*time.Millisecond).StreamTodoEvents)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", srv.URL+"/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "1")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+
+	expect := func(prefix string) string {
+		t.Helper()
+		for line := range lines {
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+		}
+		t.Fatalf("stream ended before %q", prefix)
+		return ""
+	}
+
+	assert.Equal(t, "id: 2", expect("id: "))
+	assert.Equal(t, "event: todo.toggled", expect("event: "))
+
+	bus.Publish(eventbus.Event{Type: eventbus.TodoDeleted, TodoID: 1})
+	assert.Equal(t, "id: 3", expect("id: "))
+	assert.Equal(t, "event: todo.deleted", expect("event: "))
+	assert.Contains(t, expect("data: "), `"todo_id":1`)
+
+	expect(": heartbeat")
+}
+
+func TestStreamTodoEvents_InvalidLastEventID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	h := handler.NewEventHandler(eventbus.NewBus(10), time.Second)
+
+	req := httptest.NewRequest("GET", "/events?last_event_id=abc", nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+
+	h.StreamTodoEvents(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
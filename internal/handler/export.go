@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/export"
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+// ExportTodos streams the todos as a file in the format given by the format
+// query parameter, CSV by default. It takes the filters of GetAllTodos.
+//
+// Todos are written as they are read, so the status is sent before the
+// export is complete. An error after the first bytes went out can only cut
+// the response short; it is logged through the context.
+func (h *TodoHandler) ExportTodos(c *gin.Context) {
+	format := c.DefaultQuery("format", export.CSV)
+	w, err := export.NewWriter(format, c.Writer)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format parameter"})
+		return
+	}
+	completed, ok := completedFilter(c)
+	if !ok {
+		return
+	}
+
+	header := c.Writer.Header()
+	header.Set("Content-Type", export.ContentType(format))
+	header.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="todos.%s"`, format))
+	c.Status(http.StatusOK)
+
+	err = h.service.ExportTodos(c.Request.Context(), completed, func(todo model.Todo) error {
+		return w.Write(todo)
+	})
+	if err == nil {
+		err = w.Close()
+	}
+	if err == nil {
+		return
+	}
+	if c.Writer.Written() {
+		_ = c.Error(err)
+		return
+	}
+	header.Del("Content-Disposition")
+	header.Del("Content-Type")
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
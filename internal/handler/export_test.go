@@ -0,0 +1,126 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newExportRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	repo := repository.NewMemoryTodoRepository()
+	events := repo.Events()
+	todos := service.NewTodoService(repo, events, nil)
+	ctx := context.Background()
+	due := time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC)
+	_, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Buy milk, bread", Project: "home", DueAt: &due})
+	require.NoError(t, err)
+	done, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Write report", Description: "Q3\nwith charts"})
+	require.NoError(t, err)
+	_, err = todos.ToggleTodo(ctx, done.ID)
+	require.NoError(t, err)
+
+	h := handler.NewTodoHandler(todos, service.NewUndoService(repo, nil, time.Minute))
+	r := gin.New()
+	r.GET("/todos/export", h.ExportTodos)
+	return r
+}
+
+func TestExportTodos_CSV(t *testing.T) {
+	r := newExportRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/todos/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="todos.csv"`, w.Header().Get("Content-Disposition"))
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"id", "title", "description", "project", "completed", "due_at", "created_at", "updated_at"}, rows[0])
+	assert.Equal(t, []string{"1", "Buy milk, bread", "", "home", "false", "2026-11-01T09:00:00Z"}, rows[1][:6])
+	assert.Equal(t, "Q3\nwith charts", rows[2][2])
+	assert.Equal(t, "true", rows[2][4])
+}
+
+func TestExportTodos_JSONLFiltered(t *testing.T) {
+	r := newExportRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/todos/export?format=jsonl&completed=true", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	var todos []model.Todo
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var todo model.Todo
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &todo))
+		todos = append(todos, todo)
+	}
+	require.Len(t, todos, 1)
+	assert.Equal(t, "Write report", todos[0].Title)
+}
+
+func TestExportTodos_Markdown(t *testing.T) {
+	r := newExportRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/todos/export?format=md", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "# Todos\n\n"+
+		"- [ ] Buy milk, bread (project: home, due: 2026-11-01T09:00:00Z)\n"+
+		"- [x] Write report\n"+
+		"  Q3\n"+
+		"  with charts\n", w.Body.String())
+}
+
+func TestExportTodos_ICalendar(t *testing.T) {
+	r := newExportRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/todos/export?format=ics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(body, "END:VCALENDAR\r\n"))
+	assert.Equal(t, 2, strings.Count(body, "BEGIN:VTODO\r\n"))
+	assert.Contains(t, body, "UID:todo-1@petgoapi\r\n")
+	assert.Contains(t, body, "SUMMARY:Buy milk\\, bread\r\n")
+	assert.Contains(t, body, "DUE:20261101T090000Z\r\n")
+	assert.Contains(t, body, "STATUS:NEEDS-ACTION\r\n")
+	assert.Contains(t, body, "STATUS:COMPLETED\r\n")
+	assert.Contains(t, body, "DESCRIPTION:Q3\\nwith charts\r\n")
+	assert.Contains(t, body, "CREATED:")
+	assert.Contains(t, body, "LAST-MODIFIED:")
+}
+
+func TestExportTodos_InvalidParameters(t *testing.T) {
+	r := newExportRouter(t)
+
+	for _, query := range []string{"format=xlsx", "completed=maybe"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/todos/export?"+query, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"), query)
+	}
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/importer"
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+// importMaxBytes is the largest file ImportTodos accepts.
+const importMaxBytes = 32 << 20
+
+// importFormats maps the media types of request bodies to import formats,
+// for requests without the format parameter.
+var importFormats = map[string]string{
+	"text/csv":             importer.CSV,
+	"application/x-ndjson": importer.JSONL,
+	"application/jsonl":    importer.JSONL,
+	"text/plain":           importer.TodoTxt,
+}
+
+// ImportTodos creates todos from the file in the request body. The format
+// comes from the format parameter or else the Content-Type of the body;
+// Todoist and Trello exports, both JSON, need the parameter.
+// With dry_run=true nothing is created and the response previews the todos
+// and lists the rejected lines. Otherwise the file is imported as a whole:
+// a single rejected line fails the import with 422 and the list of lines.
+func (h *TodoHandler) ImportTodos(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		format = importFormats[mediaType]
+	}
+
+	dryRun := false
+	if value := c.Query("dry_run"); value != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(value); err != nil {
+			fail(c, http.StatusBadRequest, "Invalid dry_run parameter")
+			return
+		}
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, importMaxBytes)
+	rows, err := importer.Parse(format, body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.Is(err, importer.ErrUnknownFormat):
+			fail(c, http.StatusBadRequest, "Invalid format parameter")
+		case errors.As(err, &tooLarge):
+			fail(c, http.StatusRequestEntityTooLarge, "Import file is too large")
+		default:
+			fail(c, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	report, err := h.service.ImportTodos(c.Request.Context(), rows, dryRun)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	switch {
+	case dryRun:
+		reply(c, http.StatusOK, listOf("Import preview", report, len(report.Todos)))
+	case len(report.Errors) > 0:
+		respond(c, http.StatusUnprocessableEntity, model.ErrorBody{
+			Error: "Import file has invalid lines",
+			Lines: report.Errors,
+		})
+	default:
+		reply(c, http.StatusCreated, listOf("Todos imported successfully", report, len(report.Todos)))
+	}
+}
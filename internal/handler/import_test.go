@@ -0,0 +1,174 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newImportRouter() (*gin.Engine, *service.TodoService) {
+	gin.SetMode(gin.TestMode)
+
+	repo := repository.NewMemoryTodoRepository()
+	events := repo.Events()
+	todos := service.NewTodoService(repo, events, nil)
+	h := handler.NewTodoHandler(todos, service.NewUndoService(repo, nil, time.Minute))
+
+	r := gin.New()
+	r.POST("/todos/import", h.ImportTodos)
+	return r, todos
+}
+
+func postImport(r *gin.Engine, query, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/todos/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+type importResponse struct {
+	Data  model.ImportReport `json:"data"`
+	Count int                `json:"count"`
+}
+
+func decodeImport(t *testing.T, w *httptest.ResponseRecorder) importResponse {
+	t.Helper()
+	var res importResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res), w.Body.String())
+	return res
+}
+
+const todoTxt = "x 2026-10-02 2026-09-30 Write report +work\n" +
+	"(B) Call mom @phone due:2026-10-05\n"
+
+func TestImportTodos_TodoTxt(t *testing.T) {
+	r, todos := newImportRouter()
+
+	w := postImport(r, "", "text/plain; charset=utf-8", todoTxt)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	res := decodeImport(t, w)
+	assert.Equal(t, 2, res.Count)
+	assert.Equal(t, 2, res.Data.Total)
+	assert.Empty(t, res.Data.Errors)
+
+	all, err := todos.GetAllTodos(context.Background())
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+
+	report, err := todos.GetTodoByID(context.Background(), res.Data.Todos[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "work", report.Project)
+	assert.True(t, report.Completed)
+	assert.Equal(t, "2026-09-30", report.CreatedAt.Format(time.DateOnly))
+	assert.Equal(t, "2026-10-02", report.FieldTimes["completed"].Format(time.DateOnly))
+
+	history, err := todos.GetHistory(context.Background(), report.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	assert.Equal(t, model.TodoCreated, history[0].Action)
+}
+
+func TestImportTodos_DryRun(t *testing.T) {
+	r, todos := newImportRouter()
+
+	w := postImport(r, "?format=csv&dry_run=true", "application/octet-stream", "title,project\nBuy milk,home\n,home\n")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	res := decodeImport(t, w)
+	assert.True(t, res.Data.DryRun)
+	require.Len(t, res.Data.Todos, 1)
+	assert.Equal(t, "Buy milk", res.Data.Todos[0].Title)
+	assert.Zero(t, res.Data.Todos[0].ID)
+	assert.Equal(t, []model.ImportError{{Line: 3, Error: "title is required"}}, res.Data.Errors)
+
+	all, err := todos.GetAllTodos(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, all)
+}
+
+func TestImportTodos_RejectsInvalidLines(t *testing.T) {
+	r, todos := newImportRouter()
+
+	body := `{"title":"ok"}` + "\n" +
+		`{"title":"` + strings.Repeat("x", 256) + `"}` + "\n" +
+		`not json` + "\n"
+	w := postImport(r, "", "application/x-ndjson", body)
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	var res struct {
+		Error string              `json:"error"`
+		Lines []model.ImportError `json:"lines"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, "Import file has invalid lines", res.Error)
+	require.Len(t, res.Lines, 2)
+	assert.Equal(t, model.ImportError{Line: 2, Error: "title too long (max 255 characters)"}, res.Lines[0])
+	assert.Equal(t, 3, res.Lines[1].Line)
+
+	all, err := todos.GetAllTodos(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, all, "nothing is imported when a line is rejected")
+}
+
+func TestImportTodos_InvalidParameters(t *testing.T) {
+	r, _ := newImportRouter()
+
+	w := postImport(r, "", "application/pdf", "%PDF")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"Invalid format parameter"}`, w.Body.String())
+
+	w = postImport(r, "?dry_run=perhaps", "text/plain", todoTxt)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+const trelloBoard = `{
+  "id": "5f1c0a000000000000000001",
+  "name": "Launch",
+  "lists": [{"id": "l1", "name": "Doing"}],
+  "cards": [{"id": "5f1c0a100000000000000002", "name": "Landing page", "idList": "l1"}],
+  "checklists": [{"id": "c1", "name": "Sections", "idCard": "5f1c0a100000000000000002",
+    "checkItems": [{"id": "5f1c0a200000000000000003", "name": "Pricing", "state": "complete"}]}]
+}`
+
+func TestImportTodos_TrelloIsIdempotent(t *testing.T) {
+	r, todos := newImportRouter()
+
+	w := postImport(r, "?format=trello", "application/json", trelloBoard)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	res := decodeImport(t, w)
+	require.Len(t, res.Data.Todos, 2)
+	assert.Equal(t, "trello:card:5f1c0a100000000000000002", *res.Data.Todos[0].ExternalID)
+	assert.Empty(t, res.Data.Skipped)
+
+	// Trashed todos count as imported too.
+	_, err := todos.DeleteTodo(context.Background(), res.Data.Todos[1].ID)
+	require.NoError(t, err)
+
+	w = postImport(r, "?format=trello&dry_run=true", "application/json", trelloBoard)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	res = decodeImport(t, w)
+	assert.Empty(t, res.Data.Todos)
+	assert.Len(t, res.Data.Skipped, 2)
+
+	w = postImport(r, "?format=trello", "application/json", trelloBoard)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	res = decodeImport(t, w)
+	assert.Zero(t, res.Count)
+	assert.Equal(t, []string{"trello:card:5f1c0a100000000000000002", "trello:checkitem:5f1c0a200000000000000003"}, res.Data.Skipped)
+
+	all, err := todos.GetAllTodos(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+}
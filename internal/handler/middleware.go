@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+const ActorHeader = "X-Actor"
+
+// Actor attributes the changes made by a request to the caller named in the
+// X-Actor header, so they show up in the todo history. Requests
+// authenticated with an API key keep the key's user.
+func Actor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if actor := c.GetHeader(ActorHeader); actor != "" && service.UserFromContext(c.Request.Context()) == nil {
+			c.Request = c.Request.WithContext(service.WithActor(c.Request.Context(), actor))
+		}
+		c.Next()
+	}
+}
+
+// Authenticate requires an API key on every request except CORS preflights
+// and those for the public paths. Clients send the key as a bearer token,
+// or as the password of Basic authentication, which is what calendar
+// clients support.
+func Authenticate(users service.UserServiceInterface, public ...string) gin.HandlerFunc {
+	open := make(map[string]bool, len(public))
+	for _, path := range public {
+		open[path] = true
+	}
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodOptions || open[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+		user, err := users.Authenticate(c.Request.Context(), apiKey(c.Request))
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			c.Header("WWW-Authenticate", `Bearer, Basic realm="petgoapi"`)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.Request = c.Request.WithContext(service.WithUser(c.Request.Context(), user))
+		c.Next()
+	}
+}
+
+func apiKey(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	return ""
+}
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	requestIDKey    = "request_id"
+	// maxRequestIDLength caps the IDs taken from clients.
+	maxRequestIDLength = 128
+)
+
+// RequestID names each request with the ID in its X-Request-ID header, or
+// a new random one, and sends it back in the same header and in the meta of
+// v2 responses, so a response can be matched with the logs of its request.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if id == "" || len(id) > maxRequestIDLength {
+			id = newRequestID()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestIDOf returns the ID RequestID gave the request, or "" without
+// the middleware.
+func RequestIDOf(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// Logger logs every request like gin's default logger, with the ID that
+// RequestID gave it.
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+		id, _ := param.Keys[requestIDKey].(string)
+		return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v | %s\n%s",
+			param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+			param.StatusCode,
+			param.Latency.Truncate(time.Microsecond),
+			param.ClientIP,
+			param.Method,
+			param.Path,
+			id,
+			param.ErrorMessage,
+		)
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+const basePathKey = "base_path"
+
+// BasePath records where the router mounts the API, so links in responses
+// point to the routes wherever they are mounted.
+func BasePath(path string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(basePathKey, strings.TrimSuffix(path, "/"))
+		c.Next()
+	}
+}
+
+// basePathOf returns the path BasePath recorded, or "" without it.
+func basePathOf(c *gin.Context) string {
+	return c.GetString(basePathKey)
+}
@@ -0,0 +1,31 @@
+package handler_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/stavagg/petGoApi/internal/handler"
+)
+
+func TestLogger_WritesRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var logs bytes.Buffer
+	previous := gin.DefaultWriter
+	gin.DefaultWriter = &logs
+	defer func() { gin.DefaultWriter = previous }()
+
+	r := gin.New()
+	r.Use(handler.RequestID(), handler.Logger())
+	r.GET("/ping", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	req := httptest.NewRequest("GET", "/ping", nil)
+	req.Header.Set(handler.RequestIDHeader, "req-42")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, logs.String(), `"/ping" | req-42`)
+}
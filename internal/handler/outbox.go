@@ -0,0 +1,28 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/outbox"
+)
+
+type OutboxHandler struct {
+	relay *outbox.Relay
+}
+
+func NewOutboxHandler(relay *outbox.Relay) *OutboxHandler {
+	return &OutboxHandler{relay: relay}
+}
+
+// GetStats reports relay throughput and lag together with the backlog of
+// unpublished events.
+func (h *OutboxHandler) GetStats(c *gin.Context) {
+	stats, err := h.relay.Stats()
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reply(c, http.StatusOK, result{message: "Outbox stats retrieved successfully", data: stats})
+}
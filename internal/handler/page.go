@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+const maxPageLimit = 1000
+
+// paginate reads the limit and offset query parameters of lists. Without
+// limit the whole list is returned and the page is nil. It responds with
+// 400 when a parameter is invalid.
+func paginate(c *gin.Context) (*model.Pagination, bool) {
+	rawLimit, rawOffset := c.Query("limit"), c.Query("offset")
+	if rawLimit == "" && rawOffset == "" {
+		return nil, true
+	}
+	page := &model.Pagination{Limit: maxPageLimit}
+	if rawLimit != "" {
+		limit, err := strconv.Atoi(rawLimit)
+		if err != nil || limit <= 0 || limit > maxPageLimit {
+			fail(c, http.StatusBadRequest, "Invalid limit parameter")
+			return nil, false
+		}
+		page.Limit = limit
+	}
+	if rawOffset != "" {
+		offset, err := strconv.Atoi(rawOffset)
+		if err != nil || offset < 0 {
+			fail(c, http.StatusBadRequest, "Invalid offset parameter")
+			return nil, false
+		}
+		page.Offset = offset
+	}
+	return page, true
+}
+
+// pageURL is the request URL with another page.
+func pageURL(c *gin.Context, limit, offset int) string {
+	u := *c.Request.URL
+	query := u.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	u.RawQuery = query.Encode()
+	return (&url.URL{Path: u.Path, RawQuery: u.RawQuery}).String()
+}
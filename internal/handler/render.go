@@ -0,0 +1,362 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/stavagg/petGoApi/internal/export"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// Media types responses are rendered in, chosen by the Accept header. JSON
+// comes first, so it is what clients get without an Accept header.
+const (
+	MIMEJSON    = "application/json"
+	MIMEYAML    = "application/yaml"
+	MIMEMsgPack = "application/msgpack"
+	// MIMECSV is offered by lists of todos only.
+	MIMECSV = "text/csv"
+
+	// The v2 media types render the same responses as a model.Envelope.
+	MIMEJSONv2    = "application/vnd.petgoapi.v2+json"
+	MIMEYAMLv2    = "application/vnd.petgoapi.v2+yaml"
+	MIMEMsgPackv2 = "application/vnd.petgoapi.v2+msgpack"
+)
+
+// formats are the media types every rendered response is offered in.
+var formats = []string{MIMEJSON, MIMEYAML, MIMEMsgPack, MIMEJSONv2, MIMEYAMLv2, MIMEMsgPackv2}
+
+// aliases are the other names clients use for the formats.
+var aliases = map[string][]string{
+	MIMEYAML:    {"application/x-yaml", "text/yaml"},
+	MIMEMsgPack: {"application/x-msgpack", "application/vnd.msgpack"},
+}
+
+// v2Encodings maps the v2 media types to the v1 ones they are encoded as.
+var v2Encodings = map[string]string{
+	MIMEJSONv2:    MIMEJSON,
+	MIMEYAMLv2:    MIMEYAML,
+	MIMEMsgPackv2: MIMEMsgPack,
+}
+
+// negotiate returns the format of the response out of formats and extra, or
+// "" when the Accept header admits none of them. Each format gets the q-value
+// of the most specific media range that matches it; the highest wins, then
+// the one whose range comes first in the header, then the earlier format.
+// A q-value of 0 rules a format out.
+func negotiate(c *gin.Context, extra ...string) string {
+	offered := append(append([]string{}, formats...), extra...)
+	header := c.GetHeader("Accept")
+	if strings.TrimSpace(header) == "" {
+		return offered[0]
+	}
+	ranges := parseAccept(header)
+
+	best, bestQ, bestIndex := "", 0.0, 0
+	for _, format := range offered {
+		for _, name := range append([]string{format}, aliases[format]...) {
+			match, ok := bestRange(ranges, name)
+			if !ok || match.q <= 0 {
+				continue
+			}
+			if match.q > bestQ || (match.q == bestQ && match.index < bestIndex) {
+				best, bestQ, bestIndex = format, match.q, match.index
+			}
+		}
+	}
+	return best
+}
+
+// mediaRange is one entry of an Accept header.
+type mediaRange struct {
+	typ, subtype string
+	q            float64
+	// index is the position of the range in the header.
+	index int
+}
+
+// parseAccept parses the media ranges of an Accept header. Parameters other
+// than q are ignored, and a missing or malformed q counts as 1.
+func parseAccept(header string) []mediaRange {
+	var ranges []mediaRange
+	for i, part := range strings.Split(header, ",") {
+		params := strings.Split(part, ";")
+		typ, subtype, ok := strings.Cut(strings.ToLower(strings.TrimSpace(params[0])), "/")
+		if !ok {
+			continue
+		}
+		r := mediaRange{typ: typ, subtype: subtype, q: 1, index: i}
+		for _, param := range params[1:] {
+			key, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(key) != "q" {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil && q >= 0 && q <= 1 {
+				r.q = q
+			}
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// bestRange returns the most specific of ranges that matches name: the full
+// type over type/* over */*.
+func bestRange(ranges []mediaRange, name string) (mediaRange, bool) {
+	typ, subtype, _ := strings.Cut(name, "/")
+	var best mediaRange
+	specificity := -1
+	for _, r := range ranges {
+		s := -1
+		switch {
+		case r.typ == typ && r.subtype == subtype:
+			s = 2
+		case r.typ == typ && r.subtype == "*":
+			s = 1
+		case r.typ == "*" && r.subtype == "*":
+			s = 0
+		}
+		if s > specificity {
+			best, specificity = r, s
+		}
+	}
+	return best, specificity >= 0
+}
+
+// Negotiate responds with 406 Not Acceptable when the Accept header admits
+// none of the formats of a route: JSON, YAML and MessagePack in v1 and v2,
+// and extra, like MIMECSV for lists. It runs before the handler, so no
+// change is made for a response the client could not read.
+func Negotiate(extra ...string) gin.HandlerFunc {
+	supported := strings.Join(append(append([]string{}, formats...), extra...), ", ")
+	return func(c *gin.Context) {
+		if negotiate(c, extra...) == "" {
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+				"error": "Not acceptable: responses are available as " + supported,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+func todoPath(c *gin.Context, id uint) string {
+	return basePathOf(c) + "/todos/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// result is the successful response of a route. v1 renders it as message
+// and data with count for lists and undo beside them; v2 renders it as a
+// model.Envelope.
+type result struct {
+	message string
+	// data is left out of v1 and null in v2 when nil.
+	data interface{}
+	// list is set for lists. count is the length of data, total the length
+	// of the list before page was taken from it.
+	list  bool
+	count int
+	total int
+	page  *model.Pagination
+	undo  *service.UndoToken
+	// links are added to self, and to next and prev for pages.
+	links model.Links
+	// csv is set for lists of todos, which can also be rendered as CSV
+	// with the columns of the CSV export.
+	csv   bool
+	todos []model.Todo
+}
+
+// listOf is the result of a list that was not paginated.
+func listOf(message string, data interface{}, count int) result {
+	return result{message: message, data: data, list: true, count: count, total: count}
+}
+
+// pageResult is the result of a page of count items out of total, or of
+// the whole list when page is nil.
+func pageResult(message string, data interface{}, count, total int, page *model.Pagination) result {
+	return result{message: message, data: data, list: true, count: count, total: total, page: page}
+}
+
+// todoList is the result of a page of todos out of total.
+func todoList(message string, todos []model.Todo, total int, page *model.Pagination) result {
+	r := pageResult(message, todos, len(todos), total, page)
+	r.csv, r.todos = true, todos
+	return r
+}
+
+// todoResult is the result of a single todo, linked to its toggle and
+// delete routes while it is not in the trash.
+func todoResult(c *gin.Context, message string, todo *model.Todo) result {
+	r := result{message: message, data: todo}
+	if todo != nil && !todo.DeletedAt.Valid {
+		path := todoPath(c, todo.ID)
+		r.links = model.Links{Toggle: path + "/toggle", Delete: path}
+	}
+	return r
+}
+
+// reply writes a successful response in the format negotiated from the
+// Accept header.
+func reply(c *gin.Context, code int, r result) {
+	format := negotiate(c)
+	if r.csv {
+		format = negotiate(c, MIMECSV)
+	}
+	if format == MIMECSV {
+		writeCSV(c, code, r.todos)
+		return
+	}
+	if _, ok := v2Encodings[format]; ok {
+		write(c, code, format, envelope(c, r))
+		return
+	}
+
+	body := gin.H{"message": r.message}
+	if r.data != nil {
+		body["data"] = r.data
+	}
+	if r.list {
+		body["count"] = r.count
+	}
+	if r.undo != nil {
+		body["undo"] = r.undo
+	}
+	write(c, code, format, body)
+}
+
+// fail responds with an error that has only a message.
+func fail(c *gin.Context, code int, message string) {
+	respond(c, code, model.ErrorBody{Error: message})
+}
+
+// respond writes an error in the negotiated format. v2 adds meta. When no
+// format fits, which only happens for errors of lists asked for as CSV or
+// for routes without Negotiate, body is written as JSON.
+func respond(c *gin.Context, code int, body model.ErrorBody) {
+	format := negotiate(c)
+	if _, ok := v2Encodings[format]; ok {
+		body.Meta = &model.Meta{RequestID: RequestIDOf(c)}
+	}
+	write(c, code, format, body)
+}
+
+func envelope(c *gin.Context, r result) model.Envelope {
+	env := model.Envelope{
+		Data:  r.data,
+		Meta:  model.Meta{RequestID: RequestIDOf(c)},
+		Links: r.links,
+	}
+	env.Links.Self = c.Request.URL.RequestURI()
+	if r.list {
+		total := r.total
+		env.Meta.Total = &total
+		env.Meta.Pagination = r.page
+	}
+	if r.page != nil {
+		if next := r.page.Offset + r.page.Limit; next < r.total {
+			env.Links.Next = pageURL(c, r.page.Limit, next)
+		}
+		if r.page.Offset > 0 {
+			env.Links.Prev = pageURL(c, r.page.Limit, max(r.page.Offset-r.page.Limit, 0))
+		}
+	}
+	if r.undo != nil {
+		env.Links.Undo = basePathOf(c) + "/undo/" + r.undo.Token
+		env.Meta.UndoExpiresAt = &r.undo.ExpiresAt
+	}
+	return env
+}
+
+// write encodes body in format. The v2 media types keep their name in the
+// Content-Type and are otherwise written as their v1 encoding.
+func write(c *gin.Context, code int, format string, body interface{}) {
+	c.Header("Vary", "Accept")
+	if encoding, ok := v2Encodings[format]; ok {
+		c.Header("Content-Type", format+"; charset=utf-8")
+		format = encoding
+	}
+	if format == "" || format == MIMEJSON {
+		c.JSON(code, body)
+		return
+	}
+
+	// YAML and MessagePack get the values JSON would, with the same field
+	// names and times in RFC 3339, rather than what their encoders make of
+	// the model structs. Going through JSON costs an extra encode, but keeps
+	// the json tags the only place field names are defined.
+	value, err := plain(body)
+	if err != nil {
+		c.Header("Content-Type", "")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	switch format {
+	case MIMEYAML:
+		c.YAML(code, value)
+	case MIMEMsgPack:
+		c.Render(code, render.MsgPack{Data: value})
+	}
+}
+
+// writeCSV writes todos as CSV. The rest of the response is left out.
+func writeCSV(c *gin.Context, code int, todos []model.Todo) {
+	c.Header("Vary", "Accept")
+	c.Header("Content-Type", export.ContentType(export.CSV))
+	c.Status(code)
+	w, err := export.NewWriter(export.CSV, c.Writer)
+	if err != nil {
+		_ = c.Error(err)
+		return
+	}
+	for _, todo := range todos {
+		if err := w.Write(todo); err != nil {
+			_ = c.Error(err)
+			return
+		}
+	}
+	if err := w.Close(); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// plain turns v into the maps, slices, strings, numbers and booleans of its
+// JSON encoding. Whole numbers stay integers.
+func plain(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var out interface{}
+	if err := decoder.Decode(&out); err != nil {
+		return nil, err
+	}
+	return numbers(out), nil
+}
+
+func numbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			v[key] = numbers(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = numbers(value)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
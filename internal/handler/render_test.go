@@ -0,0 +1,174 @@
+package handler_test
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+	"gopkg.in/yaml.v3"
+
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/service/mocks"
+)
+
+// newNegotiatedRouter serves todos as the page of a list of total todos.
+func newNegotiatedRouter(todos []model.Todo, total int) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	serviceMock := new(mocks.TodoServiceMock)
+	serviceMock.On("ListTodos", mock.Anything, mock.Anything).Return(todos, total, nil)
+	serviceMock.On("GetStats").Return(map[string]interface{}{"total": 2}, nil)
+	serviceMock.On("GetTodoByID", uint(1)).Return(&model.Todo{ID: 1, Title: "Milk"}, nil)
+	serviceMock.On("GetTodoByID", uint(2)).Return((*model.Todo)(nil), errors.New("not found"))
+	h := handler.NewTodoHandler(serviceMock, new(mocks.UndoServiceMock))
+
+	r := gin.New()
+	r.Use(handler.RequestID())
+	r.GET("/todos", handler.Negotiate(handler.MIMECSV), h.GetAllTodos)
+	r.GET("/todos/stats", handler.Negotiate(), h.GetStats)
+	r.GET("/todos/:id", handler.Negotiate(), h.GetTodoByID)
+	return r
+}
+
+func get(r http.Handler, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestNegotiate_Formats(t *testing.T) {
+	r := newNegotiatedRouter([]model.Todo{{ID: 1, Title: "Milk, 2%", Version: 1}, {ID: 2, Title: "Bread", Completed: true}}, 2)
+
+	w := get(r, "/todos", "")
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	w = get(r, "/todos", "application/x-yaml")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "Accept", w.Header().Get("Vary"))
+	var fromYAML struct {
+		Count int                      `yaml:"count"`
+		Data  []map[string]interface{} `yaml:"data"`
+	}
+	require.NoError(t, yaml.Unmarshal(w.Body.Bytes(), &fromYAML))
+	assert.Equal(t, 2, fromYAML.Count)
+	assert.Equal(t, "Milk, 2%", fromYAML.Data[0]["title"], "fields are named as in JSON")
+	assert.Equal(t, 1, fromYAML.Data[0]["id"], "whole numbers stay integers")
+
+	w = get(r, "/todos", "application/msgpack")
+	require.Equal(t, http.StatusOK, w.Code)
+	var fromMsgPack map[string]interface{}
+	require.NoError(t, codec.NewDecoderBytes(w.Body.Bytes(), new(codec.MsgpackHandle)).Decode(&fromMsgPack))
+	assert.EqualValues(t, 2, fromMsgPack["count"])
+
+	w = get(r, "/todos", "text/csv")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/csv"))
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, "Milk, 2%", records[1][1])
+}
+
+func TestNegotiate_NotAcceptable(t *testing.T) {
+	r := newNegotiatedRouter(nil, 0)
+
+	w := get(r, "/todos", "application/xml")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Contains(t, w.Body.String(), "text/csv")
+
+	w = get(r, "/todos/stats", "text/csv")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code, "only lists are offered as CSV")
+
+	w = get(r, "/todos/stats", "application/xml, */*;q=0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+}
+
+func TestNegotiate_QValues(t *testing.T) {
+	r := newNegotiatedRouter(nil, 0)
+
+	w := get(r, "/todos/stats", "application/json;q=0.5, application/yaml")
+	assert.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"), "the higher q wins over order")
+
+	w = get(r, "/todos/stats", "application/yaml;q=0.9, application/msgpack;q=0.9")
+	assert.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"), "ties go to the earlier range")
+
+	w = get(r, "/todos/stats", "application/json;q=0, application/*;q=0.5")
+	assert.Equal(t, "application/yaml; charset=utf-8", w.Header().Get("Content-Type"), "q=0 rules the format out")
+
+	w = get(r, "/todos", "text/csv;q=0, */*;q=0.1")
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	w = get(r, "/todos/stats", "application/*;q=0, text/html")
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+}
+
+func TestNegotiate_V2Envelope(t *testing.T) {
+	r := newNegotiatedRouter([]model.Todo{{ID: 3}, {ID: 4}}, 5)
+
+	req := httptest.NewRequest("GET", "/todos?completed=&limit=2&offset=2", nil)
+	req.Header.Set("Accept", handler.MIMEJSONv2)
+	req.Header.Set(handler.RequestIDHeader, "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, handler.MIMEJSONv2+"; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "req-1", w.Header().Get(handler.RequestIDHeader))
+
+	var page struct {
+		Data  []model.Todo
+		Meta  model.Meta
+		Links model.Links
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Data, 2)
+	assert.Equal(t, uint(3), page.Data[0].ID)
+	assert.Equal(t, "req-1", page.Meta.RequestID)
+	assert.Equal(t, 5, *page.Meta.Total)
+	assert.Equal(t, &model.Pagination{Limit: 2, Offset: 2}, page.Meta.Pagination)
+	assert.Equal(t, "/todos?completed=&limit=2&offset=2", page.Links.Self)
+	assert.Equal(t, "/todos?completed=&limit=2&offset=4", page.Links.Next)
+	assert.Equal(t, "/todos?completed=&limit=2&offset=0", page.Links.Prev)
+
+	w = get(r, "/todos/1", handler.MIMEJSONv2)
+	require.Equal(t, http.StatusOK, w.Code)
+	var single model.Envelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &single))
+	assert.NotEmpty(t, single.Meta.RequestID, "an ID is made up without the header")
+	assert.Equal(t, "/todos/1/toggle", single.Links.Toggle, "links follow where the routes are mounted")
+	assert.Equal(t, "/todos/1", single.Links.Delete)
+	assert.Empty(t, single.Links.Next)
+
+	w = get(r, "/todos/2", handler.MIMEJSONv2)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	var failed model.ErrorBody
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &failed))
+	assert.Equal(t, "Todo not found", failed.Error)
+	require.NotNil(t, failed.Meta)
+	assert.NotEmpty(t, failed.Meta.RequestID)
+
+	w = get(r, "/todos?limit=2", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var v1 map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &v1))
+	assert.EqualValues(t, 2, v1["count"], "v1 stays the default")
+	assert.NotContains(t, v1, "meta")
+
+	w = get(r, "/todos?limit=0", handler.MIMEJSONv2)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+const (
+	defaultSyncLimit = 500
+	maxSyncLimit     = 1000
+)
+
+type SyncHandler struct {
+	service service.SyncServiceInterface
+}
+
+func NewSyncHandler(service service.SyncServiceInterface) *SyncHandler {
+	return &SyncHandler{service: service}
+}
+
+// GetChanges returns the todos created, updated and deleted since the change
+// token in the since query parameter. Without since it returns everything.
+func (h *SyncHandler) GetChanges(c *gin.Context) {
+	limit := defaultSyncLimit
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxSyncLimit {
+			fail(c, http.StatusBadRequest, "Invalid limit parameter")
+			return
+		}
+	}
+
+	changes, err := h.service.Changes(c.Request.Context(), c.Query("since"), limit)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSyncToken) {
+			fail(c, http.StatusBadRequest, "Invalid since token")
+			return
+		}
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// The next batch is linked while there are more changes to catch up on.
+	r := result{message: "Changes retrieved successfully", data: changes}
+	if changes.HasMore {
+		query := url.Values{"since": {changes.Token}, "limit": {strconv.Itoa(limit)}}
+		r.links.Next = (&url.URL{Path: c.Request.URL.Path, RawQuery: query.Encode()}).String()
+	}
+	reply(c, http.StatusOK, r)
+}
+
+// Push applies a batch of offline mutations and returns a result for each
+// of them in the same order.
+func (h *SyncHandler) Push(c *gin.Context) {
+	var req model.SyncPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	results, err := h.service.Push(c.Request.Context(), req.Mutations)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reply(c, http.StatusOK, listOf("Mutations processed", results, len(results)))
+}
@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSyncRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	repo := repository.NewMemoryTodoRepository()
+	todos := service.NewTodoService(repo, repo.Events(), nil)
+	h := handler.NewSyncHandler(service.NewSyncService(todos, repo, 0))
+
+	r := gin.New()
+	r.GET("/sync", h.GetChanges)
+	r.POST("/sync", h.Push)
+	return r
+}
+
+func TestSyncHandler_PushThenPull(t *testing.T) {
+	r := newSyncRouter()
+
+	w := httptest.NewRecorder()
+	body := `{"mutations":[{"client_id":"tmp-1","op":"create","data":{"title":"Offline"}}]}`
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/sync", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var pushed struct {
+		Data []model.SyncResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pushed))
+	require.Len(t, pushed.Data, 1)
+	assert.Equal(t, model.SyncApplied, pushed.Data[0].Status)
+	assert.Equal(t, "tmp-1", pushed.Data[0].ClientID)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/sync", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var pulled struct {
+		Data model.SyncChanges `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pulled))
+	require.Len(t, pulled.Data.Created, 1)
+	assert.Equal(t, "Offline", pulled.Data.Created[0].Title)
+	assert.NotEmpty(t, pulled.Data.Token)
+}
+
+func TestSyncHandler_Errors(t *testing.T) {
+	r := newSyncRouter()
+
+	for _, tc := range []struct {
+		method, path, body string
+	}{
+		{"GET", "/sync?since=garbage", ""},
+		{"GET", "/sync?limit=0", ""},
+		{"POST", "/sync", `{"mutations":[{"id":1}]}`},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, bytes.NewBufferString(tc.body)))
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s %s", tc.method, tc.path)
+	}
+}
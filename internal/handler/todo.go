@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -11,150 +12,283 @@ import (
 
 type TodoHandler struct {
 	service service.TodoServiceInterface
+	undo    service.UndoServiceInterface
 }
 
-func NewTodoHandler(service service.TodoServiceInterface) *TodoHandler {
-	return &TodoHandler{service: service}
+func NewTodoHandler(service service.TodoServiceInterface, undo service.UndoServiceInterface) *TodoHandler {
+	return &TodoHandler{service: service, undo: undo}
 }
 
 func (h *TodoHandler) CreateTodo(c *gin.Context) {
 	var req model.CreateTodoRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	todo, err := h.service.CreateTodo(req)
+	todo, err := h.service.CreateTodo(c.Request.Context(), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"message": "Todo created successfully",
-		"data":    todo,
-	})
+	reply(c, http.StatusCreated, todoResult(c, "Todo created successfully", todo))
 }
 
 func (h *TodoHandler) GetAllTodos(c *gin.Context) {
-	completed := c.Query("completed")
-
-	var todos []model.Todo
-	var err error
-
-	if completed != "" {
-		isCompleted, parseErr := strconv.ParseBool(completed)
-		if parseErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid completed parameter"})
-			return
-		}
-		todos, err = h.service.GetTodosByCompleted(isCompleted)
-	} else {
-		todos, err = h.service.GetAllTodos()
+	completed, ok := completedFilter(c)
+	if !ok {
+		return
+	}
+	page, ok := paginate(c)
+	if !ok {
+		return
 	}
 
+	todos, total, err := h.service.ListTodos(c.Request.Context(), completed, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Todos retrieved successfully",
-		"data":    todos,
-		"count":   len(todos),
-	})
+	reply(c, http.StatusOK, todoList("Todos retrieved successfully", todos, total, page))
+}
+
+// completedFilter parses the completed query parameter of list routes. It
+// returns nil when the parameter is absent and responds with 400 when it is
+// not a boolean.
+func completedFilter(c *gin.Context) (*bool, bool) {
+	completed := c.Query("completed")
+	if completed == "" {
+		return nil, true
+	}
+	isCompleted, err := strconv.ParseBool(completed)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "Invalid completed parameter")
+		return nil, false
+	}
+	return &isCompleted, true
 }
 
 func (h *TodoHandler) GetTodoByID(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		fail(c, http.StatusBadRequest, "Invalid ID format")
 		return
 	}
 
-	todo, err := h.service.GetTodoByID(uint(id))
+	todo, err := h.service.GetTodoByID(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Todo not found"})
+		fail(c, http.StatusNotFound, "Todo not found")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Todo retrieved successfully",
-		"data":    todo,
-	})
+	reply(c, http.StatusOK, todoResult(c, "Todo retrieved successfully", todo))
 }
 
 func (h *TodoHandler) UpdateTodo(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		fail(c, http.StatusBadRequest, "Invalid ID format")
 		return
 	}
 
 	var req model.UpdateTodoRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		fail(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	todo, err := h.service.UpdateTodo(uint(id), req)
+	todo, err := h.service.UpdateTodo(c.Request.Context(), uint(id), req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Todo updated successfully",
-		"data":    todo,
-	})
+	reply(c, http.StatusOK, todoResult(c, "Todo updated successfully", todo))
 }
 
 func (h *TodoHandler) DeleteTodo(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		fail(c, http.StatusBadRequest, "Invalid ID format")
 		return
 	}
 
-	err = h.service.DeleteTodo(uint(id))
+	todo, err := h.service.DeleteTodo(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Todo deleted successfully",
+	reply(c, http.StatusOK, result{
+		message: "Todo deleted successfully",
+		undo:    h.undo.Remember(service.UndoDelete, []model.Todo{*todo}),
 	})
 }
 
 func (h *TodoHandler) GetStats(c *gin.Context) {
-	stats, err := h.service.GetStats()
+	stats, err := h.service.GetStats(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Statistics retrieved successfully",
-		"data":    stats,
-	})
+	reply(c, http.StatusOK, result{message: "Statistics retrieved successfully", data: stats})
 }
 
 func (h *TodoHandler) ToggleTodo(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		fail(c, http.StatusBadRequest, "Invalid ID format")
 		return
 	}
 
-	todo, err := h.service.ToggleTodo(uint(id))
+	todo, err := h.service.ToggleTodo(c.Request.Context(), uint(id))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		fail(c, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Todo status toggled successfully",
-		"data":    todo,
-	})
+	r := todoResult(c, "Todo status toggled successfully", todo)
+	r.undo = h.undo.Remember(service.UndoToggle, []model.Todo{*todo})
+	reply(c, http.StatusOK, r)
+}
+
+func (h *TodoHandler) MarkAllCompleted(c *gin.Context) {
+	todos, err := h.service.MarkAllCompleted(c.Request.Context())
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	r := listOf("Todos marked as completed", todos, len(todos))
+	r.undo = h.undo.Remember(service.UndoCompleteAll, todos)
+	reply(c, http.StatusOK, r)
+}
+
+func (h *TodoHandler) Undo(c *gin.Context) {
+	todos, err := h.undo.Undo(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrUndoNotFound):
+			fail(c, http.StatusNotFound, err.Error())
+		case errors.Is(err, service.ErrUndoConflict):
+			fail(c, http.StatusConflict, err.Error())
+		default:
+			fail(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	reply(c, http.StatusOK, listOf("Action undone successfully", todos, len(todos)))
+}
+
+func (h *TodoHandler) GetTrash(c *gin.Context) {
+	page, ok := paginate(c)
+	if !ok {
+		return
+	}
+
+	todos, total, err := h.service.ListTrash(c.Request.Context(), page)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reply(c, http.StatusOK, todoList("Trash retrieved successfully", todos, total, page))
+}
+
+func (h *TodoHandler) RestoreTodo(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	todo, err := h.service.RestoreTodo(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrNotInTrash) {
+			fail(c, http.StatusNotFound, "Todo not found in trash")
+			return
+		}
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reply(c, http.StatusOK, todoResult(c, "Todo restored successfully", todo))
+}
+
+func (h *TodoHandler) PurgeTodo(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	err = h.service.PurgeTodo(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, service.ErrNotInTrash) {
+			fail(c, http.StatusNotFound, "Todo not found in trash")
+			return
+		}
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reply(c, http.StatusOK, result{message: "Todo permanently deleted"})
+}
+
+func (h *TodoHandler) GetHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	page, ok := paginate(c)
+	if !ok {
+		return
+	}
+
+	events, total, err := h.service.ListHistory(c.Request.Context(), uint(id), page)
+	if err != nil {
+		if errors.Is(err, service.ErrTodoNotFound) {
+			fail(c, http.StatusNotFound, "Todo not found")
+			return
+		}
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reply(c, http.StatusOK, pageResult("History retrieved successfully", events, len(events), total, page))
+}
+
+func (h *TodoHandler) RevertTodo(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	revision, err := strconv.ParseUint(c.Query("to"), 10, 32)
+	if err != nil || revision == 0 {
+		fail(c, http.StatusBadRequest, "Invalid to parameter")
+		return
+	}
+
+	todo, err := h.service.RevertTodo(c.Request.Context(), uint(id), uint(revision))
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrRevisionNotFound):
+			fail(c, http.StatusNotFound, "Revision not found")
+		case errors.Is(err, service.ErrTodoNotFound):
+			fail(c, http.StatusNotFound, "Todo not found")
+		default:
+			fail(c, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	reply(c, http.StatusOK, todoResult(c, "Todo reverted successfully", todo))
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/stavagg/petGoApi/internal/handler"
 	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/service"
 	"github.com/stavagg/petGoApi/internal/service/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -19,7 +20,7 @@ func TestCreateTodo_Handler_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	serviceMock := new(mocks.TodoServiceMock)
-	h := handler.NewTodoHandler(serviceMock)
+	h := handler.NewTodoHandler(serviceMock, new(mocks.UndoServiceMock))
 
 	todo := &model.Todo{ID: 1, Title: "Test", Description: "Desc"}
 	serviceMock.On("CreateTodo", mock.AnythingOfType("model.CreateTodoRequest")).Return(todo, nil)
@@ -42,10 +43,10 @@ func TestGetAllTodos_Handler_Success(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
 	serviceMock := new(mocks.TodoServiceMock)
-	h := handler.NewTodoHandler(serviceMock)
+	h := handler.NewTodoHandler(serviceMock, new(mocks.UndoServiceMock))
 
 	todos := []model.Todo{{ID: 1, Title: "Test"}}
-	serviceMock.On("GetAllTodos").Return(todos, nil)
+	serviceMock.On("ListTodos", (*bool)(nil), (*model.Pagination)(nil)).Return(todos, len(todos), nil)
 
 	req := httptest.NewRequest("GET", "/todos", nil)
 	w := httptest.NewRecorder()
@@ -57,3 +58,61 @@ func TestGetAllTodos_Handler_Success(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	serviceMock.AssertExpectations(t)
 }
+
+func TestRestoreTodo_Handler_NotInTrash(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serviceMock := new(mocks.TodoServiceMock)
+	h := handler.NewTodoHandler(serviceMock, new(mocks.UndoServiceMock))
+
+	serviceMock.On("RestoreTodo", uint(7)).Return((*model.Todo)(nil), service.ErrNotInTrash)
+
+	req := httptest.NewRequest("POST", "/todos/7/restore", nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: "7"}}
+
+	h.RestoreTodo(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	serviceMock.AssertExpectations(t)
+}
+
+func TestRevertTodo_Handler_InvalidRevision(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serviceMock := new(mocks.TodoServiceMock)
+	h := handler.NewTodoHandler(serviceMock, new(mocks.UndoServiceMock))
+
+	req := httptest.NewRequest("POST", "/todos/1/revert?to=abc", nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "id", Value: "1"}}
+
+	h.RevertTodo(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	serviceMock.AssertNotCalled(t, "RevertTodo", mock.Anything, mock.Anything)
+}
+
+func TestUndo_Handler_Conflict(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	undoMock := new(mocks.UndoServiceMock)
+	h := handler.NewTodoHandler(new(mocks.TodoServiceMock), undoMock)
+
+	undoMock.On("Undo", "abc").Return([]model.Todo(nil), service.ErrUndoConflict)
+
+	req := httptest.NewRequest("POST", "/undo/abc", nil)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = req
+	c.Params = gin.Params{{Key: "token", Value: "abc"}}
+
+	h.Undo(c)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	undoMock.AssertExpectations(t)
+}
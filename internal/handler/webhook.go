@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 200
+)
+
+type WebhookHandler struct {
+	service service.WebhookServiceInterface
+}
+
+func NewWebhookHandler(service service.WebhookServiceInterface) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req model.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := h.service.CreateWebhook(c.Request.Context(), req)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	reply(c, http.StatusCreated, webhookResult(c, "Webhook created successfully", webhook))
+}
+
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	page, ok := paginate(c)
+	if !ok {
+		return
+	}
+
+	webhooks, total, err := h.service.ListWebhooks(c.Request.Context(), page)
+	if err != nil {
+		fail(c, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	reply(c, http.StatusOK, pageResult("Webhooks retrieved successfully", webhooks, len(webhooks), total, page))
+}
+
+func (h *WebhookHandler) GetWebhookByID(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	webhook, err := h.service.GetWebhookByID(c.Request.Context(), uint(id))
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	reply(c, http.StatusOK, webhookResult(c, "Webhook retrieved successfully", webhook))
+}
+
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	var req model.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		fail(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := h.service.UpdateWebhook(c.Request.Context(), uint(id), req)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	reply(c, http.StatusOK, webhookResult(c, "Webhook updated successfully", webhook))
+}
+
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	if err := h.service.DeleteWebhook(c.Request.Context(), uint(id)); err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	reply(c, http.StatusOK, result{message: "Webhook deleted successfully"})
+}
+
+// GetDeliveries returns the delivery log of a webhook, newest first. The
+// optional limit query parameter caps the number of entries.
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		fail(c, http.StatusBadRequest, "Invalid ID format")
+		return
+	}
+
+	limit := defaultDeliveryLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 || limit > maxDeliveryLimit {
+			fail(c, http.StatusBadRequest, "Invalid limit parameter")
+			return
+		}
+	}
+
+	deliveries, err := h.service.GetDeliveries(c.Request.Context(), uint(id), limit)
+	if err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	reply(c, http.StatusOK, listOf("Deliveries retrieved successfully", deliveries, len(deliveries)))
+}
+
+// webhookResult is the result of a single webhook, linked to its delete
+// route.
+func webhookResult(c *gin.Context, message string, webhook *model.Webhook) result {
+	return result{
+		message: message,
+		data:    webhook,
+		links:   model.Links{Delete: basePathOf(c) + "/webhooks/" + strconv.FormatUint(uint64(webhook.ID), 10)},
+	}
+}
+
+func webhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound):
+		fail(c, http.StatusNotFound, "Webhook not found")
+	case errors.Is(err, service.ErrInvalidWebhook):
+		fail(c, http.StatusBadRequest, err.Error())
+	default:
+		fail(c, http.StatusInternalServerError, err.Error())
+	}
+}
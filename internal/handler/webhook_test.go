@@ -0,0 +1,75 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newWebhookRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	svc := service.NewWebhookService(repository.NewMemoryWebhookRepository(), http.DefaultClient, service.WebhookOptions{
+		MaxAttempts:  3,
+		DisableAfter: 3,
+		RetryBase:    time.Second,
+		RetryMax:     time.Minute,
+		// Skips resolving example.com, which tests cannot rely on.
+		AllowPrivateNetworks: true,
+	})
+	h := handler.NewWebhookHandler(svc)
+
+	r := gin.New()
+	r.POST("/webhooks", h.CreateWebhook)
+	r.GET("/webhooks", h.GetWebhooks)
+	r.GET("/webhooks/:id/deliveries", h.GetDeliveries)
+	return r
+}
+
+func TestWebhookHandler_SecretOnlyReturnedOnCreate(t *testing.T) {
+	r := newWebhookRouter()
+
+	w := httptest.NewRecorder()
+	body := `{"url":"https://example.com/hook","events":["todo.created"]}`
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/webhooks", bytes.NewBufferString(body)))
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	var created struct {
+		Data struct {
+			Secret string `json:"secret"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(t, created.Data.Secret)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/webhooks", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), created.Data.Secret)
+}
+
+func TestWebhookHandler_Errors(t *testing.T) {
+	r := newWebhookRouter()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/webhooks", bytes.NewBufferString(`{"url":"not a url"}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/webhooks/7/deliveries", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/webhooks/7/deliveries?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
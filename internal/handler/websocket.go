@@ -0,0 +1,258 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/origin"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 45 * time.Second
+	wsMaxMessage   = 64 * 1024
+	// wsSendBuffer bounds the messages queued for one client. A client that
+	// lets it fill up is disconnected instead of slowing down everyone else.
+	wsSendBuffer = 64
+)
+
+// Client message types.
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsCreate      = "create"
+	wsUpdate      = "update"
+	wsToggle      = "toggle"
+	wsPing        = "ping"
+)
+
+// wsRequest is a message sent by the client. ID is an opaque correlation ID
+// echoed back in the matching response.
+type wsRequest struct {
+	ID       string          `json:"id"`
+	Type     string          `json:"type"`
+	All      bool            `json:"all"`
+	Projects []string        `json:"projects"`
+	TodoIDs  []uint          `json:"todo_ids"`
+	TodoID   uint            `json:"todo_id"`
+	Data     json.RawMessage `json:"data"`
+}
+
+type wsResponse struct {
+	Type  string          `json:"type"`
+	ID    string          `json:"id,omitempty"`
+	OK    bool            `json:"ok"`
+	Error string          `json:"error,omitempty"`
+	Data  interface{}     `json:"data,omitempty"`
+	Event *eventbus.Event `json:"event,omitempty"`
+}
+
+type WebSocketHandler struct {
+	service  service.TodoServiceInterface
+	bus      *eventbus.Bus
+	upgrader websocket.Upgrader
+}
+
+// NewWebSocketHandler creates the handler. Browsers may only connect from
+// the allowed origins, see origin.Check: the channel accepts mutations,
+// and a cross-site page would send the cached credentials of the API.
+func NewWebSocketHandler(service service.TodoServiceInterface, bus *eventbus.Bus, allowedOrigins []string) *WebSocketHandler {
+	return &WebSocketHandler{
+		service: service,
+		bus:     bus,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: origin.Check(allowedOrigins),
+		},
+	}
+}
+
+// Connect upgrades the request to a WebSocket. Clients subscribe to
+// projects or todo IDs to receive change events and may send create,
+// update and toggle mutations; every request gets a response carrying its
+// correlation ID. Browsers cannot set headers on the handshake, so the
+// actor may also be passed as the "actor" query parameter; like the header,
+// it is ignored for requests authenticated with an API key.
+func (h *WebSocketHandler) Connect(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+
+	ctx := c.Request.Context()
+	if actor := c.Query("actor"); actor != "" && c.GetHeader(ActorHeader) == "" && service.UserFromContext(ctx) == nil {
+		ctx = service.WithActor(ctx, actor)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	sub, _, _ := h.bus.Subscribe(0, wsSendBuffer)
+	defer sub.Close()
+
+	client := &wsClient{
+		conn:   conn,
+		send:   make(chan wsResponse, wsSendBuffer),
+		filter: &eventbus.Filter{},
+		cancel: cancel,
+	}
+	go client.writeLoop(ctx)
+	go client.forwardEvents(ctx, sub)
+
+	client.readLoop(ctx, h)
+}
+
+type wsClient struct {
+	conn   *websocket.Conn
+	send   chan wsResponse
+	filter *eventbus.Filter
+	cancel context.CancelFunc
+
+	closeOnce sync.Once
+}
+
+func (cl *wsClient) readLoop(ctx context.Context, h *WebSocketHandler) {
+	defer cl.cancel()
+
+	cl.conn.SetReadLimit(wsMaxMessage)
+	cl.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	cl.conn.SetPongHandler(func(string) error {
+		return cl.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		_, message, err := cl.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		cl.conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+
+		resp := wsResponse{Type: "response", Error: "invalid JSON message"}
+		var req wsRequest
+		if err := json.Unmarshal(message, &req); err == nil {
+			resp = h.handle(ctx, cl.filter, req)
+		}
+		if !cl.enqueue(resp) {
+			return
+		}
+	}
+}
+
+func (h *WebSocketHandler) handle(ctx context.Context, filter *eventbus.Filter, req wsRequest) wsResponse {
+	resp := wsResponse{Type: "response", ID: req.ID}
+
+	var data interface{}
+	var err error
+	switch req.Type {
+	case wsSubscribe:
+		filter.Add(req.All, req.Projects, req.TodoIDs)
+		data = filterState(filter)
+	case wsUnsubscribe:
+		filter.Remove(req.All, req.Projects, req.TodoIDs)
+		data = filterState(filter)
+	case wsCreate:
+		var create model.CreateTodoRequest
+		if err = json.Unmarshal(req.Data, &create); err == nil {
+			data, err = h.service.CreateTodo(ctx, create)
+		}
+	case wsUpdate:
+		var update model.UpdateTodoRequest
+		if err = json.Unmarshal(req.Data, &update); err == nil {
+			data, err = h.service.UpdateTodo(ctx, req.TodoID, update)
+		}
+	case wsToggle:
+		data, err = h.service.ToggleTodo(ctx, req.TodoID)
+	case wsPing:
+		data = "pong"
+	default:
+		err = errors.New("unknown message type " + req.Type)
+	}
+
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	resp.OK = true
+	resp.Data = data
+	return resp
+}
+
+func (cl *wsClient) forwardEvents(ctx context.Context, sub *eventbus.Subscription) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.Events():
+			if !ok {
+				cl.closeWith(websocket.CloseTryAgainLater, "client too slow, reconnect")
+				return
+			}
+			if !cl.filter.Matches(event) {
+				continue
+			}
+			if !cl.enqueue(wsResponse{Type: "event", OK: true, Event: &event}) {
+				return
+			}
+		}
+	}
+}
+
+// enqueue queues msg for the writer without blocking. It disconnects the
+// client and returns false when the queue is full.
+func (cl *wsClient) enqueue(msg wsResponse) bool {
+	select {
+	case cl.send <- msg:
+		return true
+	default:
+		cl.closeWith(websocket.CloseTryAgainLater, "client too slow, reconnect")
+		return false
+	}
+}
+
+func (cl *wsClient) writeLoop(ctx context.Context) {
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	defer cl.closeWith(websocket.CloseNormalClosure, "")
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-cl.send:
+			cl.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := cl.conn.WriteJSON(msg); err != nil {
+				cl.cancel()
+				return
+			}
+		case <-ping.C:
+			cl.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+			if err := cl.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				cl.cancel()
+				return
+			}
+		}
+	}
+}
+
+func (cl *wsClient) closeWith(code int, reason string) {
+	cl.closeOnce.Do(func() {
+		cl.cancel()
+		msg := websocket.FormatCloseMessage(code, reason)
+		cl.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsWriteTimeout))
+		cl.conn.Close()
+	})
+}
+
+// filterState answers subscribe and unsubscribe with what the client
+// follows afterwards.
+func filterState(f *eventbus.Filter) gin.H {
+	all, projects, todoIDs := f.State()
+	return gin.H{"all": all, "projects": projects, "todo_ids": todoIDs}
+}
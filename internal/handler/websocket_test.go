@@ -0,0 +1,186 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/outbox"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type wsMessage struct {
+	Type  string                 `json:"type"`
+	ID    string                 `json:"id"`
+	OK    bool                   `json:"ok"`
+	Error string                 `json:"error"`
+	Data  map[string]interface{} `json:"data"`
+	Event *eventbus.Event        `json:"event"`
+}
+
+func newWebSocketServer(t *testing.T) (*service.TodoService, string) {
+	gin.SetMode(gin.TestMode)
+
+	bus := eventbus.NewBus(10)
+	repo := repository.NewMemoryTodoRepository()
+	relay := outbox.NewRelay(repo.Outbox(), outbox.Options{
+		Interval:  time.Second,
+		Lease:     time.Minute,
+		BatchSize: 10,
+		RetryBase: time.Second,
+		RetryMax:  time.Second,
+		Retention: time.Hour,
+	}, outbox.NewBusSink(bus))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go relay.Run(ctx)
+	svc := service.NewTodoService(repo, repo.Events(), relay)
+
+	r := gin.New()
+	r.Use(handler.Actor())
+	r.GET("/ws", handler.NewWebSocketHandler(svc, bus, nil).Connect)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	return svc, "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws?actor=alice"
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var msg wsMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestWebSocket_MutationsAndProjectSubscription(t *testing.T) {
+	svc, url := newWebSocketServer(t)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"id": "s1", "type": "subscribe", "projects": []string{"board"}}))
+	resp := readMessage(t, conn)
+	assert.Equal(t, "response", resp.Type)
+	assert.Equal(t, "s1", resp.ID)
+	assert.True(t, resp.OK)
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{
+		"id":   "c1",
+		"type": "create",
+		"data": map[string]string{"title": "From socket", "project": "board"},
+	}))
+
+	var created, event *wsMessage
+	for created == nil || event == nil {
+		msg := readMessage(t, conn)
+		switch msg.Type {
+		case "response":
+			created = &msg
+		case "event":
+			event = &msg
+		}
+	}
+	assert.Equal(t, "c1", created.ID)
+	assert.True(t, created.OK)
+	assert.Equal(t, "From socket", created.Data["title"])
+	assert.Equal(t, eventbus.TodoCreated, event.Event.Type)
+	assert.Equal(t, "alice", event.Event.Actor)
+
+	_, err = svc.CreateTodo(context.Background(), model.CreateTodoRequest{Title: "Other project", Project: "elsewhere"})
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"id": "t1", "type": "toggle", "todo_id": 999}))
+	resp = readMessage(t, conn)
+	assert.Equal(t, "response", resp.Type, "events for unsubscribed projects must be filtered out")
+	assert.Equal(t, "t1", resp.ID)
+	assert.False(t, resp.OK)
+	assert.Equal(t, "todo not found", resp.Error)
+}
+
+func TestWebSocket_InvalidMessage(t *testing.T) {
+	_, url := newWebSocketServer(t)
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("not json")))
+	resp := readMessage(t, conn)
+	assert.False(t, resp.OK)
+	assert.Equal(t, "invalid JSON message", resp.Error)
+
+	require.NoError(t, conn.WriteJSON(map[string]string{"id": "x", "type": "explode"}))
+	resp = readMessage(t, conn)
+	assert.Equal(t, "x", resp.ID)
+	assert.Contains(t, resp.Error, "unknown message type")
+}
+
+func TestWebSocket_AuthenticatedActor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	users := service.NewUserService(repository.NewMemoryUserRepository())
+	_, err := users.CreateUser(ctx, "alice")
+	require.NoError(t, err)
+	key, _, err := users.CreateAPIKey(ctx, "alice")
+	require.NoError(t, err)
+
+	repo := repository.NewMemoryTodoRepository()
+	svc := service.NewTodoService(repo, repo.Events(), nil)
+	r := gin.New()
+	r.Use(handler.Authenticate(users), handler.Actor())
+	r.GET("/ws", handler.NewWebSocketHandler(svc, eventbus.NewBus(10), nil).Connect)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws?actor=mallory",
+		http.Header{"Authorization": {"Bearer " + key}})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.NoError(t, conn.WriteJSON(map[string]interface{}{"id": "c1", "type": "create", "data": map[string]string{"title": "Task"}}))
+	resp := readMessage(t, conn)
+	require.True(t, resp.OK, resp.Error)
+
+	history, err := svc.GetHistory(ctx, uint(resp.Data["id"].(float64)))
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	assert.Equal(t, "alice", history[0].Actor, "changes are attributed to the key's user, not the query actor")
+}
+
+func TestWebSocket_Origins(t *testing.T) {
+	dial := func(allowed []string, origin string) error {
+		repo := repository.NewMemoryTodoRepository()
+		svc := service.NewTodoService(repo, repo.Events(), nil)
+		r := gin.New()
+		r.GET("/ws", handler.NewWebSocketHandler(svc, eventbus.NewBus(10), allowed).Connect)
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+
+		if origin == "" {
+			origin = srv.URL
+		}
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", http.Header{"Origin": {origin}})
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+
+	assert.NoError(t, dial(nil, ""), "the API's own origin is allowed")
+	assert.Error(t, dial(nil, "https://evil.example"), "other origins are refused by default")
+	assert.NoError(t, dial([]string{"https://app.example"}, "https://app.example"))
+	assert.Error(t, dial([]string{"https://app.example"}, "https://evil.example"))
+}
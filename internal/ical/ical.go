@@ -0,0 +1,165 @@
+// Package ical writes todos as iCalendar (RFC 5545) VTODO components and
+// reads them back.
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+// ProdID identifies the application that produced a calendar.
+const ProdID = "-//petGoApi//todos//EN"
+
+// lineLimit is the length in octets after which content lines are folded.
+const lineLimit = 75
+
+const dateTimeFormat = "20060102T150405Z"
+
+// externalIDPrefix marks the Todo.ExternalID of todos created from a VTODO
+// of a calendar client, which keep the UID the client gave them.
+const externalIDPrefix = "ical:"
+
+// UID is the globally unique identifier of the VTODO of a todo.
+func UID(id uint) string {
+	return fmt.Sprintf("todo-%d@petgoapi", id)
+}
+
+// TodoUID is the UID of the VTODO of todo: the UID it was created with by a
+// calendar client, or else UID of its ID.
+func TodoUID(todo model.Todo) string {
+	if todo.ExternalID != nil {
+		if uid, ok := strings.CutPrefix(*todo.ExternalID, externalIDPrefix); ok {
+			return uid
+		}
+	}
+	return UID(todo.ID)
+}
+
+// ExternalID is the Todo.ExternalID of a todo created from a VTODO with uid.
+func ExternalID(uid string) string {
+	return externalIDPrefix + uid
+}
+
+// ParseUID returns the todo ID of a UID made by UID.
+func ParseUID(uid string) (uint, bool) {
+	var id uint
+	if _, err := fmt.Sscanf(uid, "todo-%d@petgoapi", &id); err != nil || id == 0 || UID(id) != uid {
+		return 0, false
+	}
+	return id, true
+}
+
+// Writer writes a VCALENDAR with one VTODO per todo. Errors are sticky: after
+// the first failed write every method returns it.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Begin opens the calendar.
+func (w *Writer) Begin() error {
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", ProdID)
+	w.line("CALSCALE", "GREGORIAN")
+	return w.err
+}
+
+// WriteTodo writes todo as a VTODO. DTSTAMP is the last modification, so the
+// same todo always produces the same component.
+func (w *Writer) WriteTodo(todo model.Todo) error {
+	w.line("BEGIN", "VTODO")
+	w.line("UID", escapeText(TodoUID(todo)))
+	w.line("DTSTAMP", formatTime(todo.UpdatedAt))
+	w.line("CREATED", formatTime(todo.CreatedAt))
+	w.line("LAST-MODIFIED", formatTime(todo.UpdatedAt))
+	w.line("SUMMARY", escapeText(todo.Title))
+	if todo.Description != "" {
+		w.line("DESCRIPTION", escapeText(todo.Description))
+	}
+	if todo.Project != "" {
+		w.line("CATEGORIES", escapeText(todo.Project))
+	}
+	if todo.DueAt != nil {
+		w.line("DUE", formatTime(*todo.DueAt))
+	}
+	if todo.Completed {
+		w.line("STATUS", "COMPLETED")
+		// The completion time is when the completed field last changed.
+		completedAt, ok := todo.FieldTimes["completed"]
+		if !ok {
+			completedAt = todo.UpdatedAt
+		}
+		w.line("COMPLETED", formatTime(completedAt))
+		w.line("PERCENT-COMPLETE", "100")
+	} else {
+		w.line("STATUS", "NEEDS-ACTION")
+	}
+	w.line("SEQUENCE", fmt.Sprint(sequence(todo.Version)))
+	w.line("END", "VTODO")
+	return w.err
+}
+
+// End closes the calendar.
+func (w *Writer) End() error {
+	w.line("END", "VCALENDAR")
+	return w.err
+}
+
+// line writes a content line, folded at lineLimit octets without splitting
+// UTF-8 sequences.
+func (w *Writer) line(name, value string) {
+	if w.err != nil {
+		return
+	}
+	var b strings.Builder
+	line := name + ":" + value
+	width := 0
+	for len(line) > 0 {
+		_, size := utf8.DecodeRuneInString(line)
+		if width+size > lineLimit {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteString(line[:size])
+		width += size
+		line = line[size:]
+	}
+	b.WriteString("\r\n")
+	_, w.err = io.WriteString(w.w, b.String())
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(dateTimeFormat)
+}
+
+// sequence counts the revisions of a todo after the first, as SEQUENCE does.
+func sequence(version uint) uint {
+	if version == 0 {
+		return 0
+	}
+	return version - 1
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+	"\r", `\n`,
+)
+
+// escapeText escapes a TEXT value.
+func escapeText(s string) string {
+	return textEscaper.Replace(s)
+}
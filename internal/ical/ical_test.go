@@ -0,0 +1,118 @@
+package ical_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stavagg/petGoApi/internal/ical"
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+func TestWriteTodo(t *testing.T) {
+	created := time.Date(2026, 10, 1, 8, 0, 0, 0, time.FixedZone("MSK", 3*3600))
+	completed := created.Add(time.Hour)
+	todo := model.Todo{
+		ID:          7,
+		Title:       "Plan; review, ship",
+		Project:     "work",
+		Completed:   true,
+		Version:     3,
+		CreatedAt:   created,
+		UpdatedAt:   completed.Add(time.Hour),
+		FieldTimes:  map[string]time.Time{"completed": completed},
+		Description: strings.Repeat("долгое описание ", 10),
+	}
+
+	var buf bytes.Buffer
+	w := ical.NewWriter(&buf)
+	require.NoError(t, w.WriteTodo(todo))
+
+	out := buf.String()
+	assert.Contains(t, out, "UID:todo-7@petgoapi\r\n")
+	assert.Contains(t, out, "SUMMARY:Plan\\; review\\, ship\r\n")
+	assert.Contains(t, out, "CREATED:20261001T050000Z\r\n")
+	assert.Contains(t, out, "LAST-MODIFIED:20261001T070000Z\r\n")
+	assert.Contains(t, out, "COMPLETED:20261001T060000Z\r\n")
+	assert.Contains(t, out, "CATEGORIES:work\r\n")
+	assert.Contains(t, out, "SEQUENCE:2\r\n")
+	assert.NotContains(t, out, "DUE:")
+
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		assert.LessOrEqual(t, len(line), 75, line)
+	}
+	unfolded := strings.ReplaceAll(out, "\r\n ", "")
+	assert.Contains(t, unfolded, "DESCRIPTION:"+strings.Repeat("долгое описание ", 10)+"\r\n")
+}
+
+func TestParseTodo(t *testing.T) {
+	data := "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTIMEZONE\r\nTZID:Europe/Moscow\r\nEND:VTIMEZONE\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:3a0f6c1e-reminders\r\n" +
+		`SUMMARY:Plan\; review\, ship` + "\r\n" +
+		"DESCRIPTION:first line\\nsecond line that is long enough to be fol\r\n" +
+		" ded\r\n" +
+		"DUE;TZID=Europe/Moscow:20261101T090000\r\n" +
+		"CREATED:20261001T080000Z\r\n" +
+		"COMPLETED:20261002T100000Z\r\n" +
+		"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:alarm\r\nEND:VALARM\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	todo, err := ical.ParseTodo(strings.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, "3a0f6c1e-reminders", todo.UID)
+	assert.Equal(t, "Plan; review, ship", todo.Summary)
+	assert.Equal(t, "first line\nsecond line that is long enough to be folded", todo.Description)
+	assert.Equal(t, time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC), todo.Due.UTC())
+	assert.Equal(t, time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC), *todo.Created)
+	assert.True(t, todo.Completed, "COMPLETED without STATUS completes the todo")
+}
+
+func TestParseTodo_RoundTrip(t *testing.T) {
+	uid := ical.ExternalID("abc@client")
+	due := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	w := ical.NewWriter(&buf)
+	require.NoError(t, w.Begin())
+	require.NoError(t, w.WriteTodo(model.Todo{ID: 3, ExternalID: &uid, Title: "Ship", DueAt: &due, Version: 1}))
+	require.NoError(t, w.End())
+
+	todo, err := ical.ParseTodo(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "abc@client", todo.UID)
+	assert.Equal(t, "Ship", todo.Summary)
+	assert.Equal(t, due, *todo.Due)
+	assert.False(t, todo.Completed, "STATUS:NEEDS-ACTION")
+}
+
+func TestParseTodo_Errors(t *testing.T) {
+	_, err := ical.ParseTodo(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VEVENT\nUID:1\nEND:VEVENT\nEND:VCALENDAR\n"))
+	assert.ErrorIs(t, err, ical.ErrNoTodo)
+
+	_, err = ical.ParseTodo(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VTODO\nSUMMARY:x\nEND:VTODO\nEND:VCALENDAR\n"))
+	assert.EqualError(t, err, "the VTODO has no UID")
+
+	_, err = ical.ParseTodo(strings.NewReader("BEGIN:VCALENDAR\nBEGIN:VTODO\nUID:1\nDUE:tomorrow\nEND:VTODO\nEND:VCALENDAR\n"))
+	assert.ErrorContains(t, err, "line 4: DUE:")
+
+	_, err = ical.ParseTodo(strings.NewReader("title,project\n"))
+	assert.Error(t, err)
+}
+
+func TestParseUID(t *testing.T) {
+	id, ok := ical.ParseUID(ical.UID(42))
+	assert.True(t, ok)
+	assert.Equal(t, uint(42), id)
+
+	for _, uid := range []string{"todo-0@petgoapi", "todo-42@elsewhere", "todo-042@petgoapi", "abc"} {
+		_, ok := ical.ParseUID(uid)
+		assert.False(t, ok, uid)
+	}
+}
@@ -0,0 +1,198 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNoTodo is returned by ParseTodo for calendars without a VTODO.
+var ErrNoTodo = errors.New("the calendar has no VTODO")
+
+// Todo is the part of a VTODO that maps onto a todo.
+type Todo struct {
+	UID         string
+	Summary     string
+	Description string
+	Due         *time.Time
+	Completed   bool
+	CompletedAt *time.Time
+	Created     *time.Time
+}
+
+// ParseTodo reads the first VTODO of a VCALENDAR. Other components, and the
+// components nested in the VTODO such as alarms, are skipped. Times with a
+// TZID are read in that IANA time zone; the VTIMEZONE definitions of the
+// calendar are not, so unknown zones and floating times are taken as UTC.
+func ParseTodo(r io.Reader) (*Todo, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var todo *Todo
+	var status string
+	var stack []string
+	for i, line := range lines {
+		name, params, value, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(value))
+			if len(stack) == 1 && stack[0] != "VCALENDAR" {
+				return nil, errors.New("not an iCalendar object")
+			}
+			if todo == nil && len(stack) == 2 && stack[1] == "VTODO" {
+				todo = &Todo{}
+			}
+			continue
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", i+1, value)
+			}
+			stack = stack[:len(stack)-1]
+			if todo != nil && len(stack) == 1 && strings.EqualFold(value, "VTODO") {
+				return finish(todo, status)
+			}
+			continue
+		}
+		if todo == nil || len(stack) != 2 || stack[1] != "VTODO" {
+			continue
+		}
+
+		switch name {
+		case "UID":
+			todo.UID = unescapeText(value)
+		case "SUMMARY":
+			todo.Summary = unescapeText(value)
+		case "DESCRIPTION":
+			todo.Description = unescapeText(value)
+		case "STATUS":
+			status = strings.ToUpper(value)
+		case "DUE", "COMPLETED", "CREATED":
+			t, err := parseTime(value, params)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s: %w", i+1, name, err)
+			}
+			switch name {
+			case "DUE":
+				todo.Due = &t
+			case "COMPLETED":
+				todo.CompletedAt = &t
+			case "CREATED":
+				todo.Created = &t
+			}
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%s is not closed", stack[len(stack)-1])
+	}
+	return nil, ErrNoTodo
+}
+
+func finish(todo *Todo, status string) (*Todo, error) {
+	if todo.UID == "" {
+		return nil, errors.New("the VTODO has no UID")
+	}
+	// Clients set STATUS:COMPLETED, COMPLETED or both; STATUS wins.
+	if status != "" {
+		todo.Completed = status == "COMPLETED"
+	} else {
+		todo.Completed = todo.CompletedAt != nil
+	}
+	return todo, nil
+}
+
+// unfold joins folded content lines. Empty lines are dropped.
+func unfold(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into its upper-cased name, its parameters
+// and its value. Quoted parameter values may contain ";" and ":".
+func parseLine(line string) (string, map[string]string, string, error) {
+	params := make(map[string]string)
+	end := strings.IndexAny(line, ";:")
+	if end <= 0 {
+		return "", nil, "", fmt.Errorf("malformed content line %q", line)
+	}
+	name := strings.ToUpper(line[:end])
+	rest := line[end:]
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return "", nil, "", fmt.Errorf("malformed parameter in %q", line)
+		}
+		key := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			closing := strings.IndexByte(rest[1:], '"')
+			if closing < 0 {
+				return "", nil, "", fmt.Errorf("unterminated quote in %q", line)
+			}
+			value, rest = rest[1:closing+1], rest[closing+2:]
+		} else {
+			stop := strings.IndexAny(rest, ";:")
+			if stop < 0 {
+				return "", nil, "", fmt.Errorf("malformed content line %q", line)
+			}
+			value, rest = rest[:stop], rest[stop:]
+		}
+		params[key] = value
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return "", nil, "", fmt.Errorf("malformed content line %q", line)
+	}
+	return name, params, rest[1:], nil
+}
+
+// parseTime reads a DATE-TIME in UTC, in the zone of its TZID or floating,
+// or a DATE, which is taken as midnight UTC.
+func parseTime(value string, params map[string]string) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == len("20060102") {
+		return time.Parse("20060102", value)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(dateTimeFormat, value)
+	}
+	loc := time.UTC
+	if tzid := params["TZID"]; tzid != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = l
+		}
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+var textUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\;`, ";",
+	`\,`, ",",
+	`\n`, "\n",
+	`\N`, "\n",
+)
+
+// unescapeText reads a TEXT value.
+func unescapeText(s string) string {
+	return textUnescaper.Replace(s)
+}
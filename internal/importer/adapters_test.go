@@ -0,0 +1,101 @@
+package importer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stavagg/petGoApi/internal/importer"
+)
+
+const todoistExport = `{
+  "projects": [{"id": "p1", "name": "Home"}, {"id": "p2", "name": "Work"}],
+  "sections": [{"id": "s1", "name": "Errands", "project_id": "p1"}],
+  "labels": [{"id": "l1", "name": "shopping"}],
+  "items": [
+    {"id": "i1", "content": "Buy milk", "description": "2%", "project_id": "p1", "section_id": "s1",
+     "labels": ["shopping"], "priority": 4, "checked": false,
+     "due": {"date": "2026-11-01T09:00:00", "timezone": "UTC"}, "added_at": "2026-10-01T08:00:00Z"},
+    {"id": "i2", "content": "Oat milk", "project_id": "p1", "parent_id": "i1", "priority": 1,
+     "checked": true, "completed_at": "2026-10-02T10:00:00Z", "due": {"date": "2026-11-01"}},
+    {"id": "i3", "content": "Gone", "project_id": "p2", "is_deleted": true},
+    {"id": "i4", "content": "Review", "project_id": "p2", "due": {"date": "someday"}}
+  ]
+}`
+
+func TestParseTodoist(t *testing.T) {
+	rows, err := importer.Parse(importer.Todoist, strings.NewReader(todoistExport))
+	require.NoError(t, err)
+	require.Len(t, rows, 3, "deleted items are left out")
+
+	milk := rows[0]
+	assert.Equal(t, "todoist:i1", milk.ExternalID)
+	assert.Equal(t, "Buy milk", milk.Request.Title)
+	assert.Equal(t, "Home", milk.Request.Project)
+	assert.Equal(t, "2%\n\nSection: Errands\nLabels: shopping\nPriority: p1", milk.Request.Description)
+	assert.Equal(t, time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC), milk.Request.DueAt.UTC())
+	assert.Equal(t, time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC), *milk.CreatedAt)
+	assert.False(t, milk.Completed)
+
+	oat := rows[1]
+	assert.Equal(t, "Subtask of: Buy milk", oat.Request.Description)
+	assert.True(t, oat.Completed)
+	assert.Equal(t, time.Date(2026, 10, 2, 10, 0, 0, 0, time.UTC), *oat.CompletedAt)
+	assert.Equal(t, "2026-11-01", oat.Request.DueAt.Format(time.DateOnly))
+
+	assert.Equal(t, `due: "someday" is not a date`, rows[2].Error)
+}
+
+const trelloExport = `{
+  "id": "5f1c0a000000000000000001",
+  "name": "Launch",
+  "lists": [
+    {"id": "l1", "name": "Doing", "closed": false},
+    {"id": "l2", "name": "Old", "closed": true}
+  ],
+  "cards": [
+    {"id": "5f1c0a100000000000000002", "name": "Landing page", "desc": "Hero and pricing", "idList": "l1",
+     "due": "2026-11-01T09:00:00.000Z", "dueComplete": true,
+     "labels": [{"name": "web", "color": "green"}, {"name": "", "color": "red"}]},
+    {"id": "5f1c0a100000000000000003", "name": "Archived", "idList": "l1", "closed": true},
+    {"id": "5f1c0a100000000000000004", "name": "In old list", "idList": "l2"}
+  ],
+  "checklists": [
+    {"id": "c1", "name": "Sections", "idCard": "5f1c0a100000000000000002", "checkItems": [
+      {"id": "5f1c0a200000000000000005", "name": "Pricing", "state": "complete"},
+      {"id": "5f1c0a200000000000000006", "name": "FAQ", "state": "incomplete"}
+    ]},
+    {"id": "c2", "name": "Ignored", "idCard": "5f1c0a100000000000000003", "checkItems": [
+      {"id": "5f1c0a200000000000000007", "name": "Hidden", "state": "incomplete"}
+    ]}
+  ]
+}`
+
+func TestParseTrello(t *testing.T) {
+	rows, err := importer.Parse(importer.Trello, strings.NewReader(trelloExport))
+	require.NoError(t, err)
+	require.Len(t, rows, 3, "archived cards and lists are left out with their checklists")
+
+	card := rows[0]
+	assert.Equal(t, "trello:card:5f1c0a100000000000000002", card.ExternalID)
+	assert.Equal(t, "Landing page", card.Request.Title)
+	assert.Equal(t, "Launch", card.Request.Project)
+	assert.Equal(t, "Hero and pricing\n\nList: Doing\nLabels: web, red", card.Request.Description)
+	assert.True(t, card.Completed)
+	assert.Equal(t, time.Date(2026, 11, 1, 9, 0, 0, 0, time.UTC), card.Request.DueAt.UTC())
+	assert.Equal(t, time.Unix(0x5f1c0a10, 0).UTC(), *card.CreatedAt)
+
+	assert.Equal(t, "trello:checkitem:5f1c0a200000000000000005", rows[1].ExternalID)
+	assert.Equal(t, "Pricing", rows[1].Request.Title)
+	assert.Equal(t, "Checklist: Sections\nCard: Landing page", rows[1].Request.Description)
+	assert.True(t, rows[1].Completed)
+	assert.False(t, rows[2].Completed)
+}
+
+func TestParseTrello_RejectsOtherJSON(t *testing.T) {
+	_, err := importer.Parse(importer.Trello, strings.NewReader(todoistExport))
+	assert.EqualError(t, err, "not a Trello board export: the board has no id")
+}
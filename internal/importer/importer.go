@@ -0,0 +1,209 @@
+// Package importer reads todos from the files accepted by
+// POST /api/v1/todos/import: CSV, JSON Lines and todo.txt files, and the
+// JSON exports of Todoist and Trello. Parsing is lenient per line: a line
+// that cannot be read becomes a row with Error set, so one report can list
+// every problem in the file.
+package importer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+const (
+	CSV     = "csv"
+	JSONL   = "jsonl"
+	TodoTxt = "todotxt"
+	Todoist = "todoist"
+	Trello  = "trello"
+)
+
+// Formats lists the supported formats.
+var Formats = []string{CSV, JSONL, TodoTxt, Todoist, Trello}
+
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// maxLine is the longest line the JSON Lines and todo.txt readers accept.
+const maxLine = 1 << 20
+
+// Parse reads every row of r in format. The error is only set when the file
+// as a whole cannot be read.
+func Parse(format string, r io.Reader) ([]model.ImportRow, error) {
+	switch format {
+	case CSV:
+		return parseCSV(r)
+	case JSONL:
+		return parseLines(r, parseJSONLine)
+	case TodoTxt:
+		return parseLines(r, parseTodoTxtLine)
+	case Todoist:
+		return parseTodoist(r)
+	case Trello:
+		return parseTrello(r)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// parseCSV reads a CSV file with a header row. Columns are matched by name,
+// case-insensitively; title is required and unknown columns are ignored, so
+// files written by the export can be imported back.
+func parseCSV(r io.Reader) ([]model.ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, dup := columns[name]; !dup {
+			columns[name] = i
+		}
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("line 1: the header has no title column")
+	}
+
+	rows := []model.ImportRow{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, err
+			}
+			rows = append(rows, model.ImportRow{Line: parseErr.StartLine, Error: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := model.ImportRow{
+			Line: line,
+			Request: model.CreateTodoRequest{
+				Title:       field("title"),
+				Description: field("description"),
+				Project:     field("project"),
+			},
+		}
+		row.Error = firstError(
+			parseBool(field("completed"), &row.Completed),
+			parseTimeField("due_at", field("due_at"), &row.Request.DueAt),
+			parseTimeField("created_at", field("created_at"), &row.CreatedAt),
+			parseTimeField("completed_at", field("completed_at"), &row.CompletedAt),
+		)
+		rows = append(rows, row)
+	}
+}
+
+// parseLines calls parse for every non-blank line of r.
+func parseLines(r io.Reader, parse func(line string) model.ImportRow) ([]model.ImportRow, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLine)
+
+	rows := []model.ImportRow{}
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" {
+			continue
+		}
+		row := parse(line)
+		row.Line = n
+		rows = append(rows, row)
+	}
+	return rows, scanner.Err()
+}
+
+// jsonTodo is a JSON Lines record. Todos written by the JSON Lines export
+// have these fields among others.
+type jsonTodo struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Project     string     `json:"project"`
+	Completed   bool       `json:"completed"`
+	DueAt       *time.Time `json:"due_at"`
+	CreatedAt   *time.Time `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+func parseJSONLine(line string) model.ImportRow {
+	var todo jsonTodo
+	if err := json.Unmarshal([]byte(line), &todo); err != nil {
+		return model.ImportRow{Error: "invalid JSON: " + err.Error()}
+	}
+	return model.ImportRow{
+		Request: model.CreateTodoRequest{
+			Title:       todo.Title,
+			Description: todo.Description,
+			Project:     todo.Project,
+			DueAt:       todo.DueAt,
+		},
+		Completed:   todo.Completed,
+		CreatedAt:   todo.CreatedAt,
+		CompletedAt: todo.CompletedAt,
+	}
+}
+
+func parseBool(value string, dst *bool) error {
+	if value == "" {
+		return nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("completed: %q is not a boolean", value)
+	}
+	*dst = b
+	return nil
+}
+
+// parseTimeField accepts RFC 3339 times and plain dates, which are taken as
+// midnight UTC.
+func parseTimeField(name, value string, dst **time.Time) error {
+	if value == "" {
+		return nil
+	}
+	t, err := parseTime(value)
+	if err != nil {
+		return fmt.Errorf("%s: %q is not a date or an RFC 3339 time", name, value)
+	}
+	*dst = &t
+	return nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+func firstError(errs ...error) string {
+	for _, err := range errs {
+		if err != nil {
+			return err.Error()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,105 @@
+package importer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stavagg/petGoApi/internal/importer"
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+func date(s string) *time.Time {
+	t, err := time.Parse(time.DateOnly, s)
+	if err != nil {
+		panic(err)
+	}
+	return &t
+}
+
+func TestParseCSV(t *testing.T) {
+	rows, err := importer.Parse(importer.CSV, strings.NewReader(
+		"Title,Project,Completed,Due_At,Notes\n"+
+			"Buy milk,home,false,2026-11-01,ignored\n"+
+			"\"Write\nreport\",work,yes,,\n"+
+			"Call,,true,2026-11-01T09:00:00+03:00\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	assert.Equal(t, model.ImportRow{
+		Line:    2,
+		Request: model.CreateTodoRequest{Title: "Buy milk", Project: "home", DueAt: date("2026-11-01")},
+	}, rows[0])
+
+	assert.Equal(t, 3, rows[1].Line)
+	assert.Equal(t, "Write\nreport", rows[1].Request.Title)
+	assert.Equal(t, `completed: "yes" is not a boolean`, rows[1].Error)
+
+	assert.Equal(t, 5, rows[2].Line, "lines count the newline inside the quoted title")
+	assert.True(t, rows[2].Completed)
+	assert.True(t, rows[2].Request.DueAt.Equal(time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC)))
+}
+
+func TestParseCSV_RequiresTitleColumn(t *testing.T) {
+	_, err := importer.Parse(importer.CSV, strings.NewReader("name,project\nBuy milk,home\n"))
+	assert.EqualError(t, err, "line 1: the header has no title column")
+}
+
+func TestParseJSONL(t *testing.T) {
+	rows, err := importer.Parse(importer.JSONL, strings.NewReader(
+		`{"id":9,"title":"Buy milk","completed":true,"created_at":"2026-01-02T03:04:05Z"}`+"\n"+
+			"\n"+
+			`{"title":`+"\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+
+	assert.Equal(t, 1, rows[0].Line)
+	assert.Equal(t, "Buy milk", rows[0].Request.Title)
+	assert.True(t, rows[0].Completed)
+	assert.Equal(t, time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), *rows[0].CreatedAt)
+
+	assert.Equal(t, 3, rows[1].Line)
+	assert.Contains(t, rows[1].Error, "invalid JSON")
+}
+
+func TestParseTodoTxt(t *testing.T) {
+	rows, err := importer.Parse(importer.TodoTxt, strings.NewReader(
+		"(A) 2026-09-30 Call mom @phone +family +weekly due:2026-10-05\n"+
+			"x 2026-10-02 2026-09-30 Write report +work pri:B\n"+
+			"x Pay rent\n"+
+			"Plain task\n"+
+			"Broken due:tomorrow\n"))
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+
+	assert.Equal(t, model.ImportRow{
+		Line: 1,
+		Request: model.CreateTodoRequest{
+			Title:       "Call mom @phone +weekly",
+			Description: "pri:A",
+			Project:     "family",
+			DueAt:       date("2026-10-05"),
+		},
+		CreatedAt: date("2026-09-30"),
+	}, rows[0])
+
+	assert.Equal(t, model.ImportRow{
+		Line:        2,
+		Request:     model.CreateTodoRequest{Title: "Write report", Description: "pri:B", Project: "work"},
+		Completed:   true,
+		CompletedAt: date("2026-10-02"),
+		CreatedAt:   date("2026-09-30"),
+	}, rows[1])
+
+	assert.Equal(t, model.ImportRow{Line: 3, Request: model.CreateTodoRequest{Title: "Pay rent"}, Completed: true}, rows[2])
+	assert.Equal(t, "Plain task", rows[3].Request.Title)
+	assert.Equal(t, `due: "tomorrow" is not a date`, rows[4].Error)
+}
+
+func TestParse_UnknownFormat(t *testing.T) {
+	_, err := importer.Parse("xlsx", strings.NewReader(""))
+	assert.ErrorIs(t, err, importer.ErrUnknownFormat)
+}
@@ -0,0 +1,148 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+// todoistExport is the JSON returned by the Todoist Sync API for a full
+// sync (resource_types=["all"]), which is what Todoist export tools save.
+type todoistExport struct {
+	Projects []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"projects"`
+	Sections []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"sections"`
+	Items []todoistItem `json:"items"`
+}
+
+type todoistItem struct {
+	ID          string      `json:"id"`
+	Content     string      `json:"content"`
+	Description string      `json:"description"`
+	ProjectID   string      `json:"project_id"`
+	SectionID   string      `json:"section_id"`
+	ParentID    string      `json:"parent_id"`
+	Checked     bool        `json:"checked"`
+	IsDeleted   bool        `json:"is_deleted"`
+	Labels      []string    `json:"labels"`
+	Priority    int         `json:"priority"`
+	Due         *todoistDue `json:"due"`
+	AddedAt     *time.Time  `json:"added_at"`
+	CompletedAt *time.Time  `json:"completed_at"`
+}
+
+type todoistDue struct {
+	// Date is a date, a floating local time or a UTC time.
+	Date     string `json:"date"`
+	Timezone string `json:"timezone"`
+}
+
+// parseTodoist maps every item of a Todoist export to a todo in the project
+// of the item. Sub-tasks, Todoist's checklists, become todos of their own
+// that name their parent. Sections, labels and priorities have no field of
+// their own, so they are listed under the description. Deleted items are
+// left out.
+func parseTodoist(r io.Reader) ([]model.ImportRow, error) {
+	var export todoistExport
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return nil, fmt.Errorf("not a Todoist export: %w", err)
+	}
+
+	projects := make(map[string]string, len(export.Projects))
+	for _, p := range export.Projects {
+		projects[p.ID] = p.Name
+	}
+	sections := make(map[string]string, len(export.Sections))
+	for _, s := range export.Sections {
+		sections[s.ID] = s.Name
+	}
+	titles := make(map[string]string, len(export.Items))
+	for _, item := range export.Items {
+		titles[item.ID] = item.Content
+	}
+
+	rows := []model.ImportRow{}
+	for _, item := range export.Items {
+		if item.IsDeleted {
+			continue
+		}
+		row := model.ImportRow{
+			ExternalID: "todoist:" + item.ID,
+			Request: model.CreateTodoRequest{
+				Title:   strings.TrimSpace(item.Content),
+				Project: projects[item.ProjectID],
+			},
+			Completed:   item.Checked,
+			CreatedAt:   item.AddedAt,
+			CompletedAt: item.CompletedAt,
+		}
+
+		var details []string
+		if name := sections[item.SectionID]; name != "" {
+			details = append(details, "Section: "+name)
+		}
+		if parent, ok := titles[item.ParentID]; ok {
+			details = append(details, "Subtask of: "+parent)
+		}
+		if len(item.Labels) > 0 {
+			details = append(details, "Labels: "+strings.Join(item.Labels, ", "))
+		}
+		// Todoist stores p1, the most urgent, as 4 and no priority as 1.
+		if item.Priority > 1 {
+			details = append(details, fmt.Sprintf("Priority: p%d", 5-item.Priority))
+		}
+		row.Request.Description = withDetails(item.Description, details)
+
+		if item.Due != nil && item.Due.Date != "" {
+			due, err := todoistDueTime(*item.Due)
+			if err != nil {
+				row.Error = err.Error()
+			}
+			row.Request.DueAt = due
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// todoistDueTime reads a due date. Floating times are taken in the time
+// zone of the due date, or UTC without one.
+func todoistDueTime(due todoistDue) (*time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, due.Date); err == nil {
+		return &t, nil
+	}
+	loc := time.UTC
+	if due.Timezone != "" {
+		if l, err := time.LoadLocation(due.Timezone); err == nil {
+			loc = l
+		}
+	}
+	for _, layout := range []string{"2006-01-02T15:04:05", time.DateOnly} {
+		if t, err := time.ParseInLocation(layout, due.Date, loc); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("due: %q is not a date", due.Date)
+}
+
+// withDetails appends "Key: value" lines to a description, separated from
+// it by a blank line.
+func withDetails(description string, details []string) string {
+	description = strings.TrimSpace(description)
+	if len(details) == 0 {
+		return description
+	}
+	if description == "" {
+		return strings.Join(details, "\n")
+	}
+	return description + "\n\n" + strings.Join(details, "\n")
+}
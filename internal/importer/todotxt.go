@@ -0,0 +1,83 @@
+package importer
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+// A todo.txt line (https://github.com/todotxt/todo.txt) is
+//
+//	x 2026-10-02 2026-09-30 Call mom @phone +family due:2026-10-05
+//	(A) 2026-09-30 Write report +work
+//
+// "x" marks a completed task and is followed by the completion and the
+// creation dates; a pending task may start with a priority and the creation
+// date. Todos have neither priorities nor contexts, so @contexts stay in the
+// title and the priority is kept in the description as pri:A, the way
+// todo.txt keeps it on completed tasks. The first +project becomes the
+// project; due:YYYY-MM-DD sets the due date.
+
+var priority = regexp.MustCompile(`^\(([A-Z])\)$`)
+
+func parseTodoTxtLine(line string) model.ImportRow {
+	var row model.ImportRow
+	fields := strings.Fields(line)
+
+	date := func(dst **time.Time) bool {
+		if len(fields) == 0 {
+			return false
+		}
+		t, err := time.Parse(time.DateOnly, fields[0])
+		if err != nil {
+			return false
+		}
+		*dst = &t
+		fields = fields[1:]
+		return true
+	}
+
+	var pri string
+	if len(fields) > 0 && fields[0] == "x" {
+		row.Completed = true
+		fields = fields[1:]
+		if date(&row.CompletedAt) {
+			date(&row.CreatedAt)
+		}
+	} else {
+		if len(fields) > 0 {
+			if m := priority.FindStringSubmatch(fields[0]); m != nil {
+				pri = m[1]
+				fields = fields[1:]
+			}
+		}
+		date(&row.CreatedAt)
+	}
+
+	title := make([]string, 0, len(fields))
+	for _, field := range fields {
+		switch {
+		case strings.HasPrefix(field, "+") && len(field) > 1 && row.Request.Project == "":
+			row.Request.Project = field[1:]
+		case strings.HasPrefix(field, "due:"):
+			due, err := time.Parse(time.DateOnly, field[len("due:"):])
+			if err != nil {
+				row.Error = fmt.Sprintf("due: %q is not a date", field[len("due:"):])
+				continue
+			}
+			row.Request.DueAt = &due
+		case strings.HasPrefix(field, "pri:") && priority.MatchString("("+field[len("pri:"):]+")"):
+			pri = field[len("pri:"):]
+		default:
+			title = append(title, field)
+		}
+	}
+	row.Request.Title = strings.Join(title, " ")
+	if pri != "" {
+		row.Request.Description = "pri:" + pri
+	}
+	return row
+}
@@ -0,0 +1,144 @@
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+// trelloBoard is the JSON of a board exported from its menu in Trello
+// ("Print, export and share", "Export as JSON").
+type trelloBoard struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Lists []struct {
+		ID     string `json:"id"`
+		Name   string `json:"name"`
+		Closed bool   `json:"closed"`
+	} `json:"lists"`
+	Cards []struct {
+		ID          string     `json:"id"`
+		Name        string     `json:"name"`
+		Desc        string     `json:"desc"`
+		IDList      string     `json:"idList"`
+		Closed      bool       `json:"closed"`
+		Due         *time.Time `json:"due"`
+		DueComplete bool       `json:"dueComplete"`
+		Labels      []struct {
+			Name  string `json:"name"`
+			Color string `json:"color"`
+		} `json:"labels"`
+	} `json:"cards"`
+	Checklists []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		IDCard     string `json:"idCard"`
+		CheckItems []struct {
+			ID    string     `json:"id"`
+			Name  string     `json:"name"`
+			State string     `json:"state"`
+			Due   *time.Time `json:"due"`
+		} `json:"checkItems"`
+	} `json:"checklists"`
+}
+
+// parseTrello maps a Trello board to a project of the same name. Cards
+// become todos, completed when their due date is marked complete, and the
+// items of their checklists become todos of their own that name the card.
+// Lists and labels have no field of their own, so they are listed under the
+// description. Archived cards and the cards of archived lists are left out.
+func parseTrello(r io.Reader) ([]model.ImportRow, error) {
+	var board trelloBoard
+	if err := json.NewDecoder(r).Decode(&board); err != nil {
+		return nil, fmt.Errorf("not a Trello board export: %w", err)
+	}
+	if board.ID == "" {
+		return nil, fmt.Errorf("not a Trello board export: the board has no id")
+	}
+
+	lists := make(map[string]string, len(board.Lists))
+	closedLists := make(map[string]bool)
+	for _, list := range board.Lists {
+		lists[list.ID] = list.Name
+		closedLists[list.ID] = list.Closed
+	}
+
+	rows := []model.ImportRow{}
+	cards := make(map[string]string, len(board.Cards))
+	for _, card := range board.Cards {
+		if card.Closed || closedLists[card.IDList] {
+			continue
+		}
+		cards[card.ID] = card.Name
+
+		var details []string
+		if name := lists[card.IDList]; name != "" {
+			details = append(details, "List: "+name)
+		}
+		var labels []string
+		for _, label := range card.Labels {
+			name := label.Name
+			if name == "" {
+				name = label.Color
+			}
+			if name != "" {
+				labels = append(labels, name)
+			}
+		}
+		if len(labels) > 0 {
+			details = append(details, "Labels: "+strings.Join(labels, ", "))
+		}
+
+		rows = append(rows, model.ImportRow{
+			ExternalID: "trello:card:" + card.ID,
+			Request: model.CreateTodoRequest{
+				Title:       strings.TrimSpace(card.Name),
+				Description: withDetails(card.Desc, details),
+				Project:     board.Name,
+				DueAt:       card.Due,
+			},
+			Completed: card.DueComplete,
+			CreatedAt: trelloCreatedAt(card.ID),
+		})
+	}
+
+	for _, checklist := range board.Checklists {
+		card, ok := cards[checklist.IDCard]
+		if !ok {
+			continue
+		}
+		for _, item := range checklist.CheckItems {
+			rows = append(rows, model.ImportRow{
+				ExternalID: "trello:checkitem:" + item.ID,
+				Request: model.CreateTodoRequest{
+					Title:       strings.TrimSpace(item.Name),
+					Description: "Checklist: " + checklist.Name + "\nCard: " + card,
+					Project:     board.Name,
+					DueAt:       item.Due,
+				},
+				Completed: item.State == "complete",
+				CreatedAt: trelloCreatedAt(item.ID),
+			})
+		}
+	}
+	return rows, nil
+}
+
+// trelloCreatedAt reads the creation time Trello IDs start with: they are
+// MongoDB object IDs, whose first 8 hex digits are a Unix time.
+func trelloCreatedAt(id string) *time.Time {
+	if len(id) != 24 {
+		return nil
+	}
+	seconds, err := strconv.ParseInt(id[:8], 16, 64)
+	if err != nil {
+		return nil
+	}
+	t := time.Unix(seconds, 0).UTC()
+	return &t
+}
@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+// Envelope is the body of responses in the v2 media types, such as
+// application/vnd.petgoapi.v2+json. Data is what the route returns, the
+// same value as data in v1; it is null for routes that return nothing.
+type Envelope struct {
+	Data  interface{} `json:"data"`
+	Meta  Meta        `json:"meta"`
+	Links Links       `json:"links"`
+}
+
+// Meta describes a response. Total is the number of items of a list before
+// pagination; Pagination is set when the list was asked for a page.
+type Meta struct {
+	RequestID  string      `json:"request_id"`
+	Total      *int        `json:"total,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+	// UndoExpiresAt is set with Links.Undo, after actions that can be undone.
+	UndoExpiresAt *time.Time `json:"undo_expires_at,omitempty"`
+}
+
+// Pagination is the page of a list, from the limit and offset query
+// parameters.
+type Pagination struct {
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// Links are the URLs of the resource and of what can be done with it next,
+// relative to the host. Only Self is always set.
+type Links struct {
+	Self   string `json:"self"`
+	Next   string `json:"next,omitempty"`
+	Prev   string `json:"prev,omitempty"`
+	Toggle string `json:"toggle,omitempty"`
+	Delete string `json:"delete,omitempty"`
+	Undo   string `json:"undo,omitempty"`
+}
+
+// ErrorBody is the body of error responses. Meta is only set in the v2
+// media types.
+type ErrorBody struct {
+	Error string `json:"error"`
+	// Lines are the invalid lines of an import file.
+	Lines []ImportError `json:"lines,omitempty"`
+	Meta  *Meta         `json:"meta,omitempty"`
+}
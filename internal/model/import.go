@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+// ImportRow is a todo read from one line of an import file, or from one
+// item of an export of another tracker. Error is set when it could not be
+// parsed.
+type ImportRow struct {
+	Line int
+	// ExternalID is set for items of other trackers, see Todo.ExternalID.
+	ExternalID string
+	Request    CreateTodoRequest
+	Completed  bool
+	// CreatedAt and CompletedAt come from the file when it has them.
+	CreatedAt   *time.Time
+	CompletedAt *time.Time
+	Error       string
+}
+
+// ImportError reports why a line of an import file, or an item of another
+// tracker, was rejected.
+type ImportError struct {
+	Line       int    `json:"line,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
+	Error      string `json:"error"`
+}
+
+// ImportReport is the outcome of an import. Todos holds the created todos,
+// or the todos a dry run would create, without IDs. Skipped lists the
+// external IDs that were imported before. Nothing is imported when Errors
+// is not empty.
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Total   int           `json:"total"`
+	Todos   []Todo        `json:"todos"`
+	Skipped []string      `json:"skipped"`
+	Errors  []ImportError `json:"errors"`
+}
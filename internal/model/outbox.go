@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// OutboxMessage is a change event written in the same transaction as the
+// change itself. The relay publishes it afterwards, so an event is never
+// lost when the process dies between the commit and the publish.
+type OutboxMessage struct {
+	ID uint `json:"id" gorm:"primaryKey"`
+	// AggregateID is the todo the event belongs to. Messages of one todo
+	// are published in ID order.
+	AggregateID uint   `json:"aggregate_id" gorm:"not null;index"`
+	EventType   string `json:"event_type" gorm:"not null"`
+	Payload     string `json:"payload" gorm:"type:text;not null"`
+	// AvailableAt is when the message may be claimed next. Claiming pushes
+	// it forward by a lease, a failed publish by the retry backoff.
+	AvailableAt time.Time  `json:"available_at" gorm:"not null"`
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	LastError   string     `json:"last_error"`
+	PublishedAt *time.Time `json:"published_at" gorm:"index"`
+	// DeadAt is set when the relay gave up on the message after its last
+	// attempt. Dead messages stay in the outbox for inspection and no
+	// longer hold back the later messages of their todo.
+	DeadAt    *time.Time `json:"dead_at" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at"`
+}
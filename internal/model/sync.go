@@ -0,0 +1,87 @@
+package model
+
+import "time"
+
+// Sync mutation operations.
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+// Sync mutation outcomes.
+const (
+	SyncApplied = "applied"
+	// SyncMerged means part of an update was applied and the fields listed
+	// in Conflicts were left at the server value.
+	SyncMerged   = "merged"
+	SyncConflict = "conflict"
+	SyncNotFound = "not_found"
+	SyncRejected = "rejected"
+)
+
+// TodoTombstone remembers a todo that was purged from the trash, so sync
+// clients that last saw it alive still learn it is gone.
+type TodoTombstone struct {
+	ID       uint      `json:"id" gorm:"primaryKey"`
+	TodoID   uint      `json:"todo_id" gorm:"not null;index"`
+	PurgedAt time.Time `json:"purged_at" gorm:"not null;index"`
+	// ExternalID and Project are those of the purged todo.
+	ExternalID *string `json:"external_id,omitempty"`
+	Project    string  `json:"project"`
+}
+
+// SyncTombstone is a deleted todo. ExternalID is set for todos imported
+// from other trackers, which know them by it. Project is the project the
+// todo was in when it was deleted.
+type SyncTombstone struct {
+	ID         uint      `json:"id"`
+	ExternalID string    `json:"external_id,omitempty"`
+	Project    string    `json:"project"`
+	DeletedAt  time.Time `json:"deleted_at"`
+}
+
+// SyncChanges is what changed since a change token. Token is passed as
+// since on the next pull; while HasMore is set the client should pull again
+// right away.
+type SyncChanges struct {
+	Created []Todo          `json:"created"`
+	Updated []Todo          `json:"updated"`
+	Deleted []SyncTombstone `json:"deleted"`
+	Token   string          `json:"token"`
+	HasMore bool            `json:"has_more"`
+}
+
+// SyncMutation is one offline change pushed by a client. BaseVersion or
+// BaseUpdatedAt describe the server state the change was made against. With
+// BaseVersion a todo that changed since is reported as a conflict instead of
+// being applied; an update with BaseUpdatedAt is merged field by field and
+// only fields both sides changed conflict.
+type SyncMutation struct {
+	// ClientID is an opaque ID echoed back in the result, e.g. the
+	// temporary ID of a todo created offline.
+	ClientID      string            `json:"client_id"`
+	Op            string            `json:"op" binding:"required"`
+	ID            uint              `json:"id"`
+	BaseVersion   uint              `json:"base_version"`
+	BaseUpdatedAt *time.Time        `json:"base_updated_at"`
+	Data          UpdateTodoRequest `json:"data"`
+}
+
+type SyncPushRequest struct {
+	Mutations []SyncMutation `json:"mutations" binding:"required,max=500,dive"`
+}
+
+// SyncResult reports the outcome of a mutation together with the resolved
+// server state of the todo.
+type SyncResult struct {
+	ClientID string `json:"client_id,omitempty"`
+	Op       string `json:"op"`
+	Status   string `json:"status"`
+	ID       uint   `json:"id,omitempty"`
+	Todo     *Todo  `json:"todo,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Conflicts lists the fields of a merged or conflicting update that
+	// were left at the server value.
+	Conflicts []FieldConflict `json:"conflicts,omitempty"`
+}
@@ -2,24 +2,102 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Todo struct {
-	ID          uint      `json:"id" gorm:"primaryKey"`
-	Title       string    `json:"title" binding:"required" gorm:"not null"`
-	Description string    `json:"description"`
-	Completed   bool      `json:"completed" gorm:"default:false"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Title       string         `json:"title" binding:"required" gorm:"not null"`
+	Description string         `json:"description"`
+	Project     string         `json:"project" gorm:"index"`
+	Completed   bool           `json:"completed" gorm:"default:false"`
+	DueAt       *time.Time     `json:"due_at"`
+	Version     uint           `json:"version" gorm:"not null;default:1"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"index"`
+	DeletedAt   gorm.DeletedAt `json:"deleted_at" gorm:"index"`
+
+	// ExternalID identifies the item of another tracker the todo was
+	// imported from, like "trello:card:5f1c...". Imports skip items whose
+	// todo exists, so running one twice creates nothing new.
+	ExternalID *string `json:"external_id,omitempty" gorm:"uniqueIndex"`
+
+	// FieldTimes maps each user-editable field to the time it last changed.
+	// Sync uses it to merge concurrent edits field by field.
+	FieldTimes map[string]time.Time `json:"field_times,omitempty" gorm:"serializer:json"`
 }
 
 type CreateTodoRequest struct {
-	Title       string `json:"title" binding:"required"`
-	Description string `json:"description"`
+	Title       string     `json:"title" binding:"required,max=255"`
+	Description string     `json:"description" binding:"max=1000"`
+	Project     string     `json:"project" binding:"max=100"`
+	DueAt       *time.Time `json:"due_at"`
 }
 
 type UpdateTodoRequest struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	Completed   *bool  `json:"completed"`
+	Title       string     `json:"title" binding:"max=255"`
+	Description string     `json:"description" binding:"max=1000"`
+	Project     string     `json:"project" binding:"max=100"`
+	Completed   *bool      `json:"completed"`
+	DueAt       *time.Time `json:"due_at"`
+	// ClearDescription and ClearDueAt remove the description and the due
+	// date. An empty description or a missing due_at leaves them as is, like
+	// the other fields, so clearing needs its own flag.
+	ClearDescription bool `json:"clear_description" binding:"excluded_with=Description"`
+	ClearDueAt       bool `json:"clear_due_at" binding:"excluded_with=DueAt"`
+}
+
+// EditableFields are the fields FieldTimes tracks.
+var EditableFields = []string{"title", "description", "project", "completed", "due_at"}
+
+// ModifiedAt returns when field last changed. Todos stored before field
+// times existed fall back to UpdatedAt for every field.
+func (t *Todo) ModifiedAt(field string) time.Time {
+	if len(t.FieldTimes) == 0 {
+		return t.UpdatedAt
+	}
+	return t.FieldTimes[field]
+}
+
+// Touch stamps at on every field that differs from before. It replaces
+// FieldTimes instead of writing into it, since copies of a todo share the
+// map.
+func (t *Todo) Touch(before TodoSnapshot, at time.Time) {
+	times := make(map[string]time.Time, len(EditableFields))
+	for field, changed := range t.FieldTimes {
+		times[field] = changed
+	}
+	// A todo stored before field times existed gets them from UpdatedAt,
+	// so the fields this change leaves alone keep their age.
+	if len(t.FieldTimes) == 0 && !t.UpdatedAt.IsZero() {
+		for _, field := range EditableFields {
+			times[field] = t.UpdatedAt
+		}
+	}
+	for _, change := range before.Diff(t.Snapshot()) {
+		if change.Field != "deleted" {
+			times[change.Field] = at
+		}
+	}
+	t.FieldTimes = times
+}
+
+// FieldConflict is a field both sides changed to different values since the
+// client last synced.
+type FieldConflict struct {
+	Field            string      `json:"field"`
+	Server           interface{} `json:"server"`
+	Client           interface{} `json:"client"`
+	ServerModifiedAt time.Time   `json:"server_modified_at"`
+}
+
+// ProjectSummary sums up the todos of a project, the ones in the trash
+// left out. Any todo added to, edited in or removed from the project
+// changes it.
+type ProjectSummary struct {
+	Project    string `json:"project"`
+	Todos      int64  `json:"todos"`
+	IDSum      int64  `json:"id_sum"`
+	VersionSum int64  `json:"version_sum"`
 }
@@ -0,0 +1,83 @@
+package model
+
+import "time"
+
+const (
+	TodoCreated  = "created"
+	TodoUpdated  = "updated"
+	TodoToggled  = "toggled"
+	TodoDeleted  = "deleted"
+	TodoRestored = "restored"
+	TodoReverted = "reverted"
+)
+
+// TodoEvent is an append-only record of a single change to a todo. Revisions
+// are numbered per todo starting at 1.
+type TodoEvent struct {
+	ID        uint          `json:"id" gorm:"primaryKey"`
+	TodoID    uint          `json:"todo_id" gorm:"not null;uniqueIndex:idx_todo_events_revision"`
+	Revision  uint          `json:"revision" gorm:"not null;uniqueIndex:idx_todo_events_revision"`
+	Action    string        `json:"action" gorm:"not null"`
+	Actor     string        `json:"actor" gorm:"not null"`
+	Changes   []FieldChange `json:"changes" gorm:"serializer:json"`
+	Snapshot  TodoSnapshot  `json:"snapshot" gorm:"serializer:json"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// TodoSnapshot holds the user-editable state of a todo after an event.
+type TodoSnapshot struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Project     string     `json:"project"`
+	Completed   bool       `json:"completed"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	Deleted     bool       `json:"deleted"`
+}
+
+func (t *Todo) Snapshot() TodoSnapshot {
+	return TodoSnapshot{
+		Title:       t.Title,
+		Description: t.Description,
+		Project:     t.Project,
+		Completed:   t.Completed,
+		DueAt:       t.DueAt,
+		Deleted:     t.DeletedAt.Valid,
+	}
+}
+
+// Diff lists the fields that differ between two snapshots.
+func (s TodoSnapshot) Diff(next TodoSnapshot) []FieldChange {
+	changes := []FieldChange{}
+	if s.Title != next.Title {
+		changes = append(changes, FieldChange{Field: "title", From: s.Title, To: next.Title})
+	}
+	if s.Description != next.Description {
+		changes = append(changes, FieldChange{Field: "description", From: s.Description, To: next.Description})
+	}
+	if s.Project != next.Project {
+		changes = append(changes, FieldChange{Field: "project", From: s.Project, To: next.Project})
+	}
+	if s.Completed != next.Completed {
+		changes = append(changes, FieldChange{Field: "completed", From: s.Completed, To: next.Completed})
+	}
+	if !sameTime(s.DueAt, next.DueAt) {
+		changes = append(changes, FieldChange{Field: "due_at", From: s.DueAt, To: next.DueAt})
+	}
+	if s.Deleted != next.Deleted {
+		changes = append(changes, FieldChange{Field: "deleted", From: s.Deleted, To: next.Deleted})
+	}
+	return changes
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
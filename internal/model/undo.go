@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// UndoAction is a recent action that can be reverted with its token until
+// ExpiresAt. It is stored with the todos, so any instance can undo it.
+type UndoAction struct {
+	Token     string       `gorm:"primaryKey"`
+	Action    string       `gorm:"not null"`
+	Targets   []UndoTarget `gorm:"serializer:json"`
+	ExpiresAt time.Time    `gorm:"index"`
+}
+
+// UndoTarget is a todo touched by an undoable action together with the
+// version it had right after the action.
+type UndoTarget struct {
+	ID      uint `json:"id"`
+	Version uint `json:"version"`
+}
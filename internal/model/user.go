@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// User is a caller of the API. Changes made with one of the user's API
+// keys are attributed to the user's name in the todo history.
+type User struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"uniqueIndex;size:100;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIKey authenticates requests as its user. Only the SHA-256 hash of the
+// key is stored; Prefix, the start of the key, tells keys apart.
+type APIKey struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index;not null"`
+	Prefix    string    `json:"prefix" gorm:"size:16;not null"`
+	Hash      string    `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,54 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// requests checks requests against their binding tags. Gin enforces the
+// same tags on the REST API and the OpenAPI document is generated from
+// them, so they are the single place the limits of a request are declared.
+var requests = func() *validator.Validate {
+	v := validator.New()
+	v.SetTagName("binding")
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		return strings.Split(field.Tag.Get("json"), ",")[0]
+	})
+	return v
+}()
+
+// Validate checks req against its binding tags.
+func (req CreateTodoRequest) Validate() error {
+	return validateRequest(req)
+}
+
+// Validate checks req against its binding tags.
+func (req UpdateTodoRequest) Validate() error {
+	return validateRequest(req)
+}
+
+// validateRequest reports the first rule req breaks, naming the field as in
+// JSON.
+func validateRequest(req interface{}) error {
+	var errs validator.ValidationErrors
+	if err := requests.Struct(req); !errors.As(err, &errs) {
+		return err
+	}
+
+	field := errs[0]
+	switch field.Tag() {
+	case "required":
+		return fmt.Errorf("%s is required", field.Field())
+	case "max":
+		return fmt.Errorf("%s too long (max %s characters)", field.Field(), field.Param())
+	case "excluded_with":
+		other, _ := reflect.TypeOf(req).FieldByName(field.Param())
+		return fmt.Errorf("%s cannot be combined with %s", field.Field(), strings.Split(other.Tag.Get("json"), ",")[0])
+	default:
+		return fmt.Errorf("%s is invalid", field.Field())
+	}
+}
@@ -0,0 +1,73 @@
+package model
+
+import "time"
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is an outgoing subscription to todo change events. An empty
+// Events list subscribes to every event type.
+type Webhook struct {
+	ID     uint     `json:"id" gorm:"primaryKey"`
+	URL    string   `json:"url" gorm:"not null"`
+	Events []string `json:"events" gorm:"serializer:json"`
+	// Secret signs the payloads. It is only returned when the webhook is
+	// created.
+	Secret string `json:"secret,omitempty" gorm:"not null"`
+	Active bool   `json:"active" gorm:"not null;default:true"`
+	// ConsecutiveFailures counts failed attempts since the last successful
+	// delivery; the webhook is disabled when it reaches the limit.
+	ConsecutiveFailures int        `json:"consecutive_failures" gorm:"not null;default:0"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// Subscribes reports whether the webhook wants events of the given type.
+func (w *Webhook) Subscribes(eventType string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == eventType || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one payload queued for a webhook. Pending deliveries
+// form the persistent retry queue; finished ones serve as the delivery log.
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	WebhookID      uint       `json:"webhook_id" gorm:"not null;index"`
+	EventType      string     `json:"event_type" gorm:"not null"`
+	Payload        string     `json:"payload" gorm:"type:text;not null"`
+	Status         string     `json:"status" gorm:"not null;index:idx_webhook_deliveries_due,priority:1"`
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index:idx_webhook_deliveries_due,priority:2"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus int        `json:"response_status"`
+	LastError      string     `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events"`
+	// Secret is generated when left empty.
+	Secret string `json:"secret"`
+}
+
+type UpdateWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Active set to true re-enables a webhook that was disabled after
+	// repeated failures.
+	Active *bool `json:"active"`
+}
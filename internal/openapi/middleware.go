@@ -0,0 +1,113 @@
+package openapi
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware rejects requests that do not match the document with 400 and
+// the list of offending fields. With validateResponses set, JSON responses
+// are buffered and checked too; a response that breaks the contract is
+// replaced with 500, so handler drift shows up in tests and development.
+func (v *Validator) Middleware(validateResponses bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+
+		params := make(map[string]string, len(c.Params))
+		for _, p := range c.Params {
+			params[p.Key] = p.Value
+		}
+		if errs := v.ValidateRequest(route, c.Request, params); len(errs) > 0 {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error":  "Request validation failed",
+				"fields": errs,
+			})
+			return
+		}
+
+		if !validateResponses || v.Streaming(c.Request.Method, route) {
+			c.Next()
+			return
+		}
+
+		w := &bufferedWriter{ResponseWriter: c.Writer, status: http.StatusOK}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.hijacked {
+			return
+		}
+
+		if errs := v.ValidateResponse(c.Request.Method, route, w.status, w.Header().Get("Content-Type"), w.body.Bytes()); len(errs) > 0 {
+			log.Printf("openapi: %s %s responded with %d against the specification: %+v", c.Request.Method, route, w.status, errs)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":  "Response does not match the API specification",
+				"fields": errs,
+			})
+			return
+		}
+		w.ResponseWriter.WriteHeader(w.status)
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
+
+// bufferedWriter holds the response back until it has been validated.
+// Hijacked connections bypass it.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status   int
+	written  bool
+	hijacked bool
+	body     bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 && !w.written {
+		w.status = code
+	}
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+func (w *bufferedWriter) Flush() {}
+
+func (w *bufferedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.hijacked = true
+	return w.ResponseWriter.Hijack()
+}
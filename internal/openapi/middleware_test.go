@@ -0,0 +1,83 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func statsRouter(validateResponses bool, stats gin.H) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(openapi.Validate().Middleware(validateResponses))
+	r.GET("/api/v1/todos/stats", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "Statistics retrieved successfully", "data": stats})
+	})
+	return r
+}
+
+func TestMiddleware_ValidatesResponses(t *testing.T) {
+	valid := gin.H{"total": 1, "completed": 1, "pending": 0, "completion_rate": 100.0}
+	drifted := gin.H{"total": "1", "completed": 1, "pending": 0}
+
+	w := httptest.NewRecorder()
+	statsRouter(true, valid).ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/todos/stats", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "completion_rate")
+
+	w = httptest.NewRecorder()
+	statsRouter(true, drifted).ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/todos/stats", nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	var resp struct {
+		Fields []openapi.FieldError `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []openapi.FieldError{
+		{Field: "data.completion_rate", In: "response", Message: "is required"},
+		{Field: "data.total", In: "response", Message: "got string, want integer"},
+	}, resp.Fields)
+
+	w = httptest.NewRecorder()
+	statsRouter(false, drifted).ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/todos/stats", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "responses pass through when response validation is off")
+}
+
+func TestMiddleware_UndocumentedStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(openapi.Validate().Middleware(true))
+	r.GET("/api/v1/todos/stats", func(c *gin.Context) {
+		c.JSON(http.StatusTeapot, gin.H{"error": "teapot"})
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/todos/stats", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "status 418 is not documented")
+}
+
+func TestMiddleware_LimitsBodySize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(openapi.Validate().Middleware(false))
+	r.POST("/api/v1/todos", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	body := `{"title": "` + strings.Repeat("a", 33<<20) + `"}`
+	req := httptest.NewRequest("POST", "/api/v1/todos", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "is larger than")
+}
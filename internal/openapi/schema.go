@@ -0,0 +1,231 @@
+package openapi
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// schemas generates component schemas from Go types.
+type schemas struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+// ref returns a reference to the component schema of a named struct type,
+// generating it on first use. name overrides the Go type name.
+func (s *schemas) ref(v interface{}, name ...string) *Schema {
+	return s.of(reflect.TypeOf(v), name...)
+}
+
+func (s *schemas) of(t reflect.Type, name ...string) *Schema {
+	if t.Kind() != reflect.Struct || t == timeType || t == deletedAtType {
+		return s.inline(t)
+	}
+
+	if existing, ok := s.names[t]; ok {
+		return &Schema{Ref: componentRef(existing)}
+	}
+	component := t.Name()
+	if len(name) > 0 {
+		component = name[0]
+	}
+	if _, taken := s.components[component]; taken {
+		panic(fmt.Sprintf("openapi: schema name %s is used by two types", component))
+	}
+	s.names[t] = component
+	s.components[component] = &Schema{}
+	*s.components[component] = *s.object(t)
+	return &Schema{Ref: componentRef(component)}
+}
+
+func componentRef(name string) string {
+	return "#/components/schemas/" + name
+}
+
+func (s *schemas) inline(t reflect.Type) *Schema {
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == deletedAtType:
+		return &Schema{Type: []string{"string", "null"}, Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: float(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Ptr:
+		return nullable(s.of(t.Elem()))
+	case reflect.Slice, reflect.Array:
+		return nullable(&Schema{Type: "array", Items: s.of(t.Elem())})
+	case reflect.Map:
+		return nullable(&Schema{Type: "object", AdditionalProperties: s.of(t.Elem())})
+	case reflect.Struct:
+		return s.object(t)
+	case reflect.Interface:
+		return &Schema{}
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+// object describes a struct through its json tags. Fields marked required
+// by their binding tag are required; the other binding rules become length,
+// size and range limits.
+func (s *schemas) object(t reflect.Type) *Schema {
+	obj := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, omitEmpty, skip := jsonName(field)
+		if skip {
+			continue
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && name == "" {
+			embedded := s.object(field.Type)
+			for prop, schema := range embedded.Properties {
+				obj.Properties[prop] = schema
+			}
+			obj.Required = append(obj.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := s.of(field.Type)
+		if omitEmpty {
+			// An omitted value is never sent as null.
+			schema = notNull(schema)
+		}
+		if applyBinding(schema, field.Tag.Get("binding")) {
+			obj.Required = append(obj.Required, name)
+		}
+		obj.Properties[name] = schema
+	}
+	return obj
+}
+
+func jsonName(field reflect.StructField) (name string, omitEmpty, skip bool) {
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty, false
+}
+
+// applyBinding copies the validator rules of a binding tag onto schema and
+// reports whether the field is required.
+func applyBinding(schema *Schema, tag string) (required bool) {
+	for _, rule := range strings.Split(tag, ",") {
+		if rule == "dive" {
+			// The remaining rules apply to the elements.
+			return required
+		}
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "min", "max":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				panic(fmt.Sprintf("openapi: invalid binding rule %q", rule))
+			}
+			limit(schema, key, n)
+		case "oneof":
+			for _, v := range strings.Fields(value) {
+				schema.Enum = append(schema.Enum, v)
+			}
+		}
+	}
+	return required
+}
+
+func limit(schema *Schema, key string, n int) {
+	switch primaryType(schema) {
+	case "string":
+		if key == "min" {
+			schema.MinLength = &n
+		} else {
+			schema.MaxLength = &n
+		}
+	case "array":
+		if key == "min" {
+			schema.MinItems = &n
+		} else {
+			schema.MaxItems = &n
+		}
+	default:
+		if key == "min" {
+			schema.Minimum = float(n)
+		} else {
+			schema.Maximum = float(n)
+		}
+	}
+}
+
+func primaryType(schema *Schema) string {
+	switch t := schema.Type.(type) {
+	case string:
+		return t
+	case []string:
+		return t[0]
+	}
+	return ""
+}
+
+func nullable(schema *Schema) *Schema {
+	switch t := schema.Type.(type) {
+	case string:
+		schema.Type = []string{t, "null"}
+		return schema
+	case []string:
+		return schema
+	}
+	return &Schema{OneOf: []*Schema{schema, {Type: "null"}}}
+}
+
+func notNull(schema *Schema) *Schema {
+	if t, ok := schema.Type.([]string); ok && len(t) == 2 && t[1] == "null" {
+		schema.Type = t[0]
+		return schema
+	}
+	if len(schema.OneOf) == 2 && schema.OneOf[1].Type == "null" {
+		return schema.OneOf[0]
+	}
+	return schema
+}
+
+func float(n int) *float64 {
+	f := float64(n)
+	return &f
+}
@@ -0,0 +1,779 @@
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/stavagg/petGoApi/internal/export"
+	"github.com/stavagg/petGoApi/internal/importer"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/outbox"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// Version is the API version reported by the document and the root route.
+const Version = "1.0.0"
+
+// Spec returns the OpenAPI document of the API. It is built once; call
+// Check at startup so a document that cannot be built stops the server
+// there rather than panicking on first use.
+func Spec() *Document {
+	doc, err := built()
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// Check builds the document and its validator and reports why they cannot
+// be built.
+func Check() error {
+	_, err := compiled()
+	return err
+}
+
+var built = sync.OnceValues(func() (doc *Document, err error) {
+	// The builder panics on mistakes in the description of the API.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return build(), nil
+})
+
+type builder struct {
+	doc     *Document
+	schemas *schemas
+}
+
+func build() *Document {
+	b := &builder{
+		doc: &Document{
+			OpenAPI: "3.1.0",
+			Info: Info{
+				Title:       "PetGoApi",
+				Description: "REST API для управления задачами. Автор изменения передается в заголовке X-Actor.",
+				Version:     Version,
+			},
+			Tags: []Tag{
+				{Name: "todos", Description: "Задачи"},
+				{Name: "trash", Description: "Корзина"},
+				{Name: "undo", Description: "Отмена действий"},
+				{Name: "webhooks", Description: "Исходящие webhooks"},
+				{Name: "sync", Description: "Офлайн-синхронизация"},
+				{Name: "realtime", Description: "Потоки изменений"},
+				{Name: "outbox", Description: "Ретрансляция событий"},
+				{Name: "graphql", Description: "GraphQL API"},
+				{Name: "system", Description: "Служебные маршруты"},
+			},
+			Paths: make(map[string]*PathItem),
+		},
+		schemas: newSchemas(),
+	}
+
+	b.system()
+	b.graphql()
+	b.todos()
+	b.webhooks()
+	b.sync()
+
+	b.doc.Components.Schemas = b.schemas.components
+	// Errors are rendered in the format asked for in Accept too.
+	b.doc.Components.Responses = map[string]*Response{
+		"BadRequest":    b.negotiatedResponse(jsonResponse("Некорректный запрос", b.errorSchema())),
+		"NotFound":      b.negotiatedResponse(jsonResponse("Не найдено", b.errorSchema())),
+		"Conflict":      b.negotiatedResponse(jsonResponse("Конфликт", b.errorSchema())),
+		"NotAcceptable": jsonResponse("Ответ недоступен ни в одном из форматов Accept", b.errorSchema()),
+		"InternalError": b.negotiatedResponse(jsonResponse("Внутренняя ошибка сервера", b.errorSchema())),
+	}
+	// API keys are only required with API_KEYS_REQUIRED, so the empty
+	// requirement comes first.
+	b.doc.Components.SecuritySchemes = map[string]*SecurityScheme{
+		"apiKey": {
+			Type:        "http",
+			Scheme:      "bearer",
+			Description: "API-ключ из `petgoapi admin create-api-key`. Обязателен при API_KEYS_REQUIRED=true, иначе сервер отвечает 401.",
+		},
+	}
+	b.doc.Security = []map[string][]string{{}, {"apiKey": {}}}
+	return b.doc
+}
+
+// healthSchema is the body of the health check. database is left out with
+// memory storage.
+func healthSchema() *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"status":   {Type: "string"},
+			"database": {Type: "string"},
+			"storage":  {Type: "string"},
+			"error":    {Type: "string"},
+		},
+		Required: []string{"status", "storage"},
+	}
+}
+
+func (b *builder) system() {
+	b.add("GET", "/", &Operation{
+		OperationID: "getRoot",
+		Summary:     "Информация о сервисе и список маршрутов",
+		Tags:        []string{"system"},
+		Responses:   responses(http.StatusOK, jsonResponse("Информация о сервисе", &Schema{Type: "object"})),
+	})
+	b.add("GET", "/health", &Operation{
+		OperationID: "getHealth",
+		Summary:     "Проверка состояния",
+		Tags:        []string{"system"},
+		Responses: responses(http.StatusOK, jsonResponse("Сервис работает", healthSchema()),
+			http.StatusServiceUnavailable, jsonResponse("База данных не отвечает", healthSchema())),
+	})
+	b.add("GET", "/openapi.json", &Operation{
+		OperationID: "getOpenAPI",
+		Summary:     "Спецификация OpenAPI 3.1",
+		Tags:        []string{"system"},
+		Responses:   responses(http.StatusOK, jsonResponse("Этот документ", &Schema{Type: "object"})),
+	})
+	b.add("GET", "/docs", &Operation{
+		OperationID: "getSwaggerUI",
+		Summary:     "Swagger UI",
+		Tags:        []string{"system"},
+		Responses:   responses(http.StatusOK, htmlResponse("Страница Swagger UI")),
+	})
+	b.add("GET", "/redoc", &Operation{
+		OperationID: "getRedoc",
+		Summary:     "Документация Redoc",
+		Tags:        []string{"system"},
+		Responses:   responses(http.StatusOK, htmlResponse("Страница Redoc")),
+	})
+}
+
+func (b *builder) graphql() {
+	result := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"data":   {},
+			"errors": {Type: "array", Items: &Schema{Type: "object"}},
+		},
+	}
+
+	b.add("GET", "/graphql", &Operation{
+		OperationID: "getGraphQL",
+		Summary:     "GraphQL-запрос через GET или подписка через WebSocket",
+		Description: "С заголовком Upgrade: websocket открывает подписку по протоколу graphql-transport-ws.",
+		Tags:        []string{"graphql"},
+		Parameters: []*Parameter{
+			query("query", &Schema{Type: "string"}, "Текст запроса; не нужен при подключении по WebSocket", false),
+			query("variables", &Schema{Type: "string"}, "Переменные в JSON", false),
+			query("operationName", &Schema{Type: "string"}, "Имя операции", false),
+		},
+		Responses: responses(http.StatusOK, jsonResponse("Результат запроса", result)),
+	})
+	b.add("POST", "/graphql", &Operation{
+		OperationID: "postGraphQL",
+		Summary:     "GraphQL-запрос",
+		Tags:        []string{"graphql"},
+		RequestBody: jsonBody(&Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"query":         {Type: "string"},
+				"variables":     {Type: []string{"object", "null"}},
+				"operationName": {Type: []string{"string", "null"}},
+			},
+			Required: []string{"query"},
+		}),
+		Responses: responses(http.StatusOK, jsonResponse("Результат запроса", result)),
+	})
+	b.add("GET", "/graphql/playground", &Operation{
+		OperationID: "getGraphQLPlayground",
+		Summary:     "GraphQL Playground",
+		Tags:        []string{"graphql"},
+		Responses:   responses(http.StatusOK, htmlResponse("Страница GraphQL Playground")),
+	})
+}
+
+func (b *builder) todos() {
+	todo := b.schemas.ref(model.Todo{})
+	todos := b.schemas.ref([]model.Todo{})
+	stats := &Schema{Ref: componentRef("TodoStats")}
+	b.schemas.components["TodoStats"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"total":           {Type: "integer"},
+			"completed":       {Type: "integer"},
+			"pending":         {Type: "integer"},
+			"completion_rate": {Type: "number", Description: "Доля выполненных задач в процентах"},
+		},
+		Required: []string{"total", "completed", "pending", "completion_rate"},
+	}
+
+	b.add("POST", "/api/v1/todos", b.negotiated(&Operation{
+		OperationID: "createTodo",
+		Summary:     "Создать задачу",
+		Tags:        []string{"todos"},
+		RequestBody: jsonBody(b.schemas.ref(model.CreateTodoRequest{})),
+		Responses: responses(
+			http.StatusCreated, jsonResponse("Задача создана", b.envelope(todo)),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("GET", "/api/v1/todos", b.negotiatedList(&Operation{
+		OperationID: "getAllTodos",
+		Summary:     "Получить все задачи",
+		Tags:        []string{"todos"},
+		Parameters: append([]*Parameter{
+			query("completed", &Schema{Type: "boolean"}, "Только выполненные или только невыполненные", false),
+		}, pagination()...),
+		Responses: responses(
+			http.StatusOK, jsonResponse("Список задач", b.envelope(todos, "count")),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("GET", "/api/v1/todos/stats", b.negotiated(&Operation{
+		OperationID: "getStats",
+		Summary:     "Статистика",
+		Tags:        []string{"todos"},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Статистика задач", b.envelope(stats)),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("GET", "/api/v1/todos/export", &Operation{
+		OperationID: "exportTodos",
+		Summary:     "Выгрузить задачи в файл",
+		Description: "Задачи передаются по мере чтения из базы, поэтому ошибка посреди выгрузки обрывает ответ. " +
+			"В iCalendar каждая задача — компонент VTODO.",
+		Tags: []string{"todos"},
+		Parameters: []*Parameter{
+			query("format", &Schema{Type: "string", Enum: enum(export.Formats)}, "Формат файла, по умолчанию csv", false),
+			query("completed", &Schema{Type: "boolean"}, "Только выполненные или только невыполненные", false),
+		},
+		Responses: responses(
+			http.StatusOK, &Response{
+				Description: "Файл с задачами",
+				Content: map[string]*MediaType{
+					"text/csv":             {Schema: &Schema{Type: "string"}},
+					"application/x-ndjson": {Schema: &Schema{Type: "string"}},
+					"text/markdown":        {Schema: &Schema{Type: "string"}},
+					"text/calendar":        {Schema: &Schema{Type: "string"}},
+				},
+			},
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	})
+	report := b.schemas.ref(model.ImportReport{})
+	b.add("POST", "/api/v1/todos/import", b.negotiated(&Operation{
+		OperationID: "importTodos",
+		Summary:     "Загрузить задачи из файла",
+		Description: "Формат берется из параметра format или из Content-Type тела. Каждая строка проверяется по правилам создания задачи. " +
+			"Файл импортируется целиком в одной транзакции: если хоть одна строка отклонена, не создается ничего. " +
+			"С dry_run=true ничего не создается, а ответ показывает задачи, которые были бы созданы, и отклоненные строки. " +
+			"Выгрузки Todoist и Trello загружаются с format=todoist или format=trello; их задачи запоминают external_id, " +
+			"поэтому повторная загрузка той же выгрузки пропускает уже загруженные задачи и перечисляет их в skipped.",
+		Tags: []string{"todos"},
+		Parameters: []*Parameter{
+			query("format", &Schema{Type: "string", Enum: enum(importer.Formats)}, "Формат файла", false),
+			query("dry_run", &Schema{Type: "boolean"}, "Только проверить файл", false),
+		},
+		RequestBody: &RequestBody{
+			Required: true,
+			Content: map[string]*MediaType{
+				"text/csv":             {Schema: &Schema{Type: "string"}},
+				"application/x-ndjson": {Schema: &Schema{Type: "string"}},
+				"text/plain":           {Schema: &Schema{Type: "string", Description: "todo.txt"}},
+				"application/json":     {Schema: &Schema{Type: "object", Description: "Выгрузка Todoist (Sync API) или доски Trello"}},
+			},
+		},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Предпросмотр импорта", b.envelope(report, "count")),
+			http.StatusCreated, jsonResponse("Задачи созданы", b.envelope(report, "count")),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusRequestEntityTooLarge, jsonResponse("Файл слишком большой", b.errorSchema()),
+			http.StatusUnprocessableEntity, jsonResponse("Строки файла отклонены", &Schema{
+				Type: "object",
+				Properties: map[string]*Schema{
+					"error": {Type: "string"},
+					"lines": b.schemas.ref([]model.ImportError{}),
+				},
+				Required: []string{"error", "lines"},
+			}),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("GET", "/api/v1/todos/events", &Operation{
+		OperationID: "streamTodoEvents",
+		Summary:     "Поток изменений (Server-Sent Events)",
+		Tags:        []string{"realtime"},
+		Parameters: []*Parameter{
+			{Name: "Last-Event-ID", In: "header", Description: "ID последнего полученного события", Schema: &Schema{Type: "string"}},
+			query("last_event_id", &Schema{Type: "string"}, "То же, что Last-Event-ID, для клиентов без заголовков", false),
+		},
+		Responses: responses(
+			http.StatusOK, &Response{
+				Description: "Поток событий",
+				Content:     map[string]*MediaType{"text/event-stream": {Schema: &Schema{Type: "string"}}},
+			},
+			http.StatusBadRequest, shared("BadRequest"),
+		),
+	})
+	b.add("POST", "/api/v1/todos/complete-all", b.negotiated(&Operation{
+		OperationID: "markAllCompleted",
+		Summary:     "Отметить все задачи выполненными",
+		Tags:        []string{"todos"},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Отмеченные задачи", b.envelope(todos, "count", "undo")),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("GET", "/api/v1/todos/{id}", b.negotiated(&Operation{
+		OperationID: "getTodoByID",
+		Summary:     "Получить задачу по ID",
+		Tags:        []string{"todos"},
+		Parameters:  []*Parameter{pathID("ID задачи")},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Задача", b.envelope(todo)),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusNotFound, shared("NotFound"),
+		),
+	}))
+	b.add("PUT", "/api/v1/todos/{id}", b.negotiated(&Operation{
+		OperationID: "updateTodo",
+		Summary:     "Обновить задачу",
+		Description: "Пустые поля остаются без изменений.",
+		Tags:        []string{"todos"},
+		Parameters:  []*Parameter{pathID("ID задачи")},
+		RequestBody: jsonBody(b.schemas.ref(model.UpdateTodoRequest{})),
+		Responses: responses(
+			http.StatusOK, jsonResponse("Обновленная задача", b.envelope(todo)),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("DELETE", "/api/v1/todos/{id}", b.negotiated(&Operation{
+		OperationID: "deleteTodo",
+		Summary:     "Удалить задачу в корзину",
+		Tags:        []string{"todos"},
+		Parameters:  []*Parameter{pathID("ID задачи")},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Задача перемещена в корзину", b.envelope(nil, "undo")),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("POST", "/api/v1/todos/{id}/toggle", b.negotiated(&Operation{
+		OperationID: "toggleTodo",
+		Summary:     "Переключить статус",
+		Tags:        []string{"todos"},
+		Parameters:  []*Parameter{pathID("ID задачи")},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Задача с новым статусом", b.envelope(todo, "undo")),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("POST", "/api/v1/todos/{id}/restore", b.negotiated(&Operation{
+		OperationID: "restoreTodo",
+		Summary:     "Восстановить задачу из корзины",
+		Tags:        []string{"trash"},
+		Parameters:  []*Parameter{pathID("ID задачи")},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Восстановленная задача", b.envelope(todo)),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusNotFound, shared("NotFound"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("GET", "/api/v1/todos/{id}/history", b.negotiated(&Operation{
+		OperationID: "getHistory",
+		Summary:     "История изменений задачи",
+		Tags:        []string{"todos"},
+		Parameters:  append([]*Parameter{pathID("ID задачи")}, pagination()...),
+		Responses: responses(
+			http.StatusOK, jsonResponse("Ревизии задачи по возрастанию", b.envelope(b.schemas.ref([]model.TodoEvent{}), "count")),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusNotFound, shared("NotFound"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("POST", "/api/v1/todos/{id}/revert", b.negotiated(&Operation{
+		OperationID: "revertTodo",
+		Summary:     "Откатить задачу к ревизии",
+		Tags:        []string{"todos"},
+		Parameters: []*Parameter{
+			pathID("ID задачи"),
+			query("to", &Schema{Type: "integer", Minimum: float(0)}, "Номер ревизии", true),
+		},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Задача после отката", b.envelope(todo)),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusNotFound, shared("NotFound"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+
+	b.add("GET", "/api/v1/trash", b.negotiatedList(&Operation{
+		OperationID: "getTrash",
+		Summary:     "Корзина",
+		Tags:        []string{"trash"},
+		Parameters:  pagination(),
+		Responses: responses(
+			http.StatusOK, jsonResponse("Удаленные задачи", b.envelope(todos, "count")),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("DELETE", "/api/v1/trash/{id}", b.negotiated(&Operation{
+		OperationID: "purgeTodo",
+		Summary:     "Удалить задачу навсегда",
+		Tags:        []string{"trash"},
+		Parameters:  []*Parameter{pathID("ID задачи")},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Задача удалена", b.envelope(nil)),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusNotFound, shared("NotFound"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+
+	b.add("POST", "/api/v1/undo/{token}", b.negotiated(&Operation{
+		OperationID: "undo",
+		Summary:     "Отменить последнее действие",
+		Tags:        []string{"undo"},
+		Parameters: []*Parameter{{
+			Name: "token", In: "path", Required: true,
+			Description: "Токен из поля undo ответа",
+			Schema:      &Schema{Type: "string"},
+		}},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Задачи после отмены", b.envelope(todos, "count")),
+			http.StatusNotFound, shared("NotFound"),
+			http.StatusConflict, shared("Conflict"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+}
+
+func (b *builder) webhooks() {
+	webhook := b.schemas.ref(model.Webhook{})
+
+	b.add("POST", "/api/v1/webhooks", b.negotiated(&Operation{
+		OperationID: "createWebhook",
+		Summary:     "Подписать webhook на изменения",
+		Tags:        []string{"webhooks"},
+		RequestBody: jsonBody(b.schemas.ref(model.CreateWebhookRequest{})),
+		Responses: responses(
+			http.StatusCreated, jsonResponse("Webhook создан, секрет возвращается только здесь", b.envelope(webhook)),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("GET", "/api/v1/webhooks", b.negotiated(&Operation{
+		OperationID: "getWebhooks",
+		Summary:     "Список webhooks",
+		Tags:        []string{"webhooks"},
+		Parameters:  pagination(),
+		Responses: responses(
+			http.StatusOK, jsonResponse("Webhooks", b.envelope(b.schemas.ref([]model.Webhook{}), "count")),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("GET", "/api/v1/webhooks/{id}", b.negotiated(&Operation{
+		OperationID: "getWebhookByID",
+		Summary:     "Получить webhook",
+		Tags:        []string{"webhooks"},
+		Parameters:  []*Parameter{pathID("ID webhook")},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Webhook", b.envelope(webhook)),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusNotFound, shared("NotFound"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("PUT", "/api/v1/webhooks/{id}", b.negotiated(&Operation{
+		OperationID: "updateWebhook",
+		Summary:     "Обновить webhook",
+		Tags:        []string{"webhooks"},
+		Parameters:  []*Parameter{pathID("ID webhook")},
+		RequestBody: jsonBody(b.schemas.ref(model.UpdateWebhookRequest{})),
+		Responses: responses(
+			http.StatusOK, jsonResponse("Обновленный webhook", b.envelope(webhook)),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusNotFound, shared("NotFound"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("DELETE", "/api/v1/webhooks/{id}", b.negotiated(&Operation{
+		OperationID: "deleteWebhook",
+		Summary:     "Удалить webhook",
+		Tags:        []string{"webhooks"},
+		Parameters:  []*Parameter{pathID("ID webhook")},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Webhook удален", b.envelope(nil)),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusNotFound, shared("NotFound"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("GET", "/api/v1/webhooks/{id}/deliveries", b.negotiated(&Operation{
+		OperationID: "getDeliveries",
+		Summary:     "Журнал доставок webhook",
+		Tags:        []string{"webhooks"},
+		Parameters: []*Parameter{
+			pathID("ID webhook"),
+			query("limit", &Schema{Type: "integer", Minimum: float(1)}, "Сколько последних доставок вернуть", false),
+		},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Доставки, новые первыми", b.envelope(b.schemas.ref([]model.WebhookDelivery{}), "count")),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusNotFound, shared("NotFound"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+
+	b.add("GET", "/api/v1/outbox/stats", b.negotiated(&Operation{
+		OperationID: "getOutboxStats",
+		Summary:     "Метрики ретрансляции событий",
+		Tags:        []string{"outbox"},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Метрики", b.envelope(b.schemas.ref(outbox.Stats{}, "OutboxStats"))),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+}
+
+func (b *builder) sync() {
+	b.add("GET", "/api/v1/sync", b.negotiated(&Operation{
+		OperationID: "getChanges",
+		Summary:     "Изменения для офлайн-клиента",
+		Tags:        []string{"sync"},
+		Parameters: []*Parameter{
+			query("since", &Schema{Type: "string"}, "Токен из предыдущего ответа; без него возвращаются все задачи", false),
+			query("limit", &Schema{Type: "integer", Minimum: float(1), Maximum: float(1000)}, "Размер страницы", false),
+		},
+		Responses: responses(
+			http.StatusOK, jsonResponse("Изменения и новый токен", b.envelope(b.schemas.ref(model.SyncChanges{}))),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+	b.add("POST", "/api/v1/sync", b.negotiated(&Operation{
+		OperationID: "pushChanges",
+		Summary:     "Отправить офлайн-изменения",
+		Tags:        []string{"sync"},
+		RequestBody: jsonBody(b.schemas.ref(model.SyncPushRequest{})),
+		Responses: responses(
+			http.StatusOK, jsonResponse("Результат каждой операции в том же порядке", b.envelope(b.schemas.ref([]model.SyncResult{}), "count")),
+			http.StatusBadRequest, shared("BadRequest"),
+			http.StatusInternalServerError, shared("InternalError"),
+		),
+	}))
+
+	b.add("GET", "/api/v1/ws", &Operation{
+		OperationID: "connectWebSocket",
+		Summary:     "WebSocket для совместной работы",
+		Tags:        []string{"realtime"},
+		Parameters:  []*Parameter{query("actor", &Schema{Type: "string"}, "Автор изменений, если нельзя передать X-Actor", false)},
+		Responses: map[string]*Response{
+			strconv.Itoa(http.StatusSwitchingProtocols): {Description: "Соединение переключено на WebSocket"},
+		},
+	})
+}
+
+// add registers op under the OpenAPI path, which uses {param} segments.
+func (b *builder) add(method, path string, op *Operation) {
+	item := b.doc.Paths[path]
+	if item == nil {
+		item = &PathItem{}
+		b.doc.Paths[path] = item
+	}
+
+	var slot **Operation
+	switch method {
+	case "GET":
+		slot = &item.Get
+	case "PUT":
+		slot = &item.Put
+	case "POST":
+		slot = &item.Post
+	case "DELETE":
+		slot = &item.Delete
+	default:
+		panic(fmt.Sprintf("openapi: unsupported method %s", method))
+	}
+	if *slot != nil {
+		panic(fmt.Sprintf("openapi: %s %s is described twice", method, path))
+	}
+	*slot = op
+}
+
+// envelope is the response body shared by the handlers: a message, the
+// data when there is any and the optional count and undo fields.
+func (b *builder) envelope(data *Schema, fields ...string) *Schema {
+	schema := &Schema{
+		Type:       "object",
+		Properties: map[string]*Schema{"message": {Type: "string"}},
+		Required:   []string{"message"},
+	}
+	if data != nil {
+		schema.Properties["data"] = data
+		schema.Required = append(schema.Required, "data")
+	}
+	for _, field := range fields {
+		switch field {
+		case "count":
+			schema.Properties["count"] = &Schema{Type: "integer", Minimum: float(0)}
+			schema.Required = append(schema.Required, field)
+		case "undo":
+			// Absent when the action changed nothing.
+			schema.Properties["undo"] = b.schemas.ref(service.UndoToken{})
+		default:
+			panic(fmt.Sprintf("openapi: unknown envelope field %s", field))
+		}
+	}
+	return schema
+}
+
+// negotiated documents the formats other than JSON that the responses of
+// op are rendered in when asked for in Accept, see handler.Negotiate: YAML
+// and MessagePack with the schemas of JSON, the v2 media types with the
+// schemas of v2Envelope, and 406 when none of them is acceptable.
+func (b *builder) negotiated(op *Operation) *Operation {
+	for status, resp := range op.Responses {
+		if resp.Ref == "" {
+			op.Responses[status] = b.negotiatedResponse(resp)
+		}
+	}
+	op.Responses[strconv.Itoa(http.StatusNotAcceptable)] = shared("NotAcceptable")
+	return op
+}
+
+// negotiatedList is negotiated for lists of todos, which are also rendered
+// as CSV with the columns of the export.
+func (b *builder) negotiatedList(op *Operation) *Operation {
+	b.negotiated(op)
+	op.Responses[strconv.Itoa(http.StatusOK)].Content["text/csv"] = &MediaType{
+		Schema: &Schema{Type: "string", Description: "Только задачи, без остальных полей ответа"},
+	}
+	return op
+}
+
+// v2MediaTypes are the versioned media types of the v2 envelope.
+var v2MediaTypes = []string{
+	"application/vnd.petgoapi.v2+json",
+	"application/vnd.petgoapi.v2+yaml",
+	"application/vnd.petgoapi.v2+msgpack",
+}
+
+func (b *builder) negotiatedResponse(resp *Response) *Response {
+	body, ok := resp.Content["application/json"]
+	if !ok {
+		return resp
+	}
+	resp.Content["application/yaml"] = &MediaType{Schema: body.Schema}
+	resp.Content["application/msgpack"] = &MediaType{Schema: body.Schema}
+	v2 := b.v2Envelope(body.Schema)
+	for _, mediaType := range v2MediaTypes {
+		resp.Content[mediaType] = &MediaType{Schema: v2}
+	}
+	return resp
+}
+
+// v2Envelope is the v2 form of a v1 response body: the data of envelope,
+// or null, with meta and links, see model.Envelope. Errors keep their
+// fields and get meta.
+func (b *builder) v2Envelope(v1 *Schema) *Schema {
+	meta := b.schemas.ref(model.Meta{})
+	if _, ok := v1.Properties["message"]; !ok {
+		return &Schema{AllOf: []*Schema{v1, {
+			Type:       "object",
+			Properties: map[string]*Schema{"meta": meta},
+			Required:   []string{"meta"},
+		}}}
+	}
+
+	data := v1.Properties["data"]
+	if data == nil {
+		data = &Schema{Type: "null"}
+	}
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"data":  data,
+			"meta":  meta,
+			"links": b.schemas.ref(model.Links{}),
+		},
+		Required: []string{"data", "meta", "links"},
+	}
+}
+
+func (b *builder) errorSchema() *Schema {
+	if _, ok := b.schemas.components["Error"]; !ok {
+		b.schemas.components["Error"] = &Schema{
+			Type: "object",
+			Properties: map[string]*Schema{
+				"error": {Type: "string"},
+				"fields": {
+					Type:        "array",
+					Description: "Поля, не прошедшие проверку по спецификации",
+					Items:       b.schemas.ref(FieldError{}),
+				},
+			},
+			Required: []string{"error"},
+		}
+	}
+	return &Schema{Ref: componentRef("Error")}
+}
+
+// responses pairs status codes with responses.
+func responses(pairs ...interface{}) map[string]*Response {
+	out := make(map[string]*Response, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		out[strconv.Itoa(pairs[i].(int))] = pairs[i+1].(*Response)
+	}
+	return out
+}
+
+func jsonResponse(description string, schema *Schema) *Response {
+	return &Response{Description: description, Content: map[string]*MediaType{"application/json": {Schema: schema}}}
+}
+
+func htmlResponse(description string) *Response {
+	return &Response{Description: description, Content: map[string]*MediaType{"text/html": {Schema: &Schema{Type: "string"}}}}
+}
+
+func shared(name string) *Response {
+	return &Response{Ref: "#/components/responses/" + name}
+}
+
+func jsonBody(schema *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]*MediaType{"application/json": {Schema: schema}}}
+}
+
+func pathID(description string) *Parameter {
+	return &Parameter{Name: "id", In: "path", Description: description, Required: true, Schema: &Schema{Type: "integer", Minimum: float(0)}}
+}
+
+func enum(values []string) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+// pagination are the limit and offset parameters of paginated lists.
+func pagination() []*Parameter {
+	return []*Parameter{
+		query("limit", &Schema{Type: "integer", Minimum: float(1), Maximum: float(1000)}, "Размер страницы; без него возвращается весь список", false),
+		query("offset", &Schema{Type: "integer", Minimum: float(0)}, "Сколько элементов пропустить", false),
+	}
+}
+
+func query(name string, schema *Schema, description string, required bool) *Parameter {
+	return &Parameter{Name: name, In: "query", Description: description, Required: required, Schema: schema}
+}
@@ -0,0 +1,64 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stavagg/petGoApi/internal/openapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpec_Builds(t *testing.T) {
+	require.NoError(t, openapi.Check())
+}
+
+func TestSpec_ModelSchemas(t *testing.T) {
+	schemas := openapi.Spec().Components.Schemas
+
+	create := schemas["CreateTodoRequest"]
+	require.NotNil(t, create)
+	assert.Equal(t, []string{"title"}, create.Required, "required comes from the binding tag")
+	assert.Equal(t, "string", create.Properties["title"].Type)
+
+	update := schemas["UpdateTodoRequest"]
+	require.NotNil(t, update)
+	assert.Empty(t, update.Required)
+	assert.Equal(t, []string{"boolean", "null"}, update.Properties["completed"].Type, "pointers are nullable")
+
+	todo := schemas["Todo"]
+	require.NotNil(t, todo)
+	assert.Equal(t, "date-time", todo.Properties["created_at"].Format)
+	assert.Equal(t, []string{"string", "null"}, todo.Properties["deleted_at"].Type)
+	assert.Equal(t, "object", todo.Properties["field_times"].Type, "omitempty values are never null")
+
+	push := schemas["SyncPushRequest"]
+	require.NotNil(t, push)
+	require.NotNil(t, push.Properties["mutations"].MaxItems)
+	assert.Equal(t, 500, *push.Properties["mutations"].MaxItems)
+	assert.Equal(t, "#/components/schemas/SyncMutation", push.Properties["mutations"].Items.Ref)
+}
+
+func TestSpec_Envelope(t *testing.T) {
+	op := openapi.Spec().Paths["/api/v1/todos"].Get
+	require.NotNil(t, op)
+
+	raw, err := json.Marshal(op.Responses["200"].Content["application/json"].Schema)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"message": {"type": "string"},
+			"data": {"type": ["array", "null"], "items": {"$ref": "#/components/schemas/Todo"}},
+			"count": {"type": "integer", "minimum": 0}
+		},
+		"required": ["message", "data", "count"]
+	}`, string(raw))
+	assert.Equal(t, "#/components/responses/BadRequest", op.Responses["400"].Ref)
+}
+
+func TestSpec_Endpoints(t *testing.T) {
+	endpoints := openapi.Spec().Endpoints()
+	assert.Contains(t, endpoints, "GET / - Информация о сервисе и список маршрутов")
+	assert.Contains(t, endpoints, "DELETE /api/v1/trash/{id} - "+openapi.Spec().Paths["/api/v1/trash/{id}"].Delete.Summary)
+}
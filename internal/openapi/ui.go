@@ -0,0 +1,13 @@
+package openapi
+
+import _ "embed"
+
+// SwaggerUI and Redoc are documentation pages that render /openapi.json.
+// Their scripts are loaded from a CDN.
+var (
+	//go:embed swagger.html
+	SwaggerUI []byte
+
+	//go:embed redoc.html
+	Redoc []byte
+)
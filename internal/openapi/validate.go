@@ -0,0 +1,386 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+const (
+	documentURL = "openapi.json"
+	jsonType    = "application/json"
+)
+
+// FieldError is a value that does not match the document. In is where the
+// value came from: path, query, header, body or response.
+type FieldError struct {
+	Field   string `json:"field"`
+	In      string `json:"in"`
+	Message string `json:"message"`
+}
+
+// Validator checks requests and responses against the document.
+type Validator struct {
+	operations map[string]*operation
+}
+
+type operation struct {
+	params       []parameter
+	body         *jsonschema.Schema
+	bodyRequired bool
+	// otherBodies is set when the body may also be sent in media types
+	// other than JSON, which are passed through unchecked.
+	otherBodies bool
+	// responses maps documented status codes to the schemas of their
+	// JSON media types, such as application/json and the +json v2 type.
+	responses map[string]map[string]*jsonschema.Schema
+	// otherResponses holds the status and media type, like "200 text/csv",
+	// of responses documented in media types other than JSON. They are
+	// passed through unchecked.
+	otherResponses map[string]bool
+	// streaming is set for operations whose responses are not JSON, like
+	// event streams and WebSocket upgrades. Their responses are passed
+	// through unchecked.
+	streaming bool
+}
+
+type parameter struct {
+	*Parameter
+	schema *jsonschema.Schema
+}
+
+// Validate returns the validator of Spec. It is compiled once; see Check.
+func Validate() *Validator {
+	v, err := compiled()
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+var compiled = sync.OnceValues(func() (*Validator, error) {
+	doc, err := built()
+	if err != nil {
+		return nil, err
+	}
+	return NewValidator(doc)
+})
+
+// NewValidator compiles the schemas of every operation of doc.
+func NewValidator(doc *Document) (*Validator, error) {
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	resource, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.AssertFormat()
+	if err := c.AddResource(documentURL, resource); err != nil {
+		return nil, err
+	}
+	compile := func(pointer ...string) (*jsonschema.Schema, error) {
+		tokens := make([]string, len(pointer))
+		for i, token := range pointer {
+			tokens[i] = escapePointer(token)
+		}
+		return c.Compile(documentURL + "#/" + strings.Join(tokens, "/"))
+	}
+
+	v := &Validator{operations: make(map[string]*operation)}
+	for path, item := range doc.Paths {
+		for method, op := range item.Operations() {
+			location := []string{"paths", path, strings.ToLower(method)}
+			compiled := &operation{responses: make(map[string]map[string]*jsonschema.Schema), otherResponses: make(map[string]bool)}
+
+			for i, p := range op.Parameters {
+				schema, err := compile(append(location, "parameters", strconv.Itoa(i), "schema")...)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: %w", method, path, err)
+				}
+				compiled.params = append(compiled.params, parameter{Parameter: p, schema: schema})
+			}
+
+			if op.RequestBody != nil {
+				compiled.bodyRequired = op.RequestBody.Required
+				compiled.otherBodies = len(op.RequestBody.Content) > 1
+				if _, ok := op.RequestBody.Content[jsonType]; ok {
+					compiled.body, err = compile(append(location, "requestBody", "content", jsonType, "schema")...)
+					if err != nil {
+						return nil, fmt.Errorf("%s %s: %w", method, path, err)
+					}
+				}
+			}
+
+			for status, resp := range op.Responses {
+				respLocation := append(append([]string{}, location...), "responses", status)
+				if resp.Ref != "" {
+					name := strings.TrimPrefix(resp.Ref, "#/components/responses/")
+					resp = doc.Components.Responses[name]
+					respLocation = []string{"components", "responses", name}
+				}
+				if _, ok := resp.Content[jsonType]; !ok {
+					if len(resp.Content) > 0 || status == strconv.Itoa(http.StatusSwitchingProtocols) {
+						compiled.streaming = true
+					}
+				}
+				compiled.responses[status] = make(map[string]*jsonschema.Schema)
+				for mediaType := range resp.Content {
+					if !isJSONType(mediaType) {
+						compiled.otherResponses[status+" "+mediaType] = true
+						continue
+					}
+					compiled.responses[status][mediaType], err = compile(append(respLocation, "content", mediaType, "schema")...)
+					if err != nil {
+						return nil, fmt.Errorf("%s %s: %w", method, path, err)
+					}
+				}
+			}
+
+			v.operations[method+" "+path] = compiled
+		}
+	}
+	return v, nil
+}
+
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1", "{", "%7B", "}", "%7D").Replace(token)
+}
+
+var ginParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// operation returns the operation behind a Gin route, which uses :param
+// segments.
+func (v *Validator) operation(method, route string) *operation {
+	return v.operations[method+" "+ginParam.ReplaceAllString(route, "{$1}")]
+}
+
+// ValidateRequest checks the parameters and body of a request to a Gin
+// route. The body is read and replaced with a copy, so handlers can still
+// bind it.
+func (v *Validator) ValidateRequest(route string, r *http.Request, pathParams map[string]string) []FieldError {
+	op := v.operation(r.Method, route)
+	if op == nil {
+		return nil
+	}
+
+	var errs []FieldError
+	for _, p := range op.params {
+		var raw string
+		var present bool
+		switch p.In {
+		case "path":
+			raw, present = pathParams[p.Name]
+		case "query":
+			var values []string
+			values, present = r.URL.Query()[p.Name]
+			if present {
+				raw = values[0]
+			}
+		case "header":
+			raw = r.Header.Get(p.Name)
+			present = raw != ""
+		}
+		if !present {
+			if p.Required {
+				errs = append(errs, FieldError{Field: p.Name, In: p.In, Message: "is required"})
+			}
+			continue
+		}
+		errs = append(errs, validateParameter(p, raw)...)
+	}
+
+	if op.body != nil && !(op.otherBodies && !isJSON(r.Header.Get("Content-Type"))) {
+		errs = append(errs, validateBody(op, r)...)
+	}
+	return errs
+}
+
+func validateParameter(p parameter, raw string) []FieldError {
+	var value interface{} = raw
+	switch primaryType(p.Schema) {
+	case "integer":
+		if _, err := strconv.ParseInt(raw, 10, 64); err != nil {
+			return []FieldError{{Field: p.Name, In: p.In, Message: "must be an integer"}}
+		}
+		value = json.Number(raw)
+	case "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return []FieldError{{Field: p.Name, In: p.In, Message: "must be a number"}}
+		}
+		value = json.Number(raw)
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return []FieldError{{Field: p.Name, In: p.In, Message: "must be a boolean"}}
+		}
+		value = b
+	}
+	return fieldErrors(p.schema.Validate(value), p.In, p.Name)
+}
+
+// isJSONType reports whether a media type is JSON, like application/json
+// and the application/vnd.petgoapi.v2+json of the v2 envelope.
+func isJSONType(mediaType string) bool {
+	return mediaType == jsonType || strings.HasSuffix(mediaType, "+json")
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	return mediaType == jsonType
+}
+
+// maxBodyBytes is the largest JSON body the validator reads. It matches
+// the largest file the import accepts.
+const maxBodyBytes = 32 << 20
+
+func validateBody(op *operation, r *http.Request) []FieldError {
+	var raw []byte
+	if r.Body != nil {
+		var err error
+		raw, err = io.ReadAll(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+		r.Body.Close()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return []FieldError{{In: "body", Message: fmt.Sprintf("is larger than %d bytes", maxBodyBytes)}}
+		}
+		if err != nil {
+			return []FieldError{{In: "body", Message: "could not be read"}}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(raw))
+	}
+
+	if len(bytes.TrimSpace(raw)) == 0 {
+		if op.bodyRequired {
+			return []FieldError{{In: "body", Message: "is required"}}
+		}
+		return nil
+	}
+	value, err := jsonschema.UnmarshalJSON(bytes.NewReader(raw))
+	if err != nil {
+		return []FieldError{{In: "body", Message: "is not valid JSON"}}
+	}
+	return fieldErrors(op.body.Validate(value), "body", "")
+}
+
+// ValidateResponse checks a response of a Gin route. Undocumented status
+// codes and bodies that do not match the schema of their status are
+// reported.
+func (v *Validator) ValidateResponse(method, route string, status int, contentType string, body []byte) []FieldError {
+	op := v.operation(method, route)
+	if op == nil || op.streaming {
+		return nil
+	}
+
+	schemas, ok := op.responses[strconv.Itoa(status)]
+	if !ok {
+		return []FieldError{{Field: "status", In: "response", Message: fmt.Sprintf("status %d is not documented", status)}}
+	}
+	if len(schemas) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	schema, ok := schemas[mediaType]
+	if !ok {
+		if op.otherResponses[strconv.Itoa(status)+" "+mediaType] {
+			return nil
+		}
+		return []FieldError{{Field: "content-type", In: "response", Message: fmt.Sprintf("%q is not documented", contentType)}}
+	}
+	value, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return []FieldError{{In: "response", Message: "is not valid JSON"}}
+	}
+	return fieldErrors(schema.Validate(value), "response", "")
+}
+
+// Streaming reports whether responses of the route are passed through
+// without validation.
+func (v *Validator) Streaming(method, route string) bool {
+	op := v.operation(method, route)
+	return op == nil || op.streaming
+}
+
+var printer = message.NewPrinter(language.English)
+
+// fieldErrors flattens a validation error into one entry per field. Field
+// names are dotted paths with indexes, like mutations[0].op.
+func fieldErrors(err error, in, field string) []FieldError {
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return []FieldError{{Field: field, In: in, Message: err.Error()}}
+	}
+
+	seen := make(map[string]bool)
+	var out []FieldError
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) > 0 {
+			for _, cause := range e.Causes {
+				walk(cause)
+			}
+			return
+		}
+
+		name := fieldName(field, e.InstanceLocation)
+		if required, ok := e.ErrorKind.(*kind.Required); ok {
+			for _, missing := range required.Missing {
+				add(&out, seen, FieldError{Field: fieldName(name, []string{missing}), In: in, Message: "is required"})
+			}
+			return
+		}
+		add(&out, seen, FieldError{Field: name, In: in, Message: e.ErrorKind.LocalizedString(printer)})
+	}
+	walk(verr)
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Field < out[j].Field })
+	return out
+}
+
+// add keeps the first error of every field; alternatives of a oneOf would
+// otherwise report the same value several times.
+func add(out *[]FieldError, seen map[string]bool, err FieldError) {
+	key := err.In + " " + err.Field
+	if seen[key] {
+		return
+	}
+	seen[key] = true
+	*out = append(*out, err)
+}
+
+func fieldName(prefix string, location []string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for _, token := range location {
+		if _, err := strconv.Atoi(token); err == nil {
+			b.WriteString("[" + token + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(token)
+	}
+	return b.String()
+}
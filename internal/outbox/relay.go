@@ -0,0 +1,231 @@
+// Package outbox relays the change events stored in the outbox table to
+// sinks such as the event bus and webhooks.
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+)
+
+type Options struct {
+	// Interval is how often the outbox is polled when nobody calls Wake.
+	Interval time.Duration
+	// Lease is how long a claimed batch stays hidden from other relays.
+	Lease     time.Duration
+	BatchSize int
+	// RetryBase and RetryMax bound the exponential backoff of a message
+	// whose sinks failed.
+	RetryBase time.Duration
+	RetryMax  time.Duration
+	// MaxAttempts is how often a message is tried before it is marked
+	// dead. Zero retries forever.
+	MaxAttempts int
+	// Retention is how long published messages are kept for instances
+	// catching up after a reconnect.
+	Retention time.Duration
+}
+
+// Stats are the relay metrics. Lag is the time between the commit of a
+// change and the publish of its event. Dead counts the messages the relays
+// gave up on, in the whole outbox.
+type Stats struct {
+	Published            uint64     `json:"published"`
+	Failed               uint64     `json:"failed"`
+	Dead                 int64      `json:"dead"`
+	LastLagSeconds       float64    `json:"last_lag_seconds"`
+	MaxLagSeconds        float64    `json:"max_lag_seconds"`
+	LastPublishedAt      *time.Time `json:"last_published_at"`
+	Pending              int64      `json:"pending"`
+	OldestPendingSeconds float64    `json:"oldest_pending_seconds"`
+}
+
+const cleanupInterval = time.Hour
+
+// Relay publishes outbox messages to its sinks in ID order. A message is
+// marked published only after every sink accepted it, and a failed message
+// holds back the later messages of the same todo until it succeeds or is
+// marked dead after MaxAttempts.
+type Relay struct {
+	repo  repository.OutboxRepositoryInterface
+	sinks []Sink
+	opts  Options
+	wake  chan struct{}
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+func NewRelay(repo repository.OutboxRepositoryInterface, opts Options, sinks ...Sink) *Relay {
+	return &Relay{
+		repo:  repo,
+		sinks: sinks,
+		opts:  opts,
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// Wake makes Run relay right away instead of at the next tick.
+func (r *Relay) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run relays until ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+
+	var lastCleanup time.Time
+	for {
+		r.drain(ctx)
+
+		if time.Since(lastCleanup) >= cleanupInterval {
+			r.cleanup()
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
+
+func (r *Relay) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		relayed, err := r.RelayOnce(ctx)
+		if err != nil {
+			log.Printf("outbox relay failed: %v", err)
+			return
+		}
+		if relayed == 0 {
+			return
+		}
+	}
+}
+
+// RelayOnce claims one batch and publishes it. It returns how many messages
+// were published.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	messages, err := r.repo.ClaimPending(time.Now().UTC(), r.opts.Lease, r.opts.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, message := range messages {
+		if err := r.publish(ctx, message); err != nil {
+			continue
+		}
+
+		now := time.Now().UTC()
+		if err := r.repo.MarkPublished(message.ID, now); err != nil {
+			return published, err
+		}
+		r.observe(now.Sub(message.CreatedAt), now)
+		published++
+	}
+	return published, nil
+}
+
+// publish hands one message to every sink and schedules a retry when one
+// of them fails, or gives up on it after the last attempt.
+func (r *Relay) publish(ctx context.Context, message model.OutboxMessage) error {
+	err := r.send(ctx, message)
+	if err == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	r.stats.Failed++
+	r.mu.Unlock()
+
+	attempts := message.Attempts + 1
+	var markErr error
+	if r.opts.MaxAttempts > 0 && attempts >= r.opts.MaxAttempts {
+		log.Printf("outbox: message %d for todo %d failed %d times, giving up: %v", message.ID, message.AggregateID, attempts, err)
+		markErr = r.repo.MarkDead(message.ID, err.Error(), time.Now().UTC())
+	} else {
+		retryIn := r.backoff(attempts)
+		log.Printf("outbox: message %d for todo %d failed, retrying in %s: %v", message.ID, message.AggregateID, retryIn, err)
+		markErr = r.repo.MarkFailed(message.ID, err.Error(), time.Now().UTC().Add(retryIn))
+	}
+	if markErr != nil {
+		log.Printf("outbox: failed to record failure of message %d: %v", message.ID, markErr)
+	}
+	return err
+}
+
+func (r *Relay) send(ctx context.Context, message model.OutboxMessage) error {
+	event, err := eventbus.FromOutbox(message)
+	if err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+
+	for _, sink := range r.sinks {
+		if err := sink.Publish(ctx, event); err != nil {
+			return fmt.Errorf("%s: %w", sink.Name(), err)
+		}
+	}
+	return nil
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := r.opts.RetryBase
+	for i := 1; i < attempts && delay < r.opts.RetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, r.opts.RetryMax)
+}
+
+func (r *Relay) observe(lag time.Duration, at time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stats.Published++
+	r.stats.LastLagSeconds = lag.Seconds()
+	r.stats.MaxLagSeconds = max(r.stats.MaxLagSeconds, lag.Seconds())
+	r.stats.LastPublishedAt = &at
+}
+
+func (r *Relay) cleanup() {
+	deleted, err := r.repo.DeletePublishedBefore(time.Now().UTC().Add(-r.opts.Retention))
+	if err != nil {
+		log.Printf("outbox cleanup failed: %v", err)
+		return
+	}
+	if deleted > 0 {
+		log.Printf("outbox: removed %d published messages older than %s", deleted, r.opts.Retention)
+	}
+}
+
+// Stats returns the relay counters of this process together with the
+// current backlog, which is shared by every instance.
+func (r *Relay) Stats() (Stats, error) {
+	backlog, err := r.repo.Stats()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	r.mu.Lock()
+	stats := r.stats
+	r.mu.Unlock()
+
+	stats.Pending = backlog.Pending
+	stats.Dead = backlog.Dead
+	if backlog.OldestPending != nil {
+		stats.OldestPendingSeconds = time.Since(*backlog.OldestPending).Seconds()
+	}
+	return stats, nil
+}
@@ -0,0 +1,153 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/outbox"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink remembers the events it got and fails while failFor
+// contains their todo ID.
+type recordingSink struct {
+	events  []eventbus.Event
+	failFor map[uint]bool
+}
+
+func (s *recordingSink) Name() string { return "recording" }
+
+func (s *recordingSink) Publish(ctx context.Context, event eventbus.Event) error {
+	if s.failFor[event.TodoID] {
+		return errors.New("sink down")
+	}
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *recordingSink) todoIDs() []uint {
+	ids := []uint{}
+	for _, e := range s.events {
+		ids = append(ids, e.TodoID)
+	}
+	return ids
+}
+
+var testOptions = outbox.Options{
+	Interval:  time.Second,
+	Lease:     time.Minute,
+	BatchSize: 10,
+	RetryBase: time.Millisecond,
+	RetryMax:  time.Millisecond,
+	Retention: time.Hour,
+}
+
+func appendEvents(t *testing.T, repo *repository.MemoryTodoRepository, todoIDs ...uint) {
+	t.Helper()
+	for _, id := range todoIDs {
+		message, err := eventbus.NewOutboxMessage(eventbus.Event{Type: eventbus.TodoUpdated, TodoID: id, Todo: &model.Todo{ID: id}})
+		require.NoError(t, err)
+		require.NoError(t, repo.AppendOutbox(message))
+	}
+}
+
+func TestRelay_PublishesToEverySinkInOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryTodoRepository()
+	appendEvents(t, repo, 1, 2, 1)
+
+	bus := eventbus.NewBus(10)
+	sink := &recordingSink{}
+	relay := outbox.NewRelay(repo.Outbox(), testOptions, outbox.NewBusSink(bus), sink)
+
+	// One message per todo is claimed at a time.
+	published, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, published)
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []uint{1, 2, 1}, sink.todoIDs())
+	assert.Equal(t, uint(1), sink.events[0].OutboxID)
+	assert.Equal(t, uint64(3), bus.LastID())
+
+	published, err = relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, published, "published messages are not relayed again")
+
+	stats, err := relay.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), stats.Published)
+	assert.Zero(t, stats.Pending)
+	assert.NotNil(t, stats.LastPublishedAt)
+}
+
+func TestRelay_RetriesAndKeepsPerTodoOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryTodoRepository()
+	appendEvents(t, repo, 1, 2, 1)
+
+	sink := &recordingSink{failFor: map[uint]bool{1: true}}
+	relay := outbox.NewRelay(repo.Outbox(), testOptions, sink)
+
+	published, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.Equal(t, []uint{2}, sink.todoIDs(), "todo 1 is held back, todo 2 is not")
+
+	stats, err := relay.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), stats.Failed)
+	assert.Equal(t, int64(2), stats.Pending)
+
+	sink.failFor = nil
+	time.Sleep(5 * time.Millisecond)
+	for _, want := range []int{1, 1, 0} {
+		published, err = relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, want, published)
+	}
+
+	require.Len(t, sink.events, 3)
+	assert.Equal(t, uint(1), sink.events[1].OutboxID)
+	assert.Equal(t, uint(3), sink.events[2].OutboxID)
+
+	messages, err := repo.Outbox().GetAfter(0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 2, messages[0].Attempts)
+}
+
+func TestRelay_GivesUpAfterMaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryTodoRepository()
+	appendEvents(t, repo, 1, 1)
+
+	sink := &recordingSink{failFor: map[uint]bool{1: true}}
+	opts := testOptions
+	opts.MaxAttempts = 2
+	relay := outbox.NewRelay(repo.Outbox(), opts, sink)
+
+	for i := 0; i < 2; i++ {
+		_, err := relay.RelayOnce(ctx)
+		require.NoError(t, err)
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	stats, err := relay.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.Dead)
+	assert.Equal(t, int64(1), stats.Pending)
+
+	sink.failFor = nil
+	published, err := relay.RelayOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, published, "the next message of the todo is no longer held back")
+	require.Len(t, sink.events, 1)
+	assert.Equal(t, uint(2), sink.events[0].OutboxID)
+}
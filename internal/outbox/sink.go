@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"context"
+	"log"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// Sink receives relayed events. Delivery is at least once: when any sink
+// fails the message is retried for every sink, so sinks must tolerate
+// duplicates (Event.OutboxID identifies the change).
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event eventbus.Event) error
+}
+
+// BusSink publishes to the in-process bus, or to anything else that
+// implements eventbus.Publisher.
+type BusSink struct {
+	publisher eventbus.Publisher
+}
+
+func NewBusSink(publisher eventbus.Publisher) *BusSink {
+	return &BusSink{publisher: publisher}
+}
+
+func (s *BusSink) Name() string { return "bus" }
+
+func (s *BusSink) Publish(ctx context.Context, event eventbus.Event) error {
+	s.publisher.Publish(event)
+	return nil
+}
+
+// WebhookSink queues webhook deliveries for the event and wakes the
+// dispatcher that sends them.
+type WebhookSink struct {
+	service    service.WebhookServiceInterface
+	dispatcher service.Waker
+}
+
+func NewWebhookSink(service service.WebhookServiceInterface, dispatcher service.Waker) *WebhookSink {
+	return &WebhookSink{service: service, dispatcher: dispatcher}
+}
+
+func (s *WebhookSink) Name() string { return "webhooks" }
+
+func (s *WebhookSink) Publish(ctx context.Context, event eventbus.Event) error {
+	if err := s.service.Enqueue(ctx, event); err != nil {
+		return err
+	}
+	if s.dispatcher != nil {
+		s.dispatcher.Wake()
+	}
+	return nil
+}
+
+// LogSink writes one log line per event.
+type LogSink struct{}
+
+func (LogSink) Name() string { return "log" }
+
+func (LogSink) Publish(ctx context.Context, event eventbus.Event) error {
+	log.Printf("event %d: %s todo=%d actor=%s", event.OutboxID, event.Type, event.TodoID, event.Actor)
+	return nil
+}
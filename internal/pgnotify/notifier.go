@@ -0,0 +1,263 @@
+// Package pgnotify shares todo change events between API instances through
+// Postgres LISTEN/NOTIFY.
+package pgnotify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	Channel = "todo_changes"
+
+	catchUpBatch = 500
+	// recentSize bounds how many outbox IDs are remembered to suppress
+	// duplicates between notifications, catch-up and local publishes.
+	recentSize = 4096
+	maxBackoff = 30 * time.Second
+	// catchUpOverlap is how far before a lost connection was noticed the
+	// catch-up starts. It covers notifications lost before the read failed
+	// and clock skew between instances; what is replayed twice is dropped
+	// as a duplicate.
+	catchUpOverlap = time.Minute
+)
+
+// message is the payload of a notification. It only names the outbox
+// message, which listeners load themselves: Postgres rejects payloads of
+// 8000 bytes or more, and an event carries the whole todo and the actor.
+type message struct {
+	Instance string `json:"instance"`
+	OutboxID uint   `json:"outbox_id"`
+}
+
+// Notifier is an eventbus.Publisher that delivers events to the local bus
+// and announces them to other instances with pg_notify. Run listens for the
+// announcements of other instances and republishes them locally.
+type Notifier struct {
+	db       *gorm.DB
+	dsn      string
+	bus      eventbus.Publisher
+	outbox   repository.OutboxRepositoryInterface
+	instance string
+
+	mu     sync.Mutex
+	recent map[uint]struct{}
+	order  []uint
+}
+
+func NewNotifier(db *gorm.DB, dsn string, bus eventbus.Publisher, outbox repository.OutboxRepositoryInterface) *Notifier {
+	return &Notifier{
+		db:       db,
+		dsn:      dsn,
+		bus:      bus,
+		outbox:   outbox,
+		instance: newInstanceID(),
+		recent:   make(map[uint]struct{}),
+	}
+}
+
+// Publish skips the local bus when catch-up already delivered the event
+// there, but always notifies the other instances. Events that did not come
+// from the outbox are not announced, since others could not load them.
+func (n *Notifier) Publish(event eventbus.Event) eventbus.Event {
+	published := event
+	if n.markSeen(event.OutboxID) {
+		published = n.bus.Publish(event)
+	}
+	if event.OutboxID == 0 {
+		return published
+	}
+
+	payload, err := json.Marshal(message{Instance: n.instance, OutboxID: event.OutboxID})
+	if err != nil {
+		log.Printf("pgnotify: failed to encode event: %v", err)
+		return published
+	}
+	if err := n.db.Exec("SELECT pg_notify(?, ?)", Channel, string(payload)).Error; err != nil {
+		log.Printf("pgnotify: failed to notify: %v", err)
+	}
+	return published
+}
+
+// Run keeps a LISTEN connection open until ctx is done. After a lost
+// connection it reconnects with exponential backoff and replays the outbox
+// messages relayed in the meantime, since notifications sent while nobody
+// listened are gone.
+//
+// The replay goes by what the relays committed, not by outbox ID: IDs are
+// assigned before commit, so a lower ID can be relayed after a higher one.
+// A relay notifies before it marks a message published, so every message
+// whose notification may have been missed is either still unpublished or
+// was published after the connection was lost.
+func (n *Notifier) Run(ctx context.Context) {
+	backoff := time.Second
+	var lost time.Time
+
+	for ctx.Err() == nil {
+		conn, err := n.listen(ctx)
+		if err != nil {
+			log.Printf("pgnotify: listen failed, retrying in %s: %v", backoff, err)
+			if !sleep(ctx, backoff) {
+				return
+			}
+			backoff = min(backoff*2, maxBackoff)
+			continue
+		}
+		backoff = time.Second
+
+		if !lost.IsZero() {
+			n.catchUp(lost.Add(-catchUpOverlap))
+		}
+
+		err = n.receive(ctx, conn)
+		conn.Close(context.Background())
+		lost = time.Now().UTC()
+		if ctx.Err() == nil {
+			log.Printf("pgnotify: connection lost: %v", err)
+		}
+	}
+}
+
+func (n *Notifier) listen(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, n.dsn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (n *Notifier) receive(ctx context.Context, conn *pgx.Conn) error {
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		n.handle(notification.Payload)
+	}
+}
+
+func (n *Notifier) handle(payload string) {
+	var msg message
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		log.Printf("pgnotify: ignoring malformed notification: %v", err)
+		return
+	}
+	if msg.Instance == n.instance || msg.OutboxID == 0 || n.seen(msg.OutboxID) {
+		return
+	}
+
+	// The message is only marked seen once loaded, so one that failed to
+	// load is still replayed by the next catch-up.
+	outboxMessage, err := n.outbox.GetByID(msg.OutboxID)
+	if err != nil {
+		log.Printf("pgnotify: failed to load outbox message %d: %v", msg.OutboxID, err)
+		return
+	}
+	event, err := eventbus.FromOutbox(*outboxMessage)
+	if err != nil {
+		log.Printf("pgnotify: skipping undecodable outbox message %d: %v", msg.OutboxID, err)
+		return
+	}
+	if n.markSeen(msg.OutboxID) {
+		n.bus.Publish(event)
+	}
+}
+
+// catchUp publishes the outbox messages published at or after since, or not
+// published yet, that this instance has not seen. The notification of a
+// message that is relayed later is dropped as a duplicate.
+func (n *Notifier) catchUp(since time.Time) {
+	replayed := 0
+	var after uint
+	for {
+		messages, err := n.outbox.GetUnpublishedOrSince(since, after, catchUpBatch)
+		if err != nil {
+			log.Printf("pgnotify: catch-up query failed: %v", err)
+			return
+		}
+		for _, message := range messages {
+			after = message.ID
+			if !n.markSeen(message.ID) {
+				continue
+			}
+			event, err := eventbus.FromOutbox(message)
+			if err != nil {
+				log.Printf("pgnotify: skipping undecodable outbox message %d: %v", message.ID, err)
+				continue
+			}
+			n.bus.Publish(event)
+			replayed++
+		}
+		if len(messages) < catchUpBatch {
+			break
+		}
+	}
+
+	if replayed > 0 {
+		log.Printf("pgnotify: replayed %d changes missed while disconnected", replayed)
+	}
+}
+
+// seen reports whether an outbox ID was already delivered to the local bus.
+func (n *Notifier) seen(id uint) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	_, ok := n.recent[id]
+	return ok
+}
+
+// markSeen records an outbox ID and reports whether it was new. Events
+// without an outbox ID are always treated as new.
+func (n *Notifier) markSeen(id uint) bool {
+	if id == 0 {
+		return true
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.recent[id]; ok {
+		return false
+	}
+	n.recent[id] = struct{}{}
+	n.order = append(n.order, id)
+	if len(n.order) > recentSize {
+		delete(n.recent, n.order[0])
+		n.order = n.order[1:]
+	}
+	return true
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,109 @@
+package pgnotify
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func payload(t *testing.T, instance string, outboxID uint) string {
+	t.Helper()
+	b, err := json.Marshal(message{Instance: instance, OutboxID: outboxID})
+	require.NoError(t, err)
+	return string(b)
+}
+
+// newOutbox returns an outbox holding one created event per todo ID.
+func newOutbox(t *testing.T, todoIDs ...uint) repository.OutboxRepositoryInterface {
+	t.Helper()
+	todos := repository.NewMemoryTodoRepository()
+	for _, id := range todoIDs {
+		message, err := eventbus.NewOutboxMessage(eventbus.Event{
+			Type:   eventbus.TodoCreated,
+			TodoID: id,
+			Todo:   &model.Todo{ID: id, Title: "todo"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, todos.AppendOutbox(message))
+	}
+	return todos.Outbox()
+}
+
+func TestHandleRepublishesRemoteEvents(t *testing.T) {
+	bus := eventbus.NewBus(10)
+	n := NewNotifier(nil, "", bus, newOutbox(t, 5, 6, 7))
+	sub, _, _ := bus.Subscribe(0, 10)
+	defer sub.Close()
+
+	n.handle(payload(t, "other", 3))
+
+	event := <-sub.Events()
+	assert.Equal(t, eventbus.TodoCreated, event.Type)
+	assert.Equal(t, uint(7), event.TodoID, "the event is loaded from the outbox")
+	assert.Equal(t, uint(3), event.OutboxID)
+	require.NotNil(t, event.Todo)
+	assert.Equal(t, "todo", event.Todo.Title)
+}
+
+func TestPayloadStaysSmall(t *testing.T) {
+	// Escaped to six bytes per character, this todo alone would exceed the
+	// 8000-byte limit of NOTIFY payloads.
+	title := strings.Repeat("<", 2000)
+	event := eventbus.Event{Type: eventbus.TodoCreated, TodoID: 1, OutboxID: 42, Actor: strings.Repeat("&", 2000),
+		Todo: &model.Todo{ID: 1, Title: title, Description: title}}
+	full, err := json.Marshal(event)
+	require.NoError(t, err)
+	require.Greater(t, len(full), 8000)
+
+	assert.Less(t, len(payload(t, "0123456789abcdef", event.OutboxID)), 100)
+}
+
+func TestHandleSkipsOwnAndDuplicateEvents(t *testing.T) {
+	bus := eventbus.NewBus(10)
+	n := NewNotifier(nil, "", bus, newOutbox(t, 1, 2))
+
+	n.handle(payload(t, n.instance, 1))
+	n.handle(payload(t, "other", 2))
+	n.handle(payload(t, "other", 2))
+	n.handle(payload(t, "other", 9))
+	n.handle("not json")
+
+	assert.Equal(t, uint64(1), bus.LastID())
+}
+
+func TestCatchUpReplaysMissedMessages(t *testing.T) {
+	bus := eventbus.NewBus(10)
+	outbox := newOutbox(t, 1, 2, 3, 4)
+	lost := time.Now().UTC()
+	// Message 1 was relayed before the connection was lost, 3 and 4 after
+	// it; 4 has the lower outbox ID of the two but committed later. Message
+	// 2 is not relayed yet.
+	require.NoError(t, outbox.MarkPublished(1, lost.Add(-time.Hour)))
+	require.NoError(t, outbox.MarkPublished(4, lost.Add(time.Second)))
+	require.NoError(t, outbox.MarkPublished(3, lost.Add(2*time.Second)))
+
+	n := NewNotifier(nil, "", bus, outbox)
+	// Message 3 already arrived through a notification.
+	n.markSeen(3)
+
+	sub, _, _ := bus.Subscribe(0, 10)
+	defer sub.Close()
+	n.catchUp(lost)
+
+	var replayed []uint
+	for range 2 {
+		event := <-sub.Events()
+		assert.Equal(t, eventbus.TodoCreated, event.Type)
+		assert.Equal(t, event.OutboxID, event.TodoID)
+		replayed = append(replayed, event.OutboxID)
+	}
+	assert.Equal(t, []uint{2, 4}, replayed)
+	assert.Equal(t, uint64(2), bus.LastID())
+}
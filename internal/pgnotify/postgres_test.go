@@ -0,0 +1,82 @@
+package pgnotify
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// TestPostgresCatchUpAfterReconnect runs only when TEST_POSTGRES_DSN points
+// at a disposable database, e.g. the one from docker-compose.
+func TestPostgresCatchUpAfterReconnect(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Migrator().DropTable(&model.OutboxMessage{}))
+	require.NoError(t, db.AutoMigrate(&model.OutboxMessage{}))
+	todos := repository.NewTodoRepository(db)
+	outbox := repository.NewOutboxRepository(db)
+
+	sender := NewNotifier(db, dsn, eventbus.NewBus(0), outbox)
+	bus := eventbus.NewBus(10)
+	listener := NewNotifier(db, dsn, bus, outbox)
+	sub, _, _ := bus.Subscribe(0, 10)
+	defer sub.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go listener.Run(ctx)
+
+	listening := func() bool {
+		var count int64
+		db.Raw("SELECT count(*) FROM pg_stat_activity WHERE query = ?", "LISTEN "+Channel).Scan(&count)
+		return count == 1
+	}
+	require.Eventually(t, listening, 5*time.Second, 10*time.Millisecond)
+
+	appendMessage := func(todoID uint) eventbus.Event {
+		event := eventbus.Event{Type: eventbus.TodoCreated, TodoID: todoID, Todo: &model.Todo{ID: todoID, Title: "todo"}}
+		message, err := eventbus.NewOutboxMessage(event)
+		require.NoError(t, err)
+		require.NoError(t, todos.AppendOutbox(message))
+		event.OutboxID = message.ID
+		return event
+	}
+	next := func() eventbus.Event {
+		select {
+		case event := <-sub.Events():
+			return event
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event received")
+			return eventbus.Event{}
+		}
+	}
+
+	// The first change gets the lower outbox ID but is relayed last, after
+	// its notification was lost.
+	late := appendMessage(1)
+	early := appendMessage(2)
+	sender.Publish(early)
+	require.NoError(t, outbox.MarkPublished(early.OutboxID, time.Now().UTC()))
+	assert.Equal(t, early.OutboxID, next().OutboxID)
+
+	require.NoError(t, db.Exec("SELECT pg_terminate_backend(pid) FROM pg_stat_activity WHERE query = ?", "LISTEN "+Channel).Error)
+	require.NoError(t, outbox.MarkPublished(late.OutboxID, time.Now().UTC()))
+
+	replayed := next()
+	assert.Equal(t, late.OutboxID, replayed.OutboxID)
+	assert.Equal(t, uint(1), replayed.TodoID)
+}
@@ -0,0 +1,489 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"gorm.io/gorm"
+)
+
+type MemoryTodoRepository struct {
+	mu     sync.RWMutex
+	todos  map[uint]model.Todo
+	nextID uint
+	// tombstones records purged todos for sync clients.
+	tombstones []model.TodoTombstone
+	outbox     *MemoryOutboxRepository
+	events     *MemoryTodoEventRepository
+	undo       map[string]model.UndoAction
+	// staged and stagedEvents hold the outbox messages and history events
+	// appended inside a transaction until it commits.
+	staged       []*model.OutboxMessage
+	stagedEvents []*model.TodoEvent
+	inTx         bool
+}
+
+func NewMemoryTodoRepository() *MemoryTodoRepository {
+	return &MemoryTodoRepository{
+		todos:  make(map[uint]model.Todo),
+		nextID: 1,
+		outbox: &MemoryOutboxRepository{},
+		events: NewMemoryTodoEventRepository(),
+		undo:   make(map[string]model.UndoAction),
+	}
+}
+
+// Outbox returns the repository holding the messages written with
+// AppendOutbox.
+func (r *MemoryTodoRepository) Outbox() *MemoryOutboxRepository {
+	return r.outbox
+}
+
+func (r *MemoryTodoRepository) Create(todo *model.Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if todo.ID == 0 {
+		todo.ID = r.nextID
+	} else if _, exists := r.todos[todo.ID]; exists {
+		return errors.New("duplicate primary key")
+	}
+	if todo.ID >= r.nextID {
+		r.nextID = todo.ID + 1
+	}
+
+	now := time.Now()
+	if todo.CreatedAt.IsZero() {
+		todo.CreatedAt = now
+	}
+	if todo.UpdatedAt.IsZero() {
+		todo.UpdatedAt = now
+	}
+
+	r.todos[todo.ID] = *todo
+	return nil
+}
+
+func (r *MemoryTodoRepository) CreateBatch(todos []model.Todo) error {
+	var ids []string
+	for _, todo := range todos {
+		if todo.ExternalID != nil {
+			ids = append(ids, *todo.ExternalID)
+		}
+	}
+	existing, _ := r.GetByExternalIDs(ids)
+	taken := make(map[string]bool, len(existing))
+	for _, todo := range existing {
+		taken[*todo.ExternalID] = true
+	}
+
+	for i := range todos {
+		if todos[i].ExternalID != nil && taken[*todos[i].ExternalID] {
+			todos[i].ID = 0
+			continue
+		}
+		if err := r.Create(&todos[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryTodoRepository) GetAll() ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filter(func(t model.Todo) bool { return !t.DeletedAt.Valid }), nil
+}
+
+func (r *MemoryTodoRepository) GetByID(id uint) (*model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todo, ok := r.todos[id]
+	if !ok || todo.DeletedAt.Valid {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &todo, nil
+}
+
+func (r *MemoryTodoRepository) Update(todo *model.Todo) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if todo.ID == 0 {
+		todo.ID = r.nextID
+	}
+	if todo.ID >= r.nextID {
+		r.nextID = todo.ID + 1
+	}
+
+	now := time.Now()
+	if todo.CreatedAt.IsZero() {
+		if existing, ok := r.todos[todo.ID]; ok {
+			todo.CreatedAt = existing.CreatedAt
+		} else {
+			todo.CreatedAt = now
+		}
+	}
+	todo.UpdatedAt = now
+
+	r.todos[todo.ID] = *todo
+	return nil
+}
+
+func (r *MemoryTodoRepository) UpdateIfVersion(todo *model.Todo, version uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.todos[todo.ID]
+	if !ok || existing.DeletedAt.Valid || existing.Version != version {
+		return ErrStaleVersion
+	}
+	todo.CreatedAt = existing.CreatedAt
+	todo.UpdatedAt = time.Now()
+	r.todos[todo.ID] = *todo
+	return nil
+}
+
+func (r *MemoryTodoRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[id]
+	if !ok || todo.DeletedAt.Valid {
+		return nil
+	}
+
+	now := time.Now()
+	todo.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	todo.UpdatedAt = now
+	r.todos[id] = todo
+	return nil
+}
+
+func (r *MemoryTodoRepository) DeleteIfVersion(id uint, version uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[id]
+	if !ok || todo.DeletedAt.Valid || todo.Version != version {
+		return ErrStaleVersion
+	}
+
+	now := time.Now()
+	todo.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+	todo.UpdatedAt = now
+	r.todos[id] = todo
+	return nil
+}
+
+func (r *MemoryTodoRepository) GetByCompleted(completed bool) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.filter(func(t model.Todo) bool { return !t.DeletedAt.Valid && t.Completed == completed }), nil
+}
+
+func (r *MemoryTodoRepository) GetPage(completed *bool, page *model.Pagination) ([]model.Todo, int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos, total := slicePage(r.filter(func(t model.Todo) bool {
+		return !t.DeletedAt.Valid && (completed == nil || t.Completed == *completed)
+	}), page)
+	return todos, total, nil
+}
+
+func (r *MemoryTodoRepository) GetByProject(project string) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := r.filter(func(t model.Todo) bool { return !t.DeletedAt.Valid && t.Project == project })
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID < todos[j].ID })
+	return todos, nil
+}
+
+func (r *MemoryTodoRepository) GetProjects() ([]model.ProjectSummary, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	byProject := make(map[string]*model.ProjectSummary)
+	for _, todo := range r.filter(func(t model.Todo) bool { return !t.DeletedAt.Valid }) {
+		summary, ok := byProject[todo.Project]
+		if !ok {
+			summary = &model.ProjectSummary{Project: todo.Project}
+			byProject[todo.Project] = summary
+		}
+		summary.Todos++
+		summary.IDSum += int64(todo.ID)
+		summary.VersionSum += int64(todo.Version)
+	}
+
+	summaries := make([]model.ProjectSummary, 0, len(byProject))
+	for _, summary := range byProject {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Project < summaries[j].Project })
+	return summaries, nil
+}
+
+// ForEach copies the matching todos and calls fn without holding the lock,
+// so a slow fn does not block writers. The todos are in memory anyway, so
+// batchSize is ignored.
+func (r *MemoryTodoRepository) ForEach(completed *bool, batchSize int, fn func(todo model.Todo) error) error {
+	r.mu.RLock()
+	todos := r.filter(func(t model.Todo) bool {
+		return !t.DeletedAt.Valid && (completed == nil || t.Completed == *completed)
+	})
+	r.mu.RUnlock()
+
+	sort.Slice(todos, func(i, j int) bool { return todos[i].ID < todos[j].ID })
+	for _, todo := range todos {
+		if err := fn(todo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *MemoryTodoRepository) GetDeleted() ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := r.filter(func(t model.Todo) bool { return t.DeletedAt.Valid })
+	sort.SliceStable(todos, func(i, j int) bool {
+		return todos[i].DeletedAt.Time.After(todos[j].DeletedAt.Time)
+	})
+	return todos, nil
+}
+
+func (r *MemoryTodoRepository) GetDeletedPage(page *model.Pagination) ([]model.Todo, int64, error) {
+	todos, err := r.GetDeleted()
+	if err != nil {
+		return nil, 0, err
+	}
+	todos, total := slicePage(todos, page)
+	return todos, total, nil
+}
+
+func (r *MemoryTodoRepository) GetByExternalIDs(ids []string) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	return r.filter(func(t model.Todo) bool { return t.ExternalID != nil && wanted[*t.ExternalID] }), nil
+}
+
+func (r *MemoryTodoRepository) Restore(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[id]
+	if !ok || !todo.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+
+	todo.DeletedAt = gorm.DeletedAt{}
+	todo.UpdatedAt = time.Now()
+	r.todos[id] = todo
+	return nil
+}
+
+func (r *MemoryTodoRepository) Purge(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	todo, ok := r.todos[id]
+	if !ok || !todo.DeletedAt.Valid {
+		return gorm.ErrRecordNotFound
+	}
+
+	delete(r.todos, id)
+	r.addTombstone(todo, time.Now())
+	return nil
+}
+
+func (r *MemoryTodoRepository) PurgeDeletedBefore(cutoff time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	now := time.Now()
+	for id, todo := range r.todos {
+		if todo.DeletedAt.Valid && todo.DeletedAt.Time.Before(cutoff) {
+			delete(r.todos, id)
+			r.addTombstone(todo, now)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (r *MemoryTodoRepository) GetChangedSince(since time.Time, afterID uint, limit int) ([]model.Todo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	todos := []model.Todo{}
+	for _, todo := range r.todos {
+		if todo.UpdatedAt.After(since) || (todo.UpdatedAt.Equal(since) && todo.ID > afterID) {
+			todos = append(todos, todo)
+		}
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		if !todos[i].UpdatedAt.Equal(todos[j].UpdatedAt) {
+			return todos[i].UpdatedAt.Before(todos[j].UpdatedAt)
+		}
+		return todos[i].ID < todos[j].ID
+	})
+	if len(todos) > limit {
+		todos = todos[:limit]
+	}
+	return todos, nil
+}
+
+func (r *MemoryTodoRepository) GetPurgedSince(since time.Time) ([]model.TodoTombstone, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	tombstones := []model.TodoTombstone{}
+	for _, t := range r.tombstones {
+		if t.PurgedAt.After(since) {
+			tombstones = append(tombstones, t)
+		}
+	}
+	return tombstones, nil
+}
+
+func (r *MemoryTodoRepository) addTombstone(todo model.Todo, at time.Time) {
+	r.tombstones = append(r.tombstones, model.TodoTombstone{
+		ID:         uint(len(r.tombstones)) + 1,
+		TodoID:     todo.ID,
+		PurgedAt:   at,
+		ExternalID: todo.ExternalID,
+		Project:    todo.Project,
+	})
+}
+
+func (r *MemoryTodoRepository) AppendOutbox(messages ...*model.OutboxMessage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.inTx {
+		r.staged = append(r.staged, messages...)
+		return nil
+	}
+	for _, message := range messages {
+		r.outbox.append(message)
+	}
+	return nil
+}
+
+// Events returns the repository holding the history written with
+// AppendEvents.
+func (r *MemoryTodoRepository) Events() *MemoryTodoEventRepository {
+	return r.events
+}
+
+func (r *MemoryTodoRepository) AppendEvents(events []*model.TodoEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.inTx {
+		r.stagedEvents = append(r.stagedEvents, events...)
+		return nil
+	}
+	r.events.append(events)
+	return nil
+}
+
+func (r *MemoryTodoRepository) SaveUndo(action *model.UndoAction, now time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for token, saved := range r.undo {
+		if saved.ExpiresAt.Before(now) {
+			delete(r.undo, token)
+		}
+	}
+	r.undo[action.Token] = cloneUndo(*action)
+	return nil
+}
+
+func (r *MemoryTodoRepository) TakeUndo(token string, now time.Time) (*model.UndoAction, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	action, ok := r.undo[token]
+	if !ok || action.ExpiresAt.Before(now) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	delete(r.undo, token)
+	return &action, nil
+}
+
+func cloneUndo(action model.UndoAction) model.UndoAction {
+	action.Targets = append([]model.UndoTarget(nil), action.Targets...)
+	return action
+}
+
+// Transaction runs fn against a copy of the data while holding the write
+// lock, and swaps the copy in only if fn succeeds. fn must use the repository
+// it is given; calling back into r would deadlock.
+func (r *MemoryTodoRepository) Transaction(fn func(repo TodoRepositoryInterface) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx := &MemoryTodoRepository{
+		todos:      make(map[uint]model.Todo, len(r.todos)),
+		nextID:     r.nextID,
+		tombstones: append([]model.TodoTombstone(nil), r.tombstones...),
+		undo:       make(map[string]model.UndoAction, len(r.undo)),
+		inTx:       true,
+	}
+	for id, todo := range r.todos {
+		tx.todos[id] = todo
+	}
+	for token, action := range r.undo {
+		tx.undo[token] = action
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	r.todos = tx.todos
+	r.nextID = tx.nextID
+	r.tombstones = tx.tombstones
+	r.undo = tx.undo
+	for _, message := range tx.staged {
+		r.outbox.append(message)
+	}
+	r.events.append(tx.stagedEvents)
+	return nil
+}
+
+// filter returns matching todos ordered like the SQL repository: newest
+// first, with the ID as a tie-breaker so results are stable.
+func (r *MemoryTodoRepository) filter(keep func(model.Todo) bool) []model.Todo {
+	todos := make([]model.Todo, 0, len(r.todos))
+	for _, todo := range r.todos {
+		if keep(todo) {
+			todos = append(todos, todo)
+		}
+	}
+
+	sort.Slice(todos, func(i, j int) bool {
+		if !todos[i].CreatedAt.Equal(todos[j].CreatedAt) {
+			return todos[i].CreatedAt.After(todos[j].CreatedAt)
+		}
+		return todos[i].ID > todos[j].ID
+	})
+	return todos
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"gorm.io/gorm"
+)
+
+// MemoryOutboxRepository holds the outbox of a MemoryTodoRepository. Get it
+// with MemoryTodoRepository.Outbox.
+type MemoryOutboxRepository struct {
+	mu       sync.Mutex
+	messages []model.OutboxMessage
+	lastID   uint
+}
+
+func (r *MemoryOutboxRepository) append(message *model.OutboxMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastID++
+	message.ID = r.lastID
+	if message.CreatedAt.IsZero() {
+		message.CreatedAt = time.Now()
+	}
+	r.messages = append(r.messages, *message)
+}
+
+func (r *MemoryOutboxRepository) ClaimPending(now time.Time, lease time.Duration, limit int) ([]model.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := make(map[uint]bool)
+	claimed := []model.OutboxMessage{}
+	for i := range r.messages {
+		if len(claimed) == limit {
+			break
+		}
+		message := &r.messages[i]
+		if message.PublishedAt != nil || message.DeadAt != nil || seen[message.AggregateID] {
+			continue
+		}
+		seen[message.AggregateID] = true
+		if message.AvailableAt.After(now) {
+			continue
+		}
+		message.AvailableAt = now.Add(lease)
+		claimed = append(claimed, *message)
+	}
+	return claimed, nil
+}
+
+func (r *MemoryOutboxRepository) MarkPublished(id uint, at time.Time) error {
+	return r.update(id, func(m *model.OutboxMessage) {
+		m.PublishedAt = &at
+		m.Attempts++
+		m.LastError = ""
+	})
+}
+
+func (r *MemoryOutboxRepository) MarkFailed(id uint, lastError string, retryAt time.Time) error {
+	return r.update(id, func(m *model.OutboxMessage) {
+		m.AvailableAt = retryAt
+		m.Attempts++
+		m.LastError = lastError
+	})
+}
+
+func (r *MemoryOutboxRepository) MarkDead(id uint, lastError string, at time.Time) error {
+	return r.update(id, func(m *model.OutboxMessage) {
+		m.DeadAt = &at
+		m.Attempts++
+		m.LastError = lastError
+	})
+}
+
+func (r *MemoryOutboxRepository) Release(id uint) error {
+	return r.update(id, func(m *model.OutboxMessage) {
+		if m.PublishedAt == nil {
+			m.AvailableAt = time.Now().UTC()
+		}
+	})
+}
+
+func (r *MemoryOutboxRepository) GetByID(id uint) (*model.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, m := range r.messages {
+		if m.ID == id {
+			return &m, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *MemoryOutboxRepository) GetAfter(afterID uint, limit int) ([]model.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	messages := []model.OutboxMessage{}
+	for _, m := range r.messages {
+		if len(messages) == limit {
+			break
+		}
+		if m.ID > afterID {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (r *MemoryOutboxRepository) GetUnpublishedOrSince(since time.Time, afterID uint, limit int) ([]model.OutboxMessage, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	messages := []model.OutboxMessage{}
+	for _, m := range r.messages {
+		if len(messages) == limit {
+			break
+		}
+		if m.ID > afterID && (m.PublishedAt == nil || !m.PublishedAt.Before(since)) {
+			messages = append(messages, m)
+		}
+	}
+	return messages, nil
+}
+
+func (r *MemoryOutboxRepository) LastID() (uint, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.lastID, nil
+}
+
+func (r *MemoryOutboxRepository) Stats() (OutboxStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var stats OutboxStats
+	for _, m := range r.messages {
+		if m.DeadAt != nil {
+			stats.Dead++
+			continue
+		}
+		if m.PublishedAt != nil {
+			continue
+		}
+		if stats.Pending == 0 {
+			createdAt := m.CreatedAt
+			stats.OldestPending = &createdAt
+		}
+		stats.Pending++
+	}
+	return stats, nil
+}
+
+func (r *MemoryOutboxRepository) DeletePublishedBefore(cutoff time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.messages[:0]
+	for _, m := range r.messages {
+		if m.PublishedAt == nil || !m.PublishedAt.Before(cutoff) {
+			kept = append(kept, m)
+		}
+	}
+	deleted := int64(len(r.messages) - len(kept))
+	r.messages = kept
+	return deleted, nil
+}
+
+func (r *MemoryOutboxRepository) update(id uint, apply func(m *model.OutboxMessage)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.messages {
+		if r.messages[i].ID == id {
+			apply(&r.messages[i])
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"gorm.io/gorm"
+)
+
+type MemoryTodoEventRepository struct {
+	mu     sync.RWMutex
+	events []model.TodoEvent
+}
+
+func NewMemoryTodoEventRepository() *MemoryTodoEventRepository {
+	return &MemoryTodoEventRepository{}
+}
+
+func (r *MemoryTodoEventRepository) Append(event *model.TodoEvent) error {
+	r.append([]*model.TodoEvent{event})
+	return nil
+}
+
+// append numbers and stores events, holding the lock for all of them.
+func (r *MemoryTodoEventRepository) append(events []*model.TodoEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last := make(map[uint]uint)
+	for _, e := range r.events {
+		if e.Revision > last[e.TodoID] {
+			last[e.TodoID] = e.Revision
+		}
+	}
+
+	now := time.Now()
+	for _, event := range events {
+		last[event.TodoID]++
+		event.ID = uint(len(r.events)) + 1
+		event.Revision = last[event.TodoID]
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = now
+		}
+		r.events = append(r.events, cloneEvent(*event))
+	}
+}
+
+func (r *MemoryTodoEventRepository) GetByTodoID(todoID uint) ([]model.TodoEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := []model.TodoEvent{}
+	for _, e := range r.events {
+		if e.TodoID == todoID {
+			events = append(events, cloneEvent(e))
+		}
+	}
+	return events, nil
+}
+
+func (r *MemoryTodoEventRepository) GetPageByTodoID(todoID uint, page *model.Pagination) ([]model.TodoEvent, int64, error) {
+	events, err := r.GetByTodoID(todoID)
+	if err != nil {
+		return nil, 0, err
+	}
+	events, total := slicePage(events, page)
+	return events, total, nil
+}
+
+func (r *MemoryTodoEventRepository) GetByTodoIDs(todoIDs []uint) ([]model.TodoEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	wanted := make(map[uint]bool, len(todoIDs))
+	for _, id := range todoIDs {
+		wanted[id] = true
+	}
+
+	events := []model.TodoEvent{}
+	for _, e := range r.events {
+		if wanted[e.TodoID] {
+			events = append(events, cloneEvent(e))
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].TodoID != events[j].TodoID {
+			return events[i].TodoID < events[j].TodoID
+		}
+		return events[i].Revision < events[j].Revision
+	})
+	return events, nil
+}
+
+func (r *MemoryTodoEventRepository) GetRevision(todoID, revision uint) (*model.TodoEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, e := range r.events {
+		if e.TodoID == todoID && e.Revision == revision {
+			event := cloneEvent(e)
+			return &event, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *MemoryTodoEventRepository) GetAfter(afterID uint, limit int) ([]model.TodoEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	events := []model.TodoEvent{}
+	for _, e := range r.events {
+		if len(events) == limit {
+			break
+		}
+		if e.ID > afterID {
+			events = append(events, cloneEvent(e))
+		}
+	}
+	return events, nil
+}
+
+func (r *MemoryTodoEventRepository) LastID() (uint, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return uint(len(r.events)), nil
+}
+
+func cloneEvent(e model.TodoEvent) model.TodoEvent {
+	e.Changes = append([]model.FieldChange(nil), e.Changes...)
+	return e
+}
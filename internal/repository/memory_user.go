@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"gorm.io/gorm"
+)
+
+type MemoryUserRepository struct {
+	mu        sync.Mutex
+	users     map[uint]model.User
+	keys      map[string]model.APIKey
+	nextID    uint
+	nextKeyID uint
+}
+
+func NewMemoryUserRepository() *MemoryUserRepository {
+	return &MemoryUserRepository{
+		users: make(map[uint]model.User),
+		keys:  make(map[string]model.APIKey),
+	}
+}
+
+func (r *MemoryUserRepository) CreateUser(user *model.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Name == user.Name {
+			return ErrUserExists
+		}
+	}
+	r.nextID++
+	user.ID = r.nextID
+	user.CreatedAt = time.Now()
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *MemoryUserRepository) GetUserByName(name string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, u := range r.users {
+		if u.Name == name {
+			return &u, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *MemoryUserRepository) CreateAPIKey(key *model.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextKeyID++
+	key.ID = r.nextKeyID
+	key.CreatedAt = time.Now()
+	r.keys[key.Hash] = *key
+	return nil
+}
+
+func (r *MemoryUserRepository) GetUserByKeyHash(hash string) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[hash]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	user, ok := r.users[key.UserID]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &user, nil
+}
@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"gorm.io/gorm"
+)
+
+type MemoryWebhookRepository struct {
+	mu             sync.Mutex
+	webhooks       map[uint]model.Webhook
+	deliveries     map[uint]model.WebhookDelivery
+	nextID         uint
+	nextDeliveryID uint
+}
+
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{
+		webhooks:   make(map[uint]model.Webhook),
+		deliveries: make(map[uint]model.WebhookDelivery),
+	}
+}
+
+func (r *MemoryWebhookRepository) Create(webhook *model.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextID++
+	now := time.Now()
+	webhook.ID = r.nextID
+	webhook.CreatedAt = now
+	webhook.UpdatedAt = now
+	r.webhooks[webhook.ID] = cloneWebhook(*webhook)
+	return nil
+}
+
+func (r *MemoryWebhookRepository) GetAll() ([]model.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	webhooks := make([]model.Webhook, 0, len(r.webhooks))
+	for _, w := range r.webhooks {
+		webhooks = append(webhooks, cloneWebhook(w))
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (r *MemoryWebhookRepository) GetPage(page *model.Pagination) ([]model.Webhook, int64, error) {
+	webhooks, err := r.GetAll()
+	if err != nil {
+		return nil, 0, err
+	}
+	webhooks, total := slicePage(webhooks, page)
+	return webhooks, total, nil
+}
+
+func (r *MemoryWebhookRepository) GetByID(id uint) (*model.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.webhooks[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	webhook := cloneWebhook(w)
+	return &webhook, nil
+}
+
+func (r *MemoryWebhookRepository) Update(webhook *model.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.webhooks[webhook.ID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	webhook.UpdatedAt = time.Now()
+	r.webhooks[webhook.ID] = cloneWebhook(*webhook)
+	return nil
+}
+
+func (r *MemoryWebhookRepository) RecordSuccess(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if w, ok := r.webhooks[id]; ok && w.ConsecutiveFailures > 0 {
+		w.ConsecutiveFailures = 0
+		w.UpdatedAt = time.Now()
+		r.webhooks[id] = w
+	}
+	return nil
+}
+
+func (r *MemoryWebhookRepository) RecordFailure(id uint, disableAfter int, at time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.webhooks[id]
+	if !ok {
+		return false, nil
+	}
+	w.ConsecutiveFailures++
+	w.UpdatedAt = time.Now()
+	disabled := w.Active && w.ConsecutiveFailures >= disableAfter
+	if disabled {
+		w.Active = false
+		w.DisabledAt = &at
+	}
+	r.webhooks[id] = w
+	return disabled, nil
+}
+
+func (r *MemoryWebhookRepository) Delete(id uint) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.webhooks[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.webhooks, id)
+	for deliveryID, d := range r.deliveries {
+		if d.WebhookID == id {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryWebhookRepository) EnqueueDelivery(delivery *model.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.nextDeliveryID++
+	now := time.Now()
+	delivery.ID = r.nextDeliveryID
+	delivery.CreatedAt = now
+	delivery.UpdatedAt = now
+	r.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *MemoryWebhookRepository) ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	due := []model.WebhookDelivery{}
+	for _, d := range r.deliveries {
+		if d.Status == model.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if !due[i].NextAttemptAt.Equal(due[j].NextAttemptAt) {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		}
+		return due[i].ID < due[j].ID
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for i := range due {
+		due[i].NextAttemptAt = now.Add(lease)
+		r.deliveries[due[i].ID] = due[i]
+	}
+	return due, nil
+}
+
+func (r *MemoryWebhookRepository) UpdateDelivery(delivery *model.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.deliveries[delivery.ID]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	delivery.UpdatedAt = time.Now()
+	r.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *MemoryWebhookRepository) GetDeliveries(webhookID uint, limit int) ([]model.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deliveries := []model.WebhookDelivery{}
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID > deliveries[j].ID })
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func cloneWebhook(w model.Webhook) model.Webhook {
+	w.Events = append([]string(nil), w.Events...)
+	if w.DisabledAt != nil {
+		disabledAt := *w.DisabledAt
+		w.DisabledAt = &disabledAt
+	}
+	return w
+}
@@ -1,12 +1,17 @@
 package mocks
 
 import (
+	"time"
+
 	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
 	"github.com/stretchr/testify/mock"
 )
 
 type TodoRepositoryMock struct {
 	mock.Mock
+	Outbox []model.OutboxMessage
+	Events []model.TodoEvent
 }
 
 func (m *TodoRepositoryMock) Create(todo *model.Todo) error {
@@ -14,6 +19,11 @@ func (m *TodoRepositoryMock) Create(todo *model.Todo) error {
 	return args.Error(0)
 }
 
+func (m *TodoRepositoryMock) CreateBatch(todos []model.Todo) error {
+	args := m.Called(todos)
+	return args.Error(0)
+}
+
 func (m *TodoRepositoryMock) GetAll() ([]model.Todo, error) {
 	args := m.Called()
 	return args.Get(0).([]model.Todo), args.Error(1)
@@ -29,6 +39,16 @@ func (m *TodoRepositoryMock) Update(todo *model.Todo) error {
 	return args.Error(0)
 }
 
+func (m *TodoRepositoryMock) UpdateIfVersion(todo *model.Todo, version uint) error {
+	args := m.Called(todo, version)
+	return args.Error(0)
+}
+
+func (m *TodoRepositoryMock) DeleteIfVersion(id uint, version uint) error {
+	args := m.Called(id, version)
+	return args.Error(0)
+}
+
 func (m *TodoRepositoryMock) Delete(id uint) error {
 	args := m.Called(id)
 	return args.Error(0)
@@ -38,3 +58,96 @@ func (m *TodoRepositoryMock) GetByCompleted(completed bool) ([]model.Todo, error
 	args := m.Called(completed)
 	return args.Get(0).([]model.Todo), args.Error(1)
 }
+
+func (m *TodoRepositoryMock) GetPage(completed *bool, page *model.Pagination) ([]model.Todo, int64, error) {
+	args := m.Called(completed, page)
+	return args.Get(0).([]model.Todo), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *TodoRepositoryMock) GetByProject(project string) ([]model.Todo, error) {
+	args := m.Called(project)
+	return args.Get(0).([]model.Todo), args.Error(1)
+}
+
+func (m *TodoRepositoryMock) GetProjects() ([]model.ProjectSummary, error) {
+	args := m.Called()
+	return args.Get(0).([]model.ProjectSummary), args.Error(1)
+}
+
+func (m *TodoRepositoryMock) ForEach(completed *bool, batchSize int, fn func(todo model.Todo) error) error {
+	args := m.Called(completed, batchSize, fn)
+	return args.Error(0)
+}
+
+func (m *TodoRepositoryMock) GetByExternalIDs(ids []string) ([]model.Todo, error) {
+	args := m.Called(ids)
+	return args.Get(0).([]model.Todo), args.Error(1)
+}
+
+func (m *TodoRepositoryMock) GetDeleted() ([]model.Todo, error) {
+	args := m.Called()
+	return args.Get(0).([]model.Todo), args.Error(1)
+}
+
+func (m *TodoRepositoryMock) GetDeletedPage(page *model.Pagination) ([]model.Todo, int64, error) {
+	args := m.Called(page)
+	return args.Get(0).([]model.Todo), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *TodoRepositoryMock) Restore(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *TodoRepositoryMock) Purge(id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *TodoRepositoryMock) PurgeDeletedBefore(cutoff time.Time) (int64, error) {
+	args := m.Called(cutoff)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *TodoRepositoryMock) GetChangedSince(since time.Time, afterID uint, limit int) ([]model.Todo, error) {
+	args := m.Called(since, afterID, limit)
+	return args.Get(0).([]model.Todo), args.Error(1)
+}
+
+func (m *TodoRepositoryMock) GetPurgedSince(since time.Time) ([]model.TodoTombstone, error) {
+	args := m.Called(since)
+	return args.Get(0).([]model.TodoTombstone), args.Error(1)
+}
+
+// Transaction runs fn against the mock itself, so expectations set on the
+// mock apply inside the transaction too.
+func (m *TodoRepositoryMock) Transaction(fn func(repo repository.TodoRepositoryInterface) error) error {
+	return fn(m)
+}
+
+// AppendOutbox collects messages in Outbox instead of going through
+// expectations, since nearly every mutation writes one.
+func (m *TodoRepositoryMock) AppendOutbox(messages ...*model.OutboxMessage) error {
+	for _, message := range messages {
+		m.Outbox = append(m.Outbox, *message)
+	}
+	return nil
+}
+
+// AppendEvents collects history events in Events, like AppendOutbox.
+func (m *TodoRepositoryMock) AppendEvents(events []*model.TodoEvent) error {
+	for _, event := range events {
+		m.Events = append(m.Events, *event)
+	}
+	return nil
+}
+
+func (m *TodoRepositoryMock) SaveUndo(action *model.UndoAction, now time.Time) error {
+	args := m.Called(action, now)
+	return args.Error(0)
+}
+
+func (m *TodoRepositoryMock) TakeUndo(token string, now time.Time) (*model.UndoAction, error) {
+	args := m.Called(token, now)
+	return args.Get(0).(*model.UndoAction), args.Error(1)
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	"github.com/stavagg/petGoApi/internal/config"
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+// Open connects to the database selected by cfg. The memory driver has no
+// database.
+func Open(cfg *config.Config) (*gorm.DB, error) {
+	switch cfg.StorageDriver {
+	case config.StoragePostgres:
+		return gorm.Open(postgres.Open(cfg.PostgresDSN()), &gorm.Config{})
+	case config.StorageSQLite:
+		return OpenSQLite(cfg.SQLitePath)
+	default:
+		return nil, fmt.Errorf("storage driver %q has no database", cfg.StorageDriver)
+	}
+}
+
+// Models are the tables managed by Migrate, in creation order.
+func Models() []interface{} {
+	return []interface{}{&model.Todo{}, &model.TodoTombstone{}, &model.TodoEvent{}, &model.OutboxMessage{}, &model.Webhook{}, &model.WebhookDelivery{}, &model.UndoAction{}, &model.User{}, &model.APIKey{}}
+}
+
+func Migrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(Models()...); err != nil {
+		return err
+	}
+	return backfillFieldTimes(db)
+}
+
+// backfillFieldTimes stamps UpdatedAt on every editable field of todos
+// stored before field times existed. Without it sync would see their
+// fields as never changed and let any offline edit win.
+func backfillFieldTimes(db *gorm.DB) error {
+	var todos []model.Todo
+	return db.Unscoped().Select("id", "updated_at").Where("field_times IS NULL").
+		FindInBatches(&todos, 500, func(tx *gorm.DB, _ int) error {
+			for _, todo := range todos {
+				times := make(map[string]time.Time, len(model.EditableFields))
+				for _, field := range model.EditableFields {
+					times[field] = todo.UpdatedAt
+				}
+				err := db.Model(&model.Todo{ID: todo.ID}).UpdateColumns(model.Todo{FieldTimes: times}).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// Migration is a change Migrate would make: a missing table, or a missing
+// column of an existing table.
+type Migration struct {
+	Table  string `json:"table"`
+	Column string `json:"column,omitempty"`
+}
+
+func (m Migration) String() string {
+	if m.Column == "" {
+		return "create table " + m.Table
+	}
+	return "add column " + m.Table + "." + m.Column
+}
+
+// PendingMigrations lists what Migrate would create. Changes to existing
+// columns and indexes are not detected.
+func PendingMigrations(db *gorm.DB) ([]Migration, error) {
+	var pending []Migration
+	migrator := db.Migrator()
+	for _, m := range Models() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(m); err != nil {
+			return nil, err
+		}
+		table := stmt.Schema.Table
+		if !migrator.HasTable(m) {
+			pending = append(pending, Migration{Table: table})
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !migrator.HasColumn(m, field.DBName) {
+				pending = append(pending, Migration{Table: table, Column: field.DBName})
+			}
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxStats describes the messages still waiting to be published and the
+// ones the relay gave up on.
+type OutboxStats struct {
+	Pending       int64      `json:"pending"`
+	OldestPending *time.Time `json:"oldest_pending"`
+	Dead          int64      `json:"dead"`
+}
+
+// OutboxRepositoryInterface is the relay's view of the outbox. Messages are
+// written through TodoRepositoryInterface.AppendOutbox, inside the
+// transaction of the change they describe.
+type OutboxRepositoryInterface interface {
+	// ClaimPending returns, in ID order, up to limit messages that are the
+	// oldest unpublished and not dead message of their todo and available
+	// at now, and makes them unavailable to other relays for lease. Only
+	// the head of each todo is handed out, so messages of one todo are
+	// never published out of order, and a todo whose head waits for a
+	// retry does not hold back the others.
+	ClaimPending(now time.Time, lease time.Duration, limit int) ([]model.OutboxMessage, error)
+	MarkPublished(id uint, at time.Time) error
+	// MarkFailed records a failed publish and makes the message available
+	// again at retryAt.
+	MarkFailed(id uint, lastError string, retryAt time.Time) error
+	// MarkDead records the last failed publish of a message and stops
+	// retrying it.
+	MarkDead(id uint, lastError string, at time.Time) error
+	// Release makes a claimed message available again right away without
+	// counting an attempt.
+	Release(id uint) error
+	// GetByID returns a message, published or not, or
+	// gorm.ErrRecordNotFound.
+	GetByID(id uint) (*model.OutboxMessage, error)
+	// GetAfter returns up to limit messages with an ID greater than
+	// afterID, published or not, in ID order.
+	GetAfter(afterID uint, limit int) ([]model.OutboxMessage, error)
+	// GetUnpublishedOrSince returns up to limit messages with an ID greater
+	// than afterID that are not published yet or were published at or after
+	// since, in ID order.
+	GetUnpublishedOrSince(since time.Time, afterID uint, limit int) ([]model.OutboxMessage, error)
+	// LastID returns the ID of the newest message, or 0 when there are none.
+	LastID() (uint, error)
+	Stats() (OutboxStats, error)
+	DeletePublishedBefore(cutoff time.Time) (int64, error)
+}
+
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// ClaimPending locks the claimed rows with FOR UPDATE SKIP LOCKED, so
+// concurrent relays on Postgres pass over each other's heads instead of
+// waiting for them. SQLite has a single writer and ignores the clause.
+func (r *OutboxRepository) ClaimPending(now time.Time, lease time.Duration, limit int) ([]model.OutboxMessage, error) {
+	var claimed []model.OutboxMessage
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		head := tx.Table("outbox_messages AS head").Select("MIN(head.id)").
+			Where("head.aggregate_id = outbox_messages.aggregate_id AND head.published_at IS NULL AND head.dead_at IS NULL")
+		err := tx.Where("published_at IS NULL AND dead_at IS NULL AND available_at <= ? AND id = (?)", now, head).
+			Order("id asc").Limit(limit).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Find(&claimed).Error
+		if err != nil || len(claimed) == 0 {
+			return err
+		}
+
+		leaseUntil := now.Add(lease)
+		ids := make([]uint, len(claimed))
+		for i := range claimed {
+			ids[i] = claimed[i].ID
+			claimed[i].AvailableAt = leaseUntil
+		}
+		return tx.Model(&model.OutboxMessage{}).Where("id IN ?", ids).Update("available_at", leaseUntil).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (r *OutboxRepository) MarkPublished(id uint, at time.Time) error {
+	return r.db.Model(&model.OutboxMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"published_at": at,
+		"attempts":     gorm.Expr("attempts + 1"),
+		"last_error":   "",
+	}).Error
+}
+
+func (r *OutboxRepository) MarkFailed(id uint, lastError string, retryAt time.Time) error {
+	return r.db.Model(&model.OutboxMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"available_at": retryAt,
+		"attempts":     gorm.Expr("attempts + 1"),
+		"last_error":   lastError,
+	}).Error
+}
+
+func (r *OutboxRepository) MarkDead(id uint, lastError string, at time.Time) error {
+	return r.db.Model(&model.OutboxMessage{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"dead_at":    at,
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": lastError,
+	}).Error
+}
+
+func (r *OutboxRepository) Release(id uint) error {
+	return r.db.Model(&model.OutboxMessage{}).
+		Where("id = ? AND published_at IS NULL", id).
+		Update("available_at", time.Now().UTC()).Error
+}
+
+func (r *OutboxRepository) GetByID(id uint) (*model.OutboxMessage, error) {
+	var message model.OutboxMessage
+	if err := r.db.First(&message, id).Error; err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+func (r *OutboxRepository) GetAfter(afterID uint, limit int) ([]model.OutboxMessage, error) {
+	var messages []model.OutboxMessage
+	err := r.db.Where("id > ?", afterID).Order("id asc").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+func (r *OutboxRepository) GetUnpublishedOrSince(since time.Time, afterID uint, limit int) ([]model.OutboxMessage, error) {
+	var messages []model.OutboxMessage
+	err := r.db.Where("id > ? AND (published_at IS NULL OR published_at >= ?)", afterID, since).
+		Order("id asc").Limit(limit).Find(&messages).Error
+	return messages, err
+}
+
+func (r *OutboxRepository) LastID() (uint, error) {
+	var last uint
+	err := r.db.Model(&model.OutboxMessage{}).Select("COALESCE(MAX(id), 0)").Scan(&last).Error
+	return last, err
+}
+
+func (r *OutboxRepository) Stats() (OutboxStats, error) {
+	var stats OutboxStats
+	if err := r.db.Model(&model.OutboxMessage{}).Where("dead_at IS NOT NULL").Count(&stats.Dead).Error; err != nil {
+		return stats, err
+	}
+	if err := r.db.Model(&model.OutboxMessage{}).Where("published_at IS NULL AND dead_at IS NULL").Count(&stats.Pending).Error; err != nil {
+		return stats, err
+	}
+	if stats.Pending == 0 {
+		return stats, nil
+	}
+
+	var oldest model.OutboxMessage
+	if err := r.db.Where("published_at IS NULL AND dead_at IS NULL").Order("id asc").First(&oldest).Error; err != nil {
+		return stats, err
+	}
+	stats.OldestPending = &oldest.CreatedAt
+	return stats, nil
+}
+
+func (r *OutboxRepository) DeletePublishedBefore(cutoff time.Time) (int64, error) {
+	result := r.db.Where("published_at IS NOT NULL AND published_at < ?", cutoff).Delete(&model.OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,36 @@
+package repository
+
+import (
+	"github.com/stavagg/petGoApi/internal/model"
+	"gorm.io/gorm"
+)
+
+// findPage finds the rows of query on page in order, all of them when page
+// is nil, and returns them with the number of rows query matches in all.
+func findPage[T any](query *gorm.DB, order string, page *model.Pagination) ([]T, int64, error) {
+	var items []T
+	if page == nil {
+		err := query.Order(order).Find(&items).Error
+		return items, int64(len(items)), err
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if total <= int64(page.Offset) {
+		return []T{}, total, nil
+	}
+	err := query.Order(order).Limit(page.Limit).Offset(page.Offset).Find(&items).Error
+	return items, total, err
+}
+
+// slicePage is findPage for the memory repositories, with items already in
+// order.
+func slicePage[T any](items []T, page *model.Pagination) ([]T, int64) {
+	total := int64(len(items))
+	if page == nil {
+		return items, total
+	}
+	start := min(page.Offset, len(items))
+	return items[start:min(start+page.Limit, len(items))], total
+}
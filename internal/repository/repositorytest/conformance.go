@@ -0,0 +1,657 @@
+// Package repositorytest contains the conformance suite every
+// TodoRepositoryInterface implementation must pass.
+package repositorytest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns an empty repository. It is called once per subtest.
+type Factory func(t *testing.T) repository.TodoRepositoryInterface
+
+func Run(t *testing.T, newRepo Factory) {
+	t.Run("CreateAssignsIDAndTimestamps", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "First", Description: "desc"}
+		require.NoError(t, repo.Create(todo))
+
+		assert.NotZero(t, todo.ID)
+		assert.False(t, todo.CreatedAt.IsZero())
+		assert.False(t, todo.UpdatedAt.IsZero())
+	})
+
+	t.Run("CreateAssignsDistinctIDs", func(t *testing.T) {
+		repo := newRepo(t)
+
+		a := &model.Todo{Title: "A"}
+		b := &model.Todo{Title: "B"}
+		require.NoError(t, repo.Create(a))
+		require.NoError(t, repo.Create(b))
+
+		assert.NotEqual(t, a.ID, b.ID)
+	})
+
+	t.Run("CreateBatchAssignsIDs", func(t *testing.T) {
+		repo := newRepo(t)
+
+		created := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+		todos := []model.Todo{{Title: "A"}, {Title: "B", Completed: true, CreatedAt: created}}
+		require.NoError(t, repo.CreateBatch(todos))
+		require.NotZero(t, todos[0].ID)
+		assert.NotEqual(t, todos[0].ID, todos[1].ID)
+
+		got, err := repo.GetByID(todos[1].ID)
+		require.NoError(t, err)
+		assert.Equal(t, "B", got.Title)
+		assert.True(t, got.Completed)
+		assert.True(t, created.Equal(got.CreatedAt), "a given creation time is kept")
+		assert.NoError(t, repo.CreateBatch(nil))
+	})
+
+	t.Run("CreateBatchSkipsTakenExternalIDs", func(t *testing.T) {
+		repo := newRepo(t)
+
+		external := func(id string) *string { return &id }
+		require.NoError(t, repo.CreateBatch([]model.Todo{{Title: "First", ExternalID: external("todoist:1")}}))
+
+		todos := []model.Todo{
+			{Title: "Local"},
+			{Title: "Again", ExternalID: external("todoist:1")},
+			{Title: "New", ExternalID: external("todoist:2")},
+			{Title: "Other"},
+		}
+		require.NoError(t, repo.CreateBatch(todos))
+		assert.Zero(t, todos[1].ID, "the taken external ID is skipped")
+		for _, i := range []int{0, 2, 3} {
+			require.NotZero(t, todos[i].ID)
+			got, err := repo.GetByID(todos[i].ID)
+			require.NoError(t, err)
+			assert.Equal(t, todos[i].Title, got.Title, "IDs are matched to the inserted todos")
+		}
+	})
+
+	t.Run("GetByExternalIDsIncludesTrash", func(t *testing.T) {
+		repo := newRepo(t)
+
+		external := func(id string) *string { return &id }
+		todos := []model.Todo{
+			{Title: "Card", ExternalID: external("trello:card:1")},
+			{Title: "Task", ExternalID: external("todoist:1")},
+			{Title: "Local"},
+		}
+		require.NoError(t, repo.CreateBatch(todos))
+		require.NoError(t, repo.Delete(todos[1].ID))
+
+		found, err := repo.GetByExternalIDs([]string{"todoist:1", "trello:card:1", "trello:card:2"})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"Card", "Task"}, titles(found))
+
+		found, err = repo.GetByExternalIDs(nil)
+		require.NoError(t, err)
+		assert.Empty(t, found)
+	})
+
+	t.Run("GetByIDReturnsStoredTodo", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "Read", Description: "me", Project: "home", Completed: true}
+		require.NoError(t, repo.Create(todo))
+
+		got, err := repo.GetByID(todo.ID)
+		require.NoError(t, err)
+		assert.Equal(t, todo.ID, got.ID)
+		assert.Equal(t, "Read", got.Title)
+		assert.Equal(t, "me", got.Description)
+		assert.Equal(t, "home", got.Project)
+		assert.True(t, got.Completed)
+	})
+
+	t.Run("GetByIDMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetByID(42)
+		assert.Error(t, err)
+	})
+
+	t.Run("GetByIDReturnsCopy", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "Original"}
+		require.NoError(t, repo.Create(todo))
+
+		got, err := repo.GetByID(todo.ID)
+		require.NoError(t, err)
+		got.Title = "Changed without Update"
+
+		again, err := repo.GetByID(todo.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "Original", again.Title)
+	})
+
+	t.Run("GetAllNewestFirst", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for _, title := range []string{"one", "two", "three"} {
+			require.NoError(t, repo.Create(&model.Todo{Title: title}))
+		}
+
+		todos, err := repo.GetAll()
+		require.NoError(t, err)
+		require.Len(t, todos, 3)
+		assert.Equal(t, []string{"three", "two", "one"}, titles(todos))
+	})
+
+	t.Run("GetAllEmpty", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todos, err := repo.GetAll()
+		require.NoError(t, err)
+		assert.Empty(t, todos)
+	})
+
+	t.Run("UpdatePersistsFields", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "Before"}
+		require.NoError(t, repo.Create(todo))
+		created := todo.CreatedAt
+
+		todo.Title = "After"
+		todo.Description = "updated"
+		todo.Completed = true
+		todo.Version = 2
+		require.NoError(t, repo.Update(todo))
+
+		got, err := repo.GetByID(todo.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "After", got.Title)
+		assert.Equal(t, "updated", got.Description)
+		assert.True(t, got.Completed)
+		assert.Equal(t, uint(2), got.Version)
+		assert.True(t, created.Equal(got.CreatedAt), "created_at must not change on update")
+		assert.False(t, got.UpdatedAt.Before(created))
+	})
+
+	t.Run("UpdateIfVersionRejectsStaleVersion", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "Before", Version: 1}
+		require.NoError(t, repo.Create(todo))
+
+		first := *todo
+		first.Title = "First"
+		first.Version = 2
+		require.NoError(t, repo.UpdateIfVersion(&first, 1))
+
+		second := *todo
+		second.Title = "Second"
+		second.Version = 2
+		assert.ErrorIs(t, repo.UpdateIfVersion(&second, 1), repository.ErrStaleVersion)
+
+		got, err := repo.GetByID(todo.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "First", got.Title)
+		assert.Equal(t, uint(2), got.Version)
+
+		require.NoError(t, repo.Delete(todo.ID))
+		got.Title = "Trashed"
+		assert.ErrorIs(t, repo.UpdateIfVersion(got, 2), repository.ErrStaleVersion, "todos in the trash are not updated")
+	})
+
+	t.Run("DeleteIfVersionRejectsStaleVersion", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "Task", Version: 2}
+		require.NoError(t, repo.Create(todo))
+
+		assert.ErrorIs(t, repo.DeleteIfVersion(todo.ID, 1), repository.ErrStaleVersion)
+		_, err := repo.GetByID(todo.ID)
+		require.NoError(t, err, "a stale delete leaves the todo")
+
+		require.NoError(t, repo.DeleteIfVersion(todo.ID, 2))
+		_, err = repo.GetByID(todo.ID)
+		assert.Error(t, err)
+		assert.ErrorIs(t, repo.DeleteIfVersion(todo.ID, 2), repository.ErrStaleVersion, "todos in the trash are not deleted again")
+	})
+
+	t.Run("TakeUndoOnceBeforeExpiry", func(t *testing.T) {
+		repo := newRepo(t)
+
+		now := time.Now()
+		expired := &model.UndoAction{Token: "old", Action: "toggle", ExpiresAt: now.Add(-time.Minute)}
+		live := &model.UndoAction{Token: "new", Action: "toggle", Targets: []model.UndoTarget{{ID: 1, Version: 2}}, ExpiresAt: now.Add(time.Minute)}
+		require.NoError(t, repo.SaveUndo(expired, now.Add(-time.Hour)))
+		require.NoError(t, repo.SaveUndo(live, now))
+
+		_, err := repo.TakeUndo("old", now)
+		assert.Error(t, err, "expired actions are dropped")
+
+		got, err := repo.TakeUndo("new", now)
+		require.NoError(t, err)
+		assert.Equal(t, "toggle", got.Action)
+		assert.Equal(t, live.Targets, got.Targets)
+
+		_, err = repo.TakeUndo("new", now)
+		assert.Error(t, err, "an action is taken once")
+	})
+
+	t.Run("TakeUndoRollsBackWithTransaction", func(t *testing.T) {
+		repo := newRepo(t)
+
+		now := time.Now()
+		require.NoError(t, repo.SaveUndo(&model.UndoAction{Token: "t", Action: "delete", ExpiresAt: now.Add(time.Minute)}, now))
+
+		boom := errors.New("boom")
+		err := repo.Transaction(func(tx repository.TodoRepositoryInterface) error {
+			if _, err := tx.TakeUndo("t", now); err != nil {
+				return err
+			}
+			return boom
+		})
+		assert.ErrorIs(t, err, boom)
+
+		_, err = repo.TakeUndo("t", now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("DeleteRemovesTodo", func(t *testing.T) {
+		repo := newRepo(t)
+
+		keep := &model.Todo{Title: "keep"}
+		drop := &model.Todo{Title: "drop"}
+		require.NoError(t, repo.Create(keep))
+		require.NoError(t, repo.Create(drop))
+
+		require.NoError(t, repo.Delete(drop.ID))
+
+		_, err := repo.GetByID(drop.ID)
+		assert.Error(t, err)
+
+		todos, err := repo.GetAll()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"keep"}, titles(todos))
+	})
+
+	t.Run("DeleteMissingIsNoop", func(t *testing.T) {
+		repo := newRepo(t)
+
+		assert.NoError(t, repo.Delete(42))
+	})
+
+	t.Run("GetByCompleted", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Create(&model.Todo{Title: "open-1"}))
+		require.NoError(t, repo.Create(&model.Todo{Title: "done-1", Completed: true}))
+		require.NoError(t, repo.Create(&model.Todo{Title: "open-2"}))
+
+		done, err := repo.GetByCompleted(true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"done-1"}, titles(done))
+
+		open, err := repo.GetByCompleted(false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"open-2", "open-1"}, titles(open))
+	})
+
+	t.Run("GetPage", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for _, title := range []string{"open-1", "done-1", "open-2", "open-3", "trashed"} {
+			todo := &model.Todo{Title: title, Completed: title == "done-1"}
+			require.NoError(t, repo.Create(todo))
+			if title == "trashed" {
+				require.NoError(t, repo.Delete(todo.ID))
+			}
+		}
+
+		open := false
+		page, total, err := repo.GetPage(&open, &model.Pagination{Limit: 2, Offset: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, []string{"open-2", "open-1"}, titles(page))
+
+		all, total, err := repo.GetPage(nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), total)
+		assert.Equal(t, []string{"open-3", "open-2", "done-1", "open-1"}, titles(all))
+
+		past, total, err := repo.GetPage(nil, &model.Pagination{Limit: 10, Offset: 10})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), total)
+		assert.Empty(t, past)
+
+		trash, total, err := repo.GetDeletedPage(&model.Pagination{Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []string{"trashed"}, titles(trash))
+	})
+
+	t.Run("GetByProjectAndProjects", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for _, todo := range []*model.Todo{
+			{Title: "inbox"}, {Title: "work-1", Project: "work"}, {Title: "home", Project: "home"},
+			{Title: "work-2", Project: "work"}, {Title: "trashed", Project: "work"},
+		} {
+			require.NoError(t, repo.Create(todo))
+			if todo.Title == "trashed" {
+				require.NoError(t, repo.Delete(todo.ID))
+			}
+		}
+
+		work, err := repo.GetByProject("work")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"work-1", "work-2"}, titles(work))
+
+		projects, err := repo.GetProjects()
+		require.NoError(t, err)
+		require.Len(t, projects, 3)
+		assert.Equal(t, []string{"", "home", "work"}, []string{projects[0].Project, projects[1].Project, projects[2].Project})
+		assert.Equal(t, int64(2), projects[2].Todos)
+		assert.Equal(t, int64(work[0].ID+work[1].ID), projects[2].IDSum)
+		assert.Equal(t, int64(work[0].Version+work[1].Version), projects[2].VersionSum)
+	})
+
+	t.Run("ForEachInIDOrder", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for _, title := range []string{"open-1", "done-1", "open-2", "open-3", "trashed"} {
+			todo := &model.Todo{Title: title, Completed: title == "done-1"}
+			require.NoError(t, repo.Create(todo))
+			if title == "trashed" {
+				require.NoError(t, repo.Delete(todo.ID))
+			}
+		}
+
+		collect := func(completed *bool) []string {
+			var seen []model.Todo
+			require.NoError(t, repo.ForEach(completed, 2, func(todo model.Todo) error {
+				seen = append(seen, todo)
+				return nil
+			}))
+			return titles(seen)
+		}
+		open := false
+		assert.Equal(t, []string{"open-1", "done-1", "open-2", "open-3"}, collect(nil))
+		assert.Equal(t, []string{"open-1", "open-2", "open-3"}, collect(&open))
+
+		stop := errors.New("stop")
+		calls := 0
+		err := repo.ForEach(nil, 2, func(model.Todo) error {
+			calls++
+			if calls == 3 {
+				return stop
+			}
+			return nil
+		})
+		assert.ErrorIs(t, err, stop)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("DeleteMovesToTrash", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "trashed", Completed: true}
+		require.NoError(t, repo.Create(todo))
+		require.NoError(t, repo.Delete(todo.ID))
+
+		done, err := repo.GetByCompleted(true)
+		require.NoError(t, err)
+		assert.Empty(t, done)
+
+		trash, err := repo.GetDeleted()
+		require.NoError(t, err)
+		require.Len(t, trash, 1)
+		assert.Equal(t, todo.ID, trash[0].ID)
+		assert.True(t, trash[0].DeletedAt.Valid)
+	})
+
+	t.Run("RestoreReturnsTodoFromTrash", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "oops"}
+		require.NoError(t, repo.Create(todo))
+		require.NoError(t, repo.Delete(todo.ID))
+		require.NoError(t, repo.Restore(todo.ID))
+
+		got, err := repo.GetByID(todo.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "oops", got.Title)
+		assert.False(t, got.DeletedAt.Valid)
+
+		trash, err := repo.GetDeleted()
+		require.NoError(t, err)
+		assert.Empty(t, trash)
+	})
+
+	t.Run("RestoreRequiresTrashedTodo", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "alive"}
+		require.NoError(t, repo.Create(todo))
+
+		assert.Error(t, repo.Restore(todo.ID))
+		assert.Error(t, repo.Restore(42))
+	})
+
+	t.Run("PurgeRemovesOnlyTrashedTodo", func(t *testing.T) {
+		repo := newRepo(t)
+
+		alive := &model.Todo{Title: "alive"}
+		trashed := &model.Todo{Title: "trashed"}
+		require.NoError(t, repo.Create(alive))
+		require.NoError(t, repo.Create(trashed))
+		require.NoError(t, repo.Delete(trashed.ID))
+
+		assert.Error(t, repo.Purge(alive.ID))
+		require.NoError(t, repo.Purge(trashed.ID))
+
+		assert.Error(t, repo.Restore(trashed.ID))
+		trash, err := repo.GetDeleted()
+		require.NoError(t, err)
+		assert.Empty(t, trash)
+
+		_, err = repo.GetByID(alive.ID)
+		assert.NoError(t, err)
+	})
+
+	t.Run("PurgeDeletedBefore", func(t *testing.T) {
+		repo := newRepo(t)
+
+		alive := &model.Todo{Title: "alive"}
+		trashed := &model.Todo{Title: "trashed"}
+		require.NoError(t, repo.Create(alive))
+		require.NoError(t, repo.Create(trashed))
+		require.NoError(t, repo.Delete(trashed.ID))
+
+		purged, err := repo.PurgeDeletedBefore(time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, purged)
+
+		purged, err = repo.PurgeDeletedBefore(time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+
+		trash, err := repo.GetDeleted()
+		require.NoError(t, err)
+		assert.Empty(t, trash)
+
+		todos, err := repo.GetAll()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alive"}, titles(todos))
+	})
+
+	t.Run("GetChangedSinceIncludesDeleted", func(t *testing.T) {
+		repo := newRepo(t)
+
+		kept := &model.Todo{Title: "kept"}
+		gone := &model.Todo{Title: "gone"}
+		require.NoError(t, repo.Create(kept))
+		require.NoError(t, repo.Create(gone))
+		require.NoError(t, repo.Delete(gone.ID))
+
+		changes, err := repo.GetChangedSince(time.Time{}, 0, 10)
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		assert.Equal(t, []string{"kept", "gone"}, titles(changes), "oldest change first")
+		assert.True(t, changes[1].DeletedAt.Valid)
+		assert.False(t, changes[1].UpdatedAt.Before(changes[1].DeletedAt.Time), "delete bumps updated_at")
+	})
+
+	t.Run("GetChangedSinceResumesAfterCursor", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for _, title := range []string{"a", "b", "c"} {
+			require.NoError(t, repo.Create(&model.Todo{Title: title}))
+		}
+
+		first, err := repo.GetChangedSince(time.Time{}, 0, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, titles(first))
+
+		last := first[len(first)-1]
+		rest, err := repo.GetChangedSince(last.UpdatedAt, last.ID, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"c"}, titles(rest))
+	})
+
+	t.Run("PurgeLeavesTombstone", func(t *testing.T) {
+		repo := newRepo(t)
+
+		oldID, singleID := "todoist:1", "ical:single"
+		old := &model.Todo{Title: "old", ExternalID: &oldID}
+		single := &model.Todo{Title: "single", ExternalID: &singleID, Project: "work"}
+		require.NoError(t, repo.Create(old))
+		require.NoError(t, repo.Create(single))
+		require.NoError(t, repo.Delete(old.ID))
+		require.NoError(t, repo.Delete(single.ID))
+
+		before := time.Now().Add(-time.Second)
+		require.NoError(t, repo.Purge(single.ID))
+		_, err := repo.PurgeDeletedBefore(time.Now().Add(time.Hour))
+		require.NoError(t, err)
+
+		tombstones, err := repo.GetPurgedSince(before)
+		require.NoError(t, err)
+		ids := make([]uint, 0, len(tombstones))
+		externalIDs := make([]string, 0, len(tombstones))
+		projects := make([]string, 0, len(tombstones))
+		for _, tombstone := range tombstones {
+			projects = append(projects, tombstone.Project)
+			ids = append(ids, tombstone.TodoID)
+			require.NotNil(t, tombstone.ExternalID)
+			externalIDs = append(externalIDs, *tombstone.ExternalID)
+		}
+		assert.ElementsMatch(t, []uint{old.ID, single.ID}, ids)
+		assert.ElementsMatch(t, []string{oldID, singleID}, externalIDs)
+		assert.ElementsMatch(t, []string{"", "work"}, projects)
+
+		later, err := repo.GetPurgedSince(time.Now().Add(time.Hour))
+		require.NoError(t, err)
+		assert.Empty(t, later)
+	})
+
+	t.Run("TransactionCommits", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "before"}
+		require.NoError(t, repo.Create(todo))
+
+		err := repo.Transaction(func(tx repository.TodoRepositoryInterface) error {
+			got, err := tx.GetByID(todo.ID)
+			if err != nil {
+				return err
+			}
+			got.Title = "after"
+			if err := tx.Update(got); err != nil {
+				return err
+			}
+			return tx.Create(&model.Todo{Title: "new"})
+		})
+		require.NoError(t, err)
+
+		todos, err := repo.GetAll()
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"after", "new"}, titles(todos))
+	})
+
+	t.Run("TransactionRollsBackOnError", func(t *testing.T) {
+		repo := newRepo(t)
+
+		todo := &model.Todo{Title: "before"}
+		require.NoError(t, repo.Create(todo))
+
+		boom := errors.New("boom")
+		err := repo.Transaction(func(tx repository.TodoRepositoryInterface) error {
+			got, err := tx.GetByID(todo.ID)
+			if err != nil {
+				return err
+			}
+			got.Title = "after"
+			if err := tx.Update(got); err != nil {
+				return err
+			}
+			if err := tx.Delete(todo.ID); err != nil {
+				return err
+			}
+			if err := tx.Create(&model.Todo{Title: "new"}); err != nil {
+				return err
+			}
+			return boom
+		})
+		assert.ErrorIs(t, err, boom)
+
+		todos, err := repo.GetAll()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"before"}, titles(todos))
+	})
+
+	t.Run("ConcurrentCreates", func(t *testing.T) {
+		repo := newRepo(t)
+
+		const workers = 20
+		var wg sync.WaitGroup
+		errs := make(chan error, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- repo.Create(&model.Todo{Title: "concurrent"})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			require.NoError(t, err)
+		}
+
+		todos, err := repo.GetAll()
+		require.NoError(t, err)
+		assert.Len(t, todos, workers)
+
+		seen := make(map[uint]bool)
+		for _, todo := range todos {
+			assert.False(t, seen[todo.ID], "duplicate id %d", todo.ID)
+			seen[todo.ID] = true
+		}
+	})
+}
+
+func titles(todos []model.Todo) []string {
+	out := make([]string, 0, len(todos))
+	for _, todo := range todos {
+		out = append(out, todo.Title)
+	}
+	return out
+}
@@ -0,0 +1,223 @@
+package repositorytest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// OutboxFactory returns a todo repository and the outbox it writes to.
+type OutboxFactory func(t *testing.T) (repository.TodoRepositoryInterface, repository.OutboxRepositoryInterface)
+
+func RunOutbox(t *testing.T, newRepo OutboxFactory) {
+	now := time.Now().UTC().Truncate(time.Second)
+	message := func(todoID uint) *model.OutboxMessage {
+		return &model.OutboxMessage{AggregateID: todoID, EventType: "todo.updated", Payload: "{}", AvailableAt: now}
+	}
+	ids := func(messages []model.OutboxMessage) []uint {
+		result := []uint{}
+		for _, m := range messages {
+			result = append(result, m.ID)
+		}
+		return result
+	}
+
+	t.Run("AppendCommitsWithTransaction", func(t *testing.T) {
+		todos, outbox := newRepo(t)
+
+		err := todos.Transaction(func(repo repository.TodoRepositoryInterface) error {
+			todo := &model.Todo{Title: "Task", Version: 1}
+			if err := repo.Create(todo); err != nil {
+				return err
+			}
+			return repo.AppendOutbox(message(todo.ID))
+		})
+		require.NoError(t, err)
+
+		err = todos.Transaction(func(repo repository.TodoRepositoryInterface) error {
+			if err := repo.AppendOutbox(message(2)); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		})
+		require.Error(t, err)
+
+		messages, err := outbox.GetAfter(0, 10)
+		require.NoError(t, err)
+		require.Len(t, messages, 1, "the rolled back message is discarded")
+		assert.NotZero(t, messages[0].ID)
+		assert.False(t, messages[0].CreatedAt.IsZero())
+
+		lastID, err := outbox.LastID()
+		require.NoError(t, err)
+		assert.Equal(t, messages[0].ID, lastID)
+	})
+
+	t.Run("AppendSeveral", func(t *testing.T) {
+		todos, outbox := newRepo(t)
+
+		require.NoError(t, todos.AppendOutbox())
+		err := todos.Transaction(func(repo repository.TodoRepositoryInterface) error {
+			return repo.AppendOutbox(message(1), message(2), message(1))
+		})
+		require.NoError(t, err)
+
+		messages, err := outbox.GetAfter(0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{1, 2, 3}, ids(messages))
+		assert.Equal(t, uint(2), messages[1].AggregateID)
+
+		got, err := outbox.GetByID(messages[1].ID)
+		require.NoError(t, err)
+		assert.Equal(t, uint(2), got.AggregateID)
+		_, err = outbox.GetByID(99)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("ClaimKeepsPerTodoOrder", func(t *testing.T) {
+		todos, outbox := newRepo(t)
+
+		for _, todoID := range []uint{1, 2, 1, 3} {
+			require.NoError(t, todos.AppendOutbox(message(todoID)))
+		}
+
+		first, err := outbox.ClaimPending(now, time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, first, 1)
+		assert.Equal(t, uint(1), first[0].AggregateID)
+
+		// The first message of todo 1 is leased, so its second message must
+		// wait while the other todos proceed.
+		rest, err := outbox.ClaimPending(now, time.Minute, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{2, 4}, ids(rest))
+
+		require.NoError(t, outbox.MarkPublished(first[0].ID, now))
+		next, err := outbox.ClaimPending(now, time.Minute, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{3}, ids(next))
+	})
+
+	t.Run("FailedMessagesRetryLater", func(t *testing.T) {
+		todos, outbox := newRepo(t)
+
+		require.NoError(t, todos.AppendOutbox(message(1)))
+		require.NoError(t, todos.AppendOutbox(message(1)))
+
+		claimed, err := outbox.ClaimPending(now, time.Minute, 1)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		require.NoError(t, outbox.MarkFailed(claimed[0].ID, "sink down", now.Add(time.Minute)))
+
+		blocked, err := outbox.ClaimPending(now.Add(30*time.Second), time.Minute, 10)
+		require.NoError(t, err)
+		assert.Empty(t, blocked)
+
+		retried, err := outbox.ClaimPending(now.Add(2*time.Minute), time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, retried, 1)
+		assert.Equal(t, claimed[0].ID, retried[0].ID, "the failed message is handed out before the next one")
+		assert.Equal(t, 1, retried[0].Attempts)
+		assert.Equal(t, "sink down", retried[0].LastError)
+
+		require.NoError(t, outbox.Release(retried[0].ID))
+		released, err := outbox.ClaimPending(time.Now().UTC().Add(time.Second), time.Minute, 10)
+		require.NoError(t, err)
+		assert.Equal(t, ids(retried), ids(released), "a released message can be claimed again right away")
+	})
+
+	t.Run("BusyTodoDoesNotBlockOthers", func(t *testing.T) {
+		todos, outbox := newRepo(t)
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, todos.AppendOutbox(message(1)))
+		}
+		require.NoError(t, todos.AppendOutbox(message(2)))
+
+		head, err := outbox.ClaimPending(now, time.Minute, 1)
+		require.NoError(t, err)
+		require.Equal(t, []uint{1}, ids(head))
+		require.NoError(t, outbox.MarkFailed(head[0].ID, "sink down", now.Add(time.Hour)))
+
+		// A batch smaller than the backlog of todo 1 still reaches todo 2.
+		next, err := outbox.ClaimPending(now, time.Minute, 2)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{6}, ids(next))
+	})
+
+	t.Run("DeadMessagesUnblockTheirTodo", func(t *testing.T) {
+		todos, outbox := newRepo(t)
+
+		require.NoError(t, todos.AppendOutbox(message(1)))
+		require.NoError(t, todos.AppendOutbox(message(1)))
+
+		claimed, err := outbox.ClaimPending(now, time.Minute, 10)
+		require.NoError(t, err)
+		require.Equal(t, []uint{1}, ids(claimed))
+		require.NoError(t, outbox.MarkDead(claimed[0].ID, "poison", now))
+
+		next, err := outbox.ClaimPending(now, time.Minute, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{2}, ids(next))
+
+		stats, err := outbox.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.Dead)
+		assert.Equal(t, int64(1), stats.Pending)
+
+		messages, err := outbox.GetAfter(0, 1)
+		require.NoError(t, err)
+		require.NotNil(t, messages[0].DeadAt)
+		assert.Equal(t, "poison", messages[0].LastError)
+		assert.Equal(t, 1, messages[0].Attempts)
+	})
+
+	t.Run("StatsAndCleanup", func(t *testing.T) {
+		todos, outbox := newRepo(t)
+
+		stats, err := outbox.Stats()
+		require.NoError(t, err)
+		assert.Zero(t, stats.Pending)
+		assert.Nil(t, stats.OldestPending)
+
+		for _, todoID := range []uint{1, 2, 3} {
+			require.NoError(t, todos.AppendOutbox(message(todoID)))
+		}
+		require.NoError(t, outbox.MarkPublished(1, now.Add(-time.Hour)))
+		require.NoError(t, outbox.MarkPublished(2, now))
+
+		stats, err = outbox.Stats()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), stats.Pending)
+		require.NotNil(t, stats.OldestPending)
+
+		deleted, err := outbox.DeletePublishedBefore(now.Add(-time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+
+		messages, err := outbox.GetAfter(0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{2, 3}, ids(messages))
+
+		recent, err := outbox.GetUnpublishedOrSince(now, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{2, 3}, ids(recent))
+		recent, err = outbox.GetUnpublishedOrSince(now.Add(time.Second), 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{3}, ids(recent), "only the unpublished message is left")
+		recent, err = outbox.GetUnpublishedOrSince(now, 2, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{3}, ids(recent))
+
+		require.NoError(t, todos.AppendOutbox(message(4)))
+		lastID, err := outbox.LastID()
+		require.NoError(t, err)
+		assert.Equal(t, uint(4), lastID)
+	})
+}
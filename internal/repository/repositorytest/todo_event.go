@@ -0,0 +1,193 @@
+package repositorytest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type TodoEventFactory func(t *testing.T) repository.TodoEventRepositoryInterface
+
+func RunTodoEvents(t *testing.T, newRepo TodoEventFactory) {
+	t.Run("AppendNumbersRevisionsPerTodo", func(t *testing.T) {
+		repo := newRepo(t)
+
+		first := &model.TodoEvent{TodoID: 1, Action: model.TodoCreated, Actor: "alice"}
+		other := &model.TodoEvent{TodoID: 2, Action: model.TodoCreated, Actor: "bob"}
+		second := &model.TodoEvent{TodoID: 1, Action: model.TodoUpdated, Actor: "alice"}
+		require.NoError(t, repo.Append(first))
+		require.NoError(t, repo.Append(other))
+		require.NoError(t, repo.Append(second))
+
+		assert.Equal(t, uint(1), first.Revision)
+		assert.Equal(t, uint(1), other.Revision)
+		assert.Equal(t, uint(2), second.Revision)
+		assert.NotZero(t, second.ID)
+		assert.False(t, second.CreatedAt.IsZero())
+	})
+
+	t.Run("GetByTodoIDsGroupsByTodo", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Append(&model.TodoEvent{TodoID: 2, Action: model.TodoCreated}))
+		require.NoError(t, repo.Append(&model.TodoEvent{TodoID: 1, Action: model.TodoCreated}))
+		require.NoError(t, repo.Append(&model.TodoEvent{TodoID: 3, Action: model.TodoCreated}))
+		require.NoError(t, repo.Append(&model.TodoEvent{TodoID: 2, Action: model.TodoUpdated}))
+
+		events, err := repo.GetByTodoIDs([]uint{2, 1})
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		assert.Equal(t, uint(1), events[0].TodoID)
+		assert.Equal(t, uint(2), events[1].TodoID)
+		assert.Equal(t, uint(1), events[1].Revision)
+		assert.Equal(t, uint(2), events[2].Revision)
+
+		none, err := repo.GetByTodoIDs(nil)
+		require.NoError(t, err)
+		assert.Empty(t, none)
+	})
+
+	t.Run("GetByTodoIDInRevisionOrder", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for _, action := range []string{model.TodoCreated, model.TodoToggled, model.TodoDeleted} {
+			require.NoError(t, repo.Append(&model.TodoEvent{TodoID: 7, Action: action, Actor: "alice"}))
+		}
+		require.NoError(t, repo.Append(&model.TodoEvent{TodoID: 8, Action: model.TodoCreated, Actor: "bob"}))
+
+		events, err := repo.GetByTodoID(7)
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		for i, event := range events {
+			assert.Equal(t, uint(i+1), event.Revision)
+		}
+		assert.Equal(t, model.TodoDeleted, events[2].Action)
+
+		page, total, err := repo.GetPageByTodoID(7, &model.Pagination{Limit: 2, Offset: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		require.Len(t, page, 2)
+		assert.Equal(t, uint(2), page[0].Revision)
+
+		page, total, err = repo.GetPageByTodoID(7, &model.Pagination{Limit: 2, Offset: 5})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Empty(t, page)
+	})
+
+	t.Run("GetByTodoIDEmpty", func(t *testing.T) {
+		repo := newRepo(t)
+
+		events, err := repo.GetByTodoID(99)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+	})
+
+	t.Run("StoresChangesAndSnapshot", func(t *testing.T) {
+		repo := newRepo(t)
+
+		event := &model.TodoEvent{
+			TodoID:   3,
+			Action:   model.TodoUpdated,
+			Actor:    "carol",
+			Changes:  []model.FieldChange{{Field: "title", From: "old", To: "new"}},
+			Snapshot: model.TodoSnapshot{Title: "new", Description: "d", Completed: true},
+		}
+		require.NoError(t, repo.Append(event))
+
+		got, err := repo.GetRevision(3, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "carol", got.Actor)
+		assert.Equal(t, event.Snapshot, got.Snapshot)
+		require.Len(t, got.Changes, 1)
+		assert.Equal(t, "title", got.Changes[0].Field)
+		assert.Equal(t, "old", got.Changes[0].From)
+		assert.Equal(t, "new", got.Changes[0].To)
+	})
+
+	t.Run("GetAfterAcrossTodos", func(t *testing.T) {
+		repo := newRepo(t)
+
+		var ids []uint
+		for _, todoID := range []uint{1, 2, 1, 3} {
+			event := &model.TodoEvent{TodoID: todoID, Action: model.TodoUpdated, Actor: "alice"}
+			require.NoError(t, repo.Append(event))
+			ids = append(ids, event.ID)
+		}
+
+		events, err := repo.GetAfter(ids[0], 2)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		assert.Equal(t, ids[1], events[0].ID)
+		assert.Equal(t, ids[2], events[1].ID)
+
+		events, err = repo.GetAfter(ids[3], 10)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+
+		lastID, err := repo.LastID()
+		require.NoError(t, err)
+		assert.Equal(t, ids[3], lastID)
+	})
+
+	t.Run("LastIDEmpty", func(t *testing.T) {
+		repo := newRepo(t)
+
+		lastID, err := repo.LastID()
+		require.NoError(t, err)
+		assert.Zero(t, lastID)
+	})
+
+	t.Run("GetRevisionMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		require.NoError(t, repo.Append(&model.TodoEvent{TodoID: 1, Action: model.TodoCreated, Actor: "alice"}))
+
+		_, err := repo.GetRevision(1, 2)
+		assert.Error(t, err)
+	})
+}
+
+// EventLogFactory returns a todo repository and the history it appends to.
+type EventLogFactory func(t *testing.T) (repository.TodoRepositoryInterface, repository.TodoEventRepositoryInterface)
+
+// RunEventLog checks that history appended through a todo transaction
+// commits and rolls back with it.
+func RunEventLog(t *testing.T, newRepo EventLogFactory) {
+	t.Run("AppendEventsCommitsWithTransaction", func(t *testing.T) {
+		todos, events := newRepo(t)
+		require.NoError(t, events.Append(&model.TodoEvent{TodoID: 1, Action: model.TodoCreated, Actor: "alice"}))
+
+		err := todos.Transaction(func(repo repository.TodoRepositoryInterface) error {
+			return repo.AppendEvents([]*model.TodoEvent{
+				{TodoID: 1, Action: model.TodoUpdated, Actor: "alice"},
+				{TodoID: 2, Action: model.TodoCreated, Actor: "bob"},
+				{TodoID: 1, Action: model.TodoToggled, Actor: "bob"},
+			})
+		})
+		require.NoError(t, err)
+
+		err = todos.Transaction(func(repo repository.TodoRepositoryInterface) error {
+			if err := repo.AppendEvents([]*model.TodoEvent{{TodoID: 1, Action: model.TodoDeleted, Actor: "alice"}}); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		})
+		require.Error(t, err)
+
+		history, err := events.GetByTodoID(1)
+		require.NoError(t, err)
+		require.Len(t, history, 3, "the rolled back event is discarded")
+		assert.Equal(t, []uint{1, 2, 3}, []uint{history[0].Revision, history[1].Revision, history[2].Revision})
+		assert.Equal(t, model.TodoToggled, history[2].Action)
+
+		history, err = events.GetByTodoID(2)
+		require.NoError(t, err)
+		require.Len(t, history, 1)
+		assert.Equal(t, uint(1), history[0].Revision)
+	})
+}
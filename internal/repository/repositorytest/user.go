@@ -0,0 +1,61 @@
+package repositorytest
+
+import (
+	"testing"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type UserFactory func(t *testing.T) repository.UserRepositoryInterface
+
+func RunUsers(t *testing.T, newRepo UserFactory) {
+	t.Run("CreateAndGetByName", func(t *testing.T) {
+		repo := newRepo(t)
+
+		user := &model.User{Name: "alice"}
+		require.NoError(t, repo.CreateUser(user))
+		assert.NotZero(t, user.ID)
+		assert.False(t, user.CreatedAt.IsZero())
+
+		got, err := repo.GetUserByName("alice")
+		require.NoError(t, err)
+		assert.Equal(t, user.ID, got.ID)
+
+		_, err = repo.GetUserByName("bob")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+
+		err = repo.CreateUser(&model.User{Name: "alice"})
+		assert.ErrorIs(t, err, repository.ErrUserExists)
+	})
+
+	t.Run("GetUserByKeyHash", func(t *testing.T) {
+		repo := newRepo(t)
+
+		alice := &model.User{Name: "alice"}
+		bob := &model.User{Name: "bob"}
+		require.NoError(t, repo.CreateUser(alice))
+		require.NoError(t, repo.CreateUser(bob))
+		for _, key := range []*model.APIKey{
+			{UserID: alice.ID, Prefix: "pga_a", Hash: "hash-a"},
+			{UserID: bob.ID, Prefix: "pga_b1", Hash: "hash-b1"},
+			{UserID: bob.ID, Prefix: "pga_b2", Hash: "hash-b2"},
+		} {
+			require.NoError(t, repo.CreateAPIKey(key))
+			assert.NotZero(t, key.ID)
+		}
+
+		got, err := repo.GetUserByKeyHash("hash-b2")
+		require.NoError(t, err)
+		assert.Equal(t, "bob", got.Name)
+		got, err = repo.GetUserByKeyHash("hash-a")
+		require.NoError(t, err)
+		assert.Equal(t, "alice", got.Name)
+
+		_, err = repo.GetUserByKeyHash("unknown")
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+}
@@ -0,0 +1,179 @@
+package repositorytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+type WebhookFactory func(t *testing.T) repository.WebhookRepositoryInterface
+
+func RunWebhooks(t *testing.T, newRepo WebhookFactory) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	t.Run("CreateGetUpdate", func(t *testing.T) {
+		repo := newRepo(t)
+
+		webhook := &model.Webhook{URL: "http://example.com/hook", Events: []string{"todo.created"}, Secret: "s", Active: true}
+		require.NoError(t, repo.Create(webhook))
+		assert.NotZero(t, webhook.ID)
+
+		got, err := repo.GetByID(webhook.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"todo.created"}, got.Events)
+		assert.True(t, got.Active)
+
+		got.Active = false
+		got.ConsecutiveFailures = 3
+		got.DisabledAt = &now
+		require.NoError(t, repo.Update(got))
+
+		all, err := repo.GetAll()
+		require.NoError(t, err)
+		require.Len(t, all, 1)
+		assert.False(t, all[0].Active)
+		assert.Equal(t, 3, all[0].ConsecutiveFailures)
+		require.NotNil(t, all[0].DisabledAt)
+	})
+
+	t.Run("GetPage", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.Create(&model.Webhook{URL: "http://example.com/hook", Events: []string{"todo.created"}, Active: true}))
+		}
+
+		page, total, err := repo.GetPage(&model.Pagination{Limit: 1, Offset: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		require.Len(t, page, 1)
+		all, err := repo.GetAll()
+		require.NoError(t, err)
+		assert.Equal(t, all[1].ID, page[0].ID)
+
+		whole, total, err := repo.GetPage(nil)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Len(t, whole, 3)
+	})
+
+	t.Run("RecordFailureAndSuccess", func(t *testing.T) {
+		repo := newRepo(t)
+
+		webhook := &model.Webhook{URL: "http://example.com/hook", Secret: "s", Active: true}
+		require.NoError(t, repo.Create(webhook))
+
+		disabled, err := repo.RecordFailure(webhook.ID, 2, now)
+		require.NoError(t, err)
+		assert.False(t, disabled)
+
+		// A change made through the API in between is kept.
+		edited, err := repo.GetByID(webhook.ID)
+		require.NoError(t, err)
+		edited.URL = "http://example.com/edited"
+		require.NoError(t, repo.Update(edited))
+
+		disabled, err = repo.RecordFailure(webhook.ID, 2, now)
+		require.NoError(t, err)
+		assert.True(t, disabled)
+		disabled, err = repo.RecordFailure(webhook.ID, 2, now)
+		require.NoError(t, err)
+		assert.False(t, disabled, "only the call that disables the webhook reports it")
+
+		got, err := repo.GetByID(webhook.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "http://example.com/edited", got.URL)
+		assert.False(t, got.Active)
+		assert.Equal(t, 3, got.ConsecutiveFailures)
+		require.NotNil(t, got.DisabledAt)
+
+		require.NoError(t, repo.RecordSuccess(webhook.ID))
+		got, err = repo.GetByID(webhook.ID)
+		require.NoError(t, err)
+		assert.Zero(t, got.ConsecutiveFailures)
+	})
+
+	t.Run("GetByIDMissing", func(t *testing.T) {
+		repo := newRepo(t)
+
+		_, err := repo.GetByID(42)
+		assert.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	})
+
+	t.Run("DeleteRemovesDeliveries", func(t *testing.T) {
+		repo := newRepo(t)
+
+		webhook := &model.Webhook{URL: "http://example.com/hook", Secret: "s", Active: true}
+		require.NoError(t, repo.Create(webhook))
+		require.NoError(t, repo.EnqueueDelivery(&model.WebhookDelivery{
+			WebhookID: webhook.ID, EventType: "todo.created", Payload: "{}",
+			Status: model.DeliveryPending, NextAttemptAt: now,
+		}))
+
+		require.NoError(t, repo.Delete(webhook.ID))
+		assert.ErrorIs(t, repo.Delete(webhook.ID), gorm.ErrRecordNotFound)
+
+		deliveries, err := repo.GetDeliveries(webhook.ID, 10)
+		require.NoError(t, err)
+		assert.Empty(t, deliveries)
+	})
+
+	t.Run("ClaimDueDeliveries", func(t *testing.T) {
+		repo := newRepo(t)
+
+		enqueue := func(status string, next time.Time) *model.WebhookDelivery {
+			d := &model.WebhookDelivery{WebhookID: 1, EventType: "todo.created", Payload: "{}", Status: status, NextAttemptAt: next}
+			require.NoError(t, repo.EnqueueDelivery(d))
+			return d
+		}
+		later := enqueue(model.DeliveryPending, now.Add(-time.Second))
+		earlier := enqueue(model.DeliveryPending, now.Add(-time.Minute))
+		enqueue(model.DeliveryPending, now.Add(time.Minute))
+		enqueue(model.DeliverySucceeded, now.Add(-time.Minute))
+
+		claimed, err := repo.ClaimDueDeliveries(now, time.Minute, 10)
+		require.NoError(t, err)
+		require.Len(t, claimed, 2)
+		assert.Equal(t, earlier.ID, claimed[0].ID)
+		assert.Equal(t, later.ID, claimed[1].ID)
+		assert.True(t, claimed[0].NextAttemptAt.Equal(now.Add(time.Minute)))
+
+		again, err := repo.ClaimDueDeliveries(now, time.Minute, 10)
+		require.NoError(t, err)
+		assert.Empty(t, again, "claimed deliveries stay leased")
+
+		claimed[0].Status = model.DeliverySucceeded
+		claimed[0].Attempts = 1
+		claimed[0].ResponseStatus = 204
+		require.NoError(t, repo.UpdateDelivery(&claimed[0]))
+
+		afterLease, err := repo.ClaimDueDeliveries(now.Add(2*time.Minute), time.Minute, 10)
+		require.NoError(t, err)
+		assert.Len(t, afterLease, 2, "the unfinished lease expired and the future delivery became due")
+	})
+
+	t.Run("GetDeliveriesNewestFirst", func(t *testing.T) {
+		repo := newRepo(t)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, repo.EnqueueDelivery(&model.WebhookDelivery{
+				WebhookID: 5, EventType: "todo.updated", Payload: "{}",
+				Status: model.DeliveryPending, NextAttemptAt: now,
+			}))
+		}
+		require.NoError(t, repo.EnqueueDelivery(&model.WebhookDelivery{
+			WebhookID: 6, EventType: "todo.updated", Payload: "{}",
+			Status: model.DeliveryPending, NextAttemptAt: now,
+		}))
+
+		deliveries, err := repo.GetDeliveries(5, 2)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 2)
+		assert.Greater(t, deliveries[0].ID, deliveries[1].ID)
+	})
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// OpenSQLite opens a SQLite database usable by TodoRepository. The driver is
+// pure Go, so it works in CGO-less builds such as the Alpine image. SQLite
+// allows a single writer, and every ":memory:" connection is a separate
+// database, so the pool is limited to one connection.
+func OpenSQLite(path string) (*gorm.DB, error) {
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	return db, nil
+}
@@ -1,17 +1,82 @@
 package repository
 
 import (
+	"errors"
+	"time"
+
 	"github.com/stavagg/petGoApi/internal/model"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrStaleVersion is returned by UpdateIfVersion when the todo changed, or
+// went to the trash, since it was read.
+var ErrStaleVersion = errors.New("todo version changed")
+
 type TodoRepositoryInterface interface {
 	Create(todo *model.Todo) error
+	// CreateBatch creates todos in a single statement and sets their IDs.
+	// A todo whose external ID another todo already has is not created and
+	// keeps a zero ID, so concurrent imports of one file do not fail.
+	CreateBatch(todos []model.Todo) error
 	GetAll() ([]model.Todo, error)
 	GetByID(id uint) (*model.Todo, error)
 	Update(todo *model.Todo) error
+	// UpdateIfVersion stores todo with a single conditional update that
+	// only matches the row while it still has version and is not in the
+	// trash, and returns ErrStaleVersion otherwise.
+	UpdateIfVersion(todo *model.Todo, version uint) error
+	// DeleteIfVersion moves the todo to the trash like Delete, but only
+	// while it still has version, and returns ErrStaleVersion otherwise.
+	DeleteIfVersion(id uint, version uint) error
 	Delete(id uint) error
 	GetByCompleted(completed bool) ([]model.Todo, error)
+	// GetPage returns the todos matching completed, every todo when it is
+	// nil, on page, newest first, and how many match in all. A nil page
+	// returns all of them.
+	GetPage(completed *bool, page *model.Pagination) ([]model.Todo, int64, error)
+	// GetByProject returns the todos of a project in ID order.
+	GetByProject(project string) ([]model.Todo, error)
+	// GetProjects sums up every project with todos, by name.
+	GetProjects() ([]model.ProjectSummary, error)
+	// ForEach calls fn for the todos matching completed, every todo when it
+	// is nil, in ID order. Todos are read batchSize at a time, so the table
+	// is never loaded at once. An error from fn stops the iteration and is
+	// returned.
+	ForEach(completed *bool, batchSize int, fn func(todo model.Todo) error) error
+	GetDeleted() ([]model.Todo, error)
+	// GetDeletedPage is GetDeleted on page, with the size of the trash.
+	GetDeletedPage(page *model.Pagination) ([]model.Todo, int64, error)
+	// GetByExternalIDs returns the todos, trashed ones included, imported
+	// from the given external IDs.
+	GetByExternalIDs(ids []string) ([]model.Todo, error)
+	Restore(id uint) error
+	Purge(id uint) error
+	PurgeDeletedBefore(cutoff time.Time) (int64, error)
+	// GetChangedSince returns todos, deleted ones included, whose
+	// (updated_at, id) comes after the given cursor, oldest change first.
+	GetChangedSince(since time.Time, afterID uint, limit int) ([]model.Todo, error)
+	// GetPurgedSince returns the tombstones of todos purged after since.
+	GetPurgedSince(since time.Time) ([]model.TodoTombstone, error)
+	// AppendOutbox stores change events for the relay, in as few statements
+	// as possible. Call it on the repository passed to Transaction so the
+	// events commit with the change.
+	AppendOutbox(messages ...*model.OutboxMessage) error
+	// AppendEvents numbers the history events after the last revision of
+	// their todo and stores them. Call it on the repository passed to
+	// Transaction, after the change to the todos, so the history commits
+	// with the change.
+	AppendEvents(events []*model.TodoEvent) error
+	// SaveUndo stores an undoable action and drops the ones that expired
+	// before now.
+	SaveUndo(action *model.UndoAction, now time.Time) error
+	// TakeUndo deletes and returns the action stored under token, or
+	// gorm.ErrRecordNotFound when there is none or it expired before now.
+	// Call it inside Transaction, so an undo that fails keeps its token.
+	TakeUndo(token string, now time.Time) (*model.UndoAction, error)
+	// Transaction runs fn against a repository bound to a single
+	// transaction. It commits when fn returns nil and rolls back otherwise.
+	Transaction(fn func(repo TodoRepositoryInterface) error) error
 }
 
 type TodoRepository struct {
@@ -26,9 +91,47 @@ func (r *TodoRepository) Create(todo *model.Todo) error {
 	return r.db.Create(todo).Error
 }
 
+func (r *TodoRepository) CreateBatch(todos []model.Todo) error {
+	if len(todos) == 0 {
+		return nil
+	}
+	result := r.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "external_id"}}, DoNothing: true}).Create(&todos)
+	if result.Error != nil || int(result.RowsAffected) == len(todos) {
+		return result.Error
+	}
+
+	// Only the inserted rows come back, and gorm hands their IDs to the
+	// first todos in order. Match them to the todos by external ID instead.
+	ids := make([]uint, result.RowsAffected)
+	for i := range ids {
+		ids[i] = todos[i].ID
+	}
+	var inserted []model.Todo
+	if err := r.db.Select("id", "external_id").Where("id IN ?", ids).Order("id asc").Find(&inserted).Error; err != nil {
+		return err
+	}
+	next := 0
+	for i := range todos {
+		todos[i].ID = 0
+		if next < len(inserted) && sameExternalID(inserted[next].ExternalID, todos[i].ExternalID) {
+			todos[i].ID = inserted[next].ID
+			next++
+		}
+	}
+	return nil
+}
+
+// sameExternalID reports whether a and b are both unset or equal.
+func sameExternalID(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
 func (r *TodoRepository) GetAll() ([]model.Todo, error) {
 	var todos []model.Todo
-	err := r.db.Order("created_at desc").Find(&todos).Error
+	err := r.db.Order("created_at desc, id desc").Find(&todos).Error
 	return todos, err
 }
 
@@ -45,12 +148,206 @@ func (r *TodoRepository) Update(todo *model.Todo) error {
 	return r.db.Save(todo).Error
 }
 
+func (r *TodoRepository) UpdateIfVersion(todo *model.Todo, version uint) error {
+	result := r.db.Model(todo).Where("version = ?", version).Select("*").Omit("id", "created_at").Updates(todo)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleVersion
+	}
+	return nil
+}
+
+// Delete soft-deletes the todo and bumps updated_at, so sync clients see
+// the deletion as a change.
 func (r *TodoRepository) Delete(id uint) error {
-	return r.db.Delete(&model.Todo{}, id).Error
+	now := time.Now()
+	return r.db.Model(&model.Todo{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"deleted_at": now,
+		"updated_at": now,
+	}).Error
+}
+
+func (r *TodoRepository) DeleteIfVersion(id uint, version uint) error {
+	now := time.Now()
+	result := r.db.Model(&model.Todo{}).Where("id = ? AND version = ?", id, version).Updates(map[string]interface{}{
+		"deleted_at": now,
+		"updated_at": now,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrStaleVersion
+	}
+	return nil
 }
 
 func (r *TodoRepository) GetByCompleted(completed bool) ([]model.Todo, error) {
 	var todos []model.Todo
-	err := r.db.Where("completed = ?", completed).Order("created_at desc").Find(&todos).Error
+	err := r.db.Where("completed = ?", completed).Order("created_at desc, id desc").Find(&todos).Error
+	return todos, err
+}
+
+func (r *TodoRepository) GetPage(completed *bool, page *model.Pagination) ([]model.Todo, int64, error) {
+	query := r.db.Model(&model.Todo{})
+	if completed != nil {
+		query = query.Where("completed = ?", *completed)
+	}
+	return findPage[model.Todo](query, "created_at desc, id desc", page)
+}
+
+func (r *TodoRepository) GetByProject(project string) ([]model.Todo, error) {
+	var todos []model.Todo
+	err := r.db.Where("project = ?", project).Order("id asc").Find(&todos).Error
+	return todos, err
+}
+
+func (r *TodoRepository) GetProjects() ([]model.ProjectSummary, error) {
+	var summaries []model.ProjectSummary
+	err := r.db.Model(&model.Todo{}).
+		Select("project, COUNT(*) AS todos, SUM(id) AS id_sum, SUM(version) AS version_sum").
+		Group("project").
+		Order("project asc").
+		Scan(&summaries).Error
+	return summaries, err
+}
+
+func (r *TodoRepository) ForEach(completed *bool, batchSize int, fn func(todo model.Todo) error) error {
+	query := r.db
+	if completed != nil {
+		query = query.Where("completed = ?", *completed)
+	}
+	var batch []model.Todo
+	return query.FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+		for _, todo := range batch {
+			if err := fn(todo); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+func (r *TodoRepository) GetDeleted() ([]model.Todo, error) {
+	var todos []model.Todo
+	err := r.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at desc, id desc").Find(&todos).Error
+	return todos, err
+}
+
+func (r *TodoRepository) GetDeletedPage(page *model.Pagination) ([]model.Todo, int64, error) {
+	query := r.db.Unscoped().Model(&model.Todo{}).Where("deleted_at IS NOT NULL")
+	return findPage[model.Todo](query, "deleted_at desc, id desc", page)
+}
+
+func (r *TodoRepository) GetByExternalIDs(ids []string) ([]model.Todo, error) {
+	var todos []model.Todo
+	if len(ids) == 0 {
+		return todos, nil
+	}
+	err := r.db.Unscoped().Where("external_id IN ?", ids).Find(&todos).Error
+	return todos, err
+}
+
+func (r *TodoRepository) Restore(id uint) error {
+	result := r.db.Unscoped().Model(&model.Todo{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *TodoRepository) Purge(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var todo model.Todo
+		if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&todo).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Delete(&todo).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.TodoTombstone{TodoID: id, PurgedAt: time.Now(), ExternalID: todo.ExternalID, Project: todo.Project}).Error
+	})
+}
+
+func (r *TodoRepository) PurgeDeletedBefore(cutoff time.Time) (int64, error) {
+	var purged int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec("INSERT INTO todo_tombstones (todo_id, purged_at, external_id, project) SELECT id, ?, external_id, project FROM todos WHERE deleted_at IS NOT NULL AND deleted_at < ?",
+			time.Now(), cutoff).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(&model.Todo{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+func (r *TodoRepository) GetChangedSince(since time.Time, afterID uint, limit int) ([]model.Todo, error) {
+	var todos []model.Todo
+	err := r.db.Unscoped().
+		Where("updated_at > ? OR (updated_at = ? AND id > ?)", since, since, afterID).
+		Order("updated_at asc, id asc").
+		Limit(limit).
+		Find(&todos).Error
 	return todos, err
 }
+
+func (r *TodoRepository) GetPurgedSince(since time.Time) ([]model.TodoTombstone, error) {
+	var tombstones []model.TodoTombstone
+	err := r.db.Where("purged_at > ?", since).Order("purged_at asc, id asc").Find(&tombstones).Error
+	return tombstones, err
+}
+
+func (r *TodoRepository) AppendOutbox(messages ...*model.OutboxMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(messages, outboxBatchSize).Error
+}
+
+// outboxBatchSize is how many messages AppendOutbox inserts per statement.
+const outboxBatchSize = 500
+
+func (r *TodoRepository) AppendEvents(events []*model.TodoEvent) error {
+	return appendEvents(r.db, events)
+}
+
+func (r *TodoRepository) SaveUndo(action *model.UndoAction, now time.Time) error {
+	if err := r.db.Where("expires_at < ?", now).Delete(&model.UndoAction{}).Error; err != nil {
+		return err
+	}
+	return r.db.Create(action).Error
+}
+
+// TakeUndo reads the action and then deletes it. The delete is what claims
+// it: of two concurrent undos only one deletes the row.
+func (r *TodoRepository) TakeUndo(token string, now time.Time) (*model.UndoAction, error) {
+	var action model.UndoAction
+	if err := r.db.Where("token = ? AND expires_at >= ?", token, now).First(&action).Error; err != nil {
+		return nil, err
+	}
+	result := r.db.Where("token = ?", token).Delete(&model.UndoAction{})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &action, nil
+}
+
+func (r *TodoRepository) Transaction(fn func(repo TodoRepositoryInterface) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(NewTodoRepository(tx))
+	})
+}
@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"github.com/stavagg/petGoApi/internal/model"
+	"gorm.io/gorm"
+)
+
+// TodoEventRepositoryInterface stores the audit log. It is append-only:
+// events are never updated or deleted.
+type TodoEventRepositoryInterface interface {
+	Append(event *model.TodoEvent) error
+	GetByTodoID(todoID uint) ([]model.TodoEvent, error)
+	// GetPageByTodoID is GetByTodoID on page, with the number of events of
+	// the todo.
+	GetPageByTodoID(todoID uint, page *model.Pagination) ([]model.TodoEvent, int64, error)
+	// GetByTodoIDs returns the events of several todos at once, ordered by
+	// todo and revision.
+	GetByTodoIDs(todoIDs []uint) ([]model.TodoEvent, error)
+	GetRevision(todoID, revision uint) (*model.TodoEvent, error)
+	// GetAfter returns up to limit events with an ID greater than afterID,
+	// across all todos, in ID order.
+	GetAfter(afterID uint, limit int) ([]model.TodoEvent, error)
+	// LastID returns the ID of the newest event, or 0 when there are none.
+	LastID() (uint, error)
+}
+
+type TodoEventRepository struct {
+	db *gorm.DB
+}
+
+func NewTodoEventRepository(db *gorm.DB) *TodoEventRepository {
+	return &TodoEventRepository{db: db}
+}
+
+// Append assigns the next revision for the todo and stores the event. The
+// services record history with TodoRepositoryInterface.AppendEvents instead,
+// in the transaction of the change.
+func (r *TodoEventRepository) Append(event *model.TodoEvent) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return appendEvents(tx, []*model.TodoEvent{event})
+	})
+}
+
+// appendEvents numbers events after the last revision of their todo and
+// inserts them. Revisions are read in the same transaction as the change to
+// the todo row, whose lock is held until commit, so concurrent writers of a
+// todo number their events one after the other. The unique (todo_id,
+// revision) index is the backstop: a writer that still collides fails and
+// its change is rolled back with it.
+func appendEvents(db *gorm.DB, events []*model.TodoEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var ids []uint
+	last := make(map[uint]uint)
+	for _, event := range events {
+		if _, ok := last[event.TodoID]; !ok {
+			last[event.TodoID] = 0
+			ids = append(ids, event.TodoID)
+		}
+	}
+
+	var revisions []struct {
+		TodoID uint
+		Last   uint
+	}
+	err := db.Model(&model.TodoEvent{}).
+		Select("todo_id, MAX(revision) AS last").
+		Where("todo_id IN ?", ids).
+		Group("todo_id").
+		Scan(&revisions).Error
+	if err != nil {
+		return err
+	}
+	for _, revision := range revisions {
+		last[revision.TodoID] = revision.Last
+	}
+
+	for _, event := range events {
+		last[event.TodoID]++
+		event.Revision = last[event.TodoID]
+	}
+	return db.CreateInBatches(events, eventBatchSize).Error
+}
+
+// eventBatchSize is how many events appendEvents inserts per statement.
+const eventBatchSize = 500
+
+func (r *TodoEventRepository) GetByTodoID(todoID uint) ([]model.TodoEvent, error) {
+	var events []model.TodoEvent
+	err := r.db.Where("todo_id = ?", todoID).Order("revision asc").Find(&events).Error
+	return events, err
+}
+
+func (r *TodoEventRepository) GetPageByTodoID(todoID uint, page *model.Pagination) ([]model.TodoEvent, int64, error) {
+	query := r.db.Model(&model.TodoEvent{}).Where("todo_id = ?", todoID)
+	return findPage[model.TodoEvent](query, "revision asc", page)
+}
+
+func (r *TodoEventRepository) GetByTodoIDs(todoIDs []uint) ([]model.TodoEvent, error) {
+	events := []model.TodoEvent{}
+	if len(todoIDs) == 0 {
+		return events, nil
+	}
+	err := r.db.Where("todo_id IN ?", todoIDs).Order("todo_id asc, revision asc").Find(&events).Error
+	return events, err
+}
+
+func (r *TodoEventRepository) GetRevision(todoID, revision uint) (*model.TodoEvent, error) {
+	var event model.TodoEvent
+	err := r.db.Where("todo_id = ? AND revision = ?", todoID, revision).First(&event).Error
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+func (r *TodoEventRepository) GetAfter(afterID uint, limit int) ([]model.TodoEvent, error) {
+	var events []model.TodoEvent
+	err := r.db.Where("id > ?", afterID).Order("id asc").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+func (r *TodoEventRepository) LastID() (uint, error) {
+	var last uint
+	err := r.db.Model(&model.TodoEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&last).Error
+	return last, err
+}
@@ -0,0 +1,142 @@
+package repository_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/repository/repositorytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMemoryTodoRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.TodoRepositoryInterface {
+		return repository.NewMemoryTodoRepository()
+	})
+}
+
+func TestMemoryTodoEventRepository(t *testing.T) {
+	repositorytest.RunTodoEvents(t, func(t *testing.T) repository.TodoEventRepositoryInterface {
+		return repository.NewMemoryTodoEventRepository()
+	})
+}
+
+func TestMemoryTodoEventLog(t *testing.T) {
+	repositorytest.RunEventLog(t, func(t *testing.T) (repository.TodoRepositoryInterface, repository.TodoEventRepositoryInterface) {
+		repo := repository.NewMemoryTodoRepository()
+		return repo, repo.Events()
+	})
+}
+
+func TestMemoryWebhookRepository(t *testing.T) {
+	repositorytest.RunWebhooks(t, func(t *testing.T) repository.WebhookRepositoryInterface {
+		return repository.NewMemoryWebhookRepository()
+	})
+}
+
+func TestMemoryUserRepository(t *testing.T) {
+	repositorytest.RunUsers(t, func(t *testing.T) repository.UserRepositoryInterface {
+		return repository.NewMemoryUserRepository()
+	})
+}
+
+func TestMemoryOutboxRepository(t *testing.T) {
+	repositorytest.RunOutbox(t, func(t *testing.T) (repository.TodoRepositoryInterface, repository.OutboxRepositoryInterface) {
+		repo := repository.NewMemoryTodoRepository()
+		return repo, repo.Outbox()
+	})
+}
+
+func TestSQLiteTodoRepository(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.TodoRepositoryInterface {
+		db, err := repository.OpenSQLite(":memory:")
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&model.Todo{}, &model.TodoTombstone{}, &model.UndoAction{}))
+		return repository.NewTodoRepository(db)
+	})
+}
+
+func TestSQLiteTodoEventRepository(t *testing.T) {
+	repositorytest.RunTodoEvents(t, func(t *testing.T) repository.TodoEventRepositoryInterface {
+		db, err := repository.OpenSQLite(":memory:")
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&model.TodoEvent{}))
+		return repository.NewTodoEventRepository(db)
+	})
+}
+
+func TestSQLiteTodoEventLog(t *testing.T) {
+	repositorytest.RunEventLog(t, func(t *testing.T) (repository.TodoRepositoryInterface, repository.TodoEventRepositoryInterface) {
+		db, err := repository.OpenSQLite(":memory:")
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&model.Todo{}, &model.TodoEvent{}))
+		return repository.NewTodoRepository(db), repository.NewTodoEventRepository(db)
+	})
+}
+
+func TestSQLiteWebhookRepository(t *testing.T) {
+	repositorytest.RunWebhooks(t, func(t *testing.T) repository.WebhookRepositoryInterface {
+		db, err := repository.OpenSQLite(":memory:")
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&model.Webhook{}, &model.WebhookDelivery{}))
+		return repository.NewWebhookRepository(db)
+	})
+}
+
+func TestSQLiteUserRepository(t *testing.T) {
+	repositorytest.RunUsers(t, func(t *testing.T) repository.UserRepositoryInterface {
+		db, err := repository.OpenSQLite(":memory:")
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&model.User{}, &model.APIKey{}))
+		return repository.NewUserRepository(db)
+	})
+}
+
+func TestSQLiteOutboxRepository(t *testing.T) {
+	repositorytest.RunOutbox(t, func(t *testing.T) (repository.TodoRepositoryInterface, repository.OutboxRepositoryInterface) {
+		db, err := repository.OpenSQLite(":memory:")
+		require.NoError(t, err)
+		require.NoError(t, db.AutoMigrate(&model.Todo{}, &model.OutboxMessage{}))
+		return repository.NewTodoRepository(db), repository.NewOutboxRepository(db)
+	})
+}
+
+func TestMigrateBackfillsFieldTimes(t *testing.T) {
+	db, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	require.NoError(t, repository.Migrate(db))
+
+	updated := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	require.NoError(t, db.Exec("INSERT INTO todos (title, version, created_at, updated_at) VALUES (?, 1, ?, ?)", "Legacy", updated, updated).Error)
+	require.NoError(t, repository.Migrate(db))
+
+	todo, err := repository.NewTodoRepository(db).GetByID(1)
+	require.NoError(t, err)
+	require.Len(t, todo.FieldTimes, len(model.EditableFields))
+	for _, field := range model.EditableFields {
+		assert.True(t, updated.Equal(todo.FieldTimes[field]), field)
+	}
+	assert.True(t, updated.Equal(todo.UpdatedAt), "the backfill leaves updated_at alone")
+}
+
+// TestPostgresTodoRepository runs only when TEST_POSTGRES_DSN points at a
+// disposable database, e.g. the one from docker-compose.
+func TestPostgresTodoRepository(t *testing.T) {
+	dsn := os.Getenv("TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("TEST_POSTGRES_DSN is not set")
+	}
+
+	repositorytest.Run(t, func(t *testing.T) repository.TodoRepositoryInterface {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		require.NoError(t, err)
+		require.NoError(t, db.Migrator().DropTable(&model.Todo{}, &model.TodoTombstone{}, &model.UndoAction{}))
+		require.NoError(t, db.AutoMigrate(&model.Todo{}, &model.TodoTombstone{}, &model.UndoAction{}))
+		return repository.NewTodoRepository(db)
+	})
+}
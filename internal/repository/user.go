@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrUserExists is returned when a user with the same name exists.
+var ErrUserExists = errors.New("user already exists")
+
+// UserRepositoryInterface stores the users of the API and their API keys.
+type UserRepositoryInterface interface {
+	// CreateUser returns ErrUserExists when the name is taken.
+	CreateUser(user *model.User) error
+	// GetUserByName returns gorm.ErrRecordNotFound when there is no such
+	// user.
+	GetUserByName(name string) (*model.User, error)
+	CreateAPIKey(key *model.APIKey) error
+	// GetUserByKeyHash returns the user of the API key with the hash, or
+	// gorm.ErrRecordNotFound.
+	GetUserByKeyHash(hash string) (*model.User, error)
+}
+
+type UserRepository struct {
+	db *gorm.DB
+}
+
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) CreateUser(user *model.User) error {
+	result := r.db.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).Create(user)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserExists
+	}
+	return nil
+}
+
+func (r *UserRepository) GetUserByName(name string) (*model.User, error) {
+	var user model.User
+	if err := r.db.Where("name = ?", name).First(&user).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *UserRepository) CreateAPIKey(key *model.APIKey) error {
+	return r.db.Create(key).Error
+}
+
+func (r *UserRepository) GetUserByKeyHash(hash string) (*model.User, error) {
+	var user model.User
+	err := r.db.Joins("JOIN api_keys ON api_keys.user_id = users.id").
+		Where("api_keys.hash = ?", hash).First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
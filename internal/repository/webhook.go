@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"gorm.io/gorm"
+)
+
+// WebhookRepositoryInterface stores webhook subscriptions and their delivery
+// queue.
+type WebhookRepositoryInterface interface {
+	Create(webhook *model.Webhook) error
+	GetAll() ([]model.Webhook, error)
+	// GetPage returns the webhooks on page in ID order, and how many there
+	// are in all.
+	GetPage(page *model.Pagination) ([]model.Webhook, int64, error)
+	GetByID(id uint) (*model.Webhook, error)
+	Update(webhook *model.Webhook) error
+	// RecordSuccess resets the consecutive failures of a webhook.
+	RecordSuccess(id uint) error
+	// RecordFailure counts a failed delivery attempt and disables the
+	// webhook at when the count reaches disableAfter. It reports whether
+	// this call disabled it. Both only touch the counter columns, so they
+	// do not overwrite changes made through the API meanwhile.
+	RecordFailure(id uint, disableAfter int, at time.Time) (bool, error)
+	// Delete removes the webhook together with its deliveries.
+	Delete(id uint) error
+
+	EnqueueDelivery(delivery *model.WebhookDelivery) error
+	// ClaimDueDeliveries returns up to limit pending deliveries whose next
+	// attempt is due at now and pushes their next attempt to now+lease, so
+	// other workers sharing the queue skip them while they are in flight.
+	ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error)
+	UpdateDelivery(delivery *model.WebhookDelivery) error
+	// GetDeliveries returns the newest deliveries of a webhook first.
+	GetDeliveries(webhookID uint, limit int) ([]model.WebhookDelivery, error)
+}
+
+type WebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func (r *WebhookRepository) Create(webhook *model.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *WebhookRepository) GetAll() ([]model.Webhook, error) {
+	var webhooks []model.Webhook
+	err := r.db.Order("id asc").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *WebhookRepository) GetPage(page *model.Pagination) ([]model.Webhook, int64, error) {
+	return findPage[model.Webhook](r.db.Model(&model.Webhook{}), "id asc", page)
+}
+
+func (r *WebhookRepository) GetByID(id uint) (*model.Webhook, error) {
+	var webhook model.Webhook
+	err := r.db.First(&webhook, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *WebhookRepository) Update(webhook *model.Webhook) error {
+	return r.db.Save(webhook).Error
+}
+
+func (r *WebhookRepository) RecordSuccess(id uint) error {
+	return r.db.Model(&model.Webhook{}).
+		Where("id = ? AND consecutive_failures > 0", id).
+		Update("consecutive_failures", 0).Error
+}
+
+func (r *WebhookRepository) RecordFailure(id uint, disableAfter int, at time.Time) (bool, error) {
+	disabled := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&model.Webhook{}).Where("id = ?", id).
+			Update("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&model.Webhook{}).
+			Where("id = ? AND active = ? AND consecutive_failures >= ?", id, true, disableAfter).
+			Updates(map[string]interface{}{"active": false, "disabled_at": at})
+		disabled = result.RowsAffected == 1
+		return result.Error
+	})
+	return disabled, err
+}
+
+func (r *WebhookRepository) Delete(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&model.Webhook{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("webhook_id = ?", id).Delete(&model.WebhookDelivery{}).Error
+	})
+}
+
+func (r *WebhookRepository) EnqueueDelivery(delivery *model.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+// ClaimDueDeliveries claims each row with a conditional update on its
+// next_attempt_at, which works the same on every supported database.
+func (r *WebhookRepository) ClaimDueDeliveries(now time.Time, lease time.Duration, limit int) ([]model.WebhookDelivery, error) {
+	var due []model.WebhookDelivery
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", model.DeliveryPending, now).
+		Order("next_attempt_at asc, id asc").
+		Limit(limit).
+		Find(&due).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]model.WebhookDelivery, 0, len(due))
+	for _, delivery := range due {
+		leaseUntil := now.Add(lease)
+		result := r.db.Model(&model.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, model.DeliveryPending, delivery.NextAttemptAt).
+			Update("next_attempt_at", leaseUntil)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			delivery.NextAttemptAt = leaseUntil
+			claimed = append(claimed, delivery)
+		}
+	}
+	return claimed, nil
+}
+
+func (r *WebhookRepository) UpdateDelivery(delivery *model.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+func (r *WebhookRepository) GetDeliveries(webhookID uint, limit int) ([]model.WebhookDelivery, error) {
+	var deliveries []model.WebhookDelivery
+	err := r.db.Where("webhook_id = ?", webhookID).
+		Order("id desc").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
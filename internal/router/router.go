@@ -0,0 +1,196 @@
+// Package router registers the HTTP routes of the API. The routes live here
+// rather than in main so tests can check them against the OpenAPI document.
+package router
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/gin-gonic/gin"
+
+	"github.com/stavagg/petGoApi/internal/caldav"
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/openapi"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// Handlers are the handlers served by the router.
+type Handlers struct {
+	Todos     *handler.TodoHandler
+	Events    *handler.EventHandler
+	WebSocket *handler.WebSocketHandler
+	Webhooks  *handler.WebhookHandler
+	Outbox    *handler.OutboxHandler
+	Sync      *handler.SyncHandler
+	GraphQL   http.Handler
+	// CalDAV is optional; see serveCalDAV.
+	CalDAV http.Handler
+}
+
+// Options configure the router.
+type Options struct {
+	// Storage is the storage driver reported by the health check.
+	Storage string
+	// DB is the database the health check pings. It is nil with memory
+	// storage, which has no database to report.
+	DB *sql.DB
+	// ValidateResponses checks every JSON response against the OpenAPI
+	// document and replaces one that does not match with a 500. It buffers
+	// each response, so it is meant for tests and development.
+	ValidateResponses bool
+	// Users, when set, requires an API key on every route except the root,
+	// the health check and the documentation.
+	Users service.UserServiceInterface
+}
+
+// publicPaths are served without an API key.
+var publicPaths = []string{"/", "/health", "/openapi.json", "/docs", "/redoc", "/graphql/playground"}
+
+// New returns an engine with every route of the API.
+func New(opts Options, h Handlers) *gin.Engine {
+	// Every request gets its ID first, so that the log line and every
+	// response, including those of the middleware below, carry it.
+	r := gin.New()
+	r.Use(handler.RequestID(), handler.Logger(), gin.Recovery())
+
+	if opts.Users != nil {
+		r.Use(handler.Authenticate(opts.Users, publicPaths...))
+	}
+	if h.CalDAV != nil {
+		r.Use(serveCalDAV(h.CalDAV))
+	}
+	r.Use(func(c *gin.Context) {
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Actor, X-Request-ID, Last-Event-ID")
+		c.Header("Access-Control-Expose-Headers", "X-Request-ID")
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(204)
+			return
+		}
+		c.Next()
+	})
+	r.Use(handler.Actor())
+	r.Use(openapi.Validate().Middleware(opts.ValidateResponses))
+
+	// The root lists the routes documented in the spec.
+	endpoints := openapi.Spec().Endpoints()
+	r.GET("/", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"message":   "🚀 PetGoApi is running!",
+			"version":   openapi.Version,
+			"status":    "healthy",
+			"docs":      "/docs",
+			"endpoints": endpoints,
+		})
+	})
+
+	r.GET("/health", health(opts))
+
+	r.GET("/openapi.json", func(c *gin.Context) {
+		c.JSON(http.StatusOK, openapi.Spec())
+	})
+	r.GET("/docs", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.SwaggerUI)
+	})
+	r.GET("/redoc", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.Redoc)
+	})
+
+	r.GET("/graphql", gin.WrapH(h.GraphQL))
+	r.POST("/graphql", gin.WrapH(h.GraphQL))
+	r.GET("/graphql/playground", gin.WrapH(playground.Handler("PetGoApi", "/graphql")))
+
+	// The REST routes render JSON, YAML or MessagePack as asked for in
+	// Accept, in the v1 format or the v2 envelope, and lists of todos also
+	// CSV. Export and the streams keep their own formats.
+	negotiated := handler.Negotiate()
+	list := handler.Negotiate(handler.MIMECSV)
+
+	api := r.Group("/api/v1")
+	api.Use(handler.BasePath(api.BasePath()))
+	{
+		todos := api.Group("/todos")
+		{
+			todos.POST("", negotiated, h.Todos.CreateTodo)
+			todos.GET("", list, h.Todos.GetAllTodos)
+			todos.GET("/stats", negotiated, h.Todos.GetStats)
+			todos.GET("/export", h.Todos.ExportTodos)
+			todos.POST("/import", negotiated, h.Todos.ImportTodos)
+			todos.GET("/events", h.Events.StreamTodoEvents)
+			todos.POST("/complete-all", negotiated, h.Todos.MarkAllCompleted)
+			todos.GET("/:id", negotiated, h.Todos.GetTodoByID)
+			todos.PUT("/:id", negotiated, h.Todos.UpdateTodo)
+			todos.DELETE("/:id", negotiated, h.Todos.DeleteTodo)
+			todos.POST("/:id/toggle", negotiated, h.Todos.ToggleTodo)
+			todos.POST("/:id/restore", negotiated, h.Todos.RestoreTodo)
+			todos.GET("/:id/history", negotiated, h.Todos.GetHistory)
+			todos.POST("/:id/revert", negotiated, h.Todos.RevertTodo)
+		}
+
+		trash := api.Group("/trash")
+		{
+			trash.GET("", list, h.Todos.GetTrash)
+			trash.DELETE("/:id", negotiated, h.Todos.PurgeTodo)
+		}
+
+		webhooks := api.Group("/webhooks")
+		{
+			webhooks.POST("", negotiated, h.Webhooks.CreateWebhook)
+			webhooks.GET("", negotiated, h.Webhooks.GetWebhooks)
+			webhooks.GET("/:id", negotiated, h.Webhooks.GetWebhookByID)
+			webhooks.PUT("/:id", negotiated, h.Webhooks.UpdateWebhook)
+			webhooks.DELETE("/:id", negotiated, h.Webhooks.DeleteWebhook)
+			webhooks.GET("/:id/deliveries", negotiated, h.Webhooks.GetDeliveries)
+		}
+
+		api.GET("/outbox/stats", negotiated, h.Outbox.GetStats)
+		api.GET("/sync", negotiated, h.Sync.GetChanges)
+		api.POST("/sync", negotiated, h.Sync.Push)
+		api.POST("/undo/:token", negotiated, h.Todos.Undo)
+		api.GET("/ws", h.WebSocket.Connect)
+	}
+
+	return r
+}
+
+// healthTimeout bounds the database ping of the health check.
+const healthTimeout = 2 * time.Second
+
+// health reports the storage driver and, with a database, whether it
+// answers a ping. A database that does not answer makes the service
+// unhealthy.
+func health(opts Options) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if opts.DB == nil {
+			c.JSON(http.StatusOK, gin.H{"status": "ok", "storage": opts.Storage})
+			return
+		}
+		ctx, cancel := context.WithTimeout(c.Request.Context(), healthTimeout)
+		defer cancel()
+		if err := opts.DB.PingContext(ctx); err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "database": "disconnected", "storage": opts.Storage, "error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok", "database": "connected", "storage": opts.Storage})
+	}
+}
+
+// serveCalDAV hands the CalDAV paths to h ahead of the other middleware.
+// CalDAV uses methods such as PROPFIND that are not Gin routes and not in
+// the OpenAPI document, and answers OPTIONS itself.
+func serveCalDAV(h http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if path != caldav.WellKnown && path != strings.TrimSuffix(caldav.Prefix, "/") && !strings.HasPrefix(path, caldav.Prefix) {
+			c.Next()
+			return
+		}
+		h.ServeHTTP(c.Writer, c.Request)
+		c.Abort()
+	}
+}
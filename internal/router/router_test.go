@@ -0,0 +1,377 @@
+package router_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/openapi"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/router"
+	"github.com/stavagg/petGoApi/internal/router/routertest"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRouter(t *testing.T) *gin.Engine {
+	return routertest.New(t)
+}
+
+var ginParam = regexp.MustCompile(`[:*]([^/]+)`)
+
+// TestRoutesMatchSpec fails when a route is registered without being
+// described in the OpenAPI document, or the other way round.
+func TestRoutesMatchSpec(t *testing.T) {
+	var registered []string
+	for _, route := range newRouter(t).Routes() {
+		registered = append(registered, route.Method+" "+ginParam.ReplaceAllString(route.Path, "{$1}"))
+	}
+
+	var described []string
+	for path, item := range openapi.Spec().Paths {
+		for method := range item.Operations() {
+			described = append(described, method+" "+path)
+		}
+	}
+
+	sort.Strings(registered)
+	sort.Strings(described)
+	assert.Equal(t, registered, described, "routes and the OpenAPI document diverged")
+}
+
+func TestSpecDeclaresPathParameters(t *testing.T) {
+	pathParam := regexp.MustCompile(`\{([^}]+)\}`)
+	operationIDs := make(map[string]string)
+
+	for path, item := range openapi.Spec().Paths {
+		for method, op := range item.Operations() {
+			where := method + " " + path
+			if other, ok := operationIDs[op.OperationID]; ok {
+				t.Errorf("%s and %s share operationId %s", other, where, op.OperationID)
+			}
+			operationIDs[op.OperationID] = where
+			assert.NotEmpty(t, op.Responses, where)
+
+			declared := make(map[string]bool)
+			for _, p := range op.Parameters {
+				if p.In == "path" {
+					declared[p.Name] = true
+				}
+			}
+			for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+				assert.True(t, declared[m[1]], "%s does not declare path parameter %s", where, m[1])
+				delete(declared, m[1])
+			}
+			assert.Empty(t, declared, "%s declares path parameters missing from the path", where)
+		}
+	}
+}
+
+func TestServesSpecAndDocs(t *testing.T) {
+	r := newRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc struct {
+		OpenAPI string                     `json:"openapi"`
+		Paths   map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Contains(t, doc.Paths, "/api/v1/todos/{id}")
+
+	for _, page := range []string{"/docs", "/redoc"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", page, nil))
+		assert.Equal(t, http.StatusOK, w.Code, page)
+		assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), "text/html"), page)
+		assert.Contains(t, w.Body.String(), "/openapi.json", page)
+	}
+}
+
+// TestServesCalDAV checks that CalDAV requests, whose methods are not in
+// the OpenAPI document, get past CORS and request validation.
+func TestServesCalDAV(t *testing.T) {
+	r := newRouter(t)
+
+	w := do(t, r, "OPTIONS", "/caldav/", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("DAV"), "calendar-access")
+
+	w = do(t, r, "PROPFIND", "/caldav/", "")
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.Contains(t, w.Body.String(), "/caldav/default/")
+
+	w = do(t, r, "GET", "/.well-known/caldav", "")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+
+	w = do(t, r, "GET", "/health", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "database", "memory storage has no database to report")
+}
+
+func TestHealthPingsDatabase(t *testing.T) {
+	db, err := repository.OpenSQLite(":memory:")
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	r := routertest.NewWithOptions(t, router.Options{Storage: "sqlite", DB: sqlDB, ValidateResponses: true})
+
+	w := do(t, r, "GET", "/health", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"database":"connected"`)
+
+	require.NoError(t, sqlDB.Close())
+	w = do(t, r, "GET", "/health", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"database":"disconnected"`)
+}
+
+func TestRequiresAPIKeys(t *testing.T) {
+	users := service.NewUserService(repository.NewMemoryUserRepository())
+	ctx := context.Background()
+	_, err := users.CreateUser(ctx, "alice")
+	require.NoError(t, err)
+	key, _, err := users.CreateAPIKey(ctx, "alice")
+	require.NoError(t, err)
+	r := routertest.NewWithOptions(t, router.Options{Storage: "memory", ValidateResponses: true, Users: users})
+
+	send := func(method, path, body string, auth func(req *http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Actor", "mallory")
+		auth(req)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	none := func(*http.Request) {}
+	bearer := func(key string) func(*http.Request) {
+		return func(req *http.Request) { req.Header.Set("Authorization", "Bearer "+key) }
+	}
+
+	for _, path := range []string{"/", "/health", "/openapi.json", "/docs"} {
+		assert.Equal(t, http.StatusOK, send("GET", path, "", none).Code, path)
+	}
+	for _, path := range []string{"/api/v1/todos", "/graphql", "/caldav/"} {
+		w := send("GET", path, "", none)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer", path)
+	}
+	assert.Equal(t, http.StatusUnauthorized, send("GET", "/api/v1/todos", "", bearer(key+"x")).Code)
+	assert.Equal(t, http.StatusNoContent, send("OPTIONS", "/api/v1/todos", "", none).Code, "CORS preflights carry no credentials")
+
+	w := send("POST", "/api/v1/todos", `{"title": "Task"}`, bearer(key))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	w = send("GET", "/api/v1/todos/1/history", "", bearer(key))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"actor":"alice"`, "changes are attributed to the key's user, not to X-Actor")
+
+	w = send("PROPFIND", "/caldav/", "", func(req *http.Request) { req.SetBasicAuth("calendar", key) })
+	assert.Equal(t, http.StatusMultiStatus, w.Code, "calendar clients send the key as the password")
+}
+
+// TestNegotiatedResponsesMatchSpec checks that the formats other than JSON
+// are documented for the routes that render them.
+func TestNegotiatedResponsesMatchSpec(t *testing.T) {
+	r := newRouter(t)
+	require.Equal(t, http.StatusCreated, do(t, r, "POST", "/api/v1/todos", `{"title": "Negotiate"}`).Code)
+
+	steps := []struct {
+		path, accept string
+		status       int
+		contentType  string
+	}{
+		{"/api/v1/todos", "text/csv", http.StatusOK, "text/csv"},
+		{"/api/v1/todos/1", "application/yaml", http.StatusOK, "application/yaml"},
+		{"/api/v1/todos/99", "application/msgpack", http.StatusNotFound, "application/msgpack"},
+		{"/api/v1/todos?completed=maybe", "text/csv", http.StatusBadRequest, "application/json"},
+		{"/api/v1/todos/stats", "text/csv", http.StatusNotAcceptable, "application/json"},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest("GET", step.path, nil)
+		req.Header.Set("Accept", step.accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, step.status, w.Code, "%s as %s: %s", step.path, step.accept, w.Body.String())
+		assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), step.contentType), "%s as %s", step.path, step.accept)
+	}
+}
+
+func TestV2EnvelopeMatchesSpec(t *testing.T) {
+	r := newRouter(t)
+	for _, title := range []string{"One", "Two", "Three"} {
+		require.Equal(t, http.StatusCreated, do(t, r, "POST", "/api/v1/todos", `{"title": "`+title+`"}`).Code)
+	}
+
+	steps := []struct {
+		method, path, accept string
+		status               int
+	}{
+		{"GET", "/api/v1/todos?limit=2&offset=1", handler.MIMEJSONv2, http.StatusOK},
+		{"GET", "/api/v1/todos/1", handler.MIMEYAMLv2, http.StatusOK},
+		{"POST", "/api/v1/todos/1/toggle", handler.MIMEJSONv2, http.StatusOK},
+		{"DELETE", "/api/v1/todos/2", handler.MIMEJSONv2, http.StatusOK},
+		{"GET", "/api/v1/todos/99", handler.MIMEJSONv2, http.StatusNotFound},
+		{"GET", "/api/v1/trash", handler.MIMEMsgPackv2, http.StatusOK},
+		{"GET", "/api/v1/webhooks", handler.MIMEJSONv2, http.StatusOK},
+		{"GET", "/api/v1/sync?limit=1", handler.MIMEJSONv2, http.StatusOK},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, step.path, nil)
+		req.Header.Set("Accept", step.accept)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, step.status, w.Code, "%s %s: %s", step.method, step.path, w.Body.String())
+		assert.True(t, strings.HasPrefix(w.Header().Get("Content-Type"), step.accept), "%s %s", step.method, step.path)
+		assert.NotEmpty(t, w.Header().Get(handler.RequestIDHeader))
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/todos/1/toggle", nil)
+	req.Header.Set("Accept", handler.MIMEJSONv2)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var toggled model.Envelope
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &toggled))
+	assert.Equal(t, "/api/v1/todos/1", toggled.Links.Delete, "links point into the group the routes are mounted in")
+	assert.True(t, strings.HasPrefix(toggled.Links.Undo, "/api/v1/undo/"))
+}
+
+func do(t *testing.T, r http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	switch {
+	case json.Valid([]byte(body)):
+		req.Header.Set("Content-Type", "application/json")
+	case body != "":
+		// Import files.
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestValidation_RejectsRequestsAgainstSpec(t *testing.T) {
+	r := newRouter(t)
+
+	tests := []struct {
+		name, method, path, body string
+		fields                   []openapi.FieldError
+	}{
+		{
+			name: "missing title", method: "POST", path: "/api/v1/todos", body: `{"description": "x"}`,
+			fields: []openapi.FieldError{{Field: "title", In: "body", Message: "is required"}},
+		},
+		{
+			name: "title too long", method: "POST", path: "/api/v1/todos", body: `{"title": "` + strings.Repeat("я", 256) + `"}`,
+			fields: []openapi.FieldError{{Field: "title", In: "body", Message: "maxLength: got 256, want 255"}},
+		},
+		{
+			name: "wrong type", method: "PUT", path: "/api/v1/todos/1", body: `{"completed": "yes"}`,
+			fields: []openapi.FieldError{{Field: "completed", In: "body", Message: "got string, want null or boolean"}},
+		},
+		{
+			name: "invalid JSON", method: "POST", path: "/api/v1/todos", body: `{"title":`,
+			fields: []openapi.FieldError{{In: "body", Message: "is not valid JSON"}},
+		},
+		{
+			name: "invalid path parameter", method: "GET", path: "/api/v1/todos/abc",
+			fields: []openapi.FieldError{{Field: "id", In: "path", Message: "must be an integer"}},
+		},
+		{
+			name: "invalid query parameter", method: "GET", path: "/api/v1/todos?completed=maybe",
+			fields: []openapi.FieldError{{Field: "completed", In: "query", Message: "must be a boolean"}},
+		},
+		{
+			name: "missing query parameter", method: "POST", path: "/api/v1/todos/1/revert",
+			fields: []openapi.FieldError{{Field: "to", In: "query", Message: "is required"}},
+		},
+		{
+			name: "nested field", method: "POST", path: "/api/v1/sync", body: `{"mutations": [{"op": "update", "data": {"title": 5}}]}`,
+			fields: []openapi.FieldError{{Field: "mutations[0].data.title", In: "body", Message: "got number, want string"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(t, r, tt.method, tt.path, tt.body)
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+			var resp struct {
+				Error  string               `json:"error"`
+				Fields []openapi.FieldError `json:"fields"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, "Request validation failed", resp.Error)
+			assert.Equal(t, tt.fields, resp.Fields)
+		})
+	}
+}
+
+// TestResponsesMatchSpec walks through the API with response validation on,
+// so a handler whose output drifts from the document fails with 500.
+func TestResponsesMatchSpec(t *testing.T) {
+	r := newRouter(t)
+
+	steps := []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/", "", http.StatusOK},
+		{"GET", "/health", "", http.StatusOK},
+		{"GET", "/api/v1/todos", "", http.StatusOK},
+		{"POST", "/api/v1/todos", `{"title": "Write spec", "project": "api"}`, http.StatusCreated},
+		{"POST", "/api/v1/todos", `{"title": "Review spec", "due_at": "2026-11-01T09:00:00Z"}`, http.StatusCreated},
+		{"GET", "/api/v1/todos?completed=false", "", http.StatusOK},
+		{"GET", "/api/v1/todos/1", "", http.StatusOK},
+		{"GET", "/api/v1/todos/99", "", http.StatusNotFound},
+		{"PUT", "/api/v1/todos/1", `{"description": "OpenAPI 3.1", "completed": false}`, http.StatusOK},
+		{"POST", "/api/v1/todos/1/toggle", "", http.StatusOK},
+		{"GET", "/api/v1/todos/stats", "", http.StatusOK},
+		{"GET", "/api/v1/todos/export?format=ics", "", http.StatusOK},
+		{"GET", "/api/v1/todos/export?format=pdf", "", http.StatusBadRequest},
+		{"POST", "/api/v1/todos/import?format=todotxt&dry_run=true", "(A) Dry run +api", http.StatusOK},
+		{"POST", "/api/v1/todos/import?format=csv", "title\nImported\n", http.StatusCreated},
+		{"POST", "/api/v1/todos/import?format=csv", "title\n\n\"\"\n", http.StatusUnprocessableEntity},
+		{"POST", "/api/v1/todos/import?format=trello", `{"id": "b1", "name": "Board", "cards": [{"id": "c1", "name": "Card"}]}`, http.StatusCreated},
+		{"POST", "/api/v1/todos/import?format=trello", `{"id": "b1", "name": "Board", "cards": [{"id": "c1", "name": "Card"}]}`, http.StatusCreated},
+		{"GET", "/api/v1/todos/1/history", "", http.StatusOK},
+		{"POST", "/api/v1/todos/1/revert?to=1", "", http.StatusOK},
+		{"POST", "/api/v1/todos/complete-all", "", http.StatusOK},
+		{"DELETE", "/api/v1/todos/2", "", http.StatusOK},
+		{"GET", "/api/v1/trash", "", http.StatusOK},
+		{"POST", "/api/v1/todos/2/restore", "", http.StatusOK},
+		{"DELETE", "/api/v1/todos/2", "", http.StatusOK},
+		{"DELETE", "/api/v1/trash/2", "", http.StatusOK},
+		{"DELETE", "/api/v1/trash/2", "", http.StatusNotFound},
+		{"POST", "/api/v1/undo/unknown", "", http.StatusNotFound},
+		{"POST", "/api/v1/webhooks", `{"url": "http://example.com/hook", "events": ["todo.created"]}`, http.StatusCreated},
+		{"GET", "/api/v1/webhooks", "", http.StatusOK},
+		{"GET", "/api/v1/webhooks/1", "", http.StatusOK},
+		{"PUT", "/api/v1/webhooks/1", `{"active": true}`, http.StatusOK},
+		{"GET", "/api/v1/webhooks/1/deliveries?limit=5", "", http.StatusOK},
+		{"DELETE", "/api/v1/webhooks/1", "", http.StatusOK},
+		{"GET", "/api/v1/outbox/stats", "", http.StatusOK},
+		{"GET", "/api/v1/sync", "", http.StatusOK},
+		{"POST", "/api/v1/sync", `{"mutations": [{"client_id": "tmp", "op": "create", "data": {"title": "Offline"}}, {"op": "delete", "id": 99}]}`, http.StatusOK},
+		{"POST", "/graphql", `{"query": "{ stats { total } }"}`, http.StatusOK},
+	}
+
+	for _, step := range steps {
+		w := do(t, r, step.method, step.path, step.body)
+		assert.Equal(t, step.status, w.Code, "%s %s: %s", step.method, step.path, w.Body.String())
+	}
+}
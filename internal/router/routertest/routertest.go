@@ -0,0 +1,64 @@
+// Package routertest serves the API on memory storage for tests.
+package routertest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stavagg/petGoApi/internal/caldav"
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/graph"
+	"github.com/stavagg/petGoApi/internal/handler"
+	"github.com/stavagg/petGoApi/internal/outbox"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/router"
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// New returns the router with every handler on memory storage. The outbox
+// relay runs until the test ends, so changes reach the event streams.
+func New(t *testing.T) *gin.Engine {
+	t.Helper()
+	return NewWithOptions(t, router.Options{Storage: "memory", ValidateResponses: true})
+}
+
+// NewWithOptions is New with the given router options.
+func NewWithOptions(t *testing.T, opts router.Options) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	todoRepo := repository.NewMemoryTodoRepository()
+	bus := eventbus.NewBus(10)
+	relay := outbox.NewRelay(todoRepo.Outbox(), outbox.Options{Interval: time.Second, BatchSize: 10}, outbox.NewBusSink(bus))
+	todos := service.NewTodoService(todoRepo, todoRepo.Events(), relay)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go relay.Run(ctx)
+
+	syncService := service.NewSyncService(todos, todoRepo, 0)
+	// Skips resolving example.com, which tests cannot rely on.
+	webhooks := service.NewWebhookService(repository.NewMemoryWebhookRepository(), http.DefaultClient, service.WebhookOptions{AllowPrivateNetworks: true})
+	return router.New(opts, router.Handlers{
+		Todos:     handler.NewTodoHandler(todos, service.NewUndoService(todoRepo, relay, time.Minute)),
+		Events:    handler.NewEventHandler(bus, time.Second),
+		WebSocket: handler.NewWebSocketHandler(todos, bus, nil),
+		Webhooks:  handler.NewWebhookHandler(webhooks),
+		Outbox:    handler.NewOutboxHandler(relay),
+		Sync:      handler.NewSyncHandler(syncService),
+		GraphQL:   graph.NewHandler(todos, bus, graph.Options{ComplexityLimit: 1000}),
+		CalDAV:    caldav.NewHandler(todos, syncService),
+	})
+}
+
+// NewServer serves New over HTTP until the test ends.
+func NewServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(New(t))
+	t.Cleanup(srv.Close)
+	return srv
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+
+	"github.com/stavagg/petGoApi/internal/model"
+)
+
+const AnonymousActor = "anonymous"
+
+type actorKey struct{}
+
+// WithActor returns a context that attributes changes to actor in the
+// todo history.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return AnonymousActor
+}
+
+type userKey struct{}
+
+// WithUser returns a context for a request authenticated as user. Its
+// changes are attributed to the user, whatever actor the client names.
+func WithUser(ctx context.Context, user *model.User) context.Context {
+	return context.WithValue(WithActor(ctx, user.Name), userKey{}, user)
+}
+
+// UserFromContext returns the user the request was authenticated as, or
+// nil.
+func UserFromContext(ctx context.Context) *model.User {
+	user, _ := ctx.Value(userKey{}).(*model.User)
+	return user
+}
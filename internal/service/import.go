@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"gorm.io/gorm"
+)
+
+// importBatchSize is how many todos ImportTodos inserts per statement.
+const importBatchSize = 500
+
+// ImportTodos creates the todos of rows in one transaction, importBatchSize
+// at a time. Rows are first checked with the rules of CreateTodo. When a row
+// fails, or could not be parsed, nothing is created and the report lists
+// every rejected row. Rows whose external ID was imported before, also by
+// a concurrent import, are skipped, so importing the same export again
+// creates nothing. A dry run stops after the checks and reports the todos
+// the valid rows would create.
+func (s *TodoService) ImportTodos(ctx context.Context, rows []model.ImportRow, dryRun bool) (*model.ImportReport, error) {
+	report := &model.ImportReport{DryRun: dryRun, Total: len(rows), Todos: []model.Todo{}, Skipped: []string{}, Errors: []model.ImportError{}}
+
+	now := fieldTime()
+	todos := make([]model.Todo, 0, len(rows))
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		problem := row.Error
+		if problem == "" {
+			if err := validateCreate(row.Request); err != nil {
+				problem = err.Error()
+			}
+		}
+		if problem != "" {
+			report.Errors = append(report.Errors, model.ImportError{Line: row.Line, ExternalID: row.ExternalID, Error: problem})
+			continue
+		}
+		if row.ExternalID != "" {
+			if seen[row.ExternalID] {
+				report.Skipped = append(report.Skipped, row.ExternalID)
+				continue
+			}
+			seen[row.ExternalID] = true
+		}
+		todos = append(todos, importedTodo(row, now))
+	}
+
+	if dryRun {
+		fresh, skipped, err := withoutImported(s.repo, todos)
+		if err != nil {
+			return nil, errors.New("failed to check imported todos: " + err.Error())
+		}
+		report.Todos = fresh
+		report.Skipped = append(report.Skipped, skipped...)
+		return report, nil
+	}
+	if len(report.Errors) > 0 {
+		return report, nil
+	}
+
+	created := make([]model.Todo, 0, len(todos))
+	err := s.repo.Transaction(func(repo repository.TodoRepositoryInterface) error {
+		fresh, skipped, err := withoutImported(repo, todos)
+		if err != nil {
+			return err
+		}
+		report.Skipped = append(report.Skipped, skipped...)
+
+		for start := 0; start < len(fresh); start += importBatchSize {
+			batch := fresh[start:min(start+importBatchSize, len(fresh))]
+			if err := repo.CreateBatch(batch); err != nil {
+				return err
+			}
+			// A concurrent import may have created some of the todos since
+			// withoutImported checked them.
+			n := 0
+			for _, todo := range batch {
+				if todo.ID == 0 {
+					report.Skipped = append(report.Skipped, *todo.ExternalID)
+					continue
+				}
+				batch[n] = todo
+				n++
+			}
+			batch = batch[:n]
+			created = append(created, batch...)
+
+			messages := make([]*model.OutboxMessage, 0, len(batch))
+			events := make([]*model.TodoEvent, 0, len(batch))
+			for i := range batch {
+				message, err := outboxMessage(ctx, model.TodoCreated, model.TodoSnapshot{}, &batch[i])
+				if err != nil {
+					return err
+				}
+				messages = append(messages, message)
+				events = append(events, historyEvent(ctx, model.TodoCreated, model.TodoSnapshot{}, &batch[i]))
+			}
+			if err := repo.AppendOutbox(messages...); err != nil {
+				return err
+			}
+			if err := repo.AppendEvents(events); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.New("failed to import todos: " + err.Error())
+	}
+
+	wake(s.relay)
+	report.Todos = created
+	return report, nil
+}
+
+// CopiedMessages returns the outbox messages announcing todo, which was
+// copied from another database together with its history. A todo copied
+// into the trash is announced as created and then deleted, so subscribers
+// do not list it.
+func CopiedMessages(ctx context.Context, todo *model.Todo) ([]*model.OutboxMessage, error) {
+	live := *todo
+	live.DeletedAt = gorm.DeletedAt{}
+	created, err := outboxMessage(ctx, model.TodoCreated, model.TodoSnapshot{}, &live)
+	if err != nil {
+		return nil, err
+	}
+	if !todo.DeletedAt.Valid {
+		return []*model.OutboxMessage{created}, nil
+	}
+	deleted, err := outboxMessage(ctx, model.TodoDeleted, live.Snapshot(), todo)
+	if err != nil {
+		return nil, err
+	}
+	return []*model.OutboxMessage{created, deleted}, nil
+}
+
+// withoutImported drops the todos whose external ID already has a todo,
+// trashed ones included, and returns the dropped IDs.
+func withoutImported(repo repository.TodoRepositoryInterface, todos []model.Todo) ([]model.Todo, []string, error) {
+	var ids []string
+	for _, todo := range todos {
+		if todo.ExternalID != nil {
+			ids = append(ids, *todo.ExternalID)
+		}
+	}
+
+	imported := make(map[string]bool)
+	for start := 0; start < len(ids); start += importBatchSize {
+		existing, err := repo.GetByExternalIDs(ids[start:min(start+importBatchSize, len(ids))])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, todo := range existing {
+			imported[*todo.ExternalID] = true
+		}
+	}
+	if len(imported) == 0 {
+		return todos, nil, nil
+	}
+
+	fresh := make([]model.Todo, 0, len(todos)-len(imported))
+	skipped := make([]string, 0, len(imported))
+	for _, todo := range todos {
+		if todo.ExternalID != nil && imported[*todo.ExternalID] {
+			skipped = append(skipped, *todo.ExternalID)
+			continue
+		}
+		fresh = append(fresh, todo)
+	}
+	return fresh, skipped, nil
+}
+
+// importedTodo builds the todo of a valid row. Times from the file are kept,
+// so an imported todo shows when it was created and completed.
+func importedTodo(row model.ImportRow, now time.Time) model.Todo {
+	todo := model.Todo{
+		Title:       row.Request.Title,
+		Description: row.Request.Description,
+		Project:     row.Request.Project,
+		DueAt:       row.Request.DueAt,
+		Completed:   row.Completed,
+		Version:     1,
+	}
+	if row.ExternalID != "" {
+		id := row.ExternalID
+		todo.ExternalID = &id
+	}
+	if row.CreatedAt != nil {
+		todo.CreatedAt = *row.CreatedAt
+	}
+	todo.Touch(model.TodoSnapshot{}, now)
+	if row.Completed && row.CompletedAt != nil {
+		todo.FieldTimes["completed"] = *row.CompletedAt
+	}
+	return todo
+}
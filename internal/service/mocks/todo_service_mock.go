@@ -1,6 +1,9 @@
 package mocks
 
 import (
+	"context"
+	"time"
+
 	"github.com/stavagg/petGoApi/internal/model"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,52 +12,132 @@ type TodoServiceMock struct {
 	mock.Mock
 }
 
-func (m *TodoServiceMock) CreateTodo(req model.CreateTodoRequest) (*model.Todo, error) {
+func (m *TodoServiceMock) CreateTodo(ctx context.Context, req model.CreateTodoRequest) (*model.Todo, error) {
 	args := m.Called(req)
 	return args.Get(0).(*model.Todo), args.Error(1)
 }
 
-func (m *TodoServiceMock) GetAllTodos() ([]model.Todo, error) {
+func (m *TodoServiceMock) GetAllTodos(ctx context.Context) ([]model.Todo, error) {
 	args := m.Called()
 	return args.Get(0).([]model.Todo), args.Error(1)
 }
 
-func (m *TodoServiceMock) GetTodoByID(id uint) (*model.Todo, error) {
+func (m *TodoServiceMock) GetTodoByID(ctx context.Context, id uint) (*model.Todo, error) {
 	args := m.Called(id)
 	return args.Get(0).(*model.Todo), args.Error(1)
 }
 
-func (m *TodoServiceMock) UpdateTodo(id uint, req model.UpdateTodoRequest) (*model.Todo, error) {
+func (m *TodoServiceMock) GetTodoByExternalID(ctx context.Context, externalID string) (*model.Todo, error) {
+	args := m.Called(externalID)
+	return args.Get(0).(*model.Todo), args.Error(1)
+}
+
+func (m *TodoServiceMock) UpdateTodo(ctx context.Context, id uint, req model.UpdateTodoRequest) (*model.Todo, error) {
 	args := m.Called(id, req)
 	return args.Get(0).(*model.Todo), args.Error(1)
 }
 
-func (m *TodoServiceMock) DeleteTodo(id uint) error {
+func (m *TodoServiceMock) DeleteTodo(ctx context.Context, id uint) (*model.Todo, error) {
 	args := m.Called(id)
-	return args.Error(0)
+	return args.Get(0).(*model.Todo), args.Error(1)
 }
 
-func (m *TodoServiceMock) GetTodosByCompleted(completed bool) ([]model.Todo, error) {
+func (m *TodoServiceMock) GetTodosByCompleted(ctx context.Context, completed bool) ([]model.Todo, error) {
 	args := m.Called(completed)
 	return args.Get(0).([]model.Todo), args.Error(1)
 }
 
-func (m *TodoServiceMock) GetStats() (map[string]interface{}, error) {
+func (m *TodoServiceMock) ListTodos(ctx context.Context, completed *bool, page *model.Pagination) ([]model.Todo, int, error) {
+	args := m.Called(completed, page)
+	return args.Get(0).([]model.Todo), args.Int(1), args.Error(2)
+}
+
+func (m *TodoServiceMock) GetTodosByProject(ctx context.Context, project string) ([]model.Todo, error) {
+	args := m.Called(project)
+	return args.Get(0).([]model.Todo), args.Error(1)
+}
+
+func (m *TodoServiceMock) GetProjects(ctx context.Context) ([]model.ProjectSummary, error) {
+	args := m.Called()
+	return args.Get(0).([]model.ProjectSummary), args.Error(1)
+}
+
+func (m *TodoServiceMock) ExportTodos(ctx context.Context, completed *bool, fn func(todo model.Todo) error) error {
+	args := m.Called(completed, fn)
+	return args.Error(0)
+}
+
+func (m *TodoServiceMock) ImportTodos(ctx context.Context, rows []model.ImportRow, dryRun bool) (*model.ImportReport, error) {
+	args := m.Called(rows, dryRun)
+	return args.Get(0).(*model.ImportReport), args.Error(1)
+}
+
+func (m *TodoServiceMock) GetStats(ctx context.Context) (map[string]interface{}, error) {
 	args := m.Called()
 	return args.Get(0).(map[string]interface{}), args.Error(1)
 }
 
-func (m *TodoServiceMock) ToggleTodo(id uint) (*model.Todo, error) {
+func (m *TodoServiceMock) ToggleTodo(ctx context.Context, id uint) (*model.Todo, error) {
 	args := m.Called(id)
 	return args.Get(0).(*model.Todo), args.Error(1)
 }
 
-func (m *TodoServiceMock) MarkAllCompleted() error {
+func (m *TodoServiceMock) MarkAllCompleted(ctx context.Context) ([]model.Todo, error) {
+	args := m.Called()
+	return args.Get(0).([]model.Todo), args.Error(1)
+}
+
+func (m *TodoServiceMock) DeleteCompleted(ctx context.Context) error {
 	args := m.Called()
 	return args.Error(0)
 }
 
-func (m *TodoServiceMock) DeleteCompleted() error {
+func (m *TodoServiceMock) GetTrash(ctx context.Context) ([]model.Todo, error) {
 	args := m.Called()
+	return args.Get(0).([]model.Todo), args.Error(1)
+}
+
+func (m *TodoServiceMock) ListTrash(ctx context.Context, page *model.Pagination) ([]model.Todo, int, error) {
+	args := m.Called(page)
+	return args.Get(0).([]model.Todo), args.Int(1), args.Error(2)
+}
+
+func (m *TodoServiceMock) RestoreTodo(ctx context.Context, id uint) (*model.Todo, error) {
+	args := m.Called(id)
+	return args.Get(0).(*model.Todo), args.Error(1)
+}
+
+func (m *TodoServiceMock) PurgeTodo(ctx context.Context, id uint) error {
+	args := m.Called(id)
 	return args.Error(0)
 }
+
+func (m *TodoServiceMock) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	args := m.Called(retention)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *TodoServiceMock) GetHistory(ctx context.Context, id uint) ([]model.TodoEvent, error) {
+	args := m.Called(id)
+	return args.Get(0).([]model.TodoEvent), args.Error(1)
+}
+
+func (m *TodoServiceMock) ListHistory(ctx context.Context, id uint, page *model.Pagination) ([]model.TodoEvent, int, error) {
+	args := m.Called(id, page)
+	return args.Get(0).([]model.TodoEvent), args.Int(1), args.Error(2)
+}
+
+func (m *TodoServiceMock) RevertTodo(ctx context.Context, id uint, revision uint) (*model.Todo, error) {
+	args := m.Called(id, revision)
+	return args.Get(0).(*model.Todo), args.Error(1)
+}
+
+func (m *TodoServiceMock) MergeTodo(ctx context.Context, id uint, req model.UpdateTodoRequest, base time.Time) (*model.Todo, []model.FieldConflict, error) {
+	args := m.Called(id, req, base)
+	return args.Get(0).(*model.Todo), args.Get(1).([]model.FieldConflict), args.Error(2)
+}
+
+func (m *TodoServiceMock) GetHistories(ctx context.Context, ids []uint) (map[uint][]model.TodoEvent, error) {
+	args := m.Called(ids)
+	return args.Get(0).(map[uint][]model.TodoEvent), args.Error(1)
+}
@@ -0,0 +1,23 @@
+package mocks
+
+import (
+	"context"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/mock"
+)
+
+type UndoServiceMock struct {
+	mock.Mock
+}
+
+func (m *UndoServiceMock) Remember(action string, todos []model.Todo) *service.UndoToken {
+	args := m.Called(action, todos)
+	return args.Get(0).(*service.UndoToken)
+}
+
+func (m *UndoServiceMock) Undo(ctx context.Context, token string) ([]model.Todo, error) {
+	args := m.Called(token)
+	return args.Get(0).([]model.Todo), args.Error(1)
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+)
+
+type SyncServiceInterface interface {
+	Token() string
+	Changes(ctx context.Context, token string, limit int) (*model.SyncChanges, error)
+	Push(ctx context.Context, mutations []model.SyncMutation) ([]model.SyncResult, error)
+}
+
+var ErrInvalidSyncToken = errors.New("invalid sync token")
+
+// syncCursor is the position in the change feed: the updated_at and ID of
+// the last change a client has seen.
+type syncCursor struct {
+	at time.Time
+	id uint
+}
+
+// SyncService serves the delta sync protocol for offline-first clients.
+// Changes are read by updated_at, so the token never runs ahead of now minus
+// overlap: a transaction that commits late with an older updated_at is still
+// picked up by the next pull. Clients must therefore accept todos they have
+// already seen and keep the one with the higher version.
+type SyncService struct {
+	todos   *TodoService
+	repo    repository.TodoRepositoryInterface
+	overlap time.Duration
+}
+
+func NewSyncService(todos *TodoService, repo repository.TodoRepositoryInterface, overlap time.Duration) *SyncService {
+	return &SyncService{todos: todos, repo: repo, overlap: overlap}
+}
+
+// Token returns a change token for the current state, for clients that
+// load the todos some other way and only pull changes from then on. Like
+// the last token of Changes it is held back by the overlap window.
+func (s *SyncService) Token() string {
+	return encodeSyncToken(syncCursor{at: time.Now().Add(-s.overlap)})
+}
+
+// Changes returns up to limit todos that changed after token. An empty token
+// starts from the beginning.
+func (s *SyncService) Changes(ctx context.Context, token string, limit int) (*model.SyncChanges, error) {
+	since, err := decodeSyncToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	todos, err := s.repo.GetChangedSince(since.at, since.id, limit+1)
+	if err != nil {
+		return nil, errors.New("failed to get changes: " + err.Error())
+	}
+
+	changes := &model.SyncChanges{
+		Created: []model.Todo{},
+		Updated: []model.Todo{},
+		Deleted: []model.SyncTombstone{},
+	}
+	if len(todos) > limit {
+		todos = todos[:limit]
+		changes.HasMore = true
+	}
+
+	next := since
+	for _, todo := range todos {
+		switch {
+		case todo.DeletedAt.Valid:
+			changes.Deleted = append(changes.Deleted, model.SyncTombstone{ID: todo.ID, ExternalID: deref(todo.ExternalID), Project: todo.Project, DeletedAt: todo.DeletedAt.Time})
+		case todo.CreatedAt.After(since.at):
+			changes.Created = append(changes.Created, todo)
+		default:
+			changes.Updated = append(changes.Updated, todo)
+		}
+		next = syncCursor{at: todo.UpdatedAt, id: todo.ID}
+	}
+
+	// A client without a token has nothing to delete yet.
+	if token != "" {
+		purged, err := s.repo.GetPurgedSince(since.at)
+		if err != nil {
+			return nil, errors.New("failed to get changes: " + err.Error())
+		}
+		for _, tombstone := range purged {
+			changes.Deleted = append(changes.Deleted, model.SyncTombstone{ID: tombstone.TodoID, ExternalID: deref(tombstone.ExternalID), Project: tombstone.Project, DeletedAt: tombstone.PurgedAt})
+		}
+	}
+
+	if !changes.HasMore {
+		// Hold the token back by the overlap window so changes that are
+		// still committing are not skipped.
+		horizon := time.Now().Add(-s.overlap)
+		if next.at.After(horizon) {
+			next = syncCursor{at: horizon}
+		}
+		if next.at.Before(since.at) {
+			next = since
+		}
+	}
+	changes.Token = encodeSyncToken(next)
+	return changes, nil
+}
+
+// Push applies client mutations in order. Every mutation gets its own
+// result; a conflict or a rejected mutation does not stop the rest of the
+// batch.
+func (s *SyncService) Push(ctx context.Context, mutations []model.SyncMutation) ([]model.SyncResult, error) {
+	results := make([]model.SyncResult, 0, len(mutations))
+	for _, mutation := range mutations {
+		results = append(results, s.apply(ctx, mutation))
+	}
+	return results, nil
+}
+
+func (s *SyncService) apply(ctx context.Context, mutation model.SyncMutation) model.SyncResult {
+	result := model.SyncResult{ClientID: mutation.ClientID, Op: mutation.Op, ID: mutation.ID}
+
+	var todo *model.Todo
+	var err error
+	switch mutation.Op {
+	case model.SyncCreate:
+		todo, err = s.create(ctx, mutation.Data)
+	case model.SyncUpdate:
+		if mutation.BaseUpdatedAt != nil {
+			return s.merge(ctx, mutation, result)
+		}
+		todo, err = s.todos.updateIf(ctx, mutation.ID, mutation.Data, model.TodoUpdated, baseCheck(mutation))
+	case model.SyncDelete:
+		todo, err = s.delete(ctx, mutation)
+	default:
+		err = fmt.Errorf("unknown op %q", mutation.Op)
+	}
+
+	switch {
+	case err == nil:
+		result.Status = model.SyncApplied
+		result.ID = todo.ID
+		if mutation.Op != model.SyncDelete {
+			result.Todo = todo
+		}
+	case errors.Is(err, ErrVersionConflict):
+		result.Status = model.SyncConflict
+		result.Error = err.Error()
+		result.Todo = s.current(mutation.ID)
+	case errors.Is(err, ErrTodoNotFound):
+		result.Status = model.SyncNotFound
+		result.Error = err.Error()
+	default:
+		result.Status = model.SyncRejected
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// merge applies an update field by field against the state at
+// BaseUpdatedAt. Fields both sides changed are reported as conflicts while
+// the rest of the update still applies, which makes the result merged; it
+// is a conflict only when nothing applied.
+func (s *SyncService) merge(ctx context.Context, mutation model.SyncMutation, result model.SyncResult) model.SyncResult {
+	todo, conflicts, err := s.todos.MergeTodo(ctx, mutation.ID, mutation.Data, *mutation.BaseUpdatedAt)
+	switch {
+	case err == nil && len(conflicts) == 0:
+		result.Status = model.SyncApplied
+	case err == nil && len(conflicts) < len(setFields(mutation.Data)):
+		result.Status = model.SyncMerged
+		result.Conflicts = conflicts
+	case err == nil:
+		result.Status = model.SyncConflict
+		result.Error = ErrVersionConflict.Error()
+		result.Conflicts = conflicts
+	case errors.Is(err, ErrTodoNotFound):
+		result.Status = model.SyncNotFound
+		result.Error = err.Error()
+		return result
+	default:
+		result.Status = model.SyncRejected
+		result.Error = err.Error()
+		return result
+	}
+	result.Todo = todo
+	return result
+}
+
+// create adds a todo made offline. A todo that was completed before it
+// reached the server is created and then updated, as it would have been
+// online.
+func (s *SyncService) create(ctx context.Context, data model.UpdateTodoRequest) (*model.Todo, error) {
+	todo, err := s.todos.CreateTodo(ctx, model.CreateTodoRequest{
+		Title:       data.Title,
+		Description: data.Description,
+		Project:     data.Project,
+		DueAt:       data.DueAt,
+	})
+	if err != nil || data.Completed == nil || !*data.Completed {
+		return todo, err
+	}
+	return s.todos.update(ctx, todo.ID, model.UpdateTodoRequest{Completed: data.Completed}, model.TodoUpdated)
+}
+
+func (s *SyncService) delete(ctx context.Context, mutation model.SyncMutation) (*model.Todo, error) {
+	return s.todos.deleteIf(ctx, mutation.ID, baseCheck(mutation))
+}
+
+// current returns the server state of a todo for a conflict result.
+func (s *SyncService) current(id uint) *model.Todo {
+	todo, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil
+	}
+	return todo
+}
+
+// baseCheck rejects a mutation made against a state of the todo that is no
+// longer current. Without a base the mutation always applies. Updates with
+// BaseUpdatedAt are merged instead, see merge.
+//
+// Timestamps are compared to the microsecond, which is all Postgres keeps,
+// so a base a client got from a todo that was just written still matches
+// the stored one.
+func baseCheck(mutation model.SyncMutation) func(current *model.Todo) error {
+	return func(current *model.Todo) error {
+		if mutation.BaseVersion != 0 && current.Version != mutation.BaseVersion {
+			return ErrVersionConflict
+		}
+		if mutation.BaseUpdatedAt != nil && !sameInstant(current.UpdatedAt, *mutation.BaseUpdatedAt) {
+			return ErrVersionConflict
+		}
+		return nil
+	}
+}
+
+func sameInstant(a, b time.Time) bool {
+	return a.Truncate(time.Microsecond).Equal(b.Truncate(time.Microsecond))
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func encodeSyncToken(cursor syncCursor) string {
+	raw := strconv.FormatInt(cursor.at.UnixNano(), 10) + "." + strconv.FormatUint(uint64(cursor.id), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSyncToken(token string) (syncCursor, error) {
+	if token == "" {
+		return syncCursor{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return syncCursor{}, ErrInvalidSyncToken
+	}
+	at, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return syncCursor{}, ErrInvalidSyncToken
+	}
+	nanos, err := strconv.ParseInt(at, 10, 64)
+	if err != nil {
+		return syncCursor{}, ErrInvalidSyncToken
+	}
+	todoID, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return syncCursor{}, ErrInvalidSyncToken
+	}
+	return syncCursor{at: time.Unix(0, nanos), id: uint(todoID)}, nil
+}
+
+// setFields lists the fields req sets. Those not reported as conflicts by a
+// merge hold the client value afterwards.
+func setFields(req model.UpdateTodoRequest) []string {
+	fields := []string{}
+	if req.Title != "" {
+		fields = append(fields, "title")
+	}
+	if req.Description != "" || req.ClearDescription {
+		fields = append(fields, "description")
+	}
+	if req.Project != "" {
+		fields = append(fields, "project")
+	}
+	if req.Completed != nil {
+		fields = append(fields, "completed")
+	}
+	if req.DueAt != nil || req.ClearDueAt {
+		fields = append(fields, "due_at")
+	}
+	return fields
+}
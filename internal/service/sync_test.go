@@ -0,0 +1,329 @@
+package service_test
+
+import (
+	"context"
+	"fmt"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSyncFixture() (*service.TodoService, *service.SyncService) {
+	repo := repository.NewMemoryTodoRepository()
+	todos := service.NewTodoService(repo, repo.Events(), nil)
+	return todos, service.NewSyncService(todos, repo, 0)
+}
+
+func TestSync_ChangesSinceToken(t *testing.T) {
+	ctx := context.Background()
+	todos, sync := newSyncFixture()
+
+	kept, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Kept"})
+	require.NoError(t, err)
+	gone, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Gone"})
+	require.NoError(t, err)
+
+	initial, err := sync.Changes(ctx, "", 100)
+	require.NoError(t, err)
+	assert.Len(t, initial.Created, 2)
+	assert.False(t, initial.HasMore)
+	require.NotEmpty(t, initial.Token)
+
+	time.Sleep(time.Millisecond)
+	title := "Kept, renamed"
+	_, err = todos.UpdateTodo(ctx, kept.ID, model.UpdateTodoRequest{Title: title})
+	require.NoError(t, err)
+	_, err = todos.DeleteTodo(ctx, gone.ID)
+	require.NoError(t, err)
+	fresh, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Fresh"})
+	require.NoError(t, err)
+
+	delta, err := sync.Changes(ctx, initial.Token, 100)
+	require.NoError(t, err)
+	require.Len(t, delta.Created, 1)
+	assert.Equal(t, fresh.ID, delta.Created[0].ID)
+	require.Len(t, delta.Updated, 1)
+	assert.Equal(t, title, delta.Updated[0].Title)
+	require.Len(t, delta.Deleted, 1)
+	assert.Equal(t, gone.ID, delta.Deleted[0].ID)
+
+	time.Sleep(time.Millisecond)
+	require.NoError(t, todos.PurgeTodo(ctx, gone.ID))
+	purged, err := sync.Changes(ctx, delta.Token, 100)
+	require.NoError(t, err)
+	require.Len(t, purged.Deleted, 1, "purged todos are reported through tombstones")
+	assert.Equal(t, gone.ID, purged.Deleted[0].ID)
+}
+
+func TestSync_ChangesPages(t *testing.T) {
+	ctx := context.Background()
+	todos, sync := newSyncFixture()
+
+	for _, title := range []string{"A", "B", "C"} {
+		_, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: title})
+		require.NoError(t, err)
+	}
+
+	first, err := sync.Changes(ctx, "", 2)
+	require.NoError(t, err)
+	assert.Len(t, first.Created, 2)
+	assert.True(t, first.HasMore)
+
+	second, err := sync.Changes(ctx, first.Token, 2)
+	require.NoError(t, err)
+	require.Len(t, second.Created, 1)
+	assert.Equal(t, "C", second.Created[0].Title)
+	assert.False(t, second.HasMore)
+}
+
+func TestSync_ChangesInvalidToken(t *testing.T) {
+	_, sync := newSyncFixture()
+
+	_, err := sync.Changes(context.Background(), "not a token", 10)
+	assert.ErrorIs(t, err, service.ErrInvalidSyncToken)
+}
+
+func TestSync_PushDetectsConflicts(t *testing.T) {
+	ctx := context.Background()
+	todos, sync := newSyncFixture()
+
+	todo, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Shared"})
+	require.NoError(t, err)
+	_, err = todos.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{Title: "Changed online"})
+	require.NoError(t, err)
+
+	completed := true
+	results, err := sync.Push(ctx, []model.SyncMutation{
+		{ClientID: "tmp-1", Op: model.SyncCreate, Data: model.UpdateTodoRequest{Title: "Offline", Completed: &completed}},
+		{Op: model.SyncUpdate, ID: todo.ID, BaseVersion: 1, Data: model.UpdateTodoRequest{Title: "Changed offline"}},
+		{Op: model.SyncUpdate, ID: todo.ID, BaseVersion: 2, Data: model.UpdateTodoRequest{Project: "home"}},
+		{Op: model.SyncDelete, ID: 999},
+		{Op: model.SyncCreate},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	assert.Equal(t, model.SyncApplied, results[0].Status)
+	assert.Equal(t, "tmp-1", results[0].ClientID)
+	require.NotNil(t, results[0].Todo)
+	assert.True(t, results[0].Todo.Completed)
+
+	assert.Equal(t, model.SyncConflict, results[1].Status)
+	require.NotNil(t, results[1].Todo, "conflicts carry the server state")
+	assert.Equal(t, "Changed online", results[1].Todo.Title)
+
+	assert.Equal(t, model.SyncApplied, results[2].Status)
+	assert.Equal(t, "home", results[2].Todo.Project)
+	assert.Equal(t, "Changed online", results[2].Todo.Title)
+
+	assert.Equal(t, model.SyncNotFound, results[3].Status)
+	assert.Equal(t, model.SyncRejected, results[4].Status)
+}
+
+func TestSync_PushDeleteChecksBase(t *testing.T) {
+	ctx := context.Background()
+	todos, sync := newSyncFixture()
+
+	todo, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+
+	stale := todo.UpdatedAt.Add(-time.Minute)
+	results, err := sync.Push(ctx, []model.SyncMutation{
+		{Op: model.SyncDelete, ID: todo.ID, BaseUpdatedAt: &stale},
+		{Op: model.SyncDelete, ID: todo.ID, BaseUpdatedAt: &todo.UpdatedAt},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.SyncConflict, results[0].Status)
+	assert.Equal(t, model.SyncApplied, results[1].Status)
+
+	_, err = todos.GetTodoByID(ctx, todo.ID)
+	assert.ErrorIs(t, err, service.ErrTodoNotFound)
+}
+
+func TestSync_PushDeleteComparesBaseToTheMicrosecond(t *testing.T) {
+	ctx := context.Background()
+	todos, sync := newSyncFixture()
+
+	todo, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+
+	// Postgres returns updated_at with microseconds only.
+	base := todo.UpdatedAt.Truncate(time.Microsecond)
+	results, err := sync.Push(ctx, []model.SyncMutation{{Op: model.SyncDelete, ID: todo.ID, BaseUpdatedAt: &base}})
+	require.NoError(t, err)
+	assert.Equal(t, model.SyncApplied, results[0].Status)
+}
+
+func TestSync_ConcurrentPushesWithSameBaseApplyOnce(t *testing.T) {
+	ctx := context.Background()
+	todos, sync := newSyncFixture()
+
+	todo, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+
+	const clients = 8
+	statuses := make(chan string, clients)
+	var wg gosync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results, err := sync.Push(ctx, []model.SyncMutation{{
+				Op:          model.SyncUpdate,
+				ID:          todo.ID,
+				BaseVersion: todo.Version,
+				Data:        model.UpdateTodoRequest{Title: fmt.Sprintf("Edit %d", i)},
+			}})
+			assert.NoError(t, err)
+			statuses <- results[0].Status
+		}(i)
+	}
+	wg.Wait()
+	close(statuses)
+
+	applied := 0
+	for status := range statuses {
+		if status == model.SyncApplied {
+			applied++
+		} else {
+			assert.Equal(t, model.SyncConflict, status)
+		}
+	}
+	assert.Equal(t, 1, applied)
+
+	got, err := todos.GetTodoByID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, todo.Version+1, got.Version)
+}
+
+func TestMergeTodo_FieldByField(t *testing.T) {
+	ctx := context.Background()
+	todos, _ := newSyncFixture()
+
+	todo, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Draft", Description: "old"})
+	require.NoError(t, err)
+	base := todo.UpdatedAt
+
+	time.Sleep(time.Millisecond)
+	online, err := todos.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{Title: "Online title", Description: "same"})
+	require.NoError(t, err)
+	assert.True(t, online.FieldTimes["title"].After(base))
+	assert.Equal(t, todo.FieldTimes["project"], online.FieldTimes["project"], "untouched fields keep their time")
+
+	completed := true
+	merged, conflicts, err := todos.MergeTodo(ctx, todo.ID, model.UpdateTodoRequest{
+		Title:       "Offline title",
+		Description: "same",
+		Project:     "home",
+		Completed:   &completed,
+	}, base)
+	require.NoError(t, err)
+
+	require.Len(t, conflicts, 1, "only the title was changed to different values on both sides")
+	assert.Equal(t, "title", conflicts[0].Field)
+	assert.Equal(t, "Online title", conflicts[0].Server)
+	assert.Equal(t, "Offline title", conflicts[0].Client)
+
+	assert.Equal(t, "Online title", merged.Title)
+	assert.Equal(t, "same", merged.Description)
+	assert.Equal(t, "home", merged.Project)
+	assert.True(t, merged.Completed)
+}
+
+func TestMergeTodo_NothingToApply(t *testing.T) {
+	ctx := context.Background()
+	todos, _ := newSyncFixture()
+
+	todo, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+
+	merged, conflicts, err := todos.MergeTodo(ctx, todo.ID, model.UpdateTodoRequest{Title: "Task"}, todo.UpdatedAt)
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, todo.Version, merged.Version, "an edit that changes nothing is not written")
+
+	_, _, err = todos.MergeTodo(ctx, 999, model.UpdateTodoRequest{Title: "x"}, time.Now())
+	assert.ErrorIs(t, err, service.ErrTodoNotFound)
+}
+
+func TestSync_PushMergesConcurrentEdits(t *testing.T) {
+	ctx := context.Background()
+	todos, sync := newSyncFixture()
+
+	todo, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Shared"})
+	require.NoError(t, err)
+	base := todo.UpdatedAt
+
+	time.Sleep(time.Millisecond)
+	_, err = todos.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{Project: "work"})
+	require.NoError(t, err)
+
+	results, err := sync.Push(ctx, []model.SyncMutation{
+		{Op: model.SyncUpdate, ID: todo.ID, BaseUpdatedAt: &base, Data: model.UpdateTodoRequest{Description: "offline notes"}},
+		{Op: model.SyncUpdate, ID: todo.ID, BaseUpdatedAt: &base, Data: model.UpdateTodoRequest{Project: "home"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, model.SyncApplied, results[0].Status, "different fields merge cleanly")
+	assert.Equal(t, "offline notes", results[0].Todo.Description)
+	assert.Equal(t, "work", results[0].Todo.Project)
+
+	assert.Equal(t, model.SyncConflict, results[1].Status, "nothing of the update applied")
+	require.Len(t, results[1].Conflicts, 1)
+	assert.Equal(t, "project", results[1].Conflicts[0].Field)
+	assert.Equal(t, "work", results[1].Todo.Project)
+}
+
+func TestSync_PushReportsPartialMergeAsMerged(t *testing.T) {
+	ctx := context.Background()
+	todos, sync := newSyncFixture()
+
+	todo, err := todos.CreateTodo(ctx, model.CreateTodoRequest{Title: "Shared"})
+	require.NoError(t, err)
+	base := todo.UpdatedAt
+
+	time.Sleep(time.Millisecond)
+	_, err = todos.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{Project: "work"})
+	require.NoError(t, err)
+
+	results, err := sync.Push(ctx, []model.SyncMutation{
+		{Op: model.SyncUpdate, ID: todo.ID, BaseUpdatedAt: &base, Data: model.UpdateTodoRequest{Description: "offline notes", Project: "home"}},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, model.SyncMerged, results[0].Status)
+	assert.Empty(t, results[0].Error)
+	require.Len(t, results[0].Conflicts, 1)
+	assert.Equal(t, "project", results[0].Conflicts[0].Field)
+	assert.Equal(t, "offline notes", results[0].Todo.Description)
+	assert.Equal(t, "work", results[0].Todo.Project)
+}
+
+func TestMergeTodo_TodoWithoutFieldTimesUsesUpdatedAt(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryTodoRepository()
+	todos := service.NewTodoService(repo, repo.Events(), nil)
+
+	updated := time.Now().UTC().Add(-time.Hour)
+	legacy := &model.Todo{Title: "Legacy", Version: 1, UpdatedAt: updated}
+	require.NoError(t, repo.Create(legacy))
+
+	stale := updated.Add(-time.Hour)
+	merged, conflicts, err := todos.MergeTodo(ctx, legacy.ID, model.UpdateTodoRequest{Title: "Offline"}, stale)
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1, "the title may have changed since the base")
+	assert.Equal(t, "title", conflicts[0].Field)
+	assert.Equal(t, "Legacy", merged.Title)
+
+	merged, conflicts, err = todos.MergeTodo(ctx, legacy.ID, model.UpdateTodoRequest{Project: "home"}, updated.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, conflicts)
+	assert.Equal(t, "home", merged.Project)
+	assert.Equal(t, updated.Unix(), merged.ModifiedAt("title").Unix(), "untouched fields keep their age")
+}
@@ -1,62 +1,121 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"time"
 
+	"github.com/stavagg/petGoApi/internal/eventbus"
 	"github.com/stavagg/petGoApi/internal/model"
 	"github.com/stavagg/petGoApi/internal/repository"
+	"gorm.io/gorm"
 )
 
 type TodoServiceInterface interface {
-	CreateTodo(req model.CreateTodoRequest) (*model.Todo, error)
-	GetAllTodos() ([]model.Todo, error)
-	GetTodoByID(id uint) (*model.Todo, error)
-	UpdateTodo(id uint, req model.UpdateTodoRequest) (*model.Todo, error)
-	DeleteTodo(id uint) error
-	GetTodosByCompleted(completed bool) ([]model.Todo, error)
-	GetStats() (map[string]interface{}, error)
-	ToggleTodo(id uint) (*model.Todo, error)
-	MarkAllCompleted() error
-	DeleteCompleted() error
+	CreateTodo(ctx context.Context, req model.CreateTodoRequest) (*model.Todo, error)
+	GetAllTodos(ctx context.Context) ([]model.Todo, error)
+	GetTodoByID(ctx context.Context, id uint) (*model.Todo, error)
+	GetTodoByExternalID(ctx context.Context, externalID string) (*model.Todo, error)
+	UpdateTodo(ctx context.Context, id uint, req model.UpdateTodoRequest) (*model.Todo, error)
+	DeleteTodo(ctx context.Context, id uint) (*model.Todo, error)
+	GetTodosByCompleted(ctx context.Context, completed bool) ([]model.Todo, error)
+	ListTodos(ctx context.Context, completed *bool, page *model.Pagination) ([]model.Todo, int, error)
+	GetTodosByProject(ctx context.Context, project string) ([]model.Todo, error)
+	GetProjects(ctx context.Context) ([]model.ProjectSummary, error)
+	ExportTodos(ctx context.Context, completed *bool, fn func(todo model.Todo) error) error
+	ImportTodos(ctx context.Context, rows []model.ImportRow, dryRun bool) (*model.ImportReport, error)
+	GetStats(ctx context.Context) (map[string]interface{}, error)
+	ToggleTodo(ctx context.Context, id uint) (*model.Todo, error)
+	MarkAllCompleted(ctx context.Context) ([]model.Todo, error)
+	DeleteCompleted(ctx context.Context) error
+	GetTrash(ctx context.Context) ([]model.Todo, error)
+	ListTrash(ctx context.Context, page *model.Pagination) ([]model.Todo, int, error)
+	RestoreTodo(ctx context.Context, id uint) (*model.Todo, error)
+	PurgeTodo(ctx context.Context, id uint) error
+	PurgeTrash(ctx context.Context, retention time.Duration) (int64, error)
+	GetHistory(ctx context.Context, id uint) ([]model.TodoEvent, error)
+	ListHistory(ctx context.Context, id uint, page *model.Pagination) ([]model.TodoEvent, int, error)
+	GetHistories(ctx context.Context, ids []uint) (map[uint][]model.TodoEvent, error)
+	RevertTodo(ctx context.Context, id uint, revision uint) (*model.Todo, error)
+	MergeTodo(ctx context.Context, id uint, req model.UpdateTodoRequest, base time.Time) (*model.Todo, []model.FieldConflict, error)
 }
 
-type TodoService struct {
-	repo repository.TodoRepositoryInterface
+var (
+	ErrTodoNotFound     = errors.New("todo not found")
+	ErrNotInTrash       = errors.New("todo not found in trash")
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrVersionConflict  = errors.New("todo was changed by someone else")
+	// ErrInvalidTodo is matched by the errors of requests that fail
+	// validation.
+	ErrInvalidTodo = errors.New("invalid todo")
+)
+
+// validationError is a request that fails validation. Its message is shown
+// as is, without the ErrInvalidTodo prefix.
+type validationError string
+
+func invalid(message string) error {
+	return validationError(message)
 }
 
-func NewTodoService(repo repository.TodoRepositoryInterface) *TodoService {
-	return &TodoService{repo: repo}
+func (e validationError) Error() string {
+	return string(e)
 }
 
-func (s *TodoService) CreateTodo(req model.CreateTodoRequest) (*model.Todo, error) {
+func (e validationError) Is(target error) bool {
+	return target == ErrInvalidTodo
+}
 
-	if req.Title == "" {
-		return nil, errors.New("title is required")
-	}
+// Waker is told that new outbox messages were committed, so they can be
+// relayed without waiting for the next poll.
+type Waker interface {
+	Wake()
+}
 
-	if len(req.Title) > 255 {
-		return nil, errors.New("title too long (max 255 characters)")
-	}
+type TodoService struct {
+	repo   repository.TodoRepositoryInterface
+	events repository.TodoEventRepositoryInterface
+	relay  Waker
+}
+
+// NewTodoService creates the service. relay may be nil when nothing needs
+// waking up, e.g. in tests.
+func NewTodoService(repo repository.TodoRepositoryInterface, events repository.TodoEventRepositoryInterface, relay Waker) *TodoService {
+	return &TodoService{repo: repo, events: events, relay: relay}
+}
 
-	if len(req.Description) > 1000 {
-		return nil, errors.New("description too long (max 1000 characters)")
+func (s *TodoService) CreateTodo(ctx context.Context, req model.CreateTodoRequest) (*model.Todo, error) {
+	if err := validateCreate(req); err != nil {
+		return nil, err
 	}
 
 	todo := &model.Todo{
 		Title:       req.Title,
 		Description: req.Description,
+		Project:     req.Project,
+		DueAt:       req.DueAt,
 		Completed:   false,
+		Version:     1,
 	}
 
-	err := s.repo.Create(todo)
+	err := s.commit(ctx, model.TodoCreated, model.TodoSnapshot{}, todo, func(repo repository.TodoRepositoryInterface) error {
+		return repo.Create(todo)
+	})
 	if err != nil {
 		return nil, errors.New("failed to create todo: " + err.Error())
 	}
-
 	return todo, nil
 }
 
-func (s *TodoService) GetAllTodos() ([]model.Todo, error) {
+// validateCreate checks the rules every new todo must meet.
+func validateCreate(req model.CreateTodoRequest) error {
+	if err := req.Validate(); err != nil {
+		return invalid(err.Error())
+	}
+	return nil
+}
+
+func (s *TodoService) GetAllTodos(ctx context.Context) ([]model.Todo, error) {
 	todos, err := s.repo.GetAll()
 	if err != nil {
 		return nil, errors.New("failed to get todos: " + err.Error())
@@ -64,78 +123,172 @@ func (s *TodoService) GetAllTodos() ([]model.Todo, error) {
 	return todos, nil
 }
 
-func (s *TodoService) GetTodoByID(id uint) (*model.Todo, error) {
+func (s *TodoService) GetTodoByID(ctx context.Context, id uint) (*model.Todo, error) {
 	if id == 0 {
-		return nil, errors.New("invalid todo ID")
+		return nil, invalid("invalid todo ID")
 	}
 
 	todo, err := s.repo.GetByID(id)
 	if err != nil {
-		return nil, errors.New("todo not found")
+		return nil, ErrTodoNotFound
 	}
 	return todo, nil
 }
 
-func (s *TodoService) UpdateTodo(id uint, req model.UpdateTodoRequest) (*model.Todo, error) {
+// GetTodoByExternalID returns the todo imported from another tracker under
+// externalID. Trashed todos are not found.
+func (s *TodoService) GetTodoByExternalID(ctx context.Context, externalID string) (*model.Todo, error) {
+	todos, err := s.repo.GetByExternalIDs([]string{externalID})
+	if err != nil {
+		return nil, errors.New("failed to get todo: " + err.Error())
+	}
+	if len(todos) == 0 || todos[0].DeletedAt.Valid {
+		return nil, ErrTodoNotFound
+	}
+	return &todos[0], nil
+}
 
-	todo, err := s.repo.GetByID(id)
+func (s *TodoService) UpdateTodo(ctx context.Context, id uint, req model.UpdateTodoRequest) (*model.Todo, error) {
+	return s.update(ctx, id, req, model.TodoUpdated)
+}
+
+func (s *TodoService) update(ctx context.Context, id uint, req model.UpdateTodoRequest, action string) (*model.Todo, error) {
+	return s.updateIf(ctx, id, req, action, nil)
+}
+
+// updateIf applies req like update. When check is set it runs against the
+// state of the todo the update applies to, and its error aborts the change;
+// ErrTodoNotFound and ErrVersionConflict are returned as is.
+func (s *TodoService) updateIf(ctx context.Context, id uint, req model.UpdateTodoRequest, action string, check func(current *model.Todo) error) (*model.Todo, error) {
+	if err := validateUpdate(req); err != nil {
+		return nil, err
+	}
+
+	todo, err := s.change(ctx, id, action, func(todo *model.Todo) error {
+		if check != nil {
+			if err := check(todo); err != nil {
+				return err
+			}
+		}
+		applyUpdate(todo, req)
+		return nil
+	})
 	if err != nil {
-		return nil, errors.New("todo not found")
+		if errors.Is(err, ErrTodoNotFound) || errors.Is(err, ErrVersionConflict) {
+			return nil, err
+		}
+		return nil, errors.New("failed to update todo: " + err.Error())
+	}
+	return todo, nil
+}
+
+func validateUpdate(req model.UpdateTodoRequest) error {
+	if err := req.Validate(); err != nil {
+		return invalid(err.Error())
 	}
+	return nil
+}
 
+func applyUpdate(todo *model.Todo, req model.UpdateTodoRequest) {
 	if req.Title != "" {
-		if len(req.Title) > 255 {
-			return nil, errors.New("title too long (max 255 characters)")
-		}
 		todo.Title = req.Title
 	}
-
 	if req.Description != "" {
-		if len(req.Description) > 1000 {
-			return nil, errors.New("description too long (max 1000 characters)")
-		}
 		todo.Description = req.Description
 	}
-
+	if req.ClearDescription {
+		todo.Description = ""
+	}
+	if req.Project != "" {
+		todo.Project = req.Project
+	}
 	if req.Completed != nil {
 		todo.Completed = *req.Completed
 	}
+	if req.DueAt != nil {
+		due := *req.DueAt
+		todo.DueAt = &due
+	}
+	if req.ClearDueAt {
+		todo.DueAt = nil
+	}
+}
+
+// DeleteTodo moves the todo to the trash and returns it as it was before
+// deletion.
+func (s *TodoService) DeleteTodo(ctx context.Context, id uint) (*model.Todo, error) {
+	if id == 0 {
+		return nil, invalid("invalid todo ID")
+	}
 
-	err = s.repo.Update(todo)
+	todo, err := s.repo.GetByID(id)
 	if err != nil {
-		return nil, errors.New("failed to update todo: " + err.Error())
+		return nil, ErrTodoNotFound
 	}
 
+	if err := s.commitDelete(ctx, todo); err != nil {
+		return nil, errors.New("failed to delete todo: " + err.Error())
+	}
 	return todo, nil
 }
 
-func (s *TodoService) DeleteTodo(id uint) error {
-	if id == 0 {
-		return errors.New("invalid todo ID")
+func (s *TodoService) GetTodosByCompleted(ctx context.Context, completed bool) ([]model.Todo, error) {
+	todos, err := s.repo.GetByCompleted(completed)
+	if err != nil {
+		return nil, errors.New("failed to get todos by status: " + err.Error())
 	}
+	return todos, nil
+}
 
-	_, err := s.repo.GetByID(id)
+// ListTodos returns the todos matching completed, every todo when it is
+// nil, on page, and how many match in all. Without a page it returns all
+// of them.
+func (s *TodoService) ListTodos(ctx context.Context, completed *bool, page *model.Pagination) ([]model.Todo, int, error) {
+	todos, total, err := s.repo.GetPage(completed, page)
 	if err != nil {
-		return errors.New("todo not found")
+		return nil, 0, errors.New("failed to get todos: " + err.Error())
 	}
+	return todos, int(total), nil
+}
 
-	err = s.repo.Delete(id)
+// GetTodosByProject returns the todos of a project in ID order.
+func (s *TodoService) GetTodosByProject(ctx context.Context, project string) ([]model.Todo, error) {
+	todos, err := s.repo.GetByProject(project)
 	if err != nil {
-		return errors.New("failed to delete todo: " + err.Error())
+		return nil, errors.New("failed to get todos by project: " + err.Error())
 	}
+	return todos, nil
+}
 
-	return nil
+// GetProjects sums up every project with todos, by name.
+func (s *TodoService) GetProjects(ctx context.Context) ([]model.ProjectSummary, error) {
+	projects, err := s.repo.GetProjects()
+	if err != nil {
+		return nil, errors.New("failed to get projects: " + err.Error())
+	}
+	return projects, nil
 }
 
-func (s *TodoService) GetTodosByCompleted(completed bool) ([]model.Todo, error) {
-	todos, err := s.repo.GetByCompleted(completed)
+// exportBatchSize is how many todos ExportTodos reads at a time.
+const exportBatchSize = 500
+
+// ExportTodos calls fn for every todo matching completed, all of them when
+// it is nil, oldest first. Todos are read in batches, so exporting a large
+// table does not load it into memory.
+func (s *TodoService) ExportTodos(ctx context.Context, completed *bool, fn func(todo model.Todo) error) error {
+	err := s.repo.ForEach(completed, exportBatchSize, func(todo model.Todo) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fn(todo)
+	})
 	if err != nil {
-		return nil, errors.New("failed to get todos by status: " + err.Error())
+		return errors.New("failed to export todos: " + err.Error())
 	}
-	return todos, nil
+	return nil
 }
 
-func (s *TodoService) GetStats() (map[string]interface{}, error) {
+func (s *TodoService) GetStats(ctx context.Context) (map[string]interface{}, error) {
 	allTodos, err := s.repo.GetAll()
 	if err != nil {
 		return nil, errors.New("failed to get statistics: " + err.Error())
@@ -167,51 +320,498 @@ func (s *TodoService) GetStats() (map[string]interface{}, error) {
 	return stats, nil
 }
 
-func (s *TodoService) ToggleTodo(id uint) (*model.Todo, error) {
-	todo, err := s.repo.GetByID(id)
+func (s *TodoService) ToggleTodo(ctx context.Context, id uint) (*model.Todo, error) {
+	todo, err := s.change(ctx, id, model.TodoToggled, func(todo *model.Todo) error {
+		todo.Completed = !todo.Completed
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrTodoNotFound) {
+			return nil, err
+		}
+		return nil, errors.New("failed to toggle todo: " + err.Error())
+	}
+	return todo, nil
+}
+
+// MarkAllCompleted completes every pending todo and returns the todos it
+// changed.
+func (s *TodoService) MarkAllCompleted(ctx context.Context) ([]model.Todo, error) {
+	todos, err := s.repo.GetByCompleted(false)
 	if err != nil {
-		return nil, errors.New("todo not found")
+		return nil, errors.New("failed to get pending todos: " + err.Error())
 	}
 
-	todo.Completed = !todo.Completed
+	completed := make([]model.Todo, 0, len(todos))
+	for _, pending := range todos {
+		todo, err := s.change(ctx, pending.ID, model.TodoUpdated, func(todo *model.Todo) error {
+			if todo.Completed {
+				return errUnchanged
+			}
+			todo.Completed = true
+			return nil
+		})
+		switch {
+		case errors.Is(err, errUnchanged) || errors.Is(err, ErrTodoNotFound):
+			// Completed or deleted by someone else meanwhile.
+		case err != nil:
+			return nil, errors.New("failed to mark todo as completed: " + err.Error())
+		default:
+			completed = append(completed, *todo)
+		}
+	}
+
+	return completed, nil
+}
 
-	err = s.repo.Update(todo)
+func (s *TodoService) DeleteCompleted(ctx context.Context) error {
+	completedTodos, err := s.repo.GetByCompleted(true)
 	if err != nil {
-		return nil, errors.New("failed to toggle todo: " + err.Error())
+		return errors.New("failed to get completed todos: " + err.Error())
 	}
 
-	return todo, nil
+	for i := range completedTodos {
+		if err := s.commitDelete(ctx, &completedTodos[i]); err != nil {
+			return errors.New("failed to delete completed todo: " + err.Error())
+		}
+	}
+
+	return nil
 }
 
-func (s *TodoService) MarkAllCompleted() error {
-	todos, err := s.repo.GetByCompleted(false)
+func (s *TodoService) GetTrash(ctx context.Context) ([]model.Todo, error) {
+	todos, err := s.repo.GetDeleted()
 	if err != nil {
-		return errors.New("failed to get pending todos: " + err.Error())
+		return nil, errors.New("failed to get trash: " + err.Error())
 	}
+	return todos, nil
+}
 
-	for _, todo := range todos {
-		todo.Completed = true
-		err := s.repo.Update(&todo)
+// ListTrash returns the trash on page, and its size.
+func (s *TodoService) ListTrash(ctx context.Context, page *model.Pagination) ([]model.Todo, int, error) {
+	todos, total, err := s.repo.GetDeletedPage(page)
+	if err != nil {
+		return nil, 0, errors.New("failed to get trash: " + err.Error())
+	}
+	return todos, int(total), nil
+}
+
+func (s *TodoService) RestoreTodo(ctx context.Context, id uint) (*model.Todo, error) {
+	if id == 0 {
+		return nil, invalid("invalid todo ID")
+	}
+
+	var todo *model.Todo
+	var before model.TodoSnapshot
+	err := s.repo.Transaction(func(repo repository.TodoRepositoryInterface) error {
+		if err := repo.Restore(id); err != nil {
+			return err
+		}
+
+		var err error
+		todo, err = repo.GetByID(id)
 		if err != nil {
-			return errors.New("failed to mark todo as completed: " + err.Error())
+			return err
 		}
+		before = todo.Snapshot()
+		before.Deleted = true
+		return appendChange(ctx, repo, model.TodoRestored, before, todo)
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotInTrash
+		}
+		return nil, errors.New("failed to restore todo: " + err.Error())
+	}
+
+	wake(s.relay)
+	return todo, nil
+}
+
+func (s *TodoService) PurgeTodo(ctx context.Context, id uint) error {
+	if id == 0 {
+		return invalid("invalid todo ID")
 	}
 
+	if err := s.repo.Purge(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotInTrash
+		}
+		return errors.New("failed to purge todo: " + err.Error())
+	}
 	return nil
 }
 
-func (s *TodoService) DeleteCompleted() error {
-	completedTodos, err := s.repo.GetByCompleted(true)
+// PurgeTrash permanently removes todos that have been in the trash for
+// longer than retention.
+func (s *TodoService) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	purged, err := s.repo.PurgeDeletedBefore(time.Now().Add(-retention))
 	if err != nil {
-		return errors.New("failed to get completed todos: " + err.Error())
+		return 0, errors.New("failed to purge trash: " + err.Error())
 	}
+	return purged, nil
+}
 
-	for _, todo := range completedTodos {
-		err := s.repo.Delete(todo.ID)
+func (s *TodoService) GetHistory(ctx context.Context, id uint) ([]model.TodoEvent, error) {
+	if id == 0 {
+		return nil, invalid("invalid todo ID")
+	}
+
+	events, err := s.events.GetByTodoID(id)
+	if err != nil {
+		return nil, errors.New("failed to get history: " + err.Error())
+	}
+	// Todos created before history was recorded have none.
+	if len(events) == 0 {
+		if _, err := s.repo.GetByID(id); err != nil {
+			return nil, ErrTodoNotFound
+		}
+	}
+	return events, nil
+}
+
+// ListHistory returns the history of a todo on page, and its length.
+func (s *TodoService) ListHistory(ctx context.Context, id uint, page *model.Pagination) ([]model.TodoEvent, int, error) {
+	if id == 0 {
+		return nil, 0, invalid("invalid todo ID")
+	}
+
+	events, total, err := s.events.GetPageByTodoID(id, page)
+	if err != nil {
+		return nil, 0, errors.New("failed to get history: " + err.Error())
+	}
+	if total == 0 {
+		if _, err := s.repo.GetByID(id); err != nil {
+			return nil, 0, ErrTodoNotFound
+		}
+	}
+	return events, int(total), nil
+}
+
+// GetHistories returns the history of several todos in one query, keyed by
+// todo ID. Todos without history are missing from the map.
+func (s *TodoService) GetHistories(ctx context.Context, ids []uint) (map[uint][]model.TodoEvent, error) {
+	events, err := s.events.GetByTodoIDs(ids)
+	if err != nil {
+		return nil, errors.New("failed to get history: " + err.Error())
+	}
+
+	histories := make(map[uint][]model.TodoEvent, len(ids))
+	for _, event := range events {
+		histories[event.TodoID] = append(histories[event.TodoID], event)
+	}
+	return histories, nil
+}
+
+// RevertTodo reapplies the snapshot stored at revision. It goes through the
+// same rules as UpdateTodo, so empty fields in the snapshot are left as is;
+// only an empty description and a missing due date are restored, since they
+// are cleared explicitly.
+func (s *TodoService) RevertTodo(ctx context.Context, id uint, revision uint) (*model.Todo, error) {
+	if id == 0 {
+		return nil, invalid("invalid todo ID")
+	}
+
+	event, err := s.events.GetRevision(id, revision)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRevisionNotFound
+		}
+		return nil, errors.New("failed to get revision: " + err.Error())
+	}
+
+	completed := event.Snapshot.Completed
+	req := model.UpdateTodoRequest{
+		Title:            event.Snapshot.Title,
+		Description:      event.Snapshot.Description,
+		ClearDescription: event.Snapshot.Description == "",
+		Project:          event.Snapshot.Project,
+		Completed:        &completed,
+		DueAt:            event.Snapshot.DueAt,
+		ClearDueAt:       event.Snapshot.DueAt == nil,
+	}
+	return s.update(ctx, id, req, model.TodoReverted)
+}
+
+// MergeTodo applies an edit made against the state of the todo at base,
+// typically while offline. Fields changed on the server after base are kept
+// unless the edit sets them to the same value; fields only the edit touches
+// are applied. When both sides changed a field to different values the
+// server value stays and the field is returned as a conflict for the client
+// to resolve.
+func (s *TodoService) MergeTodo(ctx context.Context, id uint, req model.UpdateTodoRequest, base time.Time) (*model.Todo, []model.FieldConflict, error) {
+	if id == 0 {
+		return nil, nil, invalid("invalid todo ID")
+	}
+
+	for attempt := 0; ; attempt++ {
+		current, err := s.repo.GetByID(id)
 		if err != nil {
-			return errors.New("failed to delete completed todo: " + err.Error())
+			return nil, nil, ErrTodoNotFound
+		}
+
+		merged, conflicts := mergeRequest(current, req, base)
+		if merged == (model.UpdateTodoRequest{}) {
+			return current, conflicts, nil
+		}
+
+		// The merge is only valid for the state it was computed against, so
+		// a concurrent write makes it start over.
+		version := current.Version
+		todo, err := s.updateIf(ctx, id, merged, model.TodoUpdated, func(latest *model.Todo) error {
+			if latest.Version != version {
+				return ErrVersionConflict
+			}
+			return nil
+		})
+		if errors.Is(err, ErrVersionConflict) && attempt < maxMergeAttempts {
+			continue
+		}
+		return todo, conflicts, err
+	}
+}
+
+const maxMergeAttempts = 3
+
+// mergeRequest splits req into the part that can be applied on top of
+// current and the fields that conflict with changes made after base.
+func mergeRequest(current *model.Todo, req model.UpdateTodoRequest, base time.Time) (model.UpdateTodoRequest, []model.FieldConflict) {
+	var merged model.UpdateTodoRequest
+	conflicts := []model.FieldConflict{}
+
+	changedSinceBase := func(field string) bool {
+		return current.ModifiedAt(field).After(base)
+	}
+	conflict := func(field string, server, client interface{}) {
+		conflicts = append(conflicts, model.FieldConflict{
+			Field:            field,
+			Server:           server,
+			Client:           client,
+			ServerModifiedAt: current.ModifiedAt(field),
+		})
+	}
+
+	if req.Title != "" && req.Title != current.Title {
+		if changedSinceBase("title") {
+			conflict("title", current.Title, req.Title)
+		} else {
+			merged.Title = req.Title
+		}
+	}
+	if req.Description != "" && req.Description != current.Description {
+		if changedSinceBase("description") {
+			conflict("description", current.Description, req.Description)
+		} else {
+			merged.Description = req.Description
+		}
+	}
+	if req.ClearDescription && current.Description != "" {
+		if changedSinceBase("description") {
+			conflict("description", current.Description, "")
+		} else {
+			merged.ClearDescription = true
+		}
+	}
+	if req.Project != "" && req.Project != current.Project {
+		if changedSinceBase("project") {
+			conflict("project", current.Project, req.Project)
+		} else {
+			merged.Project = req.Project
 		}
 	}
+	if req.Completed != nil && *req.Completed != current.Completed {
+		if changedSinceBase("completed") {
+			conflict("completed", current.Completed, *req.Completed)
+		} else {
+			merged.Completed = req.Completed
+		}
+	}
+	if req.DueAt != nil && (current.DueAt == nil || !req.DueAt.Equal(*current.DueAt)) {
+		if changedSinceBase("due_at") {
+			conflict("due_at", current.DueAt, *req.DueAt)
+		} else {
+			merged.DueAt = req.DueAt
+		}
+	}
+	if req.ClearDueAt && current.DueAt != nil {
+		if changedSinceBase("due_at") {
+			conflict("due_at", current.DueAt, nil)
+		} else {
+			merged.ClearDueAt = true
+		}
+	}
+	return merged, conflicts
+}
 
+// commit runs change in a transaction that also writes the outbox message
+// and the history event for the resulting state of todo. It stamps the
+// changed fields of todo first, so change must store todo as it is when
+// called.
+func (s *TodoService) commit(ctx context.Context, action string, before model.TodoSnapshot, todo *model.Todo, change func(repo repository.TodoRepositoryInterface) error) error {
+	todo.Touch(before, fieldTime())
+	err := s.repo.Transaction(func(repo repository.TodoRepositoryInterface) error {
+		if err := change(repo); err != nil {
+			return err
+		}
+		return appendChange(ctx, repo, action, before, todo)
+	})
+	if err != nil {
+		return err
+	}
+
+	wake(s.relay)
 	return nil
 }
+
+func (s *TodoService) commitDelete(ctx context.Context, todo *model.Todo) error {
+	after := *todo
+	after.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	return s.commit(ctx, model.TodoDeleted, todo.Snapshot(), &after, func(repo repository.TodoRepositoryInterface) error {
+		return repo.Delete(todo.ID)
+	})
+}
+
+// deleteIf deletes the todo when check passes on its current state and
+// returns that state. Like change it deletes only the version check saw and
+// starts over when the todo changed in between.
+func (s *TodoService) deleteIf(ctx context.Context, id uint, check func(current *model.Todo) error) (*model.Todo, error) {
+	for attempt := 1; ; attempt++ {
+		todo, err := s.repo.GetByID(id)
+		if err != nil {
+			return nil, ErrTodoNotFound
+		}
+		if err := check(todo); err != nil {
+			return nil, err
+		}
+
+		after := *todo
+		after.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+		err = s.commit(ctx, model.TodoDeleted, todo.Snapshot(), &after, func(repo repository.TodoRepositoryInterface) error {
+			return repo.DeleteIfVersion(id, todo.Version)
+		})
+		if errors.Is(err, repository.ErrStaleVersion) {
+			if attempt < maxChangeAttempts {
+				continue
+			}
+			return nil, ErrVersionConflict
+		}
+		return todo, err
+	}
+}
+
+// change applies edit to the current state of the todo and stores the
+// result with an update conditional on the version edit saw. When another
+// write commits in between, the update matches no row and change starts
+// over from the new state, so concurrent changes never overwrite each
+// other. Errors from edit are returned as is.
+func (s *TodoService) change(ctx context.Context, id uint, action string, edit func(todo *model.Todo) error) (*model.Todo, error) {
+	for attempt := 1; ; attempt++ {
+		todo, err := s.repo.GetByID(id)
+		if err != nil {
+			return nil, ErrTodoNotFound
+		}
+		before := todo.Snapshot()
+		version := todo.Version
+
+		if err := edit(todo); err != nil {
+			return nil, err
+		}
+		todo.Version = version + 1
+
+		err = s.commit(ctx, action, before, todo, func(repo repository.TodoRepositoryInterface) error {
+			return repo.UpdateIfVersion(todo, version)
+		})
+		if errors.Is(err, repository.ErrStaleVersion) {
+			if attempt < maxChangeAttempts {
+				continue
+			}
+			return nil, ErrVersionConflict
+		}
+		if err != nil {
+			return nil, err
+		}
+		return todo, nil
+	}
+}
+
+// maxChangeAttempts bounds how often change and deleteIf start over under
+// contention before they report ErrVersionConflict.
+const maxChangeAttempts = 3
+
+// errUnchanged makes an edit passed to change skip a todo that already has
+// the wanted state.
+var errUnchanged = errors.New("todo unchanged")
+
+// appendChange writes the outbox message and the history event of a change
+// to todo. It must run inside the transaction that stores the change.
+func appendChange(ctx context.Context, repo repository.TodoRepositoryInterface, action string, before model.TodoSnapshot, todo *model.Todo) error {
+	if err := appendOutbox(ctx, repo, action, before, todo); err != nil {
+		return err
+	}
+	return repo.AppendEvents([]*model.TodoEvent{historyEvent(ctx, action, before, todo)})
+}
+
+// appendOutbox writes the change event for todo to the outbox of repo. It
+// must run inside the transaction that stores the change.
+func appendOutbox(ctx context.Context, repo repository.TodoRepositoryInterface, action string, before model.TodoSnapshot, todo *model.Todo) error {
+	message, err := outboxMessage(ctx, action, before, todo)
+	if err != nil {
+		return err
+	}
+	return repo.AppendOutbox(message)
+}
+
+// outboxMessage is the outbox message announcing a change from before to
+// todo.
+func outboxMessage(ctx context.Context, action string, before model.TodoSnapshot, todo *model.Todo) (*model.OutboxMessage, error) {
+	published := *todo
+	event := eventbus.Event{
+		Type:   busEventType(action),
+		TodoID: todo.ID,
+		Actor:  ActorFromContext(ctx),
+		Todo:   &published,
+	}
+	if action != model.TodoCreated && before.Project != todo.Project {
+		event.PreviousProject = before.Project
+	}
+
+	return eventbus.NewOutboxMessage(event)
+}
+
+// historyEvent is the history event of a change from before to todo.
+func historyEvent(ctx context.Context, action string, before model.TodoSnapshot, todo *model.Todo) *model.TodoEvent {
+	after := todo.Snapshot()
+	return &model.TodoEvent{
+		TodoID:   todo.ID,
+		Action:   action,
+		Actor:    ActorFromContext(ctx),
+		Changes:  before.Diff(after),
+		Snapshot: after,
+	}
+}
+
+// fieldTime is the time to stamp changed fields with. It is truncated to
+// the precision Postgres keeps for updated_at, so a field never looks newer
+// than the updated_at of the write that changed it.
+func fieldTime() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
+
+func wake(relay Waker) {
+	if relay != nil {
+		relay.Wake()
+	}
+}
+
+func busEventType(action string) string {
+	switch action {
+	case model.TodoCreated:
+		return eventbus.TodoCreated
+	case model.TodoToggled:
+		return eventbus.TodoToggled
+	case model.TodoDeleted:
+		return eventbus.TodoDeleted
+	default:
+		return eventbus.TodoUpdated
+	}
+}
@@ -1,24 +1,30 @@
 package service_test
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/stavagg/petGoApi/internal/eventbus"
 	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
 	"github.com/stavagg/petGoApi/internal/repository/mocks"
 	"github.com/stavagg/petGoApi/internal/service"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
 )
 
 func TestCreateTodo_Success(t *testing.T) {
 	repoMock := new(mocks.TodoRepositoryMock)
-	svc := service.NewTodoService(repoMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
 
 	req := model.CreateTodoRequest{Title: "Test", Description: "Desc"}
 	repoMock.On("Create", mock.AnythingOfType("*model.Todo")).Return(nil)
 
-	todo, err := svc.CreateTodo(req)
+	todo, err := svc.CreateTodo(context.Background(), req)
 	assert.NoError(t, err)
 	assert.Equal(t, "Test", todo.Title)
 	assert.Equal(t, "Desc", todo.Description)
@@ -27,26 +33,26 @@ func TestCreateTodo_Success(t *testing.T) {
 }
 
 func TestCreateTodo_EmptyTitle(t *testing.T) {
-	svc := service.NewTodoService(nil)
+	svc := service.NewTodoService(nil, nil, nil)
 
-	_, err := svc.CreateTodo(model.CreateTodoRequest{Title: "", Description: "desc"})
+	_, err := svc.CreateTodo(context.Background(), model.CreateTodoRequest{Title: "", Description: "desc"})
 	assert.EqualError(t, err, "title is required")
 }
 
 func TestCreateTodo_RepoError(t *testing.T) {
 	repoMock := new(mocks.TodoRepositoryMock)
-	svc := service.NewTodoService(repoMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
 
 	repoMock.On("Create", mock.Anything).Return(errors.New("db error"))
 
-	_, err := svc.CreateTodo(model.CreateTodoRequest{Title: "T", Description: ""})
+	_, err := svc.CreateTodo(context.Background(), model.CreateTodoRequest{Title: "T", Description: ""})
 	assert.ErrorContains(t, err, "failed to create todo")
 	repoMock.AssertExpectations(t)
 }
 
 func TestGetAllTodos_Success(t *testing.T) {
 	repoMock := new(mocks.TodoRepositoryMock)
-	svc := service.NewTodoService(repoMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
 
 	sample := []model.Todo{
 		{ID: 1, Title: "A", Description: "a", Completed: false},
@@ -54,7 +60,7 @@ func TestGetAllTodos_Success(t *testing.T) {
 	}
 	repoMock.On("GetAll").Return(sample, nil)
 
-	todos, err := svc.GetAllTodos()
+	todos, err := svc.GetAllTodos(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, sample, todos)
 
@@ -63,27 +69,27 @@ func TestGetAllTodos_Success(t *testing.T) {
 
 func TestGetTodoByID_NotFound(t *testing.T) {
 	repoMock := new(mocks.TodoRepositoryMock)
-	svc := service.NewTodoService(repoMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
 
 	repoMock.On("GetByID", uint(1)).Return((*model.Todo)(nil), errors.New("not found"))
 
-	_, err := svc.GetTodoByID(1)
+	_, err := svc.GetTodoByID(context.Background(), 1)
 	assert.EqualError(t, err, "todo not found")
 	repoMock.AssertExpectations(t)
 }
 
 func TestUpdateTodo_Success(t *testing.T) {
 	repoMock := new(mocks.TodoRepositoryMock)
-	svc := service.NewTodoService(repoMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
 
 	existing := &model.Todo{ID: 1, Title: "Old", Description: "old", Completed: false}
 	repoMock.On("GetByID", uint(1)).Return(existing, nil)
-	repoMock.On("Update", existing).Return(nil)
+	repoMock.On("UpdateIfVersion", existing, uint(0)).Return(nil)
 
 	req := model.UpdateTodoRequest{Title: "New", Description: "new", Completed: new(bool)}
 	*req.Completed = true
 
-	updated, err := svc.UpdateTodo(1, req)
+	updated, err := svc.UpdateTodo(context.Background(), 1, req)
 	assert.NoError(t, err)
 	assert.Equal(t, "New", updated.Title)
 	assert.True(t, updated.Completed)
@@ -93,19 +99,19 @@ func TestUpdateTodo_Success(t *testing.T) {
 
 func TestDeleteTodo_Success(t *testing.T) {
 	repoMock := new(mocks.TodoRepositoryMock)
-	svc := service.NewTodoService(repoMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
 
 	repoMock.On("GetByID", uint(1)).Return(&model.Todo{ID: 1}, nil)
 	repoMock.On("Delete", uint(1)).Return(nil)
 
-	err := svc.DeleteTodo(1)
+	_, err := svc.DeleteTodo(context.Background(), 1)
 	assert.NoError(t, err)
 	repoMock.AssertExpectations(t)
 }
 
 func TestGetStats_Calculation(t *testing.T) {
 	repoMock := new(mocks.TodoRepositoryMock)
-	svc := service.NewTodoService(repoMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
 
 	sample := []model.Todo{
 		{Completed: true},
@@ -114,7 +120,7 @@ func TestGetStats_Calculation(t *testing.T) {
 	}
 	repoMock.On("GetAll").Return(sample, nil)
 
-	stats, err := svc.GetStats()
+	stats, err := svc.GetStats(context.Background())
 	assert.NoError(t, err)
 	assert.Equal(t, 3, stats["total"])
 	assert.Equal(t, 2, stats["completed"])
@@ -123,3 +129,332 @@ func TestGetStats_Calculation(t *testing.T) {
 
 	repoMock.AssertExpectations(t)
 }
+
+func TestRestoreTodo_Success(t *testing.T) {
+	repoMock := new(mocks.TodoRepositoryMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
+
+	repoMock.On("Restore", uint(1)).Return(nil)
+	repoMock.On("GetByID", uint(1)).Return(&model.Todo{ID: 1, Title: "Back"}, nil)
+
+	todo, err := svc.RestoreTodo(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Back", todo.Title)
+	repoMock.AssertExpectations(t)
+}
+
+func TestRestoreTodo_NotInTrash(t *testing.T) {
+	repoMock := new(mocks.TodoRepositoryMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
+
+	repoMock.On("Restore", uint(1)).Return(gorm.ErrRecordNotFound)
+
+	_, err := svc.RestoreTodo(context.Background(), 1)
+	assert.ErrorIs(t, err, service.ErrNotInTrash)
+	repoMock.AssertExpectations(t)
+}
+
+func TestPurgeTrash_UsesRetentionCutoff(t *testing.T) {
+	repoMock := new(mocks.TodoRepositoryMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
+
+	retention := 24 * time.Hour
+	repoMock.On("PurgeDeletedBefore", mock.MatchedBy(func(cutoff time.Time) bool {
+		expected := time.Now().Add(-retention)
+		return cutoff.Sub(expected).Abs() < time.Minute
+	})).Return(int64(3), nil)
+
+	purged, err := svc.PurgeTrash(context.Background(), retention)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	repoMock.AssertExpectations(t)
+}
+
+func newMemoryTodoService() *service.TodoService {
+	repo := repository.NewMemoryTodoRepository()
+	return service.NewTodoService(repo, repo.Events(), nil)
+}
+
+func TestHistory_RecordsActorAndDiff(t *testing.T) {
+	svc := newMemoryTodoService()
+	ctx := service.WithActor(context.Background(), "alice")
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Draft"})
+	assert.NoError(t, err)
+	_, err = svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{Title: "Final"})
+	assert.NoError(t, err)
+	_, err = svc.ToggleTodo(context.Background(), todo.ID)
+	assert.NoError(t, err)
+
+	events, err := svc.GetHistory(ctx, todo.ID)
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+
+	assert.Equal(t, model.TodoCreated, events[0].Action)
+	assert.Equal(t, "alice", events[0].Actor)
+
+	assert.Equal(t, model.TodoUpdated, events[1].Action)
+	assert.Equal(t, []model.FieldChange{{Field: "title", From: "Draft", To: "Final"}}, events[1].Changes)
+
+	assert.Equal(t, model.TodoToggled, events[2].Action)
+	assert.Equal(t, service.AnonymousActor, events[2].Actor)
+	assert.Equal(t, uint(3), events[2].Revision)
+}
+
+func TestUpdateTodo_DueDate(t *testing.T) {
+	svc := newMemoryTodoService()
+	ctx := context.Background()
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Pay rent"})
+	assert.NoError(t, err)
+	assert.Nil(t, todo.DueAt)
+
+	due := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	updated, err := svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{DueAt: &due})
+	assert.NoError(t, err)
+	assert.True(t, due.Equal(*updated.DueAt))
+	assert.Contains(t, updated.FieldTimes, "due_at")
+
+	// Other edits leave the due date alone.
+	updated, err = svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{Title: "Pay the rent"})
+	assert.NoError(t, err)
+	assert.NotNil(t, updated.DueAt)
+
+	events, err := svc.GetHistory(ctx, todo.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "due_at", events[1].Changes[0].Field)
+	assert.True(t, due.Equal(*events[1].Snapshot.DueAt))
+
+	_, err = svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{DueAt: &due, ClearDueAt: true})
+	assert.ErrorIs(t, err, service.ErrInvalidTodo)
+	assert.ErrorContains(t, err, "clear_due_at cannot be combined with due_at")
+
+	updated, err = svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{ClearDueAt: true})
+	assert.NoError(t, err)
+	assert.Nil(t, updated.DueAt)
+
+	// Reverting to a revision without a due date clears it again.
+	_, err = svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{DueAt: &due})
+	assert.NoError(t, err)
+	reverted, err := svc.RevertTodo(ctx, todo.ID, 1)
+	assert.NoError(t, err)
+	assert.Nil(t, reverted.DueAt)
+}
+
+func TestUpdateTodo_ClearDescription(t *testing.T) {
+	svc := newMemoryTodoService()
+	ctx := context.Background()
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Pay rent", Description: "by card"})
+	assert.NoError(t, err)
+
+	_, err = svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{Description: "cash", ClearDescription: true})
+	assert.ErrorIs(t, err, service.ErrInvalidTodo)
+	assert.ErrorContains(t, err, "clear_description cannot be combined with description")
+
+	updated, err := svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{ClearDescription: true})
+	assert.NoError(t, err)
+	assert.Empty(t, updated.Description)
+
+	events, err := svc.GetHistory(ctx, todo.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.FieldChange{{Field: "description", From: "by card", To: ""}}, events[1].Changes)
+}
+
+func TestListHistory_Pages(t *testing.T) {
+	svc := newMemoryTodoService()
+	ctx := context.Background()
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Draft"})
+	assert.NoError(t, err)
+	for _, title := range []string{"Second", "Third"} {
+		_, err = svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{Title: title})
+		assert.NoError(t, err)
+	}
+
+	events, total, err := svc.ListHistory(ctx, todo.ID, &model.Pagination{Limit: 1, Offset: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, total)
+	assert.Len(t, events, 1)
+	assert.Equal(t, uint(2), events[0].Revision)
+
+	_, _, err = svc.ListHistory(ctx, 99, &model.Pagination{Limit: 1})
+	assert.ErrorIs(t, err, service.ErrTodoNotFound)
+}
+
+func TestRevertTodo_ReappliesSnapshot(t *testing.T) {
+	svc := newMemoryTodoService()
+	ctx := context.Background()
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Original", Description: "first"})
+	assert.NoError(t, err)
+	completed := true
+	_, err = svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{Title: "Edited", Completed: &completed})
+	assert.NoError(t, err)
+
+	reverted, err := svc.RevertTodo(ctx, todo.ID, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Original", reverted.Title)
+	assert.False(t, reverted.Completed)
+
+	events, err := svc.GetHistory(ctx, todo.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.TodoReverted, events[len(events)-1].Action)
+
+	_, err = svc.RevertTodo(ctx, todo.ID, 42)
+	assert.ErrorIs(t, err, service.ErrRevisionNotFound)
+}
+
+// appendCounter counts the outbox and history writes made in transactions.
+type appendCounter struct {
+	repository.TodoRepositoryInterface
+	outbox, events *int
+}
+
+func (r appendCounter) Transaction(fn func(repo repository.TodoRepositoryInterface) error) error {
+	return r.TodoRepositoryInterface.Transaction(func(tx repository.TodoRepositoryInterface) error {
+		return fn(appendCounter{TodoRepositoryInterface: tx, outbox: r.outbox, events: r.events})
+	})
+}
+
+func (r appendCounter) AppendOutbox(messages ...*model.OutboxMessage) error {
+	*r.outbox++
+	return r.TodoRepositoryInterface.AppendOutbox(messages...)
+}
+
+func (r appendCounter) AppendEvents(events []*model.TodoEvent) error {
+	*r.events++
+	return r.TodoRepositoryInterface.AppendEvents(events)
+}
+
+func TestImportTodos_BatchesOutboxAndHistory(t *testing.T) {
+	repo := repository.NewMemoryTodoRepository()
+	var outboxCalls, eventCalls int
+	svc := service.NewTodoService(appendCounter{TodoRepositoryInterface: repo, outbox: &outboxCalls, events: &eventCalls}, repo.Events(), nil)
+	ctx := context.Background()
+
+	rows := []model.ImportRow{
+		{Line: 1, Request: model.CreateTodoRequest{Title: "Buy milk"}},
+		{Line: 2, Request: model.CreateTodoRequest{Title: "Pay rent"}},
+		{Line: 3, Request: model.CreateTodoRequest{Title: "Call mom"}},
+	}
+	report, err := svc.ImportTodos(ctx, rows, false)
+	require.NoError(t, err)
+	require.Len(t, report.Todos, 3)
+	assert.Equal(t, 1, outboxCalls)
+	assert.Equal(t, 1, eventCalls)
+
+	messages, err := repo.Outbox().GetAfter(0, 10)
+	require.NoError(t, err)
+	assert.Len(t, messages, 3)
+	for _, todo := range report.Todos {
+		events, err := svc.GetHistory(ctx, todo.ID)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, uint(1), events[0].Revision)
+	}
+}
+
+// staleLookup misses todos by external ID, as when a concurrent import
+// creates them after they were checked.
+type staleLookup struct {
+	repository.TodoRepositoryInterface
+}
+
+func (r staleLookup) Transaction(fn func(repo repository.TodoRepositoryInterface) error) error {
+	return r.TodoRepositoryInterface.Transaction(func(tx repository.TodoRepositoryInterface) error {
+		return fn(staleLookup{tx})
+	})
+}
+
+func (r staleLookup) GetByExternalIDs([]string) ([]model.Todo, error) {
+	return nil, nil
+}
+
+func TestImportTodos_SkipsConcurrentlyImported(t *testing.T) {
+	repo := repository.NewMemoryTodoRepository()
+	svc := service.NewTodoService(repo, repo.Events(), nil)
+	ctx := context.Background()
+
+	rows := []model.ImportRow{{Line: 1, ExternalID: "todoist:1", Request: model.CreateTodoRequest{Title: "Buy milk"}}}
+	_, err := svc.ImportTodos(ctx, rows, false)
+	require.NoError(t, err)
+
+	rows = append(rows, model.ImportRow{Line: 2, ExternalID: "todoist:2", Request: model.CreateTodoRequest{Title: "Pay rent"}})
+	report, err := service.NewTodoService(staleLookup{repo}, repo.Events(), nil).ImportTodos(ctx, rows, false)
+	require.NoError(t, err)
+	require.Len(t, report.Todos, 1)
+	assert.Equal(t, "Pay rent", report.Todos[0].Title)
+	assert.Equal(t, []string{"todoist:1"}, report.Skipped)
+
+	messages, err := repo.Outbox().GetAfter(0, 10)
+	require.NoError(t, err)
+	assert.Len(t, messages, 2, "the skipped todo is not announced again")
+}
+
+type wakeCounter struct{ n int }
+
+func (w *wakeCounter) Wake() { w.n++ }
+
+func TestMutations_WriteOutbox(t *testing.T) {
+	repo := repository.NewMemoryTodoRepository()
+	relay := &wakeCounter{}
+	svc := service.NewTodoService(repo, repo.Events(), relay)
+	ctx := service.WithActor(context.Background(), "alice")
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Task", Project: "home"})
+	assert.NoError(t, err)
+	_, err = svc.UpdateTodo(ctx, todo.ID, model.UpdateTodoRequest{Project: "work"})
+	assert.NoError(t, err)
+	_, err = svc.ToggleTodo(ctx, todo.ID)
+	assert.NoError(t, err)
+	_, err = svc.DeleteTodo(ctx, todo.ID)
+	assert.NoError(t, err)
+	_, err = svc.RestoreTodo(ctx, todo.ID)
+	assert.NoError(t, err)
+
+	messages, err := repo.Outbox().GetAfter(0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, relay.n)
+
+	var types []string
+	for _, message := range messages {
+		event, err := eventbus.FromOutbox(message)
+		assert.NoError(t, err)
+		assert.Equal(t, todo.ID, event.TodoID)
+		assert.Equal(t, "alice", event.Actor)
+		assert.Equal(t, message.ID, event.OutboxID)
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{eventbus.TodoCreated, eventbus.TodoUpdated, eventbus.TodoToggled, eventbus.TodoDeleted, eventbus.TodoUpdated}, types)
+
+	moved, err := eventbus.FromOutbox(messages[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "home", moved.PreviousProject)
+}
+
+func TestMutations_FailedChangeWritesNoOutbox(t *testing.T) {
+	repoMock := new(mocks.TodoRepositoryMock)
+	svc := service.NewTodoService(repoMock, repository.NewMemoryTodoEventRepository(), nil)
+
+	repoMock.On("Create", mock.AnythingOfType("*model.Todo")).Return(errors.New("db down"))
+
+	_, err := svc.CreateTodo(context.Background(), model.CreateTodoRequest{Title: "Task"})
+	assert.Error(t, err)
+	assert.Empty(t, repoMock.Outbox)
+	assert.Empty(t, repoMock.Events)
+}
+
+func TestHistory_EmptyForTodoWithoutEvents(t *testing.T) {
+	repo := repository.NewMemoryTodoRepository()
+	svc := service.NewTodoService(repo, repo.Events(), nil)
+	// A todo stored before history was recorded.
+	require.NoError(t, repo.Create(&model.Todo{Title: "Legacy", Version: 1}))
+
+	events, err := svc.GetHistory(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	_, err = svc.GetHistory(context.Background(), 99)
+	assert.ErrorIs(t, err, service.ErrTodoNotFound)
+}
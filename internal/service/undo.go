@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	UndoDelete      = "delete"
+	UndoToggle      = "toggle"
+	UndoCompleteAll = "complete_all"
+)
+
+type UndoServiceInterface interface {
+	Remember(action string, todos []model.Todo) *UndoToken
+	Undo(ctx context.Context, token string) ([]model.Todo, error)
+}
+
+var (
+	ErrUndoNotFound = errors.New("undo token not found or expired")
+	ErrUndoConflict = errors.New("todo changed since the action, cannot undo")
+)
+
+type UndoToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UndoService stores the inverse of recent destructive actions for a
+// limited window. The actions live in the database next to the todos, so a
+// token works on every instance and survives a restart.
+type UndoService struct {
+	repo   repository.TodoRepositoryInterface
+	relay  Waker
+	window time.Duration
+}
+
+func NewUndoService(repo repository.TodoRepositoryInterface, relay Waker, window time.Duration) *UndoService {
+	return &UndoService{repo: repo, relay: relay, window: window}
+}
+
+// Remember stores the action applied to todos and returns a token that
+// reverts it until the window expires. There is nothing to undo when the
+// action changed no todos, and a token that could not be stored is not
+// handed out, so both return nil.
+func (s *UndoService) Remember(action string, todos []model.Todo) *UndoToken {
+	if len(todos) == 0 {
+		return nil
+	}
+
+	targets := make([]model.UndoTarget, 0, len(todos))
+	for _, todo := range todos {
+		targets = append(targets, model.UndoTarget{ID: todo.ID, Version: todo.Version})
+	}
+
+	now := time.Now()
+	entry := &model.UndoAction{
+		Token:     newUndoToken(),
+		Action:    action,
+		Targets:   targets,
+		ExpiresAt: now.Add(s.window),
+	}
+	if err := s.repo.SaveUndo(entry, now); err != nil {
+		log.Printf("failed to save undo for %s: %v", action, err)
+		return nil
+	}
+
+	return &UndoToken{Token: entry.Token, ExpiresAt: entry.ExpiresAt}
+}
+
+// Undo reverts the action behind token in a single transaction. The token is
+// taken in the same transaction, so it is consumed only when the revert
+// succeeds and two concurrent undos cannot both apply.
+func (s *UndoService) Undo(ctx context.Context, token string) ([]model.Todo, error) {
+	var reverted []model.Todo
+	err := s.repo.Transaction(func(repo repository.TodoRepositoryInterface) error {
+		entry, err := repo.TakeUndo(token, time.Now())
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrUndoNotFound
+			}
+			return err
+		}
+
+		action := model.TodoUpdated
+		switch entry.Action {
+		case UndoDelete:
+			action = model.TodoRestored
+		case UndoToggle:
+			action = model.TodoToggled
+		}
+
+		reverted = nil
+		for _, target := range entry.Targets {
+			snapshot, todo, err := revert(repo, entry.Action, target)
+			if err != nil {
+				return err
+			}
+			if err := appendChange(ctx, repo, action, snapshot, todo); err != nil {
+				return err
+			}
+			reverted = append(reverted, *todo)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrUndoNotFound) || errors.Is(err, ErrUndoConflict) {
+			return nil, err
+		}
+		return nil, errors.New("failed to undo: " + err.Error())
+	}
+
+	wake(s.relay)
+
+	return reverted, nil
+}
+
+// revert undoes action on one todo. The todo must still have the version the
+// action left it at; the update only applies while it does, so a change that
+// commits between the read and the write is a conflict too.
+func revert(repo repository.TodoRepositoryInterface, action string, target model.UndoTarget) (model.TodoSnapshot, *model.Todo, error) {
+	if action == UndoDelete {
+		if err := repo.Restore(target.ID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return model.TodoSnapshot{}, nil, ErrUndoConflict
+			}
+			return model.TodoSnapshot{}, nil, err
+		}
+
+		todo, err := repo.GetByID(target.ID)
+		if err != nil {
+			return model.TodoSnapshot{}, nil, err
+		}
+		if todo.Version != target.Version {
+			return model.TodoSnapshot{}, nil, ErrUndoConflict
+		}
+
+		before := todo.Snapshot()
+		before.Deleted = true
+		return before, todo, nil
+	}
+
+	todo, err := repo.GetByID(target.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.TodoSnapshot{}, nil, ErrUndoConflict
+		}
+		return model.TodoSnapshot{}, nil, err
+	}
+
+	before := todo.Snapshot()
+	switch action {
+	case UndoToggle:
+		todo.Completed = !todo.Completed
+	case UndoCompleteAll:
+		todo.Completed = false
+	}
+	todo.Version = target.Version + 1
+	todo.Touch(before, fieldTime())
+
+	if err := repo.UpdateIfVersion(todo, target.Version); err != nil {
+		if errors.Is(err, repository.ErrStaleVersion) {
+			return model.TodoSnapshot{}, nil, ErrUndoConflict
+		}
+		return model.TodoSnapshot{}, nil, err
+	}
+	return before, todo, nil
+}
+
+func newUndoToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
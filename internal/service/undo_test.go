@@ -0,0 +1,144 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newUndoFixture(window time.Duration) (*service.TodoService, *service.UndoService) {
+	repo := repository.NewMemoryTodoRepository()
+	events := repo.Events()
+	return service.NewTodoService(repo, events, nil), service.NewUndoService(repo, nil, window)
+}
+
+func TestUndo_Toggle(t *testing.T) {
+	ctx := context.Background()
+	svc, undo := newUndoFixture(time.Minute)
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+	toggled, err := svc.ToggleTodo(ctx, todo.ID)
+	require.NoError(t, err)
+
+	token := undo.Remember(service.UndoToggle, []model.Todo{*toggled})
+	reverted, err := undo.Undo(ctx, token.Token)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.False(t, reverted[0].Completed)
+
+	_, err = undo.Undo(ctx, token.Token)
+	assert.ErrorIs(t, err, service.ErrUndoNotFound, "tokens are single use")
+}
+
+func TestUndo_Delete(t *testing.T) {
+	ctx := context.Background()
+	svc, undo := newUndoFixture(time.Minute)
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+	deleted, err := svc.DeleteTodo(ctx, todo.ID)
+	require.NoError(t, err)
+
+	token := undo.Remember(service.UndoDelete, []model.Todo{*deleted})
+	_, err = undo.Undo(ctx, token.Token)
+	require.NoError(t, err)
+
+	restored, err := svc.GetTodoByID(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Task", restored.Title)
+
+	history, err := svc.GetHistory(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, model.TodoRestored, history[len(history)-1].Action)
+}
+
+func TestUndo_CompleteAllIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	svc, undo := newUndoFixture(time.Minute)
+
+	a, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "A"})
+	require.NoError(t, err)
+	b, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "B"})
+	require.NoError(t, err)
+
+	completed, err := svc.MarkAllCompleted(ctx)
+	require.NoError(t, err)
+	require.Len(t, completed, 2)
+	token := undo.Remember(service.UndoCompleteAll, completed)
+
+	_, err = svc.UpdateTodo(ctx, b.ID, model.UpdateTodoRequest{Title: "B edited"})
+	require.NoError(t, err)
+
+	_, err = undo.Undo(ctx, token.Token)
+	assert.ErrorIs(t, err, service.ErrUndoConflict)
+
+	got, err := svc.GetTodoByID(ctx, a.ID)
+	require.NoError(t, err)
+	assert.True(t, got.Completed, "a conflict on one todo must not revert the others")
+}
+
+func TestUndo_ConflictWhenRestoredMeanwhile(t *testing.T) {
+	ctx := context.Background()
+	svc, undo := newUndoFixture(time.Minute)
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+	deleted, err := svc.DeleteTodo(ctx, todo.ID)
+	require.NoError(t, err)
+	token := undo.Remember(service.UndoDelete, []model.Todo{*deleted})
+
+	_, err = svc.RestoreTodo(ctx, todo.ID)
+	require.NoError(t, err)
+
+	_, err = undo.Undo(ctx, token.Token)
+	assert.ErrorIs(t, err, service.ErrUndoConflict)
+}
+
+func TestUndo_Expired(t *testing.T) {
+	ctx := context.Background()
+	svc, undo := newUndoFixture(time.Nanosecond)
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+	toggled, err := svc.ToggleTodo(ctx, todo.ID)
+	require.NoError(t, err)
+
+	token := undo.Remember(service.UndoToggle, []model.Todo{*toggled})
+	time.Sleep(time.Millisecond)
+
+	_, err = undo.Undo(ctx, token.Token)
+	assert.ErrorIs(t, err, service.ErrUndoNotFound)
+}
+
+func TestUndo_NothingToUndo(t *testing.T) {
+	ctx := context.Background()
+	svc, undo := newUndoFixture(time.Minute)
+
+	completed, err := svc.MarkAllCompleted(ctx)
+	require.NoError(t, err)
+	assert.Nil(t, undo.Remember(service.UndoCompleteAll, completed))
+}
+
+func TestUndo_TokenWorksOnOtherInstance(t *testing.T) {
+	ctx := context.Background()
+	repo := repository.NewMemoryTodoRepository()
+	svc := service.NewTodoService(repo, repo.Events(), nil)
+
+	todo, err := svc.CreateTodo(ctx, model.CreateTodoRequest{Title: "Task"})
+	require.NoError(t, err)
+	toggled, err := svc.ToggleTodo(ctx, todo.ID)
+	require.NoError(t, err)
+
+	token := service.NewUndoService(repo, nil, time.Minute).Remember(service.UndoToggle, []model.Todo{*toggled})
+	reverted, err := service.NewUndoService(repo, nil, time.Minute).Undo(ctx, token.Token)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.False(t, reverted[0].Completed)
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"gorm.io/gorm"
+)
+
+type UserServiceInterface interface {
+	CreateUser(ctx context.Context, name string) (*model.User, error)
+	// CreateAPIKey returns a new key of the user. The key itself is only
+	// returned here; the server keeps its hash.
+	CreateAPIKey(ctx context.Context, userName string) (string, *model.APIKey, error)
+	// Authenticate returns the user of key, or ErrInvalidAPIKey.
+	Authenticate(ctx context.Context, key string) (*model.User, error)
+}
+
+var (
+	ErrUserNotFound  = errors.New("user not found")
+	ErrUserExists    = errors.New("user already exists")
+	ErrInvalidUser   = errors.New("invalid user")
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+const (
+	// apiKeyPrefix starts every key, so leaked keys are easy to search for.
+	apiKeyPrefix = "pga_"
+	// apiKeyShownLength is how much of a key is kept as its Prefix.
+	apiKeyShownLength = 12
+	maxUserNameLength = 100
+)
+
+type UserService struct {
+	repo repository.UserRepositoryInterface
+}
+
+func NewUserService(repo repository.UserRepositoryInterface) *UserService {
+	return &UserService{repo: repo}
+}
+
+func (s *UserService) CreateUser(ctx context.Context, name string) (*model.User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxUserNameLength {
+		return nil, fmt.Errorf("%w: name must be 1 to %d bytes", ErrInvalidUser, maxUserNameLength)
+	}
+
+	user := &model.User{Name: name}
+	err := s.repo.CreateUser(user)
+	if errors.Is(err, repository.ErrUserExists) {
+		return nil, ErrUserExists
+	}
+	if err != nil {
+		return nil, errors.New("failed to create user: " + err.Error())
+	}
+	return user, nil
+}
+
+func (s *UserService) CreateAPIKey(ctx context.Context, userName string) (string, *model.APIKey, error) {
+	user, err := s.repo.GetUserByName(userName)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil, ErrUserNotFound
+	}
+	if err != nil {
+		return "", nil, errors.New("failed to get user: " + err.Error())
+	}
+
+	key := newAPIKey()
+	apiKey := &model.APIKey{UserID: user.ID, Prefix: key[:apiKeyShownLength], Hash: hashAPIKey(key)}
+	if err := s.repo.CreateAPIKey(apiKey); err != nil {
+		return "", nil, errors.New("failed to create API key: " + err.Error())
+	}
+	return key, apiKey, nil
+}
+
+func (s *UserService) Authenticate(ctx context.Context, key string) (*model.User, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	user, err := s.repo.GetUserByKeyHash(hashAPIKey(key))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, errors.New("failed to check API key: " + err.Error())
+	}
+	return user, nil
+}
+
+func newAPIKey() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+}
+
+// hashAPIKey is the stored form of a key. Keys are random, so a plain
+// SHA-256 is enough; there is nothing to guess that a slow hash would
+// protect.
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
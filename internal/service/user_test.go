@@ -0,0 +1,42 @@
+package service_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserService_APIKeys(t *testing.T) {
+	users := service.NewUserService(repository.NewMemoryUserRepository())
+	ctx := context.Background()
+
+	_, _, err := users.CreateAPIKey(ctx, "alice")
+	assert.ErrorIs(t, err, service.ErrUserNotFound)
+
+	_, err = users.CreateUser(ctx, " ")
+	assert.ErrorIs(t, err, service.ErrInvalidUser)
+	alice, err := users.CreateUser(ctx, "alice")
+	require.NoError(t, err)
+	_, err = users.CreateUser(ctx, "alice")
+	assert.ErrorIs(t, err, service.ErrUserExists)
+
+	key, apiKey, err := users.CreateAPIKey(ctx, "alice")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, apiKey.Prefix))
+	assert.NotContains(t, apiKey.Hash, key, "only a hash of the key is stored")
+	assert.Equal(t, alice.ID, apiKey.UserID)
+
+	user, err := users.Authenticate(ctx, key)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Name)
+
+	for _, wrong := range []string{"", key + "x", strings.TrimPrefix(key, "pga_")} {
+		_, err = users.Authenticate(ctx, wrong)
+		assert.ErrorIs(t, err, service.ErrInvalidAPIKey, wrong)
+	}
+}
@@ -0,0 +1,417 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Headers sent with every webhook delivery. The signature is the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook secret,
+// prefixed with "sha256=".
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+	WebhookSignatureHeader = "X-Webhook-Signature"
+)
+
+type WebhookServiceInterface interface {
+	CreateWebhook(ctx context.Context, req model.CreateWebhookRequest) (*model.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]model.Webhook, error)
+	ListWebhooks(ctx context.Context, page *model.Pagination) ([]model.Webhook, int, error)
+	GetWebhookByID(ctx context.Context, id uint) (*model.Webhook, error)
+	UpdateWebhook(ctx context.Context, id uint, req model.UpdateWebhookRequest) (*model.Webhook, error)
+	DeleteWebhook(ctx context.Context, id uint) error
+	GetDeliveries(ctx context.Context, id uint, limit int) ([]model.WebhookDelivery, error)
+	// Enqueue queues a delivery of event for every active webhook subscribed
+	// to its type.
+	Enqueue(ctx context.Context, event eventbus.Event) error
+	// DeliverDue sends the queued deliveries that are due and returns how
+	// many were attempted.
+	DeliverDue(ctx context.Context) (int, error)
+}
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidWebhook  = errors.New("invalid webhook")
+)
+
+// WebhookOptions tunes retries. A delivery is retried with exponential
+// backoff starting at RetryBase until MaxAttempts is reached; a webhook is
+// disabled after DisableAfter consecutive failed attempts.
+type WebhookOptions struct {
+	MaxAttempts  int
+	DisableAfter int
+	RetryBase    time.Duration
+	RetryMax     time.Duration
+	// AllowPrivateNetworks accepts webhook URLs on loopback, private and
+	// link-local addresses. Pair it with NewWebhookClient(timeout, true).
+	AllowPrivateNetworks bool
+	// Resolver looks up webhook hosts when they are created or changed;
+	// nil uses net.DefaultResolver.
+	Resolver Resolver
+}
+
+const (
+	webhookBatchSize = 50
+	// webhookLease must outlive one attempt so a slow request is not picked
+	// up again by another worker.
+	webhookLease       = 5 * time.Minute
+	webhookMaxBodyRead = 4 * 1024
+)
+
+var webhookEventTypes = map[string]bool{
+	"*":                  true,
+	eventbus.TodoCreated: true,
+	eventbus.TodoUpdated: true,
+	eventbus.TodoToggled: true,
+	eventbus.TodoDeleted: true,
+}
+
+type WebhookService struct {
+	repo   repository.WebhookRepositoryInterface
+	client *http.Client
+	opts   WebhookOptions
+}
+
+func NewWebhookService(repo repository.WebhookRepositoryInterface, client *http.Client, opts WebhookOptions) *WebhookService {
+	if opts.Resolver == nil {
+		opts.Resolver = net.DefaultResolver
+	}
+	return &WebhookService{repo: repo, client: client, opts: opts}
+}
+
+// webhookPayload is the JSON body of a delivery.
+type webhookPayload struct {
+	// EventID identifies the change. Deliveries are at least once, so
+	// receivers can use it to drop duplicates.
+	EventID         uint        `json:"event_id,omitempty"`
+	Type            string      `json:"type"`
+	OccurredAt      time.Time   `json:"occurred_at"`
+	Actor           string      `json:"actor"`
+	TodoID          uint        `json:"todo_id"`
+	Todo            *model.Todo `json:"todo"`
+	PreviousProject string      `json:"previous_project,omitempty"`
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, req model.CreateWebhookRequest) (*model.Webhook, error) {
+	if err := s.validateURL(ctx, req.URL); err != nil {
+		return nil, err
+	}
+	if err := validateWebhookEvents(req.Events); err != nil {
+		return nil, err
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret = newWebhookSecret()
+	}
+
+	webhook := &model.Webhook{
+		URL:    req.URL,
+		Events: req.Events,
+		Secret: secret,
+		Active: true,
+	}
+	if err := s.repo.Create(webhook); err != nil {
+		return nil, errors.New("failed to create webhook: " + err.Error())
+	}
+	return webhook, nil
+}
+
+func (s *WebhookService) GetWebhooks(ctx context.Context) ([]model.Webhook, error) {
+	webhooks, err := s.repo.GetAll()
+	if err != nil {
+		return nil, errors.New("failed to get webhooks: " + err.Error())
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// ListWebhooks returns the webhooks on page, without their secrets, and how
+// many there are in all.
+func (s *WebhookService) ListWebhooks(ctx context.Context, page *model.Pagination) ([]model.Webhook, int, error) {
+	webhooks, total, err := s.repo.GetPage(page)
+	if err != nil {
+		return nil, 0, errors.New("failed to get webhooks: " + err.Error())
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, int(total), nil
+}
+
+func (s *WebhookService) GetWebhookByID(ctx context.Context, id uint) (*model.Webhook, error) {
+	webhook, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id uint, req model.UpdateWebhookRequest) (*model.Webhook, error) {
+	webhook, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != "" {
+		if err := s.validateURL(ctx, req.URL); err != nil {
+			return nil, err
+		}
+		webhook.URL = req.URL
+	}
+
+	if req.Events != nil {
+		if err := validateWebhookEvents(req.Events); err != nil {
+			return nil, err
+		}
+		webhook.Events = req.Events
+	}
+
+	if req.Active != nil {
+		webhook.Active = *req.Active
+		if webhook.Active {
+			webhook.ConsecutiveFailures = 0
+			webhook.DisabledAt = nil
+		}
+	}
+
+	if err := s.repo.Update(webhook); err != nil {
+		return nil, errors.New("failed to update webhook: " + err.Error())
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uint) error {
+	if err := s.repo.Delete(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrWebhookNotFound
+		}
+		return errors.New("failed to delete webhook: " + err.Error())
+	}
+	return nil
+}
+
+func (s *WebhookService) GetDeliveries(ctx context.Context, id uint, limit int) ([]model.WebhookDelivery, error) {
+	if _, err := s.get(id); err != nil {
+		return nil, err
+	}
+
+	deliveries, err := s.repo.GetDeliveries(id, limit)
+	if err != nil {
+		return nil, errors.New("failed to get deliveries: " + err.Error())
+	}
+	return deliveries, nil
+}
+
+func (s *WebhookService) Enqueue(ctx context.Context, event eventbus.Event) error {
+	webhooks, err := s.repo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	for _, webhook := range webhooks {
+		if !webhook.Active || !webhook.Subscribes(event.Type) {
+			continue
+		}
+
+		if body == nil {
+			body, err = json.Marshal(webhookPayload{
+				Type:            event.Type,
+				OccurredAt:      event.OccurredAt,
+				Actor:           event.Actor,
+				TodoID:          event.TodoID,
+				Todo:            event.Todo,
+				PreviousProject: event.PreviousProject,
+				EventID:         event.OutboxID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		delivery := &model.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			EventType:     event.Type,
+			Payload:       string(body),
+			Status:        model.DeliveryPending,
+			NextAttemptAt: time.Now().UTC(),
+		}
+		if err := s.repo.EnqueueDelivery(delivery); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	deliveries, err := s.repo.ClaimDueDeliveries(time.Now().UTC(), webhookLease, webhookBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			return i, ctx.Err()
+		}
+		if err := s.deliver(ctx, &deliveries[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// deliver makes one attempt and records its outcome on the delivery and the
+// webhook.
+func (s *WebhookService) deliver(ctx context.Context, delivery *model.WebhookDelivery) error {
+	webhook, err := s.repo.GetByID(delivery.WebhookID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if webhook == nil || !webhook.Active {
+		delivery.Status = model.DeliveryFailed
+		delivery.LastError = "webhook disabled"
+		return s.repo.UpdateDelivery(delivery)
+	}
+
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+
+	status, sendErr := s.send(ctx, webhook, delivery)
+	delivery.ResponseStatus = status
+	if sendErr == nil {
+		delivery.Status = model.DeliverySucceeded
+		delivery.LastError = ""
+		if err := s.repo.UpdateDelivery(delivery); err != nil {
+			return err
+		}
+		return s.repo.RecordSuccess(webhook.ID)
+	}
+
+	delivery.LastError = sendErr.Error()
+	if delivery.Attempts >= s.opts.MaxAttempts {
+		delivery.Status = model.DeliveryFailed
+	} else {
+		delivery.NextAttemptAt = now.Add(s.backoff(delivery.Attempts))
+	}
+	if err := s.repo.UpdateDelivery(delivery); err != nil {
+		return err
+	}
+
+	disabled, err := s.repo.RecordFailure(webhook.ID, s.opts.DisableAfter, now)
+	if err != nil {
+		return err
+	}
+	if disabled {
+		log.Printf("webhook %d disabled after %d consecutive failures", webhook.ID, s.opts.DisableAfter)
+	}
+	return nil
+}
+
+func (s *WebhookService) send(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PetGoApi-Webhooks/1.0")
+	req.Header.Set(WebhookEventHeader, delivery.EventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, webhookMaxBodyRead))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (s *WebhookService) backoff(attempts int) time.Duration {
+	delay := s.opts.RetryBase
+	for i := 1; i < attempts && delay < s.opts.RetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, s.opts.RetryMax)
+}
+
+func (s *WebhookService) get(id uint) (*model.Webhook, error) {
+	webhook, err := s.repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, errors.New("failed to get webhook: " + err.Error())
+	}
+	return webhook, nil
+}
+
+// SignWebhookPayload returns the signature header value for a payload sent
+// at timestamp. Receivers recompute it to verify a delivery.
+func SignWebhookPayload(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookService) validateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if s.opts.AllowPrivateNetworks {
+		return nil
+	}
+	return checkWebhookHost(ctx, s.opts.Resolver, u.Hostname())
+}
+
+func validateWebhookEvents(events []string) error {
+	for _, e := range events {
+		if !webhookEventTypes[e] {
+			return fmt.Errorf("%w: unknown event type %q", ErrInvalidWebhook, e)
+		}
+	}
+	return nil
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// Resolver looks up the addresses of a webhook host. *net.Resolver
+// implements it.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// errBlockedAddress is returned when a webhook would reach an address
+// inside the server's own network.
+var errBlockedAddress = errors.New("address is not allowed for webhooks")
+
+// sharedAddressSpace is the carrier-grade NAT range, which netip does not
+// count as private.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// blockedAddress reports whether addr is loopback, private, link-local
+// (which includes the 169.254.169.254 cloud metadata endpoint), shared,
+// multicast or unspecified.
+func blockedAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		addr.IsUnspecified() || sharedAddressSpace.Contains(addr)
+}
+
+// checkWebhookHost resolves host and rejects it when any of its addresses is
+// blocked, so a webhook is refused when it is created rather than only
+// failing its deliveries.
+func checkWebhookHost(ctx context.Context, resolver Resolver, host string) error {
+	addrs := []netip.Addr{}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, addr)
+	} else {
+		addrs, err = resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			return fmt.Errorf("%w: cannot resolve host %s", ErrInvalidWebhook, host)
+		}
+	}
+
+	for _, addr := range addrs {
+		if blockedAddress(addr) {
+			return fmt.Errorf("%w: %s resolves to %s: %v", ErrInvalidWebhook, host, addr, errBlockedAddress)
+		}
+	}
+	return nil
+}
+
+// NewWebhookClient returns the HTTP client for webhook deliveries. Unless
+// allowPrivate is set it refuses to connect to blocked addresses. The check
+// runs on the address actually dialed, so it also covers DNS answers that
+// changed since the webhook was created and redirects. Proxies from the
+// environment are not used, as they would dial on the client's behalf.
+func NewWebhookClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if blockedAddress(addrPort.Addr()) {
+				return fmt.Errorf("%s: %w", addrPort.Addr(), errBlockedAddress)
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
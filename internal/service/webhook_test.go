@@ -0,0 +1,290 @@
+package service_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/eventbus"
+	"github.com/stavagg/petGoApi/internal/model"
+	"github.com/stavagg/petGoApi/internal/repository"
+	"github.com/stavagg/petGoApi/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver is an httptest webhook endpoint that answers with the queued
+// status codes and records the requests it got.
+type receiver struct {
+	server *httptest.Server
+
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   string
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rec := &receiver{statuses: statuses}
+	rec.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, receivedRequest{header: r.Header.Clone(), body: string(body)})
+		status := http.StatusNoContent
+		if len(rec.statuses) > 0 {
+			status = rec.statuses[0]
+			rec.statuses = rec.statuses[1:]
+		}
+		rec.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rec.server.Close)
+	return rec
+}
+
+func (r *receiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+// newWebhookService allows private networks, since the receivers listen on
+// loopback.
+func newWebhookService(repo repository.WebhookRepositoryInterface) *service.WebhookService {
+	return service.NewWebhookService(repo, &http.Client{Timeout: time.Second}, service.WebhookOptions{
+		MaxAttempts:          3,
+		DisableAfter:         5,
+		RetryBase:            time.Millisecond,
+		RetryMax:             10 * time.Millisecond,
+		AllowPrivateNetworks: true,
+	})
+}
+
+// staticResolver answers lookups from a fixed table.
+type staticResolver map[string][]netip.Addr
+
+func (r staticResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	if addrs, ok := r[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func todoEvent(eventType string) eventbus.Event {
+	return eventbus.Event{
+		Type:       eventType,
+		TodoID:     1,
+		Actor:      "alice",
+		OccurredAt: time.Now(),
+		Todo:       &model.Todo{ID: 1, Title: "Ship it"},
+	}
+}
+
+func TestWebhook_DeliversSignedPayload(t *testing.T) {
+	ctx := context.Background()
+	rec := newReceiver(t)
+	repo := repository.NewMemoryWebhookRepository()
+	svc := newWebhookService(repo)
+
+	webhook, err := svc.CreateWebhook(ctx, model.CreateWebhookRequest{URL: rec.server.URL, Secret: "topsecret"})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Enqueue(ctx, todoEvent(eventbus.TodoCreated)))
+	attempted, err := svc.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, attempted)
+
+	requests := rec.received()
+	require.Len(t, requests, 1)
+	req := requests[0]
+	assert.Equal(t, eventbus.TodoCreated, req.header.Get(service.WebhookEventHeader))
+	assert.NotEmpty(t, req.header.Get(service.WebhookDeliveryHeader))
+	expected := service.SignWebhookPayload("topsecret", req.header.Get(service.WebhookTimestampHeader), req.body)
+	assert.Equal(t, expected, req.header.Get(service.WebhookSignatureHeader))
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(req.body), &payload))
+	assert.Equal(t, eventbus.TodoCreated, payload["type"])
+	assert.Equal(t, "alice", payload["actor"])
+
+	deliveries, err := svc.GetDeliveries(ctx, webhook.ID, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, model.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusNoContent, deliveries[0].ResponseStatus)
+}
+
+func TestWebhook_FiltersEvents(t *testing.T) {
+	ctx := context.Background()
+	rec := newReceiver(t)
+	svc := newWebhookService(repository.NewMemoryWebhookRepository())
+
+	_, err := svc.CreateWebhook(ctx, model.CreateWebhookRequest{URL: rec.server.URL, Events: []string{eventbus.TodoDeleted}})
+	require.NoError(t, err)
+
+	require.NoError(t, svc.Enqueue(ctx, todoEvent(eventbus.TodoCreated)))
+	require.NoError(t, svc.Enqueue(ctx, todoEvent(eventbus.TodoDeleted)))
+	_, err = svc.DeliverDue(ctx)
+	require.NoError(t, err)
+
+	requests := rec.received()
+	require.Len(t, requests, 1)
+	assert.Equal(t, eventbus.TodoDeleted, requests[0].header.Get(service.WebhookEventHeader))
+}
+
+func TestWebhook_RetriesWithBackoff(t *testing.T) {
+	ctx := context.Background()
+	rec := newReceiver(t, http.StatusInternalServerError, http.StatusOK)
+	svc := newWebhookService(repository.NewMemoryWebhookRepository())
+
+	webhook, err := svc.CreateWebhook(ctx, model.CreateWebhookRequest{URL: rec.server.URL})
+	require.NoError(t, err)
+	require.NoError(t, svc.Enqueue(ctx, todoEvent(eventbus.TodoUpdated)))
+
+	_, err = svc.DeliverDue(ctx)
+	require.NoError(t, err)
+	deliveries, err := svc.GetDeliveries(ctx, webhook.ID, 10)
+	require.NoError(t, err)
+	assert.Equal(t, model.DeliveryPending, deliveries[0].Status)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+	assert.NotEmpty(t, deliveries[0].LastError)
+
+	attempted, err := svc.DeliverDue(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, attempted, "retry waits for the backoff")
+
+	time.Sleep(5 * time.Millisecond)
+	_, err = svc.DeliverDue(ctx)
+	require.NoError(t, err)
+
+	deliveries, err = svc.GetDeliveries(ctx, webhook.ID, 10)
+	require.NoError(t, err)
+	assert.Equal(t, model.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, 2, deliveries[0].Attempts)
+	assert.Len(t, rec.received(), 2)
+}
+
+func TestWebhook_GivesUpAndDisablesAfterRepeatedFailures(t *testing.T) {
+	ctx := context.Background()
+	rec := newReceiver(t, 500, 500, 500, 500, 500, 500)
+	svc := newWebhookService(repository.NewMemoryWebhookRepository())
+
+	webhook, err := svc.CreateWebhook(ctx, model.CreateWebhookRequest{URL: rec.server.URL})
+	require.NoError(t, err)
+	require.NoError(t, svc.Enqueue(ctx, todoEvent(eventbus.TodoCreated)))
+	require.NoError(t, svc.Enqueue(ctx, todoEvent(eventbus.TodoUpdated)))
+
+	for i := 0; i < 10; i++ {
+		_, err := svc.DeliverDue(ctx)
+		require.NoError(t, err)
+		time.Sleep(15 * time.Millisecond)
+	}
+
+	got, err := svc.GetWebhookByID(ctx, webhook.ID)
+	require.NoError(t, err)
+	assert.False(t, got.Active)
+	assert.NotNil(t, got.DisabledAt)
+	assert.Empty(t, got.Secret)
+
+	// Three attempts for the first delivery and two for the second disable
+	// the webhook; the second delivery is abandoned instead of retried.
+	assert.Len(t, rec.received(), 5)
+	deliveries, err := svc.GetDeliveries(ctx, webhook.ID, 10)
+	require.NoError(t, err)
+	for _, d := range deliveries {
+		assert.Equal(t, model.DeliveryFailed, d.Status)
+	}
+
+	require.NoError(t, svc.Enqueue(ctx, todoEvent(eventbus.TodoCreated)))
+	deliveries, err = svc.GetDeliveries(ctx, webhook.ID, 10)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 2, "disabled webhooks get no new deliveries")
+
+	active := true
+	got, err = svc.UpdateWebhook(ctx, webhook.ID, model.UpdateWebhookRequest{Active: &active})
+	require.NoError(t, err)
+	assert.True(t, got.Active)
+	assert.Zero(t, got.ConsecutiveFailures)
+}
+
+func TestWebhook_ValidatesInput(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewWebhookService(repository.NewMemoryWebhookRepository(), http.DefaultClient, service.WebhookOptions{
+		Resolver: staticResolver{"example.com": {netip.MustParseAddr("93.184.215.14")}},
+	})
+
+	_, err := svc.CreateWebhook(ctx, model.CreateWebhookRequest{URL: "ftp://example.com"})
+	assert.ErrorIs(t, err, service.ErrInvalidWebhook)
+
+	_, err = svc.CreateWebhook(ctx, model.CreateWebhookRequest{URL: "https://example.com", Events: []string{"todo.exploded"}})
+	assert.ErrorIs(t, err, service.ErrInvalidWebhook)
+
+	webhook, err := svc.CreateWebhook(ctx, model.CreateWebhookRequest{URL: "https://example.com"})
+	require.NoError(t, err)
+	assert.Len(t, webhook.Secret, 64, "a secret is generated when none is given")
+
+	_, err = svc.GetWebhookByID(ctx, 999)
+	assert.ErrorIs(t, err, service.ErrWebhookNotFound)
+}
+
+func TestWebhook_RejectsInternalAddresses(t *testing.T) {
+	ctx := context.Background()
+	svc := service.NewWebhookService(repository.NewMemoryWebhookRepository(), http.DefaultClient, service.WebhookOptions{
+		Resolver: staticResolver{
+			"internal.example": {netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("10.0.0.5")},
+			"localhost":        {netip.MustParseAddr("127.0.0.1"), netip.MustParseAddr("::1")},
+		},
+	})
+
+	for _, url := range []string{
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://192.168.1.10/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[::ffff:10.0.0.1]/hook",
+		"http://internal.example/hook",
+		"http://unknown.example/hook",
+	} {
+		_, err := svc.CreateWebhook(ctx, model.CreateWebhookRequest{URL: url})
+		assert.ErrorIs(t, err, service.ErrInvalidWebhook, url)
+	}
+}
+
+func TestWebhook_ClientRefusesInternalAddressesAtDial(t *testing.T) {
+	ctx := context.Background()
+	rec := newReceiver(t)
+	repo := repository.NewMemoryWebhookRepository()
+	// The URL passed validation earlier, e.g. before its DNS record changed.
+	require.NoError(t, repo.Create(&model.Webhook{URL: rec.server.URL, Secret: "s", Active: true}))
+
+	svc := service.NewWebhookService(repo, service.NewWebhookClient(time.Second, false), service.WebhookOptions{
+		MaxAttempts:  3,
+		DisableAfter: 5,
+		RetryBase:    time.Millisecond,
+		RetryMax:     10 * time.Millisecond,
+	})
+	require.NoError(t, svc.Enqueue(ctx, todoEvent(eventbus.TodoCreated)))
+	_, err := svc.DeliverDue(ctx)
+	require.NoError(t, err)
+
+	assert.Empty(t, rec.received())
+	deliveries, err := svc.GetDeliveries(ctx, 1, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	assert.Contains(t, deliveries[0].LastError, "not allowed")
+}
@@ -0,0 +1,48 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// TrashPurger periodically removes todos that stayed in the trash longer
+// than the retention period.
+type TrashPurger struct {
+	service   service.TodoServiceInterface
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewTrashPurger(service service.TodoServiceInterface, retention, interval time.Duration) *TrashPurger {
+	return &TrashPurger{service: service, retention: retention, interval: interval}
+}
+
+// Run purges once immediately and then on every tick until ctx is done.
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.purge(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *TrashPurger) purge(ctx context.Context) {
+	purged, err := p.service.PurgeTrash(ctx, p.retention)
+	if err != nil {
+		log.Printf("trash purge failed: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("🗑️ Purged %d todos older than %s from trash", purged, p.retention)
+	}
+}
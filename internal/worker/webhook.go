@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/service"
+)
+
+// WebhookDispatcher sends the queued webhook deliveries. The queue lives in
+// the database, so retries survive restarts and are shared by every
+// instance.
+type WebhookDispatcher struct {
+	service  service.WebhookServiceInterface
+	interval time.Duration
+	wake     chan struct{}
+}
+
+func NewWebhookDispatcher(service service.WebhookServiceInterface, interval time.Duration) *WebhookDispatcher {
+	return &WebhookDispatcher{service: service, interval: interval, wake: make(chan struct{}, 1)}
+}
+
+// Wake makes Run deliver right away instead of at the next tick.
+func (d *WebhookDispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due deliveries on every tick and on Wake until ctx is done.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		d.deliver(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *WebhookDispatcher) deliver(ctx context.Context) {
+	for ctx.Err() == nil {
+		attempted, err := d.service.DeliverDue(ctx)
+		if err != nil {
+			log.Printf("webhook delivery failed: %v", err)
+			return
+		}
+		if attempted == 0 {
+			return
+		}
+	}
+}
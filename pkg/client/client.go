@@ -0,0 +1,256 @@
+// Package client is a typed Go client for the PetGoApi REST API.
+//
+//	c, err := client.New("http://localhost:8080", client.WithActor("alice"))
+//	todo, err := c.CreateTodo(ctx, client.CreateTodoRequest{Title: "Buy milk"})
+//
+// Requests are retried with exponential backoff on 429 and 503, and on
+// other 5xx responses and network errors for idempotent methods. API error
+// responses are returned as *Error.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ActorHeader names the author of changes in the todo history.
+const ActorHeader = "X-Actor"
+
+// RetryPolicy controls retries. MaxAttempts counts the first attempt, so 1
+// disables retries. The delay before retry n is a random duration up to
+// BaseDelay*2^(n-1), capped at MaxDelay, unless the server sent
+// Retry-After.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// DefaultRetryPolicy returns the policy used unless WithRetry is given.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 4, BaseDelay: 200 * time.Millisecond, MaxDelay: 5 * time.Second}
+}
+
+// Client calls the API. It is safe for concurrent use.
+type Client struct {
+	baseURL   *url.URL
+	http      *http.Client
+	actor     string
+	token     string
+	userAgent string
+	retry     RetryPolicy
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sets the HTTP client, e.g. to configure timeouts.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.http = hc }
+}
+
+// WithActor sends actor as the author of every change.
+func WithActor(actor string) Option {
+	return func(c *Client) { c.actor = actor }
+}
+
+// WithToken sends token as a bearer token, for deployments behind an
+// authenticating proxy.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) { c.userAgent = userAgent }
+}
+
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// New returns a client for the API at baseURL, e.g. http://localhost:8080.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("petgoapi: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("petgoapi: base URL %q must be http or https", baseURL)
+	}
+
+	c := &Client{baseURL: u, http: http.DefaultClient, userAgent: "petgoapi-go", retry: DefaultRetryPolicy()}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.retry.MaxAttempts < 1 {
+		c.retry.MaxAttempts = 1
+	}
+	return c, nil
+}
+
+// envelope is the response body of the API.
+type envelope[T any] struct {
+	Message string     `json:"message"`
+	Data    T          `json:"data"`
+	Count   int        `json:"count"`
+	Undo    *UndoToken `json:"undo"`
+}
+
+// rawBody is a request body sent as it is rather than encoded as JSON.
+type rawBody struct {
+	contentType string
+	data        []byte
+}
+
+// call sends a request and decodes the envelope of a successful response.
+func call[T any](ctx context.Context, c *Client, method, path string, query url.Values, body interface{}) (*envelope[T], error) {
+	var payload []byte
+	contentType := "application/json"
+	if raw, ok := body.(rawBody); ok {
+		payload, contentType = raw.data, raw.contentType
+	} else if body != nil {
+		var err error
+		payload, err = json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("petgoapi: encode request: %w", err)
+		}
+	}
+
+	u := *c.baseURL
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	resp, err := c.send(ctx, method, u.String(), "application/json", contentType, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return nil, decodeError(resp)
+	}
+	var out envelope[T]
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("petgoapi: decode %s %s response: %w", method, path, err)
+	}
+	return &out, nil
+}
+
+// send performs the request, retrying as described by the retry policy.
+func (c *Client) send(ctx context.Context, method, url, accept, contentType string, payload []byte) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("petgoapi: %w", err)
+		}
+		req.Header.Set("Accept", accept)
+		req.Header.Set("User-Agent", c.userAgent)
+		if payload != nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if c.actor != "" {
+			req.Header.Set(ActorHeader, c.actor)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+
+		resp, err := c.http.Do(req)
+		retry, wait := c.shouldRetry(method, resp, err)
+		if !retry || attempt >= c.retry.MaxAttempts {
+			if err != nil {
+				return nil, fmt.Errorf("petgoapi: %s %s: %w", method, url, err)
+			}
+			return resp, nil
+		}
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if wait <= 0 {
+			wait = c.backoff(attempt)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("petgoapi: %s %s: %w", method, url, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry decides whether a failed attempt is retried and how long the
+// server asked to wait. A request that never reached a handler (429, 503) is
+// always safe to retry; other failures only for idempotent methods.
+func (c *Client) shouldRetry(method string, resp *http.Response, err error) (bool, time.Duration) {
+	idempotent := method != http.MethodPost
+	if err != nil {
+		return idempotent && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded), 0
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusServiceUnavailable:
+		return true, retryAfter(resp)
+	case resp.StatusCode >= 500:
+		return idempotent, 0
+	}
+	return false, 0
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	limit := c.retry.BaseDelay << (attempt - 1)
+	if limit <= 0 || (c.retry.MaxDelay > 0 && limit > c.retry.MaxDelay) {
+		limit = c.retry.MaxDelay
+	}
+	if limit <= 0 {
+		return 0
+	}
+	return rand.N(limit) + 1
+}
+
+func retryAfter(resp *http.Response) time.Duration {
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+func decodeError(resp *http.Response) error {
+	apiErr := &Error{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp)}
+
+	var body struct {
+		Error  string        `json:"error"`
+		Fields []FieldError  `json:"fields"`
+		Lines  []ImportError `json:"lines"`
+	}
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err := json.Unmarshal(raw, &body); err == nil && body.Error != "" {
+		apiErr.Message = body.Error
+		apiErr.Fields = body.Fields
+		apiErr.Lines = body.Lines
+	} else {
+		apiErr.Message = strings.TrimSpace(string(raw))
+		if apiErr.Message == "" {
+			apiErr.Message = http.StatusText(resp.StatusCode)
+		}
+	}
+	return apiErr
+}
@@ -0,0 +1,325 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stavagg/petGoApi/internal/router/routertest"
+	"github.com/stavagg/petGoApi/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fastRetry = client.WithRetry(client.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond})
+
+func newClient(t *testing.T) *client.Client {
+	t.Helper()
+	srv := routertest.NewServer(t)
+
+	c, err := client.New(srv.URL, client.WithActor("alice"), fastRetry)
+	require.NoError(t, err)
+	return c
+}
+
+func TestClient_TodoLifecycle(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	todo, err := c.CreateTodo(ctx, client.CreateTodoRequest{Title: "Buy milk", Project: "home"})
+	require.NoError(t, err)
+	assert.Equal(t, "Buy milk", todo.Title)
+	assert.Equal(t, "home", todo.Project)
+
+	updated, err := c.UpdateTodo(ctx, todo.ID, client.UpdateTodoRequest{Description: "2 liters"})
+	require.NoError(t, err)
+	assert.Equal(t, "2 liters", updated.Description)
+
+	toggled, undo, err := c.ToggleTodo(ctx, todo.ID)
+	require.NoError(t, err)
+	assert.True(t, toggled.Completed)
+	require.NotNil(t, undo)
+
+	stats, err := c.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Completed)
+
+	history, err := c.History(ctx, todo.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "alice", history[0].Actor)
+
+	reverted, err := c.RevertTodo(ctx, todo.ID, 1)
+	require.NoError(t, err)
+	assert.False(t, reverted.Completed)
+
+	deleteUndo, err := c.DeleteTodo(ctx, todo.ID)
+	require.NoError(t, err)
+	require.NotNil(t, deleteUndo)
+
+	_, err = c.GetTodo(ctx, todo.ID)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+
+	trash, err := c.Trash(ctx)
+	require.NoError(t, err)
+	require.Len(t, trash, 1)
+	assert.NotNil(t, trash[0].DeletedAt)
+
+	restored, err := c.Undo(ctx, deleteUndo.Token)
+	require.NoError(t, err)
+	require.Len(t, restored, 1)
+
+	_, err = c.DeleteTodo(ctx, todo.ID)
+	require.NoError(t, err)
+	require.NoError(t, c.PurgeTodo(ctx, todo.ID))
+	_, err = c.RestoreTodo(ctx, todo.ID)
+	assert.ErrorIs(t, err, client.ErrNotFound)
+}
+
+func TestClient_ValidationErrors(t *testing.T) {
+	c := newClient(t)
+
+	_, err := c.CreateTodo(context.Background(), client.CreateTodoRequest{Title: strings.Repeat("x", 256)})
+
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.ErrorIs(t, err, client.ErrBadRequest)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+	require.NotEmpty(t, apiErr.Fields)
+	assert.Equal(t, "title", apiErr.Fields[0].Field)
+}
+
+func TestClient_Events(t *testing.T) {
+	c := newClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	connected := make(chan struct{})
+	received := make(chan client.ChangeEvent)
+	go func() {
+		defer close(received)
+		for event, err := range c.Events(ctx, 0, client.OnConnect(func() { close(connected) })) {
+			if err != nil {
+				return
+			}
+			received <- event
+		}
+	}()
+
+	select {
+	case <-connected:
+	case <-ctx.Done():
+		t.Fatal("event stream did not connect")
+	}
+
+	// The subscription starts once the request reaches the server, so keep
+	// creating todos until the first event arrives.
+	var created client.ChangeEvent
+	for created.Todo == nil {
+		_, err := c.CreateTodo(ctx, client.CreateTodoRequest{Title: "Buy milk"})
+		require.NoError(t, err)
+		select {
+		case event, ok := <-received:
+			require.True(t, ok, "event stream ended")
+			created = event
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+	assert.Equal(t, client.EventTodoCreated, created.Type)
+	assert.Equal(t, "alice", created.Actor)
+	assert.Equal(t, "Buy milk", created.Todo.Title)
+	assert.NotZero(t, created.ID)
+}
+
+func TestClient_EventsResumeAndReset(t *testing.T) {
+	srv, _ := stubServer(t, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "41", r.URL.Query().Get("last_event_id"))
+		assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("retry: 3000\n\n" +
+			"id: 50\nevent: reset\ndata: {}\n\n" +
+			": heartbeat\n\n" +
+			"id: 51\nevent: todo.deleted\ndata: {\"id\":51,\"type\":\"todo.deleted\",\"todo_id\":7}\n\n"))
+	})
+	c, err := client.New(srv.URL)
+	require.NoError(t, err)
+
+	var events []client.ChangeEvent
+	var streamErr error
+	for event, err := range c.Events(context.Background(), 41) {
+		if err != nil {
+			streamErr = err
+			break
+		}
+		events = append(events, event)
+	}
+
+	require.Len(t, events, 2)
+	assert.Equal(t, client.ChangeEvent{ID: 50, Type: client.EventReset}, events[0])
+	assert.Equal(t, client.EventTodoDeleted, events[1].Type)
+	assert.Equal(t, uint(7), events[1].TodoID)
+	assert.ErrorIs(t, streamErr, io.ErrUnexpectedEOF)
+}
+
+func TestClient_Todos(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	for _, title := range []string{"a", "b", "c"} {
+		_, err := c.CreateTodo(ctx, client.CreateTodoRequest{Title: title})
+		require.NoError(t, err)
+	}
+	_, _, err := c.CompleteAll(ctx)
+	require.NoError(t, err)
+	_, err = c.CreateTodo(ctx, client.CreateTodoRequest{Title: "d"})
+	require.NoError(t, err)
+
+	pending := false
+	var titles []string
+	for todo, err := range c.Todos(ctx, client.ListOptions{Completed: &pending}) {
+		require.NoError(t, err)
+		titles = append(titles, todo.Title)
+	}
+	assert.Equal(t, []string{"d"}, titles)
+
+	all, err := c.ListTodos(ctx, client.ListOptions{})
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+
+	page, err := c.ListTodos(ctx, client.ListOptions{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, "c", page[0].Title, "newest first")
+
+	titles = nil
+	for todo, err := range c.Todos(ctx, client.ListOptions{Limit: 3}) {
+		require.NoError(t, err)
+		titles = append(titles, todo.Title)
+	}
+	assert.Equal(t, []string{"d", "c", "b", "a"}, titles, "pages are fetched until the list ends")
+}
+
+func TestClient_SyncPages(t *testing.T) {
+	c := newClient(t)
+	ctx := context.Background()
+
+	results, err := c.Push(ctx, []client.SyncMutation{
+		{ClientID: "1", Op: client.SyncCreate, Data: client.UpdateTodoRequest{Title: "a"}},
+		{ClientID: "2", Op: client.SyncCreate, Data: client.UpdateTodoRequest{Title: "b"}},
+		{ClientID: "3", Op: client.SyncCreate, Data: client.UpdateTodoRequest{Title: "c"}},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, client.SyncApplied, results[0].Status)
+
+	var pages, created int
+	var token string
+	for page, err := range c.ChangePages(ctx, "", 2) {
+		require.NoError(t, err)
+		pages++
+		created += len(page.Created)
+		token = page.Token
+	}
+	assert.Equal(t, 2, pages)
+	assert.Equal(t, 3, created)
+
+	caughtUp, err := c.Changes(ctx, token, 0)
+	require.NoError(t, err)
+	assert.Empty(t, caughtUp.Created)
+	assert.False(t, caughtUp.HasMore)
+}
+
+func stubServer(t *testing.T, handler func(attempt int32, w http.ResponseWriter, r *http.Request)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler(attempts.Add(1), w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &attempts
+}
+
+func TestClient_RetriesUnavailable(t *testing.T) {
+	srv, attempts := stubServer(t, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		if attempt < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":"ok","data":{"id":7,"title":"x"}}`))
+	})
+	c, err := client.New(srv.URL, fastRetry)
+	require.NoError(t, err)
+
+	todo, err := c.CreateTodo(context.Background(), client.CreateTodoRequest{Title: "x"})
+	require.NoError(t, err)
+	assert.Equal(t, uint(7), todo.ID)
+	assert.Equal(t, int32(3), attempts.Load())
+}
+
+func TestClient_HonoursRetryAfter(t *testing.T) {
+	srv, attempts := stubServer(t, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":"Too many requests"}`))
+	})
+	c, err := client.New(srv.URL, client.WithRetry(client.RetryPolicy{MaxAttempts: 2}))
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = c.Stats(context.Background())
+
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.ErrorIs(t, err, client.ErrRateLimit)
+	assert.Equal(t, "Too many requests", apiErr.Message)
+	assert.Equal(t, time.Second, apiErr.RetryAfter)
+	assert.Equal(t, int32(2), attempts.Load())
+	assert.GreaterOrEqual(t, time.Since(start), time.Second)
+}
+
+func TestClient_DoesNotRetryPostOnServerError(t *testing.T) {
+	srv, attempts := stubServer(t, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	c, err := client.New(srv.URL, fastRetry)
+	require.NoError(t, err)
+
+	_, _, err = c.ToggleTodo(context.Background(), 1)
+	assert.ErrorIs(t, err, client.ErrServer)
+	assert.Equal(t, int32(1), attempts.Load())
+
+	_, err = c.GetTodo(context.Background(), 1)
+	assert.ErrorIs(t, err, client.ErrServer)
+	assert.Equal(t, int32(4), attempts.Load())
+}
+
+func TestClient_StopsRetryingWhenContextIsDone(t *testing.T) {
+	srv, attempts := stubServer(t, func(attempt int32, w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	c, err := client.New(srv.URL)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.ListTodos(ctx, client.ListOptions{})
+
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "got %v", err)
+	assert.Equal(t, int32(1), attempts.Load())
+}
+
+func TestNew_RejectsInvalidBaseURL(t *testing.T) {
+	_, err := client.New("localhost:8080")
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Sentinels matched by errors.Is against an *Error with the corresponding
+// status code.
+var (
+	ErrBadRequest = errors.New("bad request")
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+	ErrRateLimit  = errors.New("rate limited")
+	ErrServer     = errors.New("server error")
+)
+
+// FieldError is a field rejected by request validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	In      string `json:"in"`
+	Message string `json:"message"`
+}
+
+// Error is an error response of the API: {"error": ..., "fields": [...]}.
+// Lines lists the rejected lines of a failed import.
+type Error struct {
+	StatusCode int
+	Message    string
+	Fields     []FieldError
+	Lines      []ImportError
+	// RetryAfter is the delay the server asked for with 429 and 503.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("petgoapi: %d %s", e.StatusCode, e.Message)
+	if len(e.Fields) == 0 && len(e.Lines) == 0 {
+		return msg
+	}
+	fields := make([]string, 0, len(e.Fields)+len(e.Lines))
+	for _, f := range e.Fields {
+		name := f.Field
+		if name == "" {
+			name = f.In
+		}
+		fields = append(fields, name+" "+f.Message)
+	}
+	for _, l := range e.Lines {
+		fields = append(fields, l.String())
+	}
+	return msg + ": " + strings.Join(fields, "; ")
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrRateLimit:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
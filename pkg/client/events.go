@@ -0,0 +1,148 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Change event types. EventReset means events were missed and the todo
+// list has to be reloaded.
+const (
+	EventTodoCreated = "todo.created"
+	EventTodoUpdated = "todo.updated"
+	EventTodoToggled = "todo.toggled"
+	EventTodoDeleted = "todo.deleted"
+	EventReset       = "reset"
+)
+
+// ChangeEvent is a todo change from the event stream. Todo is the state
+// after the change.
+type ChangeEvent struct {
+	ID              uint64    `json:"id"`
+	Type            string    `json:"type"`
+	TodoID          uint      `json:"todo_id"`
+	Actor           string    `json:"actor"`
+	OccurredAt      time.Time `json:"occurred_at"`
+	Todo            *Todo     `json:"todo"`
+	PreviousProject string    `json:"previous_project,omitempty"`
+}
+
+// EventOption configures an event stream.
+type EventOption func(*eventOptions)
+
+type eventOptions struct {
+	onConnect func()
+}
+
+// OnConnect calls fn once the server has accepted the stream, before the
+// first event arrives.
+func OnConnect(fn func()) EventOption {
+	return func(o *eventOptions) { o.onConnect = fn }
+}
+
+// Events streams todo changes published after the event lastEventID; 0
+// starts with the events published from now on. The stream has no end, so
+// iteration stops when ctx is done, the caller breaks out, or the
+// connection fails, in which case the error is yielded. Callers reconnect
+// with the ID of the last event they saw.
+func (c *Client) Events(ctx context.Context, lastEventID uint64, opts ...EventOption) iter.Seq2[ChangeEvent, error] {
+	var options eventOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return func(yield func(ChangeEvent, error) bool) {
+		u := *c.baseURL
+		u.Path += "/api/v1/todos/events"
+		if lastEventID > 0 {
+			u.RawQuery = "last_event_id=" + strconv.FormatUint(lastEventID, 10)
+		}
+
+		resp, err := c.send(ctx, http.MethodGet, u.String(), "text/event-stream", "", nil)
+		if err != nil {
+			yield(ChangeEvent{}, err)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			yield(ChangeEvent{}, decodeError(resp))
+			return
+		}
+		if options.onConnect != nil {
+			options.onConnect()
+		}
+
+		err = readEvents(resp.Body, func(id uint64, event string, data []byte) bool {
+			var change ChangeEvent
+			if event != EventReset {
+				if err := json.Unmarshal(data, &change); err != nil {
+					return yield(ChangeEvent{}, fmt.Errorf("petgoapi: decode %s event: %w", event, err))
+				}
+			}
+			change.ID = id
+			change.Type = event
+			return yield(change, nil)
+		})
+		if err != nil && ctx.Err() == nil {
+			yield(ChangeEvent{}, fmt.Errorf("petgoapi: event stream: %w", err))
+		}
+	}
+}
+
+// readEvents parses a Server-Sent Events stream and calls dispatch for
+// every event until it returns false. A stream that ends is reported as
+// io.ErrUnexpectedEOF, since the server never closes it on its own.
+func readEvents(r io.Reader, dispatch func(id uint64, event string, data []byte) bool) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var id uint64
+	var event string
+	var data []byte
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data != nil {
+				if event == "" {
+					event = "message"
+				}
+				if !dispatch(id, event, data) {
+					return nil
+				}
+			}
+			event, data = "", nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id, _ = strconv.ParseUint(value, 10, 64)
+		case "event":
+			event = value
+		case "data":
+			if data != nil {
+				data = append(data, '\n')
+			}
+			data = append(data, value...)
+			if data == nil {
+				data = []byte{}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
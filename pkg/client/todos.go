@@ -0,0 +1,259 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"iter"
+	"net/url"
+	"strconv"
+)
+
+// NoData decodes envelopes without data.
+type noData = struct{}
+
+func todoPath(id uint, suffix string) string {
+	return "/api/v1/todos/" + strconv.FormatUint(uint64(id), 10) + suffix
+}
+
+func (c *Client) CreateTodo(ctx context.Context, req CreateTodoRequest) (*Todo, error) {
+	resp, err := call[*Todo](ctx, c, "POST", "/api/v1/todos", nil, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// ListTodos returns the todos matching opts.
+func (c *Client) ListTodos(ctx context.Context, opts ListOptions) ([]Todo, error) {
+	query := url.Values{}
+	if opts.Completed != nil {
+		query.Set("completed", strconv.FormatBool(*opts.Completed))
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	if opts.Offset > 0 {
+		query.Set("offset", strconv.Itoa(opts.Offset))
+	}
+	resp, err := call[[]Todo](ctx, c, "GET", "/api/v1/todos", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Todos iterates over the todos matching opts, requesting them a page at a
+// time from opts.Offset on. Todos created or deleted meanwhile may shift
+// the pages, so a todo can be skipped or seen twice. Iteration stops at the
+// first error, which is yielded with a zero Todo.
+func (c *Client) Todos(ctx context.Context, opts ListOptions) iter.Seq2[Todo, error] {
+	if opts.Limit <= 0 {
+		opts.Limit = DefaultPageSize
+	}
+	return func(yield func(Todo, error) bool) {
+		for {
+			todos, err := c.ListTodos(ctx, opts)
+			if err != nil {
+				yield(Todo{}, err)
+				return
+			}
+			for _, todo := range todos {
+				if !yield(todo, nil) {
+					return
+				}
+			}
+			// A short page is the last one.
+			if len(todos) < opts.Limit {
+				return
+			}
+			opts.Offset += len(todos)
+		}
+	}
+}
+
+func (c *Client) GetTodo(ctx context.Context, id uint) (*Todo, error) {
+	resp, err := call[*Todo](ctx, c, "GET", todoPath(id, ""), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// UpdateTodo changes the non-empty fields of req.
+func (c *Client) UpdateTodo(ctx context.Context, id uint, req UpdateTodoRequest) (*Todo, error) {
+	resp, err := call[*Todo](ctx, c, "PUT", todoPath(id, ""), nil, req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// DeleteTodo moves a todo to the trash.
+func (c *Client) DeleteTodo(ctx context.Context, id uint) (*UndoToken, error) {
+	resp, err := call[noData](ctx, c, "DELETE", todoPath(id, ""), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Undo, nil
+}
+
+func (c *Client) ToggleTodo(ctx context.Context, id uint) (*Todo, *UndoToken, error) {
+	resp, err := call[*Todo](ctx, c, "POST", todoPath(id, "/toggle"), nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Data, resp.Undo, nil
+}
+
+// CompleteAll marks every pending todo as completed and returns them.
+func (c *Client) CompleteAll(ctx context.Context) ([]Todo, *UndoToken, error) {
+	resp, err := call[[]Todo](ctx, c, "POST", "/api/v1/todos/complete-all", nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Data, resp.Undo, nil
+}
+
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	resp, err := call[*Stats](ctx, c, "GET", "/api/v1/todos/stats", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// History returns the revisions of a todo, oldest first.
+func (c *Client) History(ctx context.Context, id uint) ([]TodoEvent, error) {
+	resp, err := call[[]TodoEvent](ctx, c, "GET", todoPath(id, "/history"), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// RevertTodo restores the fields a todo had at revision.
+func (c *Client) RevertTodo(ctx context.Context, id, revision uint) (*Todo, error) {
+	query := url.Values{"to": {strconv.FormatUint(uint64(revision), 10)}}
+	resp, err := call[*Todo](ctx, c, "POST", todoPath(id, "/revert"), query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (c *Client) Trash(ctx context.Context) ([]Todo, error) {
+	resp, err := call[[]Todo](ctx, c, "GET", "/api/v1/trash", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+func (c *Client) RestoreTodo(ctx context.Context, id uint) (*Todo, error) {
+	resp, err := call[*Todo](ctx, c, "POST", todoPath(id, "/restore"), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// PurgeTodo deletes a todo in the trash for good.
+func (c *Client) PurgeTodo(ctx context.Context, id uint) error {
+	_, err := call[noData](ctx, c, "DELETE", "/api/v1/trash/"+strconv.FormatUint(uint64(id), 10), nil, nil)
+	return err
+}
+
+// Undo reverts the action that returned token and returns the todos it
+// restored.
+func (c *Client) Undo(ctx context.Context, token string) ([]Todo, error) {
+	resp, err := call[[]Todo](ctx, c, "POST", "/api/v1/undo/"+url.PathEscape(token), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Changes returns one page of changes since the change token; an empty
+// since starts from the beginning. limit 0 uses the server default.
+func (c *Client) Changes(ctx context.Context, since string, limit int) (*SyncChanges, error) {
+	query := url.Values{}
+	if since != "" {
+		query.Set("since", since)
+	}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	resp, err := call[*SyncChanges](ctx, c, "GET", "/api/v1/sync", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Data == nil {
+		return nil, fmt.Errorf("petgoapi: sync response without data")
+	}
+	return resp.Data, nil
+}
+
+// ChangePages iterates over the pages of changes since the change token
+// until the client has caught up. The Token of the last page is the one to
+// resume from later.
+func (c *Client) ChangePages(ctx context.Context, since string, pageSize int) iter.Seq2[*SyncChanges, error] {
+	return func(yield func(*SyncChanges, error) bool) {
+		for {
+			page, err := c.Changes(ctx, since, pageSize)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(page, nil) || !page.HasMore {
+				return
+			}
+			since = page.Token
+		}
+	}
+}
+
+// Push applies offline mutations and returns their results in order.
+func (c *Client) Push(ctx context.Context, mutations []SyncMutation) ([]SyncResult, error) {
+	body := struct {
+		Mutations []SyncMutation `json:"mutations"`
+	}{mutations}
+	resp, err := call[[]SyncResult](ctx, c, "POST", "/api/v1/sync", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// ImportTodos creates todos from a file in one of the import formats of the
+// server: csv, jsonl, todotxt, todoist or trello. With dryRun nothing is
+// created and the report previews the import. A rejected line fails the
+// whole import with an *Error listing the lines.
+func (c *Client) ImportTodos(ctx context.Context, format string, r io.Reader, dryRun bool) (*ImportReport, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("petgoapi: read import file: %w", err)
+	}
+	query := url.Values{"format": {format}}
+	if dryRun {
+		query.Set("dry_run", "true")
+	}
+	resp, err := call[*ImportReport](ctx, c, "POST", "/api/v1/todos/import", query, rawBody{importContentType(format), data})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// importContentType is the media type of files in an import format.
+func importContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv"
+	case "jsonl":
+		return "application/x-ndjson"
+	case "todoist", "trello":
+		return "application/json"
+	}
+	return "text/plain"
+}
@@ -0,0 +1,181 @@
+package client
+
+import (
+	"fmt"
+	"time"
+)
+
+// Todo is a task as returned by the API. DeletedAt is set for todos in the
+// trash.
+type Todo struct {
+	ID          uint                 `json:"id"`
+	Title       string               `json:"title"`
+	Description string               `json:"description"`
+	Project     string               `json:"project"`
+	Completed   bool                 `json:"completed"`
+	DueAt       *time.Time           `json:"due_at"`
+	Version     uint                 `json:"version"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	DeletedAt   *time.Time           `json:"deleted_at"`
+	FieldTimes  map[string]time.Time `json:"field_times,omitempty"`
+	// ExternalID is the ID of a todo imported from another tracker, such
+	// as "todoist:123".
+	ExternalID string `json:"external_id,omitempty"`
+}
+
+type CreateTodoRequest struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description,omitempty"`
+	Project     string     `json:"project,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+}
+
+// UpdateTodoRequest changes the non-empty fields of a todo.
+// ClearDescription and ClearDueAt remove the description and the due date.
+type UpdateTodoRequest struct {
+	Title            string     `json:"title,omitempty"`
+	Description      string     `json:"description,omitempty"`
+	ClearDescription bool       `json:"clear_description,omitempty"`
+	Project          string     `json:"project,omitempty"`
+	Completed        *bool      `json:"completed,omitempty"`
+	DueAt            *time.Time `json:"due_at,omitempty"`
+	ClearDueAt       bool       `json:"clear_due_at,omitempty"`
+}
+
+// ListOptions filter the todo list. A nil Completed lists every todo.
+type ListOptions struct {
+	Completed *bool
+	// Limit and Offset select a page of the list. Without Limit ListTodos
+	// returns the whole list and Todos pages through it in pages of
+	// DefaultPageSize.
+	Limit  int
+	Offset int
+}
+
+// DefaultPageSize is the page size of Todos when ListOptions has no Limit.
+const DefaultPageSize = 100
+
+type Stats struct {
+	Total          int     `json:"total"`
+	Completed      int     `json:"completed"`
+	Pending        int     `json:"pending"`
+	CompletionRate float64 `json:"completion_rate"`
+}
+
+// UndoToken reverts the action that returned it when passed to Undo before
+// it expires.
+type UndoToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// TodoEvent is one revision in the history of a todo.
+type TodoEvent struct {
+	ID        uint          `json:"id"`
+	TodoID    uint          `json:"todo_id"`
+	Revision  uint          `json:"revision"`
+	Action    string        `json:"action"`
+	Actor     string        `json:"actor"`
+	Changes   []FieldChange `json:"changes"`
+	Snapshot  TodoSnapshot  `json:"snapshot"`
+	CreatedAt time.Time     `json:"created_at"`
+}
+
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type TodoSnapshot struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Project     string `json:"project"`
+	Completed   bool   `json:"completed"`
+	Deleted     bool   `json:"deleted"`
+}
+
+// Sync mutation operations.
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+// Sync mutation outcomes.
+const (
+	SyncApplied  = "applied"
+	SyncMerged   = "merged"
+	SyncConflict = "conflict"
+	SyncNotFound = "not_found"
+	SyncRejected = "rejected"
+)
+
+// SyncChanges is one page of changes since a change token.
+type SyncChanges struct {
+	Created []Todo          `json:"created"`
+	Updated []Todo          `json:"updated"`
+	Deleted []SyncTombstone `json:"deleted"`
+	Token   string          `json:"token"`
+	HasMore bool            `json:"has_more"`
+}
+
+type SyncTombstone struct {
+	ID        uint      `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// SyncMutation is an offline change. See the server documentation for how
+// BaseVersion and BaseUpdatedAt detect conflicts.
+type SyncMutation struct {
+	ClientID      string            `json:"client_id,omitempty"`
+	Op            string            `json:"op"`
+	ID            uint              `json:"id,omitempty"`
+	BaseVersion   uint              `json:"base_version,omitempty"`
+	BaseUpdatedAt *time.Time        `json:"base_updated_at,omitempty"`
+	Data          UpdateTodoRequest `json:"data"`
+}
+
+type SyncResult struct {
+	ClientID  string          `json:"client_id,omitempty"`
+	Op        string          `json:"op"`
+	Status    string          `json:"status"`
+	ID        uint            `json:"id,omitempty"`
+	Todo      *Todo           `json:"todo,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	Conflicts []FieldConflict `json:"conflicts,omitempty"`
+}
+
+type FieldConflict struct {
+	Field            string      `json:"field"`
+	Server           interface{} `json:"server"`
+	Client           interface{} `json:"client"`
+	ServerModifiedAt time.Time   `json:"server_modified_at"`
+}
+
+// ImportReport is the outcome of an import. Todos holds the created todos,
+// or the todos a dry run would create. Skipped lists the external IDs that
+// were imported before.
+type ImportReport struct {
+	DryRun  bool          `json:"dry_run"`
+	Total   int           `json:"total"`
+	Todos   []Todo        `json:"todos"`
+	Skipped []string      `json:"skipped"`
+	Errors  []ImportError `json:"errors"`
+}
+
+// ImportError is a rejected line of an import file, or a rejected item of
+// another tracker.
+type ImportError struct {
+	Line       int    `json:"line,omitempty"`
+	ExternalID string `json:"external_id,omitempty"`
+	Error      string `json:"error"`
+}
+
+func (e ImportError) String() string {
+	if e.Line == 0 {
+		return e.ExternalID + ": " + e.Error
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Error)
+}